@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !windows
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!windows
+
+package main
+
+import "errors"
+
+func enableCbreak(fd int) (func(), error) {
+	return nil, errors.New("reading single key presses is not supported on this platform")
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+// enableColor reports whether ANSI escape codes can be written to the
+// terminal. Terminals on platforms other than Windows always support them.
+func enableColor(fd uintptr) bool {
+	return true
+}
@@ -0,0 +1,41 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// enableColor turns on virtual terminal processing for the console so ANSI
+// escape codes are rendered. It reports false when the console does not
+// support it, e.g. on Windows versions before Windows 10.
+func enableColor(fd uintptr) bool {
+	h := windows.Handle(fd)
+
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
+
+// enableCbreak makes the console deliver key presses immediately and stop
+// echoing them. The returned function restores the previous mode.
+func enableCbreak(fd int) (func(), error) {
+	h := windows.Handle(fd)
+
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+
+	raw := mode &^ (windows.ENABLE_LINE_INPUT | windows.ENABLE_ECHO_INPUT)
+	if err := windows.SetConsoleMode(h, raw); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		windows.SetConsoleMode(h, mode)
+	}, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+)
+
+// followControlsSetup returns a setup function that switches the terminal
+// to reading single key presses. The terminal is restored when the returned
+// function is called or the plugin is interrupted.
+func followControlsSetup(fd int) cf.ControlsSetup {
+	return func() (func(), error) {
+		restore, err := enableCbreak(fd)
+		if err != nil {
+			return nil, err
+		}
+
+		sig := make(chan os.Signal, 1)
+		done := make(chan struct{})
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+		go func() {
+			select {
+			case <-sig:
+				restore()
+				os.Exit(130)
+			case <-done:
+			}
+		}()
+
+		var once sync.Once
+		return func() {
+			once.Do(func() {
+				signal.Stop(sig)
+				close(done)
+				restore()
+			})
+		}, nil
+	}
+}
@@ -8,11 +8,15 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"code.cloudfoundry.org/cli/plugin"
 	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -36,27 +40,40 @@ func (c *LogCacheCLI) Run(conn plugin.CliConnection, args []string) {
 	}
 
 	isTerminal := terminal.IsTerminal(int(os.Stdout.Fd()))
+	cf.SetLocale(cf.DetectLocale())
+
+	conf, err := cf.BuildConfig()
+	if err != nil {
+		log.Fatalf("Could not read config: %s", err)
+	}
 
 	commands["tail"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
-		var opts []cf.TailOption
+		opts := []cf.TailOption{cf.WithTailScrubber(conf.Scrubber)}
 		if !isTerminal {
 			opts = append(opts, cf.WithTailNoHeaders())
+		} else {
+			if enableColor(os.Stdout.Fd()) {
+				opts = append(opts, cf.WithTailColor())
+			}
+			if stdin := int(os.Stdin.Fd()); terminal.IsTerminal(stdin) {
+				opts = append(opts, cf.WithTailControls(os.Stdin, followControlsSetup(stdin)))
+			}
 		}
 		cf.Tail(ctx, cli, args, c, log, tableWriter, opts...)
 	}
 
 	commands["log-meta"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
-		var opts []cf.MetaOption
+		opts := []cf.MetaOption{cf.WithMetaNameCache(conf.NameCacheTTL)}
 		if !isTerminal {
 			opts = append(opts, cf.WithMetaNoHeaders())
 		}
 		cf.Meta(
 			ctx,
 			cli,
-			func(sourceID string) []string {
+			func(sourceID string, window time.Duration, limit int) []string {
 				var buf linesWriter
 				end := time.Now()
-				start := end.Add(-time.Minute)
+				start := end.Add(-window)
 
 				args := []string{
 					sourceID,
@@ -65,7 +82,7 @@ func (c *LogCacheCLI) Run(conn plugin.CliConnection, args []string) {
 					"--end-time",
 					strconv.FormatInt(end.UnixNano(), 10),
 					"--json",
-					"--lines", strconv.Itoa(cf.MaximumBatchSize),
+					"--lines", strconv.Itoa(limit),
 				}
 
 				cf.Tail(
@@ -75,6 +92,8 @@ func (c *LogCacheCLI) Run(conn plugin.CliConnection, args []string) {
 					c,
 					log,
 					&buf,
+					cf.WithTailNoRetentionCheck(),
+					cf.WithTailReadLimit(limit),
 				)
 
 				return buf.lines
@@ -87,6 +106,75 @@ func (c *LogCacheCLI) Run(conn plugin.CliConnection, args []string) {
 		)
 	}
 
+	commands["log-chargeback"] = cf.Chargeback
+
+	commands["log-crashes"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		opts := []cf.CrashesOption{cf.WithCrashesScrubber(conf.Scrubber)}
+		if !isTerminal {
+			opts = append(opts, cf.WithCrashesNoHeaders())
+		}
+		cf.Crashes(ctx, cli, args, c, log, tableWriter, opts...)
+	}
+
+	commands["log-http-errors"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		var opts []cf.HTTPErrorsOption
+		if !isTerminal {
+			opts = append(opts, cf.WithHTTPErrorsNoHeaders())
+		}
+		cf.HTTPErrors(ctx, cli, args, c, log, tableWriter, opts...)
+	}
+
+	commands["log-trace"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		opts := []cf.TraceOption{cf.WithTraceScrubber(conf.Scrubber)}
+		if !isTerminal {
+			opts = append(opts, cf.WithTraceNoHeaders())
+		}
+		cf.Trace(ctx, cli, args, c, log, tableWriter, opts...)
+	}
+
+	commands["log-latency"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		var opts []cf.LatencyOption
+		if !isTerminal {
+			opts = append(opts, cf.WithLatencyNoHeaders())
+		}
+		cf.Latency(ctx, cli, args, c, log, tableWriter, opts...)
+	}
+
+	commands["log-deploy-diff"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		opts := []cf.DeployDiffOption{cf.WithDeployDiffScrubber(conf.Scrubber)}
+		if !isTerminal {
+			opts = append(opts, cf.WithDeployDiffNoHeaders())
+		}
+		cf.DeployDiff(ctx, cli, args, c, log, tableWriter, opts...)
+	}
+
+	commands["log-export"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		opts := []cf.ExportOption{
+			cf.WithExportScrubber(conf.Scrubber),
+			cf.WithExportEventWriter(os.Stderr),
+		}
+		if isTerminal {
+			opts = append(opts, cf.WithExportProgress())
+		}
+		ctx, stop := cancelOnInterrupt(ctx)
+		defer stop()
+		cf.Export(ctx, cli, args, c, log, tableWriter, opts...)
+	}
+
+	commands["log-pipeline"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		ctx, stop := cancelOnInterrupt(ctx)
+		defer stop()
+		cf.Pipeline(ctx, cli, args, c, log, tableWriter, cf.WithPipelineScrubber(conf.Scrubber))
+	}
+
+	commands["log-telemetry"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		cf.Telemetry(args, conf, c, log, tableWriter)
+	}
+
+	commands["log-local-cache"] = func(ctx context.Context, cli plugin.CliConnection, args []string, c cf.HTTPClient, log cf.Logger, tableWriter io.Writer) {
+		cf.LocalCache(args, log, tableWriter)
+	}
+
 	skipSSL, err := conn.IsSSLDisabled()
 	if err != nil {
 		log.Fatalf("%s", err)
@@ -99,7 +187,42 @@ func (c *LogCacheCLI) Run(conn plugin.CliConnection, args []string) {
 	if !ok {
 		log.Fatalf("Unknown Log Cache command: %s", args[0])
 	}
-	op(context.Background(), conn, args[1:], http.DefaultClient, log.New(os.Stderr, "", 0), os.Stdout)
+
+	var (
+		client cf.HTTPClient = http.DefaultClient
+		logger cf.Logger     = log.New(os.Stderr, "", 0)
+	)
+	if conf.Telemetry && args[0] != "log-telemetry" {
+		recorder := cf.NewUsageRecorder(conf.TelemetrySpool, args[0])
+		client = recorder.HTTPClient(client)
+		logger = recorder.Logger(logger)
+		defer recorder.Finish()
+	}
+
+	ctx := context.Background()
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			log.Fatalf("Could not create OTLP exporter: %s", err)
+		}
+
+		var tracer *cf.Tracer
+		tracer, ctx = cf.NewTracer(ctx, args[0], sdktrace.WithBatcher(exporter))
+		client = tracer.HTTPClient(client)
+		conn = tracer.CliConnection(conn)
+		logger = tracer.Logger(logger)
+		defer tracer.Finish()
+	}
+
+	args, stats := cf.StatsFlag(args[1:])
+	if stats {
+		requestStats := cf.NewRequestStats(os.Stderr)
+		client = requestStats.HTTPClient(client)
+		logger = requestStats.Logger(logger)
+		defer requestStats.Write()
+	}
+
+	op(ctx, conn, args, client, logger, os.Stdout)
 }
 
 func (c *LogCacheCLI) GetMetadata() plugin.PluginMetadata {
@@ -117,19 +240,56 @@ func (c *LogCacheCLI) GetMetadata() plugin.PluginMetadata {
 				HelpText: "Output logs for a source-id/app",
 				UsageDetails: plugin.Usage{
 					Usage: `tail [options] <source-id/app>
+   tail [options] <source-id/app> <source-id/app>...
+   tail [options] --space
+
+   A source-id containing '*', such as 'router*' or a GUID prefix like
+   '6f2c*', tails every source in Log Cache that matches it.
+
+   Several sources are tailed together, each line prefixed with its
+   source and ordered by time.
+
+   In a terminal, log lines written to stderr or mentioning a warn, error
+   or fatal level are colored.
 
 ENVIRONMENT VARIABLES:
    LOG_CACHE_ADDR       Overrides the default location of log-cache.
-   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.
+   NO_COLOR             Set to any value to disable colors.`,
 					Options: map[string]string{
-						"-end-time":             "End of query range in UNIX nanoseconds.",
-						"-envelope-type, -type": "Envelope type filter. Available filters: 'log', 'counter', 'gauge', 'timer', and 'event'.",
-						"-follow, -f":           "Output appended to stdout as logs are egressed.",
+						"-end-time":             "End of query range as an RFC3339 timestamp or in UNIX nanoseconds.",
+						"-envelope-type, -type": "Envelope type filter. Available filters: 'log', 'counter', 'gauge', 'timer', and 'event'. Repeat the flag or separate types with commas to read several.",
 						"-json":                 "Output envelopes in JSON format.",
 						"-lines, -n":            "Number of envelopes to return. Default is 10.",
-						"-start-time":           "Start of query range in UNIX nanoseconds.",
-						"-counter-name":         "Counter name filter (implies --envelope-type=counter).",
-						"-gauge-name":           "Gauge name filter (implies --envelope-type=gauge).",
+						"-filter":               "Only show logs whose payload matches the regular expression. Other envelopes are not filtered.",
+						"-invert-match":         "Only show logs whose payload doesn't match --filter.",
+						"-instance":             "Only show envelopes of the instance with the given index. Repeat the flag or separate indexes with commas to show several.",
+						"-tag":                  "Only show envelopes with the tag, e.g. 'job=router'. Repeat the flag to require several tags.",
+						"-all":                  "Return every envelope in the query range, read in pages, instead of the last --lines.",
+						"-ndjson":               "Output each envelope as a JSON object per line with timestamp, source ID, instance ID, type, payload and tags.",
+						"-output-template":      "Render each envelope with a Go template. Fields: .Timestamp, .SourceID, .InstanceID, .Tags, .Type, .Payload (as in --ndjson) and .Envelope.",
+						"-output":               "Output mode: 'logfmt' writes each envelope as key=value pairs like 'ts=... source=... instance=... msg=\"...\"', 'syslog' as RFC5424 lines with tags and instance in structured data.",
+						"-start-time":           "Start of query range as an RFC3339 timestamp or in UNIX nanoseconds.",
+						"-since":                "Start of query range relative to now, e.g. '15m' or '2h'.",
+						"-until":                "End of query range relative to now, e.g. '30m'.",
+						"-counter-name":         "Counter name filter (implies --envelope-type=counter). Repeat the flag or separate names with commas to show several.",
+						"-gauge-name":           "Gauge name filter (implies --envelope-type=gauge). Names can be globs like 'memory*'. Repeat the flag or separate names with commas to show several.",
+						"-mark-deploys":         "Inject marker lines for app lifecycle events (deploys, crashes, scaling), fetched every 30s while following.",
+						"-space":                "Output logs for every app in the targeted space, prefixed with the app name.",
+						"-table-fields":         "Comma separated JSON payload fields to render as a table, e.g. 'ts,level,msg'.",
+						"-table-style":          "Table format for --table-fields: 'plain' (default), 'github', 'markdown' or 'tsv'.",
+						"-pretty-json":          "Indent and highlight JSON payloads. Payloads stay on one line when following.",
+						"-follow, -f":           "Output appended to stdout as logs are egressed. Press space to pause, '/' to highlight, 's' for stats.",
+						"-exclude-source":       "Skip sources whose ID or name matches the regular expression with --space or a source pattern.",
+						"-highlight":            "Highlight matches of the regular expression. Without colors, matching lines are prefixed with '** '.",
+						"-alert-on":             "Alert when a line matching the regular expression arrives while following.",
+						"-alert":                "How to alert for --alert-on: 'bell' (default) or 'notify' for a desktop notification.",
+						"-from-archive":         "Read envelopes from a log-export directory instead of Log Cache.",
+						"-raw-units":            "Show gauge values in the reported unit instead of converting bytes to KiB-TiB, nanoseconds to ms and percentages to '%', and timer durations in ns.",
+						"-show-tags":            "Append the tags of each envelope, e.g. deployment, job, index and source_type, as key=value pairs in braces.",
+						"-strict-window":        "Fail instead of warning when the start of --start-time or --since has already been evicted from Log Cache.",
+						"-accessible":           "Screen reader friendly output: no colors or reverse video, highlighted lines are prefixed with '**'.",
+						"-no-color":             "Don't color the output. Setting the NO_COLOR environment variable does the same.",
 					},
 				},
 			},
@@ -137,16 +297,240 @@ ENVIRONMENT VARIABLES:
 				Name:     "log-meta",
 				HelpText: "Show all available meta information",
 				UsageDetails: plugin.Usage{
-					Usage: `log-meta [options]
+					Usage: `log-meta [options] [<source-id/app>]
+
+ENVIRONMENT VARIABLES:
+   LOG_CACHE_ADDR       Overrides the default location of log-cache.
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+					Options: map[string]string{
+						"-source-type":                 "Source type of information to show. Available: 'all', 'application', and 'platform'.",
+						"-stream":                      "Write the rows batch by batch as their names and rates are known instead of waiting for every source. Rows are only sorted within their batch.",
+						"-sort-by":                     "Sort by specified column. Available: 'source-id', 'source' (or 'app-name'), 'source-type', 'count', 'expired', 'cache-duration', 'rate', and 'expired-ratio'. Ties are sorted by source ID.",
+						"-columns":                     "Comma separated columns of the table or CSV: 'source-id', 'source' (or 'app-name'), 'source-type', 'count', 'expired', 'expired-ratio', 'cache-duration', 'rate', 'org', 'space', 'newest' and 'oldest'.",
+						"-desc":                        "Sort in descending order.",
+						"-envelope-breakdown":          "Show how many logs, counters, gauges, timers and events are among the newest 100 envelopes of every source.",
+						"-diff":                        "Compare count and expired of every source with the snapshot in the given file. Saves the snapshot when the file does not exist.",
+						"-limit":                       "Show only the first N sources after sorting, followed by the number of sources and their totals. Default is 0, which shows all sources.",
+						"-min-count":                   "Hide sources with fewer envelopes than the given count.",
+						"-min-rate":                    "Hide sources with a lower rate than the given envelopes per minute. Requires --noise.",
+						"-no-headers":                  "Leave out the \"Retrieving log cache metadata\" line and the header row, e.g. to pipe the table into awk or cut.",
+						"-noise":                       "Fetch and display the rate of envelopes per minute for the last minute. WARNING: This is slow...",
+						"-noise-concurrency":           "Number of sources tailed at the same time for --noise. Default is 10.",
+						"-noise-interval":              "Window the --noise rate is measured over, e.g. '30s' or '5m'. Rates are per minute; sources that reach the read limit of Log Cache, usually 1000 envelopes, in the window show a lower bound, e.g. >999 over 1m or >199 over 5m. Default is 1m.",
+						"-noise-mode":                  "How --noise is measured: 'tail' (default) reads the envelopes of every source, 'meta' compares two meta snapshots --noise-interval apart, which is much lighter on Log Cache.",
+						"-guid":                        "Display raw source GUIDs",
+						"-include-job":                 "Add the BOSH deployment, job and index of every platform source as columns, read from its newest envelope.",
+						"-include-placement":           "Add the org and space of every application as columns.",
+						"-exclude-source":              "Hide sources whose ID or name matches the regular expression.",
+						"-expired-ratio":               "Add the share of expired envelopes, Expired / (Count + Expired), as a column to spot sources churning through the cache.",
+						"-fail-if-expired-ratio-above": "Exit with an error after the output when the expired ratio of any source is above the given percentage.",
+						"-fail-if-rate-above":          "Exit with an error after the output when the rate of any source is above the given envelopes per minute. Requires --noise, and with --noise-mode tail a value below the highest rate measured, e.g. 1000 over 1m with the usual read limit.",
+						"-source-id-filter":            "Show only sources whose ID or name matches the regular expression.",
+						"-group-by":                    "Sum application sources per 'org' or 'space'.",
+						"-scope":                       "Show only the apps in the targeted 'org' or 'space'.",
+						"-table-style":                 "Table format: 'plain' (default), 'github', 'markdown' or 'tsv'.",
+						"-timestamps":                  "Add the oldest and newest envelope timestamp of every source as RFC3339 columns.",
+						"-totals":                      "Append the total count, expired and rate of the table, with a subtotal per source type.",
+						"-top":                         "Show only the N sources with the highest rate. Requires --noise.",
+						"-watch":                       "Clear the screen and redraw the table every interval, e.g. '10s', until interrupted.",
+						"-record":                      "Append meta snapshots to the given file every --interval for --duration.",
+						"-refresh-names":               "Look up every app and service name in CAPI instead of reading it from the name cache set up with name_cache_ttl.",
+						"-interval":                    "Time between snapshots with --record. Default is 1m.",
+						"-duration":                    "How long to record snapshots with --record. Default is 1h.",
+						"-report":                      "Tabulate the growth of every source in a file written with --record.",
+						"-copy":                        "Copy the source IDs of the displayed sources to the clipboard, one per line.",
+						"-accessible":                  "Screen reader friendly output: --report describes trends in words instead of sparklines.",
+						"-json":                        "Output the sources as a JSON document with source ID, name, type, count, expired, cache duration and, with --noise, rate.",
+						"-output":                      "Output format: 'table' (default), 'csv' with the columns of the table, 'json' (same as --json), 'yaml' with a document per source including the raw newest and oldest timestamps, or 'prometheus' metrics for the textfile collector of the node exporter.",
+						"-output-template":             "Go template applied per source, e.g. '{{.AppName}} {{.Count}}'. Fields: .SourceID, .AppName, .SourceType, .Count, .Expired, .CacheDuration, .Rate, .Org and .Space.",
+						"-precise-durations":           "Show the cache duration without truncating it to seconds, and --timestamps and the newest and oldest columns as raw nanoseconds.",
+					},
+				},
+			},
+			{
+				Name:     "log-chargeback",
+				HelpText: "Report envelope counts and bytes per org or space as CSV",
+				UsageDetails: plugin.Usage{
+					Usage: `log-chargeback [options]
 
 ENVIRONMENT VARIABLES:
    LOG_CACHE_ADDR       Overrides the default location of log-cache.
    LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
 					Options: map[string]string{
-						"-source-type": "Source type of information to show. Available: 'all', 'application', and 'platform'.",
-						"-sort-by":     "Sort by specified column. Available: 'source-id', 'source', 'source-type', 'count', 'expired', 'cache-duration', and 'rate'.",
-						"-noise":       "Fetch and display the rate of envelopes per minute for the last minute. WARNING: This is slow...",
-						"-guid":        "Display raw source GUIDs",
+						"-window":   "Duration of the reporting window ending now. Default is 24h.",
+						"-group-by": "Aggregate usage by 'org' or 'space'. Default is 'org'.",
+					},
+				},
+			},
+			{
+				Name:     "log-crashes",
+				HelpText: "Summarize why an app's instances crashed",
+				UsageDetails: plugin.Usage{
+					Usage: `log-crashes [options] <app>
+
+ENVIRONMENT VARIABLES:
+   LOG_CACHE_ADDR       Overrides the default location of log-cache.
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+					Options: map[string]string{
+						"-last":        "Duration to search for crashes, ending now. Default is 24h.",
+						"-table-style": "Table format: 'plain' (default), 'github', 'markdown' or 'tsv'.",
+					},
+				},
+			},
+			{
+				Name:     "log-http-errors",
+				HelpText: "Summarize HTTP status classes per route of an app",
+				UsageDetails: plugin.Usage{
+					Usage: `log-http-errors [options] <app>
+
+ENVIRONMENT VARIABLES:
+   LOG_CACHE_ADDR       Overrides the default location of log-cache.
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+					Options: map[string]string{
+						"-last":        "Duration to search for requests, ending now. Default is 1h.",
+						"-threshold":   "5xx rate in percent at which a route is flagged as elevated. Default is 5.",
+						"-table-style": "Table format: 'plain' (default), 'github', 'markdown' or 'tsv'.",
+					},
+				},
+			},
+			{
+				Name:     "log-trace",
+				HelpText: "Show the envelopes that mention a request ID in time order",
+				UsageDetails: plugin.Usage{
+					Usage: `log-trace [options] --app <app> <request-id>
+
+ENVIRONMENT VARIABLES:
+   LOG_CACHE_ADDR       Overrides the default location of log-cache.
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+					Options: map[string]string{
+						"-app":    "Name of the app to search. Required.",
+						"-router": "Also search the gorouter source.",
+						"-last":   "Duration to search, ending now. Default is 1h.",
+					},
+				},
+			},
+			{
+				Name:     "log-latency",
+				HelpText: "Show request throughput and latency percentiles of an app",
+				UsageDetails: plugin.Usage{
+					Usage: `log-latency [options] <app>
+
+ENVIRONMENT VARIABLES:
+   LOG_CACHE_ADDR       Overrides the default location of log-cache.
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+					Options: map[string]string{
+						"-last":         "Duration to search for requests, ending now. Default is 1h.",
+						"-group-by":     "Set to 'instance' to compare app instances side by side.",
+						"-table-style":  "Table format: 'plain' (default), 'github', 'markdown' or 'tsv'.",
+						"-from-archive": "Read timers from a log-export directory. --last ends at the newest exported envelope.",
+					},
+				},
+			},
+			{
+				Name:     "log-deploy-diff",
+				HelpText: "Compare an app's log patterns and error rate before and after a deploy",
+				UsageDetails: plugin.Usage{
+					Usage: `log-deploy-diff [options] --deploy-time <timestamp> <app>
+
+ENVIRONMENT VARIABLES:
+   LOG_CACHE_ADDR       Overrides the default location of log-cache.
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+					Options: map[string]string{
+						"-deploy-time": "Time of the deploy as RFC3339 or UNIX nanoseconds. Required.",
+						"-window":      "Duration of the windows before and after the deploy. Default is 30m.",
+						"-spike":       "Factor by which a pattern must become more frequent to be reported. Default is 2.",
+						"-table-style": "Table format: 'plain' (default), 'github', 'markdown' or 'tsv'.",
+					},
+				},
+			},
+			{
+				Name:     "log-export",
+				HelpText: "Export envelopes of source-ids/apps to gzipped NDJSON files",
+				UsageDetails: plugin.Usage{
+					Usage: `log-export [options] --dir <dir> <source-id/app>...
+   log-export [options] --dir <dir> --scope applications
+   log-export [options] --dir <dir> --resume
+
+   Source-ids containing '*' export every matching source in Log Cache.
+   Exporting to a directory with a prior export only fetches envelopes newer
+   than the last exported one and appends them as new files.
+   Progress is checkpointed so an interrupted export can be continued with
+   --resume.
+
+   --filename-template is a Go template with the fields AppName, SourceID,
+   Date, Hour, ChunkStart and ChunkEnd. Times are UTC and the default is
+   '{{.SourceID}}/{{.ChunkStart}}.ndjson.gz'.
+
+   Failed reads are retried up to 3 times before the export fails.
+   --progress json writes one JSON event per line to stderr with the fields
+   event (start, progress, retry, source_done, heartbeat, done or
+   interrupted), time, source, percent, attempt and error of retries,
+   envelopes, sources_completed and sources. log-export is the only command
+   that reports progress.
+
+ENVIRONMENT VARIABLES:
+   LOG_CACHE_ADDR       Overrides the default location of log-cache.
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+					Options: map[string]string{
+						"-dir":               "Directory to write the export and its manifest to. Required.",
+						"-last":              "Duration to export, ending now. Default is 1h.",
+						"-chunk-size":        "Number of envelopes per file. Default is 10000.",
+						"-scope":             "Set to 'applications' to export every app in the targeted space.",
+						"-workers":           "Number of sources to export in parallel. Default is 4.",
+						"-resume":            "Continue an interrupted export in --dir up to its original end time.",
+						"-dry-run":           "Estimate the envelopes, size and duration of the export without writing files.",
+						"-filename-template": "Template for chunk file paths relative to --dir, e.g. '{{.AppName}}/{{.Date}}/{{.ChunkStart}}.ndjson.gz'.",
+						"-table-style":       "Table format for --dry-run: 'plain' (default), 'github', 'markdown' or 'tsv'.",
+						"-strict-window":     "Fail instead of warning when the start of --last has already been evicted from Log Cache.",
+						"-progress":          "Progress output: 'bar' (default on a terminal), 'json' for one export event per line on stderr, or 'none'.",
+						"-accessible":        "Screen reader friendly output: write a progress line per 10% instead of redrawing bars.",
+					},
+				},
+			},
+			{
+				Name:     "log-pipeline",
+				HelpText: "Run the query, threshold, export and notify steps of a pipeline file",
+				UsageDetails: plugin.Usage{
+					Usage: `log-pipeline <pipeline.yml>
+
+   A pipeline runs its steps in order against one source-id/app:
+
+   source: my-app  # app, source-id or a pattern matching one source
+   steps:
+   - query:       # read logs matching a regular expression
+       last: 15m
+       match: 'ERROR|panic'
+   - threshold:   # stop unless the query matched at least min logs
+       min: 10
+   - export:      # export the window from the first to the last match
+       dir: ./incident
+   - notify:      # show a desktop notification
+       message: '{{.Matches}} errors in {{.Source}}'
+
+ENVIRONMENT VARIABLES:
+   LOG_CACHE_ADDR       Overrides the default location of log-cache.
+   LOG_CACHE_SKIP_AUTH  Set to 'true' to disable CF authentication.`,
+				},
+			},
+			{
+				Name:     "log-telemetry",
+				HelpText: "Show or flush spooled usage records",
+				UsageDetails: plugin.Usage{
+					Usage: `log-telemetry [options]`,
+					Options: map[string]string{
+						"-flush": "Send spooled usage records to the configured endpoint and clear the spool.",
+					},
+				},
+			},
+			{
+				Name:     "log-local-cache",
+				HelpText: "Show or clear state the plugin caches between invocations",
+				UsageDetails: plugin.Usage{
+					Usage: `log-local-cache [options]`,
+					Options: map[string]string{
+						"-show":     "Show the size and age of each cache. This is the default.",
+						"-clear":    "Remove the cached state so it is rebuilt on the next command.",
+						"-category": "Comma separated cache categories to show or clear. Available: 'token', 'names'.",
 					},
 				},
 			},
@@ -158,6 +542,28 @@ func main() {
 	plugin.Start(&LogCacheCLI{})
 }
 
+// cancelOnInterrupt returns a context that is cancelled when the plugin is
+// interrupted so long running commands can stop cleanly.
+func cancelOnInterrupt(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sig)
+		cancel()
+	}
+}
+
 type linesWriter struct {
 	lines []string
 }
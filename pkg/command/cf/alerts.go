@@ -0,0 +1,169 @@
+package cf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	alertBell   = "bell"
+	alertNotify = "notify"
+
+	// alertInterval limits how often an alert is raised so a burst of
+	// matching lines does not ring the bell or notify for every line.
+	alertInterval = time.Second
+
+	maxNotificationLength = 200
+)
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Notifier shows a desktop notification.
+type Notifier func(title, message string) error
+
+// WithTailNotifier replaces the notifier used by --alert notify.
+func WithTailNotifier(n Notifier) TailOption {
+	return func(o *options) {
+		o.notifier = n
+	}
+}
+
+// alertOutput raises an alert when a line matching the alert pattern is
+// written. Lines written before it is armed, such as the initial lines
+// printed before following, do not raise alerts. It sits below the
+// interactive controls, so lines are matched without their highlight and
+// only once they are shown.
+type alertOutput struct {
+	lineOutput
+	pattern *regexp.Regexp
+	alert   func(line string)
+
+	mu    sync.Mutex
+	armed bool
+	last  time.Time
+}
+
+func newAlertOutput(out lineOutput, o options, w io.Writer, log Logger) *alertOutput {
+	a := &alertOutput{
+		lineOutput: out,
+		pattern:    o.alertPattern,
+	}
+
+	if o.alert == alertBell {
+		a.alert = func(string) {
+			fmt.Fprint(w, "\a")
+		}
+		return a
+	}
+
+	notify := o.notifier
+	if notify == nil {
+		notify = desktopNotify
+	}
+
+	title := "cf tail " + o.providedName
+	if o.space {
+		title = "cf tail --space"
+	}
+
+	var failed bool
+	a.alert = func(msg string) {
+		if r := []rune(msg); len(r) > maxNotificationLength {
+			msg = string(r[:maxNotificationLength]) + "..."
+		}
+
+		if err := notify(title, msg); err != nil && !failed {
+			failed = true
+			log.Printf("Could not show desktop notification: %s", err)
+		}
+	}
+
+	return a
+}
+
+func (a *alertOutput) Write(line string) error {
+	err := a.lineOutput.Write(line)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.armed || time.Since(a.last) < alertInterval {
+		return err
+	}
+
+	plain := strings.TrimPrefix(ansiEscape.ReplaceAllString(line, ""), highlightMark)
+	if !a.pattern.MatchString(plain) {
+		return err
+	}
+
+	a.last = time.Now()
+	a.alert(plain)
+
+	return err
+}
+
+// armAlerts starts raising alerts for lines written to out, if it has
+// alerts configured.
+func armAlerts(out lineOutput) {
+	for {
+		switch o := out.(type) {
+		case *alertOutput:
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			o.armed = true
+			return
+		case *highlightOutput:
+			out = o.lineOutput
+		case *followControls:
+			out = o.w
+		default:
+			return
+		}
+	}
+}
+
+// desktopNotify shows a desktop notification with the tools available on
+// the platform. The title and message are passed through the environment
+// so they need no quoting.
+func desktopNotify(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command(
+			"osascript", "-e",
+			`display notification (system attribute "LOG_CACHE_ALERT_MESSAGE") with title (system attribute "LOG_CACHE_ALERT_TITLE")`,
+		)
+	case "windows":
+		cmd = exec.Command(
+			"powershell", "-NoProfile", "-NonInteractive", "-Command",
+			`Add-Type -AssemblyName System.Windows.Forms; `+
+				`$n = New-Object System.Windows.Forms.NotifyIcon; `+
+				`$n.Icon = [System.Drawing.SystemIcons]::Information; `+
+				`$n.Visible = $true; `+
+				`$n.ShowBalloonTip(5000, $env:LOG_CACHE_ALERT_TITLE, $env:LOG_CACHE_ALERT_MESSAGE, 'Info'); `+
+				`Start-Sleep -Seconds 5; $n.Dispose()`,
+		)
+	default:
+		cmd = exec.Command("notify-send", title, message)
+	}
+
+	cmd.Env = append(
+		os.Environ(),
+		"LOG_CACHE_ALERT_TITLE="+title,
+		"LOG_CACHE_ALERT_MESSAGE="+message,
+	)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+
+	return nil
+}
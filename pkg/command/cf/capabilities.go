@@ -0,0 +1,140 @@
+package cf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// logCacheFeature is a Log Cache API the plugin relies on and the first
+// Log Cache release that serves it.
+type logCacheFeature struct {
+	name       string
+	minVersion string
+}
+
+var featureMeta = logCacheFeature{name: "meta information", minVersion: "1.1.0"}
+
+// defaultReadLimit is the most envelopes a single read returns from Log
+// Cache servers that don't report their limit.
+const defaultReadLimit = 1000
+
+// logCacheCapabilities describes the Log Cache server as reported by its
+// info endpoint. The version is empty when the server does not report one,
+// in which case every feature is assumed to be available.
+type logCacheCapabilities struct {
+	Version      string `json:"version"`
+	MaxReadLimit int    `json:"max_read_limit"`
+}
+
+// readLimit returns the most envelopes a single read returns.
+func (c logCacheCapabilities) readLimit() int {
+	if c.MaxReadLimit > 0 {
+		return c.MaxReadLimit
+	}
+
+	return defaultReadLimit
+}
+
+// require returns an error naming the required release when the server is
+// older than the first release serving the feature.
+func (c logCacheCapabilities) require(f logCacheFeature) error {
+	version, ok := parseVersion(c.Version)
+	if !ok {
+		return nil
+	}
+
+	min, _ := parseVersion(f.minVersion)
+	if compareVersions(version, min) >= 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Log Cache %s is too old for %s. Log Cache %s or later is required.", c.Version, f.name, f.minVersion)
+}
+
+// getCapabilities reads the info endpoint of the Log Cache server. Servers
+// that predate the info endpoint are reported without a version.
+func getCapabilities(ctx context.Context, c HTTPClient, logCacheAddr string) (logCacheCapabilities, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(logCacheAddr, "/")+"/api/v1/info", nil)
+	if err != nil {
+		return logCacheCapabilities{}, err
+	}
+
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return logCacheCapabilities{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return logCacheCapabilities{}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return logCacheCapabilities{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var caps logCacheCapabilities
+	if err := json.NewDecoder(resp.Body).Decode(&caps); err != nil {
+		return logCacheCapabilities{}, err
+	}
+
+	return caps, nil
+}
+
+// requireFeature fails when the Log Cache server is too old for the
+// feature and returns the server's capabilities otherwise. Failing to read
+// the server's capabilities does not fail the command; the request for the
+// feature reports the actual error instead, and the defaults are returned.
+func requireFeature(ctx context.Context, c HTTPClient, logCacheAddr string, f logCacheFeature, log Logger) logCacheCapabilities {
+	caps, err := getCapabilities(ctx, c, logCacheAddr)
+	if err != nil {
+		return logCacheCapabilities{}
+	}
+
+	if err := caps.require(f); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	return caps
+}
+
+// parseVersion parses the major, minor and patch numbers of a version such
+// as "2.1.0" or "v2.1.0-rc.1". Missing numbers are 0.
+func parseVersion(v string) ([3]int, bool) {
+	var version [3]int
+
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return version, false
+	}
+
+	for i, part := range strings.SplitN(v, ".", 3) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, false
+		}
+		version[i] = n
+	}
+
+	return version, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
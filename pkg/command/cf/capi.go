@@ -0,0 +1,204 @@
+package cf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/cli/plugin"
+)
+
+type relationship struct {
+	Data struct {
+		GUID string `json:"guid"`
+	} `json:"data"`
+}
+
+type v3Resource struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Relationships struct {
+		Space        relationship `json:"space"`
+		Organization relationship `json:"organization"`
+		App          relationship `json:"app"`
+	} `json:"relationships"`
+}
+
+type v3Response struct {
+	Resources []v3Resource `json:"resources"`
+}
+
+// getV3Resources looks up the given GUIDs against a CAPI v3 endpoint such
+// as /v3/apps or /v3/spaces.
+func getV3Resources(guids []string, endpoint string, cli plugin.CliConnection) ([]v3Resource, error) {
+	responses, err := getSourceInfoFromCAPI(guids, endpoint, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []v3Resource
+	for _, rb := range responses {
+		var r v3Response
+		err := json.NewDecoder(strings.NewReader(rb)).Decode(&r)
+		if err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, r.Resources...)
+	}
+
+	return resources, nil
+}
+
+// getSpaceApps returns the apps in the space with the given GUID.
+func getSpaceApps(spaceGUID string, cli plugin.CliConnection) ([]v3Resource, error) {
+	return listApps("space_guids="+spaceGUID, cli)
+}
+
+// getOrgApps returns the apps in the org with the given GUID.
+func getOrgApps(orgGUID string, cli plugin.CliConnection) ([]v3Resource, error) {
+	return listApps("organization_guids="+orgGUID, cli)
+}
+
+// listApps returns the apps matching the given /v3/apps filter, ordered by
+// name.
+func listApps(filter string, cli plugin.CliConnection) ([]v3Resource, error) {
+	lines, err := cli.CliCommandWithoutTerminalOutput(
+		"curl",
+		"/v3/apps?"+filter+"&order_by=name&per_page=5000",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var r v3Response
+	err = json.NewDecoder(strings.NewReader(strings.Join(lines, ""))).Decode(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Resources, nil
+}
+
+// getServiceInstanceGUID returns the GUID of the service instance with the
+// given name in the space, or an empty string if there is none.
+func getServiceInstanceGUID(name, spaceGUID string, cli plugin.CliConnection) (string, error) {
+	lines, err := cli.CliCommandWithoutTerminalOutput(
+		"curl",
+		"/v3/service_instances?names="+url.QueryEscape(name)+"&space_guids="+spaceGUID,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var r v3Response
+	err = json.NewDecoder(strings.NewReader(strings.Join(lines, ""))).Decode(&r)
+	if err != nil {
+		return "", err
+	}
+
+	if len(r.Resources) == 0 {
+		return "", nil
+	}
+
+	return r.Resources[0].GUID, nil
+}
+
+// getTaskSources looks up the given GUIDs as tasks. Tasks are named after
+// the task and the app it runs for. GUIDs that are not tasks are omitted.
+func getTaskSources(guids []string, cli plugin.CliConnection) ([]source, error) {
+	tasks, err := getV3Resources(guids, "/v3/tasks", cli)
+	if err != nil {
+		return nil, err
+	}
+
+	appGUIDs := make(map[string]bool)
+	for _, task := range tasks {
+		appGUIDs[task.Relationships.App.Data.GUID] = true
+	}
+
+	apps, err := getV3Resources(keys(appGUIDs), "/v3/apps", cli)
+	if err != nil {
+		return nil, err
+	}
+
+	appNames := make(map[string]string)
+	for _, app := range apps {
+		appNames[app.GUID] = app.Name
+	}
+
+	var sources []source
+	for _, task := range tasks {
+		sources = append(sources, source{
+			GUID: task.GUID,
+			Name: fmt.Sprintf("%s (%s)", task.Name, appNames[task.Relationships.App.Data.GUID]),
+			Type: sourceTypeTask,
+		})
+	}
+
+	return sources, nil
+}
+
+type placement struct {
+	org   string
+	space string
+}
+
+// getPlacements resolves the org and space names for the given app GUIDs.
+// Apps that CAPI does not know about are omitted.
+func getPlacements(appGUIDs []string, cli plugin.CliConnection) (map[string]placement, error) {
+	apps, err := getV3Resources(appGUIDs, "/v3/apps", cli)
+	if err != nil {
+		return nil, err
+	}
+
+	spaceGUIDs := make(map[string]bool)
+	for _, app := range apps {
+		spaceGUIDs[app.Relationships.Space.Data.GUID] = true
+	}
+
+	spaces, err := getV3Resources(keys(spaceGUIDs), "/v3/spaces", cli)
+	if err != nil {
+		return nil, err
+	}
+
+	orgGUIDs := make(map[string]bool)
+	spacesByGUID := make(map[string]v3Resource)
+	for _, space := range spaces {
+		spacesByGUID[space.GUID] = space
+		orgGUIDs[space.Relationships.Organization.Data.GUID] = true
+	}
+
+	orgs, err := getV3Resources(keys(orgGUIDs), "/v3/organizations", cli)
+	if err != nil {
+		return nil, err
+	}
+
+	orgNames := make(map[string]string)
+	for _, org := range orgs {
+		orgNames[org.GUID] = org.Name
+	}
+
+	placements := make(map[string]placement)
+	for _, app := range apps {
+		space := spacesByGUID[app.Relationships.Space.Data.GUID]
+		placements[app.GUID] = placement{
+			org:   orgNames[space.Relationships.Organization.Data.GUID],
+			space: space.Name,
+		}
+	}
+
+	return placements, nil
+}
+
+func keys(m map[string]bool) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+
+	return ks
+}
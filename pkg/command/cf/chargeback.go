@@ -0,0 +1,166 @@
+package cf
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	groupByOrg   = "org"
+	groupBySpace = "space"
+)
+
+type chargebackOptionFlags struct {
+	Window  time.Duration `long:"window" default:"24h"`
+	GroupBy string        `long:"group-by" default:"org"`
+}
+
+type usage struct {
+	org       string
+	space     string
+	envelopes int64
+	bytes     int64
+}
+
+// Chargeback reads every app's envelopes over the requested window and
+// writes a CSV report of envelope counts and bytes grouped by org or space.
+func Chargeback(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	args []string,
+	c HTTPClient,
+	log Logger,
+	w io.Writer,
+) {
+	opts := chargebackOptionFlags{}
+
+	args, err := parseFlags("log-chargeback", &opts, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) > 0 {
+		log.Fatalf("Invalid arguments, expected 0, got %d.", len(args))
+	}
+
+	groupBy := strings.ToLower(opts.GroupBy)
+	if groupBy != groupByOrg && groupBy != groupBySpace {
+		log.Fatalf("Group by must be 'org' or 'space'.")
+	}
+
+	if opts.Window <= 0 {
+		log.Fatalf("Window must be greater than 0.")
+	}
+
+	logCacheEndpoint, err := logCacheEndpoint(cli)
+	if err != nil {
+		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+	}
+
+	c = authenticatedClient(cli, c, log)
+	requireFeature(ctx, c, logCacheEndpoint, featureMeta, log)
+
+	client := logcache.NewClient(
+		logCacheEndpoint,
+		logcache.WithHTTPClient(c),
+	)
+
+	meta, err := client.Meta(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read Meta information: %s", err)
+	}
+
+	var sourceIDs []string
+	for sourceID := range meta {
+		if appOrServiceRegex.MatchString(sourceID) {
+			sourceIDs = append(sourceIDs, sourceID)
+		}
+	}
+	sort.Strings(sourceIDs)
+
+	placements, err := getPlacements(sourceIDs, cli)
+	if err != nil {
+		log.Fatalf("Failed to read application information: %s", err)
+	}
+
+	end := time.Now()
+	start := end.Add(-opts.Window)
+	usages := make(map[placement]*usage)
+
+	for _, sourceID := range sourceIDs {
+		p, ok := placements[sourceID]
+		if !ok {
+			continue
+		}
+		if groupBy == groupByOrg {
+			p.space = ""
+		}
+
+		u, ok := usages[p]
+		if !ok {
+			u = &usage{org: p.org, space: p.space}
+			usages[p] = u
+		}
+
+		logcache.Walk(
+			ctx,
+			sourceID,
+			logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+				for _, e := range envelopes {
+					u.envelopes++
+					u.bytes += int64(proto.Size(e))
+				}
+				return true
+			}),
+			client.Read,
+			logcache.WithWalkStartTime(start),
+			logcache.WithWalkEndTime(end),
+			logcache.WithWalkBackoff(newBackoff(log)),
+		)
+	}
+
+	var rows []*usage
+	for _, u := range usages {
+		rows = append(rows, u)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].org != rows[j].org {
+			return rows[i].org < rows[j].org
+		}
+		return rows[i].space < rows[j].space
+	})
+
+	cw := csv.NewWriter(w)
+	header := []string{"org", "envelopes", "bytes"}
+	if groupBy == groupBySpace {
+		header = []string{"org", "space", "envelopes", "bytes"}
+	}
+	cw.Write(header)
+
+	for _, u := range rows {
+		record := []string{u.org}
+		if groupBy == groupBySpace {
+			record = append(record, u.space)
+		}
+		record = append(record,
+			strconv.FormatInt(u.envelopes, 10),
+			strconv.FormatInt(u.bytes, 10),
+		)
+		cw.Write(record)
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
@@ -0,0 +1,187 @@
+package cf_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chargeback", func() {
+	const (
+		appA = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+		appB = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+		appC = "cccccccc-cccc-cccc-cccc-cccccccccccc"
+	)
+
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		startTime  time.Time
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+
+		httpClient.responseBody = []string{
+			metaResponseInfo(appA, appB, appC, "doppler"),
+			logResponseBody(startTime, "12345", "67890"),
+			emptyResponseBody(),
+			logResponseBody(startTime, "12345"),
+			emptyResponseBody(),
+			logResponseBody(startTime, "1234567890"),
+			emptyResponseBody(),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{capiV3AppsResponse(map[string]string{
+				appA: "space-1",
+				appB: "space-1",
+				appC: "space-2",
+			})},
+			{capiV3SpacesResponse(map[string]string{
+				"space-1": "org-1",
+				"space-2": "org-2",
+			})},
+			{capiV3OrgsResponse("org-1", "org-2")},
+		}
+	})
+
+	It("reports usage grouped by org", func() {
+		cf.Chargeback(
+			context.Background(),
+			cliConn,
+			[]string{"--window", "1h"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"org,envelopes,bytes",
+			"org-1-name,3,183",
+			"org-2-name,1,66",
+		}))
+
+		Expect(cliConn.cliCommandArgs[0][1]).To(ContainSubstring("/v3/apps?guids="))
+		Expect(cliConn.cliCommandArgs[1][1]).To(Equal("/v3/spaces?guids=space-1,space-2"))
+		Expect(cliConn.cliCommandArgs[2][1]).To(Equal("/v3/organizations?guids=org-1,org-2"))
+	})
+
+	It("reports usage grouped by space", func() {
+		cf.Chargeback(
+			context.Background(),
+			cliConn,
+			[]string{"--group-by", "space"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"org,space,envelopes,bytes",
+			"org-1-name,space-1-name,3,183",
+			"org-2-name,space-2-name,1,66",
+		}))
+	})
+
+	It("reads each app over the requested window", func() {
+		cf.Chargeback(
+			context.Background(),
+			cliConn,
+			[]string{"--window", "2h"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(httpClient.requestURLs[1]).To(ContainSubstring("/v1/read/" + appA))
+		start, end := readTimeRange(httpClient.requestURLs[1])
+		Expect(end.Sub(start)).To(Equal(2 * time.Hour))
+	})
+
+	It("fatally logs when group-by is invalid", func() {
+		Expect(func() {
+			cf.Chargeback(
+				context.Background(),
+				cliConn,
+				[]string{"--group-by", "foundation"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Group by must be 'org' or 'space'."))
+	})
+
+	It("fatally logs when it receives arguments", func() {
+		Expect(func() {
+			cf.Chargeback(
+				context.Background(),
+				cliConn,
+				[]string{"some-app"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Invalid arguments, expected 0, got 1."))
+	})
+})
+
+func capiV3AppsResponse(appSpaces map[string]string) string {
+	var resources []string
+	for appGUID, spaceGUID := range appSpaces {
+		resources = append(resources, fmt.Sprintf(
+			`{"guid": "%s", "name": "%s-name", "relationships": {"space": {"data": {"guid": "%s"}}}}`,
+			appGUID, appGUID, spaceGUID,
+		))
+	}
+	return fmt.Sprintf(`{ "resources": [%s] }`, strings.Join(resources, ","))
+}
+
+func capiV3SpacesResponse(spaceOrgs map[string]string) string {
+	var resources []string
+	for spaceGUID, orgGUID := range spaceOrgs {
+		resources = append(resources, fmt.Sprintf(
+			`{"guid": "%s", "name": "%s-name", "relationships": {"organization": {"data": {"guid": "%s"}}}}`,
+			spaceGUID, spaceGUID, orgGUID,
+		))
+	}
+	return fmt.Sprintf(`{ "resources": [%s] }`, strings.Join(resources, ","))
+}
+
+func capiV3OrgsResponse(orgGUIDs ...string) string {
+	var resources []string
+	for _, orgGUID := range orgGUIDs {
+		resources = append(resources, fmt.Sprintf(`{"guid": "%s", "name": "%s-name"}`, orgGUID, orgGUID))
+	}
+	return fmt.Sprintf(`{ "resources": [%s] }`, strings.Join(resources, ","))
+}
+
+func readTimeRange(requestURL string) (time.Time, time.Time) {
+	u, err := url.Parse(requestURL)
+	Expect(err).ToNot(HaveOccurred())
+
+	start, err := strconv.ParseInt(u.Query().Get("start_time"), 10, 64)
+	Expect(err).ToNot(HaveOccurred())
+	end, err := strconv.ParseInt(u.Query().Get("end_time"), 10, 64)
+	Expect(err).ToNot(HaveOccurred())
+
+	return time.Unix(0, start), time.Unix(0, end)
+}
@@ -0,0 +1,30 @@
+package cf
+
+import (
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// Clipboard places text on the system clipboard.
+type Clipboard func(text string) error
+
+// WithMetaClipboard replaces the clipboard used by --copy.
+func WithMetaClipboard(c Clipboard) MetaOption {
+	return func(o *optionsFlags) {
+		o.clipboard = c
+	}
+}
+
+// copySourceIDs places the source IDs on the clipboard, one per line.
+func copySourceIDs(c Clipboard, sourceIDs []string, log Logger) {
+	if c == nil {
+		c = clipboard.WriteAll
+	}
+
+	if err := c(strings.Join(sourceIDs, "\n")); err != nil {
+		log.Fatalf("Could not copy to the clipboard: %s", err)
+	}
+
+	log.Printf("Copied %d source IDs to the clipboard.", len(sourceIDs))
+}
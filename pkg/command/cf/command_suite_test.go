@@ -10,6 +10,7 @@ import (
 
 	"code.cloudfoundry.org/cli/plugin"
 	"code.cloudfoundry.org/cli/plugin/models"
+	homedir "github.com/mitchellh/go-homedir"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -21,6 +22,10 @@ func TestCommand(t *testing.T) {
 	RunSpecs(t, "Command Suite")
 }
 
+func init() {
+	homedir.DisableCache = true
+}
+
 type stubLogger struct {
 	fatalfMessage  string
 	printfMessages []string
@@ -51,6 +56,8 @@ func (w *stubWriter) lines() []string {
 
 type stubHTTPClient struct {
 	mu            sync.Mutex
+	version       string
+	maxReadLimit  int
 	responseCount int
 	responseBody  []string
 	responseCode  int
@@ -58,6 +65,7 @@ type stubHTTPClient struct {
 
 	requestURLs    []string
 	requestHeaders []http.Header
+	requestBodies  []string
 }
 
 func newStubHTTPClient() *stubHTTPClient {
@@ -72,16 +80,25 @@ func (s *stubHTTPClient) Do(r *http.Request) (*http.Response, error) {
 	defer s.mu.Unlock()
 
 	if r.URL.Path == "/api/v1/info" {
+		version := s.version
+		if version == "" {
+			version = "1.4.7"
+		}
+
 		return &http.Response{
 			StatusCode: http.StatusOK,
 			Body: ioutil.NopCloser(strings.NewReader(
-				`{"version": "1.4.7"}`,
+				fmt.Sprintf(`{"version": %q, "max_read_limit": %d}`, version, s.maxReadLimit),
 			)),
 		}, nil
 	}
 
 	s.requestURLs = append(s.requestURLs, r.URL.String())
 	s.requestHeaders = append(s.requestHeaders, r.Header)
+	if r.Body != nil {
+		b, _ := ioutil.ReadAll(r.Body)
+		s.requestBodies = append(s.requestBodies, string(b))
+	}
 
 	var body string
 	if s.responseCount < len(s.responseBody) {
@@ -122,8 +139,10 @@ type stubCliConnection struct {
 	usernameResp string
 	usernameErr  error
 	orgName      string
+	orgGUID      string
 	orgErr       error
 	spaceName    string
+	spaceGUID    string
 	spaceErr     error
 
 	accessTokenCount int
@@ -167,6 +186,7 @@ func (s *stubCliConnection) GetCurrentOrg() (plugin_models.Organization, error)
 	return plugin_models.Organization{
 		plugin_models.OrganizationFields{
 			Name: s.orgName,
+			Guid: s.orgGUID,
 		},
 	}, s.orgErr
 }
@@ -175,6 +195,7 @@ func (s *stubCliConnection) GetCurrentSpace() (plugin_models.Space, error) {
 	return plugin_models.Space{
 		plugin_models.SpaceFields{
 			Name: s.spaceName,
+			Guid: s.spaceGUID,
 		},
 	}, s.spaceErr
 }
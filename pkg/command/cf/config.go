@@ -0,0 +1,78 @@
+package cf
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config holds plugin wide settings read from ~/.log-cache-cli/config.yml.
+type Config struct {
+	// ScrubFile is the path to a scrub.yml file. Relative paths are
+	// resolved against the config directory.
+	ScrubFile string `yaml:"scrub_file"`
+
+	// Telemetry enables recording anonymous usage records in a local
+	// spool file. Records are only sent when flushed with log-telemetry.
+	Telemetry         bool   `yaml:"telemetry"`
+	TelemetryEndpoint string `yaml:"telemetry_endpoint"`
+
+	// NameCacheTTL enables caching the names of app and service GUIDs
+	// resolved by log-meta for the given duration.
+	NameCacheTTL time.Duration `yaml:"name_cache_ttl"`
+
+	Scrubber       *Scrubber `yaml:"-"`
+	TelemetrySpool string    `yaml:"-"`
+}
+
+// BuildConfig reads in the config file if it exists and returns a config
+// object. A missing config file results in an empty config.
+func BuildConfig() (Config, error) {
+	dir, err := configDir()
+	if err != nil {
+		return Config{}, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, "config.yml"))
+	if os.IsNotExist(err) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+
+	var conf Config
+	err = yaml.NewDecoder(f).Decode(&conf)
+	if err != nil && err != io.EOF {
+		return Config{}, err
+	}
+
+	conf.TelemetrySpool = filepath.Join(dir, telemetrySpoolFile)
+
+	if conf.ScrubFile != "" {
+		if !filepath.IsAbs(conf.ScrubFile) {
+			conf.ScrubFile = filepath.Join(dir, conf.ScrubFile)
+		}
+
+		conf.Scrubber, err = LoadScrubber(conf.ScrubFile)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	return conf, nil
+}
+
+func configDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".log-cache-cli"), nil
+}
@@ -0,0 +1,103 @@
+package cf_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var (
+		home     string
+		origHome string
+	)
+
+	BeforeEach(func() {
+		var err error
+		home, err = ioutil.TempDir("", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		origHome = os.Getenv("HOME")
+		Expect(os.Setenv("HOME", home)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Setenv("HOME", origHome)).To(Succeed())
+		Expect(os.RemoveAll(home)).To(Succeed())
+	})
+
+	writeConfigFile := func(name, contents string) {
+		dir := filepath.Join(home, ".log-cache-cli")
+		Expect(os.MkdirAll(dir, 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600)).To(Succeed())
+	}
+
+	It("returns an empty config when there is no config file", func() {
+		c, err := cf.BuildConfig()
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(Equal(cf.Config{}))
+	})
+
+	It("loads the scrub file relative to the config directory", func() {
+		writeConfigFile("config.yml", "scrub_file: scrub.yml\n")
+		writeConfigFile("scrub.yml", "fields: [password]\n")
+
+		c, err := cf.BuildConfig()
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.ScrubFile).To(Equal(filepath.Join(home, ".log-cache-cli", "scrub.yml")))
+		Expect(c.Scrubber).ToNot(BeNil())
+	})
+
+	It("reads the telemetry settings", func() {
+		writeConfigFile("config.yml", "telemetry: true\ntelemetry_endpoint: https://telemetry.example.com\n")
+
+		c, err := cf.BuildConfig()
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Telemetry).To(BeTrue())
+		Expect(c.TelemetryEndpoint).To(Equal("https://telemetry.example.com"))
+		Expect(c.TelemetrySpool).To(Equal(filepath.Join(home, ".log-cache-cli", "telemetry.jsonl")))
+	})
+
+	It("reads the name cache TTL", func() {
+		writeConfigFile("config.yml", "name_cache_ttl: 12h\n")
+
+		c, err := cf.BuildConfig()
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.NameCacheTTL).To(Equal(12 * time.Hour))
+	})
+
+	It("returns an error when the config file is invalid", func() {
+		writeConfigFile("config.yml", "!@$^*!^!$)%@")
+
+		_, err := cf.BuildConfig()
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when the scrub file does not exist", func() {
+		writeConfigFile("config.yml", "scrub_file: missing.yml\n")
+
+		_, err := cf.BuildConfig()
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when a scrub pattern is invalid", func() {
+		writeConfigFile("config.yml", "scrub_file: scrub.yml\n")
+		writeConfigFile("scrub.yml", "patterns:\n- pattern: '('\n")
+
+		_, err := cf.BuildConfig()
+
+		Expect(err).To(MatchError(ContainSubstring("Invalid scrub pattern")))
+	})
+})
@@ -0,0 +1,217 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+const crashMessagePrefix = "App instance exited"
+
+var (
+	crashExitDescriptionRegex = regexp.MustCompile(`"exit_description"\s*(?:=>|:)\s*"([^"]*)"`)
+	crashReasonRegex          = regexp.MustCompile(`"reason"\s*(?:=>|:)\s*"([^"]*)"`)
+)
+
+type crashesOptionFlags struct {
+	Last       time.Duration `long:"last" default:"24h"`
+	TableStyle string        `long:"table-style" default:"plain"`
+}
+
+type crashGroup struct {
+	reason string
+	count  int
+	last   time.Time
+}
+
+// CrashesOption configures the Crashes command.
+type CrashesOption func(*crashesOptions)
+
+type crashesOptions struct {
+	noHeaders bool
+	scrubber  *Scrubber
+}
+
+// WithCrashesNoHeaders omits the banner and table header.
+func WithCrashesNoHeaders() CrashesOption {
+	return func(o *crashesOptions) {
+		o.noHeaders = true
+	}
+}
+
+// WithCrashesScrubber masks sensitive data in each exit message before its
+// reason is read.
+func WithCrashesScrubber(s *Scrubber) CrashesOption {
+	return func(o *crashesOptions) {
+		o.scrubber = s
+	}
+}
+
+// Crashes reads the instance exit messages Cloud Controller writes to an
+// app's log stream and writes a table of crash reasons with their counts
+// and most recent occurrence.
+func Crashes(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	args []string,
+	c HTTPClient,
+	log Logger,
+	w io.Writer,
+	opts ...CrashesOption,
+) {
+	o := crashesOptionFlags{}
+
+	args, err := parseFlags("log-crashes", &o, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) != 1 {
+		log.Fatalf("Expected 1 argument, got %d.", len(args))
+	}
+
+	co := crashesOptions{}
+	for _, opt := range opts {
+		opt(&co)
+	}
+
+	if o.Last <= 0 {
+		log.Fatalf("--last must be greater than 0.")
+	}
+
+	style, err := parseTableStyle(o.TableStyle)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	appName := args[0]
+	appGUID := getAppGUID(appName, cli, log)
+	if appGUID == "" {
+		log.Fatalf(translate("App %s not found."), appName)
+	}
+
+	logCacheEndpoint, err := logCacheEndpoint(cli)
+	if err != nil {
+		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+	}
+
+	client := logcache.NewClient(
+		logCacheEndpoint,
+		logcache.WithHTTPClient(authenticatedClient(cli, c, log)),
+	)
+
+	if !co.noHeaders {
+		writeAppHeader(w, cli, log, "Retrieving crashes for app %s in org %s / space %s as %s...", appName)
+	}
+
+	groups := make(map[string]*crashGroup)
+	end := time.Now()
+
+	logcache.Walk(
+		ctx,
+		appGUID,
+		logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+			for _, e := range envelopes {
+				co.scrubber.scrubEnvelope(e)
+				reason, ok := crashReason(e)
+				if !ok {
+					continue
+				}
+
+				g, ok := groups[reason]
+				if !ok {
+					g = &crashGroup{reason: reason}
+					groups[reason] = g
+				}
+
+				g.count++
+				if ts := time.Unix(0, e.Timestamp); ts.After(g.last) {
+					g.last = ts
+				}
+			}
+			return true
+		}),
+		client.Read,
+		logcache.WithWalkStartTime(end.Add(-o.Last)),
+		logcache.WithWalkEndTime(end),
+		logcache.WithWalkEnvelopeTypes(logcache_v1.EnvelopeType_LOG),
+		logcache.WithWalkBackoff(newBackoff(log)),
+	)
+
+	if len(groups) == 0 {
+		fmt.Fprintf(w, translate("No crashes found in the last %s.")+"\n", o.Last)
+		return
+	}
+
+	var rows []*crashGroup
+	for _, g := range groups {
+		rows = append(rows, g)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].count != rows[j].count {
+			return rows[i].count > rows[j].count
+		}
+		return rows[i].reason < rows[j].reason
+	})
+
+	tw := newTableWriter(w, style, !co.noHeaders)
+	fmt.Fprintf(tw, "Reason\tCount\tLast Occurrence\n")
+	for _, g := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", g.reason, formatCount(g.count), formatTime(g.last, timeFormat))
+	}
+
+	if err = tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+// crashReason extracts the exit description (or the exit reason when there
+// is no description) from a Cloud Controller instance exit message.
+func crashReason(e *loggregator_v2.Envelope) (string, bool) {
+	payload := string(e.GetLog().GetPayload())
+	if !strings.Contains(payload, crashMessagePrefix) {
+		return "", false
+	}
+
+	if m := crashExitDescriptionRegex.FindStringSubmatch(payload); m != nil && m[1] != "" {
+		return m[1], true
+	}
+
+	if m := crashReasonRegex.FindStringSubmatch(payload); m != nil && m[1] != "" {
+		return m[1], true
+	}
+
+	return "unknown", true
+}
+
+// writeAppHeader writes a banner for commands that operate on a single app
+// in the targeted org and space. The org, space and user are appended to
+// the given arguments.
+func writeAppHeader(w io.Writer, cli plugin.CliConnection, log Logger, format string, args ...interface{}) {
+	user, err := cli.Username()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	org, err := cli.GetCurrentOrg()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	space, err := cli.GetCurrentSpace()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	args = append(args, org.Name, space.Name, user)
+	fmt.Fprintf(w, translate(format)+"\n\n", args...)
+}
@@ -0,0 +1,223 @@
+package cf_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Crashes", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		startTime  time.Time
+		timeFormat string
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		timeFormat = "2006-01-02T15:04:05.00-0700"
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+		cliConn.usernameResp = "a-user"
+		cliConn.orgName = "organization"
+		cliConn.spaceName = "space"
+
+		httpClient.responseBody = []string{
+			logResponseBody(
+				startTime,
+				crashPayload("CRASHED", "APP/PROC/WEB: Exited with status 137 (out of memory)"),
+				"regular log line",
+				crashPayload("CRASHED", "APP/PROC/WEB: Exited with status 1"),
+				crashPayload("CRASHED", "APP/PROC/WEB: Exited with status 137 (out of memory)"),
+				crashPayload("CRASHED", ""),
+			),
+			emptyResponseBody(),
+		}
+	})
+
+	It("groups crashes by reason", func() {
+		cf.Crashes(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Retrieving crashes for app app-name in org organization / space space as a-user...",
+			"",
+			"Reason                                                Count  Last Occurrence",
+			"APP/PROC/WEB: Exited with status 137 (out of memory)  2      " + startTime.Add(3*time.Second).Format(timeFormat),
+			"APP/PROC/WEB: Exited with status 1                    1      " + startTime.Add(2*time.Second).Format(timeFormat),
+			"CRASHED                                               1      " + startTime.Add(4*time.Second).Format(timeFormat),
+		}))
+	})
+
+	It("renders the table in the requested style", func() {
+		cf.Crashes(
+			context.Background(),
+			cliConn,
+			[]string{"--table-style", "github", "app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithCrashesNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"| Reason                                               | Count | Last Occurrence             |",
+			"|------------------------------------------------------|-------|-----------------------------|",
+			"| APP/PROC/WEB: Exited with status 137 (out of memory) | 2     | " + startTime.Add(3*time.Second).Format(timeFormat) + " |",
+			"| APP/PROC/WEB: Exited with status 1                   | 1     | " + startTime.Add(2*time.Second).Format(timeFormat) + " |",
+			"| CRASHED                                              | 1     | " + startTime.Add(4*time.Second).Format(timeFormat) + " |",
+		}))
+	})
+
+	It("scrubs the exit messages before grouping them", func() {
+		scrubber := writeScrubFile(`
+patterns:
+- pattern: 'status \d+'
+  replacement: 'status [code]'
+`)
+
+		cf.Crashes(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithCrashesNoHeaders(),
+			cf.WithCrashesScrubber(scrubber),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"APP/PROC/WEB: Exited with status [code] (out of memory)  2  " + startTime.Add(3*time.Second).Format(timeFormat),
+			"APP/PROC/WEB: Exited with status [code]                  1  " + startTime.Add(2*time.Second).Format(timeFormat),
+			"CRASHED                                                  1  " + startTime.Add(4*time.Second).Format(timeFormat),
+		}))
+	})
+
+	It("fatally logs for an unknown table style", func() {
+		Expect(func() {
+			cf.Crashes(
+				context.Background(),
+				cliConn,
+				[]string{"--table-style", "fancy", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--table-style must be 'plain', 'github', 'markdown' or 'tsv'."))
+	})
+
+	It("reads log envelopes for the requested window", func() {
+		cf.Crashes(
+			context.Background(),
+			cliConn,
+			[]string{"--last", "1h", "app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithCrashesNoHeaders(),
+		)
+
+		Expect(httpClient.requestURLs[0]).To(ContainSubstring("/v1/read/app-guid"))
+		Expect(httpClient.requestURLs[0]).To(ContainSubstring("envelope_types=LOG"))
+		start, end := readTimeRange(httpClient.requestURLs[0])
+		Expect(end.Sub(start)).To(Equal(time.Hour))
+	})
+
+	It("reads the window from the environment", func() {
+		os.Setenv("LOG_CACHE_CLI_LOG_CRASHES_LAST", "2h")
+		defer os.Unsetenv("LOG_CACHE_CLI_LOG_CRASHES_LAST")
+
+		cf.Crashes(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithCrashesNoHeaders(),
+		)
+
+		start, end := readTimeRange(httpClient.requestURLs[0])
+		Expect(end.Sub(start)).To(Equal(2 * time.Hour))
+	})
+
+	It("reports when there are no crashes", func() {
+		httpClient.responseBody = []string{emptyResponseBody()}
+
+		cf.Crashes(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithCrashesNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"No crashes found in the last 24h0m0s.",
+		}))
+	})
+
+	It("fatally logs when the app does not exist", func() {
+		cliConn.cliCommandResult = [][]string{nil}
+		cliConn.cliCommandErr = []error{fmt.Errorf("App app-name not found")}
+
+		Expect(func() {
+			cf.Crashes(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("App app-name not found."))
+	})
+
+	It("fatally logs when not given an app", func() {
+		Expect(func() {
+			cf.Crashes(
+				context.Background(),
+				cliConn,
+				[]string{},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Expected 1 argument, got 0."))
+	})
+})
+
+func crashPayload(reason, exitDescription string) string {
+	return fmt.Sprintf(
+		`App instance exited with guid app-guid payload: {"instance"=>"some-instance", "index"=>0, "reason"=>%q, "exit_description"=>%q, "crash_count"=>1}`,
+		reason,
+		exitDescription,
+	)
+}
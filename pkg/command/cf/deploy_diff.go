@@ -0,0 +1,264 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+var (
+	patternUUIDRegex   = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	patternHexRegex    = regexp.MustCompile(`(?i)\b(?:0x[0-9a-f]+|[0-9a-f]{8,})\b`)
+	patternNumberRegex = regexp.MustCompile(`\d+(?:\.\d+)?`)
+	errorLineRegex     = regexp.MustCompile(`(?i)\b(?:error|exception|fatal|panic)\b`)
+)
+
+type deployDiffOptionFlags struct {
+	DeployTime string        `long:"deploy-time"`
+	Window     time.Duration `long:"window" default:"30m"`
+	Spike      float64       `long:"spike" default:"2"`
+	TableStyle string        `long:"table-style" default:"plain"`
+}
+
+type patternCounts struct {
+	pattern string
+	before  int
+	after   int
+}
+
+// DeployDiffOption configures the DeployDiff command.
+type DeployDiffOption func(*deployDiffOptions)
+
+type deployDiffOptions struct {
+	noHeaders bool
+	scrubber  *Scrubber
+}
+
+// WithDeployDiffNoHeaders omits the banner and table header.
+func WithDeployDiffNoHeaders() DeployDiffOption {
+	return func(o *deployDiffOptions) {
+		o.noHeaders = true
+	}
+}
+
+// WithDeployDiffScrubber masks sensitive data in each log line before its
+// pattern is taken.
+func WithDeployDiffScrubber(s *Scrubber) DeployDiffOption {
+	return func(o *deployDiffOptions) {
+		o.scrubber = s
+	}
+}
+
+// DeployDiff compares an app's logs in equal windows before and after a
+// deploy. It writes the error rate of both windows followed by the log
+// patterns that only appeared after the deploy or whose frequency spiked.
+func DeployDiff(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	args []string,
+	c HTTPClient,
+	log Logger,
+	w io.Writer,
+	opts ...DeployDiffOption,
+) {
+	o := deployDiffOptionFlags{}
+
+	args, err := parseFlags("log-deploy-diff", &o, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) != 1 {
+		log.Fatalf("Expected 1 argument, got %d.", len(args))
+	}
+
+	do := deployDiffOptions{}
+	for _, opt := range opts {
+		opt(&do)
+	}
+
+	if o.DeployTime == "" {
+		log.Fatalf("--deploy-time is required.")
+	}
+
+	deployTime, err := parseTimestamp(o.DeployTime)
+	if err != nil {
+		log.Fatalf("Invalid deploy time %q, expected RFC3339 or UNIX nanoseconds.", o.DeployTime)
+	}
+
+	now := time.Now()
+	if !deployTime.Before(now) {
+		log.Fatalf("--deploy-time must be in the past.")
+	}
+
+	if o.Window <= 0 {
+		log.Fatalf("--window must be greater than 0.")
+	}
+
+	if o.Spike <= 1 {
+		log.Fatalf("--spike must be greater than 1.")
+	}
+
+	style, err := parseTableStyle(o.TableStyle)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	appName := args[0]
+	appGUID := getAppGUID(appName, cli, log)
+	if appGUID == "" {
+		log.Fatalf(translate("App %s not found."), appName)
+	}
+
+	logCacheEndpoint, err := logCacheEndpoint(cli)
+	if err != nil {
+		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+	}
+
+	client := logcache.NewClient(
+		logCacheEndpoint,
+		logcache.WithHTTPClient(authenticatedClient(cli, c, log)),
+	)
+
+	if !do.noHeaders {
+		writeAppHeader(w, cli, log, "Comparing logs before and after deploy for app %s in org %s / space %s as %s...", appName)
+	}
+
+	end := deployTime.Add(o.Window)
+	if end.After(now) {
+		end = now
+	}
+
+	var (
+		patterns                  = make(map[string]*patternCounts)
+		linesBefore, linesAfter   int
+		errorsBefore, errorsAfter int
+	)
+
+	logcache.Walk(
+		ctx,
+		appGUID,
+		logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+			for _, e := range envelopes {
+				l := e.GetLog()
+				if l == nil {
+					continue
+				}
+
+				do.scrubber.scrubEnvelope(e)
+				payload := string(l.GetPayload())
+				isError := l.GetType() == loggregator_v2.Log_ERR || errorLineRegex.MatchString(payload)
+
+				pattern := logPattern(payload)
+				p, ok := patterns[pattern]
+				if !ok {
+					p = &patternCounts{pattern: pattern}
+					patterns[pattern] = p
+				}
+
+				if time.Unix(0, e.Timestamp).Before(deployTime) {
+					p.before++
+					linesBefore++
+					if isError {
+						errorsBefore++
+					}
+					continue
+				}
+
+				p.after++
+				linesAfter++
+				if isError {
+					errorsAfter++
+				}
+			}
+			return true
+		}),
+		client.Read,
+		logcache.WithWalkStartTime(deployTime.Add(-o.Window)),
+		logcache.WithWalkEndTime(end),
+		logcache.WithWalkEnvelopeTypes(logcache_v1.EnvelopeType_LOG),
+		logcache.WithWalkBackoff(newBackoff(log)),
+	)
+
+	fmt.Fprintf(w, "Error rate before: %s\n", formatErrorRate(errorsBefore, linesBefore))
+	fmt.Fprintf(w, "Error rate after:  %s\n\n", formatErrorRate(errorsAfter, linesAfter))
+
+	// The window after the deploy is shorter than the one before when the
+	// deploy was recent, so frequencies are compared per second.
+	scale := o.Window.Seconds() / end.Sub(deployTime).Seconds()
+
+	var rows []*patternCounts
+	for _, p := range patterns {
+		if p.after == 0 {
+			continue
+		}
+		if p.before == 0 || float64(p.after)*scale >= float64(p.before)*o.Spike {
+			rows = append(rows, p)
+		}
+	}
+
+	if len(rows) == 0 {
+		fmt.Fprintf(w, "No new or spiking log patterns after the deploy.\n")
+		return
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].after != rows[j].after {
+			return rows[i].after > rows[j].after
+		}
+		return rows[i].pattern < rows[j].pattern
+	})
+
+	tw := newTableWriter(w, style, !do.noHeaders)
+	fmt.Fprintf(tw, "Before\tAfter\tChange\tPattern\n")
+	for _, p := range rows {
+		change := "new"
+		if p.before > 0 {
+			change = fmt.Sprintf("%.1fx", float64(p.after)*scale/float64(p.before))
+		}
+
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n", p.before, p.after, change, p.pattern)
+	}
+
+	if err = tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+// logPattern replaces the variable parts of a log line such as IDs and
+// numbers with placeholders so similar lines are counted together.
+func logPattern(payload string) string {
+	pattern := strings.TrimSpace(payload)
+	pattern = patternUUIDRegex.ReplaceAllString(pattern, "<uuid>")
+	pattern = patternHexRegex.ReplaceAllString(pattern, "<hex>")
+	pattern = patternNumberRegex.ReplaceAllString(pattern, "<n>")
+
+	return pattern
+}
+
+func formatErrorRate(errorLines, lines int) string {
+	if lines == 0 {
+		return "n/a (no log lines)"
+	}
+
+	return fmt.Sprintf("%.1f%% (%d of %d lines)", float64(errorLines)/float64(lines)*100, errorLines, lines)
+}
+
+// parseTimestamp accepts either an RFC3339 timestamp or UNIX nanoseconds.
+func parseTimestamp(s string) (time.Time, error) {
+	if ns, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(0, ns), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
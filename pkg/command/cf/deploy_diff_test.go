@@ -0,0 +1,194 @@
+package cf_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DeployDiff", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		startTime  time.Time
+		deployTime string
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		deployTime = strconv.FormatInt(startTime.Add(3*time.Second).UnixNano(), 10)
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+		cliConn.usernameResp = "a-user"
+		cliConn.orgName = "organization"
+		cliConn.spaceName = "space"
+
+		httpClient.responseBody = []string{
+			logResponseBody(
+				startTime,
+				"GET /orders 200 in 12ms",
+				"GET /orders 200 in 15ms",
+				"cache refreshed",
+				"GET /orders 200 in 9ms",
+				"ERROR connection to db-7 refused",
+				"ERROR connection to db-2 refused",
+				"cache refreshed",
+			),
+			emptyResponseBody(),
+		}
+	})
+
+	It("writes error rates and new patterns", func() {
+		cf.DeployDiff(
+			context.Background(),
+			cliConn,
+			[]string{"--deploy-time", deployTime, "--window", "4s", "app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Comparing logs before and after deploy for app app-name in org organization / space space as a-user...",
+			"",
+			"Error rate before: 0.0% (0 of 3 lines)",
+			"Error rate after:  50.0% (2 of 4 lines)",
+			"",
+			"Before  After  Change  Pattern",
+			"0       2      new     ERROR connection to db-<n> refused",
+		}))
+
+		start, end := readTimeRange(httpClient.requestURLs[0])
+		Expect(start).To(Equal(startTime.Add(-time.Second)))
+		Expect(end).To(Equal(startTime.Add(7 * time.Second)))
+	})
+
+	It("reports patterns that became more frequent", func() {
+		httpClient.responseBody = []string{
+			logResponseBody(
+				startTime,
+				"GET /orders 200 in 12ms",
+				"GET /orders 200 in 15ms",
+				"retrying request 1",
+				"retrying request 1",
+				"retrying request 2",
+				"retrying request 3",
+				"GET /orders 500 in 9ms",
+			),
+			emptyResponseBody(),
+		}
+
+		cf.DeployDiff(
+			context.Background(),
+			cliConn,
+			[]string{"--deploy-time", deployTime, "--window", "4s", "--spike", "3", "app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithDeployDiffNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Error rate before: 0.0% (0 of 3 lines)",
+			"Error rate after:  0.0% (0 of 4 lines)",
+			"",
+			"1  3  3.0x  retrying request <n>",
+		}))
+	})
+
+	It("scrubs log lines before taking their pattern", func() {
+		scrubber := writeScrubFile(`
+patterns:
+- pattern: 'db-\d+'
+  replacement: '[host]'
+`)
+
+		cf.DeployDiff(
+			context.Background(),
+			cliConn,
+			[]string{"--deploy-time", deployTime, "--window", "4s", "app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithDeployDiffNoHeaders(),
+			cf.WithDeployDiffScrubber(scrubber),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Error rate before: 0.0% (0 of 3 lines)",
+			"Error rate after:  50.0% (2 of 4 lines)",
+			"",
+			"0  2  new  ERROR connection to [host] refused",
+		}))
+	})
+
+	It("accepts RFC3339 deploy times", func() {
+		cf.DeployDiff(
+			context.Background(),
+			cliConn,
+			[]string{"--deploy-time", startTime.Add(3 * time.Second).Format(time.RFC3339), "--window", "4s", "app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithDeployDiffNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(ContainElement("0  2  new  ERROR connection to db-<n> refused"))
+	})
+
+	It("fatally logs without a deploy time", func() {
+		Expect(func() {
+			cf.DeployDiff(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--deploy-time is required."))
+	})
+
+	It("fatally logs for an invalid deploy time", func() {
+		Expect(func() {
+			cf.DeployDiff(
+				context.Background(),
+				cliConn,
+				[]string{"--deploy-time", "yesterday", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal(fmt.Sprintf("Invalid deploy time %q, expected RFC3339 or UNIX nanoseconds.", "yesterday")))
+	})
+
+	It("fatally logs for a deploy time in the future", func() {
+		Expect(func() {
+			cf.DeployDiff(
+				context.Background(),
+				cliConn,
+				[]string{"--deploy-time", strconv.FormatInt(time.Now().Add(time.Hour).UnixNano(), 10), "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--deploy-time must be in the past."))
+	})
+})
@@ -0,0 +1,269 @@
+package cf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+)
+
+// deployEventDescriptions maps the CAPI event types that mark lifecycle
+// changes of an app to the text shown in the marker line.
+var deployEventDescriptions = map[string]string{
+	"audit.app.deployment.create": "deploy started",
+	"audit.app.deployment.cancel": "deploy canceled",
+	"audit.app.droplet.mapped":    "deploy finished",
+	"audit.app.restage":           "restage",
+	"audit.app.start":             "app started",
+	"audit.app.stop":              "app stopped",
+	"audit.app.process.scale":     "scale",
+	"audit.app.process.crash":     "crash",
+	"app.crash":                   "crash",
+}
+
+// deployMarkerFetchInterval is how often the events of the app are fetched
+// again while following.
+const deployMarkerFetchInterval = 30 * time.Second
+
+// appEvent is a lifecycle event of an app read from CAPI.
+type appEvent struct {
+	guid            string
+	eventType       string
+	actor           string
+	timestamp       time.Time
+	exitDescription string
+}
+
+type capiAuditEvent struct {
+	GUID      string    `json:"guid"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Actor     struct {
+		Name string `json:"name"`
+	} `json:"actor"`
+	Data map[string]interface{} `json:"data"`
+}
+
+type capiAuditEventsResponse struct {
+	Resources []capiAuditEvent `json:"resources"`
+	Errors    []struct {
+		Detail string `json:"detail"`
+	} `json:"errors"`
+}
+
+type capiEvent struct {
+	Metadata struct {
+		GUID string `json:"guid"`
+	} `json:"metadata"`
+	Entity struct {
+		Type      string                 `json:"type"`
+		Actor     string                 `json:"actor_name"`
+		Timestamp time.Time              `json:"timestamp"`
+		Metadata  map[string]interface{} `json:"metadata"`
+	} `json:"entity"`
+}
+
+type capiEventsResponse struct {
+	Resources []capiEvent `json:"resources"`
+}
+
+type deployMarker struct {
+	cli     plugin.CliConnection
+	log     Logger
+	appGUID string
+
+	// v2 is set once CAPI answered that it has no /v3/audit_events, after
+	// which events are read from /v2/events.
+	v2 bool
+
+	mu      sync.Mutex
+	since   time.Time
+	seen    map[string]bool
+	pending []appEvent
+}
+
+func newDeployMarker(cli plugin.CliConnection, appGUID string, log Logger) *deployMarker {
+	return &deployMarker{
+		cli:     cli,
+		log:     log,
+		appGUID: appGUID,
+		seen:    make(map[string]bool),
+	}
+}
+
+// fetch queues the lifecycle events of the app that happened after the
+// given time or after the last fetched event, whichever is later.
+func (m *deployMarker) fetch(after time.Time) {
+	m.mu.Lock()
+	if after.After(m.since) {
+		m.since = after
+	}
+	since := m.since
+	m.mu.Unlock()
+
+	events, err := m.readEvents(since)
+	if err != nil {
+		m.log.Printf("Failed to read app events: %s", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range events {
+		if _, ok := deployEventDescriptions[e.eventType]; !ok || m.seen[e.guid] {
+			continue
+		}
+		m.seen[e.guid] = true
+		m.pending = append(m.pending, e)
+
+		if e.timestamp.After(m.since) {
+			m.since = e.timestamp
+		}
+	}
+
+	sort.SliceStable(m.pending, func(i, j int) bool {
+		return m.pending[i].timestamp.Before(m.pending[j].timestamp)
+	})
+}
+
+// poll fetches the events of the app every deployMarkerFetchInterval until
+// the context is done and writes the markers of the events that happened
+// up to then, so they are shown even while the app writes no logs.
+func (m *deployMarker) poll(ctx context.Context, after time.Time, write func(markers []string)) {
+	t := time.NewTicker(deployMarkerFetchInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			m.fetch(after)
+			write(m.markersBefore(now.UnixNano()))
+		}
+	}
+}
+
+// readEvents reads the events of the app after the given time from
+// /v3/audit_events, or from /v2/events when CAPI has no audit events.
+func (m *deployMarker) readEvents(since time.Time) ([]appEvent, error) {
+	if !m.v2 {
+		events, ok, err := m.readAuditEvents(since)
+		if ok || err != nil {
+			return events, err
+		}
+		m.v2 = true
+	}
+
+	return m.readV2Events(since)
+}
+
+// readAuditEvents reads the events of the app from /v3/audit_events. It
+// reports false when CAPI answered with an error instead of events.
+func (m *deployMarker) readAuditEvents(since time.Time) ([]appEvent, bool, error) {
+	lines, err := m.cli.CliCommandWithoutTerminalOutput(
+		"curl",
+		fmt.Sprintf(
+			"/v3/audit_events?target_guids=%s&created_ats[gt]=%s&order_by=created_at&per_page=100",
+			m.appGUID,
+			since.UTC().Format(time.RFC3339),
+		),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var r capiAuditEventsResponse
+	if err := json.NewDecoder(strings.NewReader(strings.Join(lines, ""))).Decode(&r); err != nil {
+		return nil, false, err
+	}
+
+	if len(r.Errors) > 0 {
+		return nil, false, nil
+	}
+
+	events := make([]appEvent, 0, len(r.Resources))
+	for _, e := range r.Resources {
+		reason, _ := e.Data["exit_description"].(string)
+		events = append(events, appEvent{
+			guid:            e.GUID,
+			eventType:       e.Type,
+			actor:           e.Actor.Name,
+			timestamp:       e.CreatedAt,
+			exitDescription: reason,
+		})
+	}
+
+	return events, true, nil
+}
+
+// readV2Events reads the events of the app from /v2/events.
+func (m *deployMarker) readV2Events(since time.Time) ([]appEvent, error) {
+	lines, err := m.cli.CliCommandWithoutTerminalOutput(
+		"curl",
+		fmt.Sprintf(
+			"/v2/events?q=actee:%s&q=timestamp>%s&order-direction=asc&results-per-page=100",
+			m.appGUID,
+			since.UTC().Format(time.RFC3339),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var r capiEventsResponse
+	if err := json.NewDecoder(strings.NewReader(strings.Join(lines, ""))).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	events := make([]appEvent, 0, len(r.Resources))
+	for _, e := range r.Resources {
+		reason, _ := e.Entity.Metadata["exit_description"].(string)
+		events = append(events, appEvent{
+			guid:            e.Metadata.GUID,
+			eventType:       e.Entity.Type,
+			actor:           e.Entity.Actor,
+			timestamp:       e.Entity.Timestamp,
+			exitDescription: reason,
+		})
+	}
+
+	return events, nil
+}
+
+// markersBefore returns the marker lines for queued events that happened
+// at or before the given UNIX nanosecond timestamp.
+func (m *deployMarker) markersBefore(ts int64) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var markers []string
+	for len(m.pending) > 0 && m.pending[0].timestamp.UnixNano() <= ts {
+		markers = append(markers, formatDeployMarker(m.pending[0]))
+		m.pending = m.pending[1:]
+	}
+
+	return markers
+}
+
+func formatDeployMarker(e appEvent) string {
+	description := deployEventDescriptions[e.eventType]
+	if e.exitDescription != "" {
+		description = fmt.Sprintf("%s: %s", description, e.exitDescription)
+	}
+	if e.actor != "" {
+		description = fmt.Sprintf("%s by %s", description, e.actor)
+	}
+
+	return fmt.Sprintf("   %s [CAPI] ===== %s (%s) =====",
+		e.timestamp.Local().Format(timeFormat),
+		description,
+		e.eventType,
+	)
+}
@@ -0,0 +1,30 @@
+package cf
+
+import (
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+const flagEnvPrefix = "LOG_CACHE_CLI_"
+
+// parseFlags parses the flags of a command like flags.ParseArgs. Every flag
+// defaults to the LOG_CACHE_CLI_<COMMAND>_<FLAG> environment variable when
+// it is set, e.g. LOG_CACHE_CLI_TAIL_ENVELOPE_TYPE for --envelope-type of
+// tail. Flags given on the command line take precedence.
+func parseFlags(command string, data interface{}, args []string) ([]string, error) {
+	p := flags.NewParser(data, flags.Default)
+	for _, g := range p.Groups() {
+		for _, o := range g.Options() {
+			if o.LongName != "" {
+				o.EnvDefaultKey = flagEnvKey(command, o.LongName)
+			}
+		}
+	}
+
+	return p.ParseArgs(args)
+}
+
+func flagEnvKey(command, flag string) string {
+	return flagEnvPrefix + strings.ToUpper(strings.Replace(command+"_"+flag, "-", "_", -1))
+}
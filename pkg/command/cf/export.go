@@ -0,0 +1,625 @@
+package cf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+const exportManifestFile = "manifest.json"
+
+type exportOptionFlags struct {
+	Dir       string        `long:"dir"`
+	Last      time.Duration `long:"last" default:"1h"`
+	ChunkSize int           `long:"chunk-size" default:"10000"`
+	Scope     string        `long:"scope"`
+	Workers   int           `long:"workers" default:"4"`
+	Resume    bool          `long:"resume"`
+	DryRun    bool          `long:"dry-run"`
+
+	StrictWindow bool   `long:"strict-window"`
+	Progress     string `long:"progress"`
+	Accessible   bool   `long:"accessible"`
+
+	FilenameTemplate string `long:"filename-template"`
+	TableStyle       string `long:"table-style" default:"plain"`
+}
+
+// exportManifest records what has been exported to a directory so later
+// exports only fetch newer envelopes.
+type exportManifest struct {
+	SchemaVersion int                        `json:"schema_version"`
+	Sources       map[string]*exportedSource `json:"sources"`
+}
+
+type exportedSource struct {
+	Name          string        `json:"name"`
+	LastTimestamp int64         `json:"last_timestamp"`
+	Chunks        []exportChunk `json:"chunks"`
+}
+
+type exportChunk struct {
+	File      string `json:"file"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	Envelopes int    `json:"envelopes"`
+}
+
+// ExportOption configures the Export command.
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	scrubber *Scrubber
+	progress bool
+	events   io.Writer
+}
+
+// WithExportScrubber masks sensitive data in each envelope before it is
+// written.
+func WithExportScrubber(s *Scrubber) ExportOption {
+	return func(o *exportOptions) {
+		o.scrubber = s
+	}
+}
+
+// WithExportProgress draws a progress bar per source that is redrawn in
+// place while exporting.
+func WithExportProgress() ExportOption {
+	return func(o *exportOptions) {
+		o.progress = true
+	}
+}
+
+// WithExportEventWriter sets where progress events are written with
+// --progress json. Without it the events are discarded.
+func WithExportEventWriter(w io.Writer) ExportOption {
+	return func(o *exportOptions) {
+		o.events = w
+	}
+}
+
+type exportTarget struct {
+	name       string
+	sourceID   string
+	source     *exportedSource
+	checkpoint *checkpointSource
+	start      time.Time
+}
+
+type exportResult struct {
+	envelopes int
+	chunks    int
+}
+
+// exporter writes chunks for any number of sources and keeps the shared
+// manifest up to date.
+type exporter struct {
+	client    *logcache.Client
+	dir       string
+	chunkSize int
+	filename  *template.Template
+	scrubber  *Scrubber
+	log       Logger
+
+	mu         sync.Mutex
+	manifest   *exportManifest
+	checkpoint *exportCheckpoint
+}
+
+// Export writes the envelopes of one or more sources to gzipped NDJSON
+// chunk files in a directory. Sources are exported in parallel by a
+// bounded number of workers. When the directory holds a prior export of a
+// source only envelopes newer than the last exported one are fetched and
+// appended as new chunks. Progress is checkpointed so an interrupted export
+// can be continued with --resume.
+func Export(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	args []string,
+	c HTTPClient,
+	log Logger,
+	w io.Writer,
+	opts ...ExportOption,
+) {
+	o := exportOptionFlags{}
+
+	args, err := parseFlags("log-export", &o, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if o.Resume {
+		if len(args) != 0 || o.Scope != "" {
+			log.Fatalf("--resume cannot be used with source arguments or --scope.")
+		}
+	} else if o.Scope != "" {
+		if o.Scope != "applications" {
+			log.Fatalf("--scope must be 'applications'.")
+		}
+
+		if len(args) != 0 {
+			log.Fatalf("--scope cannot be used with source arguments.")
+		}
+	} else if len(args) == 0 {
+		log.Fatalf("Expected at least 1 argument, got 0.")
+	}
+
+	eo := exportOptions{}
+	for _, opt := range opts {
+		opt(&eo)
+	}
+
+	switch o.Progress {
+	case "":
+	case "bar":
+		eo.progress = true
+	case "json", "none":
+		eo.progress = false
+	default:
+		log.Fatalf("--progress must be 'bar', 'json' or 'none'.")
+	}
+
+	if o.Dir == "" {
+		log.Fatalf("--dir is required.")
+	}
+
+	if o.Last <= 0 {
+		log.Fatalf("--last must be greater than 0.")
+	}
+
+	if o.ChunkSize <= 0 {
+		log.Fatalf("--chunk-size must be greater than 0.")
+	}
+
+	if o.Workers <= 0 {
+		log.Fatalf("--workers must be greater than 0.")
+	}
+
+	if o.FilenameTemplate == "" {
+		o.FilenameTemplate = defaultExportFilenameTemplate
+	}
+
+	filename, err := parseExportFilenameTemplate(o.FilenameTemplate)
+	if err != nil {
+		log.Fatalf("Invalid --filename-template: %s", err)
+	}
+
+	style, err := parseTableStyle(o.TableStyle)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	manifest, err := readExportManifest(o.Dir)
+	if err != nil {
+		log.Fatalf("Could not read export manifest: %s", err)
+	}
+
+	checkpoint, err := readExportCheckpoint(o.Dir)
+	if err != nil {
+		log.Fatalf("Could not read export checkpoint: %s", err)
+	}
+
+	logCacheEndpoint, err := logCacheEndpoint(cli)
+	if err != nil {
+		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+	}
+
+	c = authenticatedClient(cli, c, log)
+
+	client := logcache.NewClient(
+		logCacheEndpoint,
+		logcache.WithHTTPClient(c),
+	)
+
+	var (
+		targets []exportTarget
+		end     time.Time
+	)
+	if o.Resume {
+		if checkpoint == nil {
+			log.Fatalf("No interrupted export to resume in %s.", o.Dir)
+		}
+
+		end = time.Unix(0, checkpoint.End)
+		targets = checkpoint.resumeTargets()
+		for _, t := range targets {
+			fmt.Fprintf(w, "Resuming export of %s from %s.\n", t.name, t.start.Format(time.RFC3339Nano))
+		}
+	} else {
+		if checkpoint != nil {
+			log.Fatalf("An interrupted export exists in %s. Use --resume to continue it.", o.Dir)
+		}
+
+		end = time.Now()
+		targets = exportTargets(ctx, args, o.Scope, cli, client, log)
+		for i, t := range targets {
+			targets[i].start = end.Add(-o.Last)
+			if source, ok := manifest.Sources[t.sourceID]; ok && source.LastTimestamp > 0 && !time.Unix(0, source.LastTimestamp).Before(targets[i].start) {
+				targets[i].start = time.Unix(0, source.LastTimestamp+1)
+				fmt.Fprintf(w, "Appending to existing export of %s after %s.\n", t.name, time.Unix(0, source.LastTimestamp).Format(time.RFC3339Nano))
+			}
+		}
+
+		checkpoint = newExportCheckpoint(end, targets)
+	}
+
+	for i, t := range targets {
+		source, ok := manifest.Sources[t.sourceID]
+		if !ok {
+			source = &exportedSource{Name: t.name}
+			manifest.Sources[t.sourceID] = source
+		}
+		targets[i].source = source
+	}
+
+	if o.DryRun {
+		readLimit := requireFeature(ctx, c, logCacheEndpoint, featureMeta, log).readLimit()
+		estimateExport(ctx, client, targets, end, readLimit, o.Workers, style, w, log)
+		return
+	}
+
+	var windows []retentionWindow
+	for _, t := range targets {
+		windows = append(windows, retentionWindow{name: t.name, sourceID: t.sourceID, start: t.start})
+	}
+	checkRetention(ctx, client, windows, o.StrictWindow, log)
+
+	if err := os.MkdirAll(o.Dir, 0755); err != nil {
+		log.Fatalf("Could not create export directory: %s", err)
+	}
+
+	if err := writeExportCheckpoint(o.Dir, checkpoint); err != nil {
+		log.Fatalf("Could not write export checkpoint: %s", err)
+	}
+
+	e := &exporter{
+		client:     client,
+		dir:        o.Dir,
+		chunkSize:  o.ChunkSize,
+		filename:   filename,
+		scrubber:   eo.scrubber,
+		log:        log,
+		manifest:   manifest,
+		checkpoint: checkpoint,
+	}
+
+	var progress exportReporter = noExportProgress{}
+	switch {
+	case o.Progress == "json":
+		events := eo.events
+		if events == nil {
+			events = ioutil.Discard
+		}
+		progress = newExportEvents(events, targets)
+	case eo.progress:
+		progress = newExportProgress(w, targets, o.Accessible)
+	}
+
+	results := make([]exportResult, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < o.Workers && i < len(targets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j] = e.export(ctx, targets[j], end, func(ts int64, envelopes int) {
+					progress.update(j, ts, envelopes, end)
+				}, func(attempt int, err error) {
+					progress.retry(j, attempt, err)
+				})
+				if ctx.Err() == nil {
+					e.finish(targets[j])
+					progress.done(j)
+				}
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	progress.finish(ctx.Err() != nil)
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(w, "Export interrupted. Run again with --resume to continue.\n")
+		return
+	}
+
+	if err := removeExportCheckpoint(o.Dir); err != nil {
+		log.Fatalf("Could not remove export checkpoint: %s", err)
+	}
+
+	for i, t := range targets {
+		if results[i].envelopes == 0 {
+			fmt.Fprintf(w, "No new envelopes for %s.\n", t.name)
+			continue
+		}
+
+		fmt.Fprintf(w, "Exported %d envelopes for %s in %d chunks to %s.\n", results[i].envelopes, t.name, results[i].chunks, o.Dir)
+	}
+}
+
+// exportTargets resolves the sources to export. Source patterns are expanded
+// to the matching source IDs and names that are not apps are used as source
+// IDs.
+func exportTargets(ctx context.Context, args []string, scope string, cli plugin.CliConnection, client *logcache.Client, log Logger) []exportTarget {
+	var targets []exportTarget
+
+	if scope != "" {
+		space, err := cli.GetCurrentSpace()
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		apps, err := getSpaceApps(space.Guid, cli)
+		if err != nil {
+			log.Fatalf("Failed to read apps in space %s: %s", space.Name, err)
+		}
+
+		if len(apps) == 0 {
+			log.Fatalf("No apps found in space %s.", space.Name)
+		}
+
+		for _, app := range apps {
+			targets = append(targets, exportTarget{name: app.Name, sourceID: app.GUID})
+		}
+
+		return targets
+	}
+
+	for _, name := range args {
+		if isSourcePattern(name) {
+			sourceIDs, err := expandSourcePattern(ctx, client, name)
+			if err != nil {
+				log.Fatalf("Could not expand source pattern %s: %s", name, err)
+			}
+
+			if len(sourceIDs) == 0 {
+				log.Fatalf("No sources match %s.", name)
+			}
+
+			for _, id := range sourceIDs {
+				targets = append(targets, exportTarget{name: id, sourceID: id})
+			}
+			continue
+		}
+
+		sourceID := getAppGUID(name, cli, log)
+		if sourceID == "" {
+			sourceID = name
+		}
+
+		targets = append(targets, exportTarget{name: name, sourceID: sourceID})
+	}
+
+	return targets
+}
+
+// export walks a single source and writes its envelopes in chunks. The
+// progress function is called with the newest timestamp after each batch and
+// the retry function before each retry of a failed read.
+func (e *exporter) export(
+	ctx context.Context,
+	t exportTarget,
+	end time.Time,
+	progress func(ts int64, envelopes int),
+	retry func(attempt int, err error),
+) exportResult {
+	var (
+		chunk  []*loggregator_v2.Envelope
+		result exportResult
+		newest int64
+	)
+	flush := func() {
+		if len(chunk) == 0 {
+			return
+		}
+
+		e.writeChunk(t, chunk)
+		result.envelopes += len(chunk)
+		result.chunks++
+		chunk = nil
+	}
+
+	logcache.Walk(
+		ctx,
+		t.sourceID,
+		logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+			for _, env := range envelopes {
+				e.scrubber.scrubEnvelope(env)
+				chunk = append(chunk, env)
+				if env.Timestamp > newest {
+					newest = env.Timestamp
+				}
+				if len(chunk) >= e.chunkSize {
+					flush()
+				}
+			}
+			progress(newest, result.envelopes+len(chunk))
+			return ctx.Err() == nil
+		}),
+		e.client.Read,
+		logcache.WithWalkStartTime(t.start),
+		logcache.WithWalkEndTime(end),
+		logcache.WithWalkBackoff(newExportBackoff(ctx, e.log, retry)),
+	)
+	flush()
+
+	return result
+}
+
+// writeChunk writes the envelopes to a new chunk file and records it in the
+// manifest and checkpoint.
+func (e *exporter) writeChunk(t exportTarget, envelopes []*loggregator_v2.Envelope) {
+	chunk := newExportChunk(envelopes)
+
+	file, err := renderExportFilename(e.filename, newExportFilename(t, chunk))
+	if err != nil {
+		e.log.Fatalf("Invalid --filename-template: %s", err)
+	}
+	chunk.File = file
+
+	data, err := encodeExportChunk(envelopes)
+	if err != nil {
+		e.log.Fatalf("Could not write export chunk: %s", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	path := filepath.Join(e.dir, filepath.FromSlash(chunk.File))
+	if _, err := os.Stat(path); err == nil {
+		e.log.Fatalf("Export file %s already exists. Include {{.ChunkStart}} in --filename-template to name chunks uniquely.", chunk.File)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		e.log.Fatalf("Could not write export chunk: %s", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		e.log.Fatalf("Could not write export chunk: %s", err)
+	}
+
+	t.source.Chunks = append(t.source.Chunks, chunk)
+	if chunk.End > t.source.LastTimestamp {
+		t.source.LastTimestamp = chunk.End
+	}
+
+	if err := writeExportManifest(e.dir, e.manifest); err != nil {
+		e.log.Fatalf("Could not write export manifest: %s", err)
+	}
+
+	t.checkpoint.Chunks = append(t.checkpoint.Chunks, chunk.File)
+	if chunk.End > t.checkpoint.LastTimestamp {
+		t.checkpoint.LastTimestamp = chunk.End
+	}
+
+	if err := writeExportCheckpoint(e.dir, e.checkpoint); err != nil {
+		e.log.Fatalf("Could not write export checkpoint: %s", err)
+	}
+}
+
+// finish marks the source as completely exported in the checkpoint.
+func (e *exporter) finish(t exportTarget) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t.checkpoint.Done = true
+	if err := writeExportCheckpoint(e.dir, e.checkpoint); err != nil {
+		e.log.Fatalf("Could not write export checkpoint: %s", err)
+	}
+}
+
+func readExportManifest(dir string) (*exportManifest, error) {
+	m := &exportManifest{}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, exportManifestFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, err
+		}
+		if m.SchemaVersion > schemaVersion {
+			return nil, fmt.Errorf("schema version %d is newer than the supported version %d", m.SchemaVersion, schemaVersion)
+		}
+	}
+
+	if m.Sources == nil {
+		m.Sources = make(map[string]*exportedSource)
+	}
+
+	return m, nil
+}
+
+func writeExportManifest(dir string, m *exportManifest) error {
+	m.SchemaVersion = schemaVersion
+	data, err := marshalJSONIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, exportManifestFile), data)
+}
+
+// newExportChunk returns the chunk covering the envelopes.
+func newExportChunk(envelopes []*loggregator_v2.Envelope) exportChunk {
+	chunk := exportChunk{
+		Start:     envelopes[0].Timestamp,
+		End:       envelopes[0].Timestamp,
+		Envelopes: len(envelopes),
+	}
+	for _, e := range envelopes {
+		if e.Timestamp < chunk.Start {
+			chunk.Start = e.Timestamp
+		}
+		if e.Timestamp > chunk.End {
+			chunk.End = e.Timestamp
+		}
+	}
+
+	return chunk
+}
+
+// encodeExportChunk encodes the envelopes as gzipped NDJSON.
+func encodeExportChunk(envelopes []*loggregator_v2.Envelope) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	marshaler := jsonpb.Marshaler{}
+	for _, e := range envelopes {
+		line, err := marshaler.MarshalToString(e)
+		if err != nil {
+			return nil, err
+		}
+		stable, err := stableJSON([]byte(line))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := gz.Write([]byte(withSchemaVersion(string(stable)) + "\n")); err != nil {
+			return nil, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
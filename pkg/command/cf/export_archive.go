@@ -0,0 +1,218 @@
+package cf
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+// defaultArchiveReadLimit matches the number of envelopes Log Cache returns
+// when a read does not set a limit.
+const defaultArchiveReadLimit = 100
+
+// exportArchive reads envelopes back from a directory written by
+// log-export. Its read method has the signature of the Log Cache client's
+// Read so commands can query an archive in place of Log Cache.
+type exportArchive struct {
+	dir      string
+	manifest *exportManifest
+}
+
+func openExportArchive(dir string) (*exportArchive, error) {
+	if _, err := os.Stat(filepath.Join(dir, exportManifestFile)); err != nil {
+		return nil, fmt.Errorf("%s is not an export directory: %s", dir, err)
+	}
+
+	manifest, err := readExportManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &exportArchive{dir: dir, manifest: manifest}, nil
+}
+
+// sourceID returns the source ID for the given source ID or exported name.
+func (a *exportArchive) sourceID(name string) (string, bool) {
+	if _, ok := a.manifest.Sources[name]; ok {
+		return name, true
+	}
+
+	for id, s := range a.manifest.Sources {
+		if s.Name == name {
+			return id, true
+		}
+	}
+
+	return "", false
+}
+
+// newest returns the time of the newest envelope exported for the source.
+func (a *exportArchive) newest(sourceID string) time.Time {
+	s, ok := a.manifest.Sources[sourceID]
+	if !ok {
+		return time.Time{}
+	}
+
+	return time.Unix(0, s.LastTimestamp)
+}
+
+func (a *exportArchive) read(
+	ctx context.Context,
+	sourceID string,
+	start time.Time,
+	opts ...logcache.ReadOption,
+) ([]*loggregator_v2.Envelope, error) {
+	u := &url.URL{}
+	q := url.Values{}
+	for _, o := range opts {
+		o(u, q)
+	}
+
+	end := int64(-1)
+	if v := q.Get("end_time"); v != "" {
+		var err error
+		end, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limit := defaultArchiveReadLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	types := append(q["envelope_types"], q["envelope_type"]...)
+
+	s, ok := a.manifest.Sources[sourceID]
+	if !ok {
+		return nil, nil
+	}
+
+	var envelopes []*loggregator_v2.Envelope
+	for _, chunk := range s.Chunks {
+		if chunk.End < start.UnixNano() || (end >= 0 && chunk.Start >= end) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		chunkEnvelopes, err := a.readChunk(chunk.File)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range chunkEnvelopes {
+			if e.Timestamp < start.UnixNano() || (end >= 0 && e.Timestamp >= end) {
+				continue
+			}
+			if !matchesEnvelopeTypes(e, types) {
+				continue
+			}
+			envelopes = append(envelopes, e)
+		}
+	}
+
+	sort.SliceStable(envelopes, func(i, j int) bool {
+		return envelopes[i].Timestamp < envelopes[j].Timestamp
+	})
+
+	if q.Get("descending") == "true" {
+		for i, j := 0, len(envelopes)-1; i < j; i, j = i+1, j-1 {
+			envelopes[i], envelopes[j] = envelopes[j], envelopes[i]
+		}
+	}
+
+	if len(envelopes) > limit {
+		envelopes = envelopes[:limit]
+	}
+
+	return envelopes, nil
+}
+
+func (a *exportArchive) readChunk(file string) ([]*loggregator_v2.Envelope, error) {
+	f, err := os.Open(filepath.Join(a.dir, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	defer gz.Close()
+
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	var envelopes []*loggregator_v2.Envelope
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var e loggregator_v2.Envelope
+		if err := unmarshaler.Unmarshal(strings.NewReader(line), &e); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		envelopes = append(envelopes, &e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+
+	return envelopes, nil
+}
+
+func matchesEnvelopeTypes(e *loggregator_v2.Envelope, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+
+	for _, t := range types {
+		if t == logcache_v1.EnvelopeType_ANY.String() || t == envelopeTypeOf(e).String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func envelopeTypeOf(e *loggregator_v2.Envelope) logcache_v1.EnvelopeType {
+	switch e.Message.(type) {
+	case *loggregator_v2.Envelope_Log:
+		return logcache_v1.EnvelopeType_LOG
+	case *loggregator_v2.Envelope_Counter:
+		return logcache_v1.EnvelopeType_COUNTER
+	case *loggregator_v2.Envelope_Gauge:
+		return logcache_v1.EnvelopeType_GAUGE
+	case *loggregator_v2.Envelope_Timer:
+		return logcache_v1.EnvelopeType_TIMER
+	case *loggregator_v2.Envelope_Event:
+		return logcache_v1.EnvelopeType_EVENT
+	default:
+		return logcache_v1.EnvelopeType_ANY
+	}
+}
@@ -0,0 +1,55 @@
+package cf
+
+import (
+	"context"
+	"time"
+
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+const (
+	exportRetryInterval = 250 * time.Millisecond
+	exportMaxRetries    = 3
+)
+
+// exportBackoff retries failed reads of an export after an exponentially
+// growing delay, so a long export survives a briefly unavailable Log Cache.
+// Every retry is reported with its attempt, counting from 1. Once the
+// retries are used up the export fails.
+type exportBackoff struct {
+	logcache.AlwaysDoneBackoff
+
+	ctx     context.Context
+	log     Logger
+	retry   func(attempt int, err error)
+	attempt int
+}
+
+func newExportBackoff(ctx context.Context, log Logger, retry func(attempt int, err error)) *exportBackoff {
+	return &exportBackoff{
+		ctx:   ctx,
+		log:   log,
+		retry: retry,
+	}
+}
+
+func (b *exportBackoff) OnErr(err error) bool {
+	if b.attempt >= exportMaxRetries {
+		b.log.Fatalf("%s", err)
+		return false
+	}
+
+	b.attempt++
+	b.retry(b.attempt, err)
+
+	select {
+	case <-time.After(exportRetryInterval << uint(b.attempt-1)):
+		return true
+	case <-b.ctx.Done():
+		return false
+	}
+}
+
+func (b *exportBackoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,105 @@
+package cf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const exportCheckpointFile = "checkpoint.json"
+
+// exportCheckpoint records the progress of a running export. It is removed
+// once every source has been exported, so a checkpoint left in a directory
+// belongs to an interrupted export that can be resumed.
+type exportCheckpoint struct {
+	End     int64               `json:"end"`
+	Sources []*checkpointSource `json:"sources"`
+}
+
+type checkpointSource struct {
+	Name          string   `json:"name"`
+	SourceID      string   `json:"source_id"`
+	Start         int64    `json:"start"`
+	LastTimestamp int64    `json:"last_timestamp"`
+	Chunks        []string `json:"chunks"`
+	Done          bool     `json:"done"`
+}
+
+func newExportCheckpoint(end time.Time, targets []exportTarget) *exportCheckpoint {
+	cp := &exportCheckpoint{End: end.UnixNano()}
+	for i, t := range targets {
+		s := &checkpointSource{
+			Name:     t.name,
+			SourceID: t.sourceID,
+			Start:    t.start.UnixNano(),
+		}
+		cp.Sources = append(cp.Sources, s)
+		targets[i].checkpoint = s
+	}
+
+	return cp
+}
+
+// resumeTargets returns the sources of the checkpoint that have not been
+// completely exported. Each continues after its last exported envelope.
+func (cp *exportCheckpoint) resumeTargets() []exportTarget {
+	var targets []exportTarget
+	for _, s := range cp.Sources {
+		if s.Done {
+			continue
+		}
+
+		start := time.Unix(0, s.Start)
+		if s.LastTimestamp >= s.Start {
+			start = time.Unix(0, s.LastTimestamp+1)
+		}
+
+		targets = append(targets, exportTarget{
+			name:       s.Name,
+			sourceID:   s.SourceID,
+			start:      start,
+			checkpoint: s,
+		})
+	}
+
+	return targets
+}
+
+// readExportCheckpoint returns the checkpoint in the directory, or nil if
+// there is none.
+func readExportCheckpoint(dir string) (*exportCheckpoint, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, exportCheckpointFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &exportCheckpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+func writeExportCheckpoint(dir string, cp *exportCheckpoint) error {
+	data, err := marshalJSONIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, exportCheckpointFile), data)
+}
+
+func removeExportCheckpoint(dir string) error {
+	err := os.Remove(filepath.Join(dir, exportCheckpointFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,175 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+const exportEstimateSampleSize = 100
+
+type exportEstimate struct {
+	envelopes int64
+	bytes     int64
+	duration  time.Duration
+}
+
+// estimateExport writes the estimated number of envelopes, compressed size
+// and duration of exporting the targets without writing any files. Counts
+// come from Log Cache metadata and sizes from a sample of recent envelopes.
+func estimateExport(
+	ctx context.Context,
+	client *logcache.Client,
+	targets []exportTarget,
+	end time.Time,
+	readLimit int,
+	workers int,
+	style tableStyle,
+	w io.Writer,
+	log Logger,
+) {
+	meta, err := client.Meta(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read Meta information: %s", err)
+	}
+
+	tw := newTableWriter(w, style, true)
+	fmt.Fprintf(tw, "Source\tEnvelopes\tSize\tDuration\n")
+
+	var (
+		total   exportEstimate
+		longest time.Duration
+	)
+	for _, t := range targets {
+		e := estimateSource(ctx, client, t, meta[t.sourceID], end, readLimit, log)
+
+		total.envelopes += e.envelopes
+		total.bytes += e.bytes
+		total.duration += e.duration
+		if e.duration > longest {
+			longest = e.duration
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.name, formatCount(int(e.envelopes)), formatBytes(e.bytes), formatEstimatedDuration(e.duration))
+	}
+
+	// Sources are exported in parallel, but no faster than the slowest one.
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+	if workers > 0 {
+		total.duration /= time.Duration(workers)
+	}
+	if total.duration < longest {
+		total.duration = longest
+	}
+
+	if len(targets) > 1 {
+		fmt.Fprintf(tw, "Total\t%s\t%s\t%s\n", formatCount(int(total.envelopes)), formatBytes(total.bytes), formatEstimatedDuration(total.duration))
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nDry run: estimated from Log Cache metadata and up to %d sampled envelopes per source. Nothing was written.\n", exportEstimateSampleSize)
+}
+
+func estimateSource(
+	ctx context.Context,
+	client *logcache.Client,
+	t exportTarget,
+	m *logcache_v1.MetaInfo,
+	end time.Time,
+	readLimit int,
+	log Logger,
+) exportEstimate {
+	envelopes := estimateEnvelopes(m, t.start.UnixNano(), end.UnixNano())
+	if envelopes == 0 {
+		return exportEstimate{}
+	}
+
+	readStart := time.Now()
+	sample, err := client.Read(
+		ctx,
+		t.sourceID,
+		t.start,
+		logcache.WithEndTime(end),
+		logcache.WithLimit(exportEstimateSampleSize),
+		logcache.WithDescending(),
+	)
+	if err != nil {
+		log.Fatalf("Could not sample envelopes of %s: %s", t.name, err)
+	}
+	latency := time.Since(readStart)
+
+	var size int64
+	if len(sample) > 0 {
+		data, err := encodeExportChunk(sample)
+		if err != nil {
+			log.Fatalf("Could not sample envelopes of %s: %s", t.name, err)
+		}
+		size = int64(len(data)) * envelopes / int64(len(sample))
+	}
+
+	requests := (envelopes + int64(readLimit) - 1) / int64(readLimit)
+
+	return exportEstimate{
+		envelopes: envelopes,
+		bytes:     size,
+		duration:  time.Duration(requests) * latency,
+	}
+}
+
+// estimateEnvelopes scales the number of cached envelopes by the share of
+// the cached time range that overlaps the export window.
+func estimateEnvelopes(m *logcache_v1.MetaInfo, start, end int64) int64 {
+	if m == nil || m.Count == 0 {
+		return 0
+	}
+
+	oldest, newest := m.OldestTimestamp, m.NewestTimestamp
+	if newest <= oldest {
+		if oldest >= start && oldest <= end {
+			return m.Count
+		}
+		return 0
+	}
+
+	from, to := oldest, newest
+	if start > from {
+		from = start
+	}
+	if end < to {
+		to = end
+	}
+	if to < from {
+		return 0
+	}
+
+	return int64(float64(m.Count) * float64(to-from) / float64(newest-oldest))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+func formatEstimatedDuration(d time.Duration) string {
+	if d < time.Second {
+		return "<1s"
+	}
+
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,181 @@
+package cf
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const exportHeartbeatInterval = 5 * time.Second
+
+// exportReporter reports the progress of an export.
+type exportReporter interface {
+	update(i int, ts int64, envelopes int, end time.Time)
+	retry(i int, attempt int, err error)
+	done(i int)
+	finish(interrupted bool)
+}
+
+type noExportProgress struct{}
+
+func (noExportProgress) update(int, int64, int, time.Time) {}
+func (noExportProgress) retry(int, int, error)             {}
+func (noExportProgress) done(int)                          {}
+func (noExportProgress) finish(bool)                       {}
+
+type exportEvent struct {
+	SchemaVersion    int       `json:"schema_version"`
+	Event            string    `json:"event"`
+	Time             time.Time `json:"time"`
+	Source           string    `json:"source,omitempty"`
+	Percent          *int      `json:"percent,omitempty"`
+	Attempt          int       `json:"attempt,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	Envelopes        int       `json:"envelopes"`
+	SourcesCompleted int       `json:"sources_completed"`
+	Sources          int       `json:"sources"`
+}
+
+// exportEvents writes one JSON progress event per line for automation to
+// consume. Besides events for each batch, retry and completed source, a
+// heartbeat with the totals is written periodically until the export
+// finishes.
+type exportEvents struct {
+	w    io.Writer
+	stop chan struct{}
+
+	mu        sync.Mutex
+	names     []string
+	starts    []int64
+	envelopes []int
+	completed []bool
+}
+
+func newExportEvents(w io.Writer, targets []exportTarget) *exportEvents {
+	e := &exportEvents{
+		w:         w,
+		stop:      make(chan struct{}),
+		names:     make([]string, len(targets)),
+		starts:    make([]int64, len(targets)),
+		envelopes: make([]int, len(targets)),
+		completed: make([]bool, len(targets)),
+	}
+
+	for i, t := range targets {
+		e.names[i] = t.name
+		e.starts[i] = t.start.UnixNano()
+	}
+
+	e.mu.Lock()
+	e.write(exportEvent{Event: "start"})
+	e.mu.Unlock()
+
+	go e.heartbeat()
+
+	return e
+}
+
+func (e *exportEvents) heartbeat() {
+	t := time.NewTicker(exportHeartbeatInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-t.C:
+			e.mu.Lock()
+			e.write(exportEvent{Event: "heartbeat"})
+			e.mu.Unlock()
+		}
+	}
+}
+
+func (e *exportEvents) update(i int, ts int64, envelopes int, end time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.envelopes[i] = envelopes
+
+	percent := 0
+	if window := end.UnixNano() - e.starts[i]; window > 0 && ts > e.starts[i] {
+		percent = int(float64(ts-e.starts[i]) / float64(window) * 100)
+		if percent > 100 {
+			percent = 100
+		}
+	}
+
+	e.write(exportEvent{
+		Event:     "progress",
+		Source:    e.names[i],
+		Percent:   &percent,
+		Envelopes: envelopes,
+	})
+}
+
+func (e *exportEvents) retry(i int, attempt int, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.write(exportEvent{
+		Event:     "retry",
+		Source:    e.names[i],
+		Attempt:   attempt,
+		Error:     err.Error(),
+		Envelopes: e.envelopes[i],
+	})
+}
+
+func (e *exportEvents) done(i int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.completed[i] = true
+
+	percent := 100
+	e.write(exportEvent{
+		Event:     "source_done",
+		Source:    e.names[i],
+		Percent:   &percent,
+		Envelopes: e.envelopes[i],
+	})
+}
+
+func (e *exportEvents) finish(interrupted bool) {
+	close(e.stop)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	event := "done"
+	if interrupted {
+		event = "interrupted"
+	}
+	e.write(exportEvent{Event: event})
+}
+
+// write fills in the totals and writes the event. The caller holds the
+// lock. Per-source events carry that source's envelopes instead of the
+// total.
+func (e *exportEvents) write(event exportEvent) {
+	event.SchemaVersion = schemaVersion
+	event.Time = time.Now().UTC()
+	event.Sources = len(e.names)
+
+	var total int
+	for i, c := range e.completed {
+		if c {
+			event.SourcesCompleted++
+		}
+		total += e.envelopes[i]
+	}
+	if event.Source == "" {
+		event.Envelopes = total
+	}
+
+	line, err := marshalJSON(event)
+	if err != nil {
+		return
+	}
+	e.w.Write(append(line, '\n'))
+}
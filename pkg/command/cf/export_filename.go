@@ -0,0 +1,83 @@
+package cf
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultExportFilenameTemplate = "{{.SourceID}}/{{.ChunkStart}}.ndjson.gz"
+
+// exportFilename holds the values available to --filename-template. Times
+// are in UTC.
+type exportFilename struct {
+	AppName    string
+	SourceID   string
+	Date       string
+	Hour       string
+	ChunkStart int64
+	ChunkEnd   int64
+}
+
+func newExportFilename(t exportTarget, chunk exportChunk) exportFilename {
+	start := time.Unix(0, chunk.Start).UTC()
+
+	return exportFilename{
+		AppName:    t.name,
+		SourceID:   t.sourceID,
+		Date:       start.Format("2006-01-02"),
+		Hour:       start.Format("15"),
+		ChunkStart: chunk.Start,
+		ChunkEnd:   chunk.End,
+	}
+}
+
+// parseExportFilenameTemplate parses the template and checks that it
+// renders a usable path.
+func parseExportFilenameTemplate(s string) (*template.Template, error) {
+	t, err := template.New("FilenameTemplate").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = renderExportFilename(t, exportFilename{
+		AppName:    "app",
+		SourceID:   "source-id",
+		Date:       "2006-01-02",
+		Hour:       "15",
+		ChunkStart: 1,
+		ChunkEnd:   2,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// renderExportFilename renders the path of a chunk file relative to the
+// export directory, using forward slashes.
+func renderExportFilename(t *template.Template, v exportFilename) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, v); err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSpace(filepath.ToSlash(buf.String()))
+	clean := path.Clean(name)
+
+	switch {
+	case name == "":
+		return "", fmt.Errorf("rendered an empty file name")
+	case path.IsAbs(clean) || filepath.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../"):
+		return "", fmt.Errorf("rendered %q, which is not inside the export directory", name)
+	case clean == exportManifestFile || clean == exportCheckpointFile:
+		return "", fmt.Errorf("rendered %q, which is reserved", name)
+	}
+
+	return clean, nil
+}
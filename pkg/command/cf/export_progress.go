@@ -0,0 +1,126 @@
+package cf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const progressBarWidth = 30
+
+// exportProgress draws a progress bar per source. Progress is the share of
+// the source's export window covered so far. The bars are redrawn in place
+// by moving the cursor back up to the first bar. In append-only mode a line
+// is written instead each time a source completes another 10%, so screen
+// readers can follow the progress.
+type exportProgress struct {
+	w          io.Writer
+	width      int
+	appendOnly bool
+
+	mu        sync.Mutex
+	names     []string
+	starts    []int64
+	fractions []float64
+	envelopes []int
+	reported  []int
+	drawn     bool
+}
+
+func newExportProgress(w io.Writer, targets []exportTarget, appendOnly bool) *exportProgress {
+	p := &exportProgress{
+		w:          w,
+		appendOnly: appendOnly,
+		names:      make([]string, len(targets)),
+		starts:     make([]int64, len(targets)),
+		fractions:  make([]float64, len(targets)),
+		envelopes:  make([]int, len(targets)),
+		reported:   make([]int, len(targets)),
+	}
+
+	for i, t := range targets {
+		p.names[i] = t.name
+		p.starts[i] = t.start.UnixNano()
+		if len(t.name) > p.width {
+			p.width = len(t.name)
+		}
+	}
+
+	return p
+}
+
+// update records that the source has been exported up to the given
+// timestamp.
+func (p *exportProgress) update(i int, ts int64, envelopes int, end time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if window := end.UnixNano() - p.starts[i]; window > 0 && ts > p.starts[i] {
+		f := float64(ts-p.starts[i]) / float64(window)
+		if f > 1 {
+			f = 1
+		}
+		if f > p.fractions[i] {
+			p.fractions[i] = f
+		}
+	}
+	p.envelopes[i] = envelopes
+
+	p.draw(i)
+}
+
+// retry leaves the bars as they are, the bar of the source continues once
+// the read succeeds.
+func (p *exportProgress) retry(int, int, error) {}
+
+// done marks the source as completely exported.
+func (p *exportProgress) done(i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fractions[i] = 1
+	p.draw(i)
+}
+
+// finish leaves the bars as they were last drawn.
+func (p *exportProgress) finish(bool) {}
+
+// draw redraws the bars after the given source made progress.
+func (p *exportProgress) draw(source int) {
+	if p.appendOnly {
+		p.report(source)
+		return
+	}
+
+	if p.drawn {
+		fmt.Fprintf(p.w, "\x1b[%dA", len(p.names))
+	}
+	p.drawn = true
+
+	for i, name := range p.names {
+		filled := int(p.fractions[i] * progressBarWidth)
+		fmt.Fprintf(
+			p.w,
+			"\r\x1b[2K%-*s [%s%s] %3d%%  %s envelopes\n",
+			p.width,
+			name,
+			strings.Repeat("#", filled),
+			strings.Repeat("-", progressBarWidth-filled),
+			int(p.fractions[i]*100),
+			formatCount(p.envelopes[i]),
+		)
+	}
+}
+
+// report writes a line for the source when it completed another 10%.
+func (p *exportProgress) report(i int) {
+	percent := int(p.fractions[i]*100) / 10 * 10
+	if percent <= p.reported[i] {
+		return
+	}
+	p.reported[i] = percent
+
+	fmt.Fprintf(p.w, "%s: %d%% exported, %s envelopes\n", p.names[i], percent, formatCount(p.envelopes[i]))
+}
@@ -0,0 +1,840 @@
+package cf_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		startTime  time.Time
+		dir        string
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+
+		var err error
+		dir, err = ioutil.TempDir("", "export")
+		Expect(err).ToNot(HaveOccurred())
+
+		httpClient.responseBody = []string{
+			emptyMetaResponseBody(),
+			logResponseBody(startTime, "first", "second", "third"),
+			emptyResponseBody(),
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("writes envelopes to chunk files and a manifest", func() {
+		cf.Export(
+			context.Background(),
+			cliConn,
+			[]string{"--dir", dir, "--chunk-size", "2", "app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			fmt.Sprintf("Exported 3 envelopes for app-name in 2 chunks to %s.", dir),
+		}))
+
+		m := readManifest(dir)
+		source := m.Sources["app-guid"]
+		Expect(source.Name).To(Equal("app-name"))
+		Expect(source.LastTimestamp).To(Equal(startTime.Add(2 * time.Second).UnixNano()))
+		Expect(source.Chunks).To(HaveLen(2))
+
+		var payloads []string
+		for _, c := range source.Chunks {
+			payloads = append(payloads, readChunkPayloads(filepath.Join(dir, c.File))...)
+		}
+		Expect(payloads).To(ConsistOf("first", "second", "third"))
+
+		Expect(source.Chunks[0].File).To(Equal(
+			fmt.Sprintf("app-guid/%d.ndjson.gz", startTime.Add(time.Second).UnixNano()),
+		))
+		Expect(source.Chunks[0].Envelopes).To(Equal(2))
+	})
+
+	It("stamps the manifest and every envelope with the schema version", func() {
+		cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+
+		m := readManifest(dir)
+		Expect(m.SchemaVersion).To(Equal(1))
+
+		f, err := os.Open(filepath.Join(dir, m.Sources["app-guid"].Chunks[0].File))
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		Expect(err).ToNot(HaveOccurred())
+
+		var lines int
+		s := bufio.NewScanner(gz)
+		for s.Scan() {
+			Expect(s.Text()).To(HavePrefix(`{"schema_version":1,"instance_id":"0","log":`))
+			lines++
+		}
+		Expect(lines).To(Equal(3))
+	})
+
+	It("exports the requested window", func() {
+		cf.Export(
+			context.Background(),
+			cliConn,
+			[]string{"--dir", dir, "--last", "2h", "app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(httpClient.requestURLs[0]).To(HaveSuffix("/v1/meta"))
+		requestURL, err := url.Parse(httpClient.requestURLs[1])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requestURL.Path).To(HaveSuffix("/v1/read/app-guid"))
+
+		start, err := strconv.ParseInt(requestURL.Query().Get("start_time"), 10, 64)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(start).To(BeNumerically("~", time.Now().Add(-2*time.Hour).UnixNano(), int64(time.Second)))
+	})
+
+	It("warns when the window starts before the cache retention", func() {
+		oldest := time.Now().Add(-30 * time.Minute)
+		httpClient.responseBody[0] = evictedMetaResponse("app-guid", oldest)
+
+		cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+
+		Expect(logger.printfMessages).To(ContainElement(HavePrefix(
+			"Warning: The first 30m0s of the requested window for app-name have been evicted from Log Cache.",
+		)))
+		Expect(readManifest(dir).Sources["app-guid"].Chunks).ToNot(BeEmpty())
+	})
+
+	It("fatally logs with --strict-window when the window was evicted", func() {
+		httpClient.responseBody[0] = evictedMetaResponse("app-guid", time.Now().Add(-30*time.Minute))
+
+		Expect(func() {
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--strict-window", "app-name"}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(HavePrefix("The first 30m0s of the requested window for app-name have been evicted"))
+		_, err := os.Stat(filepath.Join(dir, "manifest.json"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("only fetches envelopes newer than a prior export", func() {
+		cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+
+		httpClient = newStubHTTPClient()
+		httpClient.responseBody = []string{
+			emptyMetaResponseBody(),
+			logResponseBody(startTime.Add(10*time.Second), "fourth"),
+			emptyResponseBody(),
+		}
+		writer = &stubWriter{}
+		cliConn.cliCommandArgs = nil
+
+		cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+
+		requestURL, err := url.Parse(httpClient.requestURLs[1])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requestURL.Query().Get("start_time")).To(Equal(
+			strconv.FormatInt(startTime.Add(2*time.Second).UnixNano()+1, 10),
+		))
+
+		Expect(writer.lines()).To(Equal([]string{
+			fmt.Sprintf("Appending to existing export of app-name after %s.", startTime.Add(2*time.Second).Format(time.RFC3339Nano)),
+			fmt.Sprintf("Exported 1 envelopes for app-name in 1 chunks to %s.", dir),
+		}))
+
+		source := readManifest(dir).Sources["app-guid"]
+		Expect(source.Chunks).To(HaveLen(2))
+		Expect(source.LastTimestamp).To(Equal(startTime.Add(10 * time.Second).UnixNano()))
+		Expect(readChunkPayloads(filepath.Join(dir, source.Chunks[1].File))).To(Equal([]string{"fourth"}))
+	})
+
+	It("fetches the whole window when a prior export is older than it", func() {
+		manifest := fmt.Sprintf(`{"sources":{"app-guid":{"name":"app-name","last_timestamp":%d}}}`, time.Now().Add(-3*time.Hour).UnixNano())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0644)).To(Succeed())
+
+		cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+
+		requestURL, err := url.Parse(httpClient.requestURLs[1])
+		Expect(err).ToNot(HaveOccurred())
+		start, err := strconv.ParseInt(requestURL.Query().Get("start_time"), 10, 64)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(start).To(BeNumerically("~", time.Now().Add(-time.Hour).UnixNano(), int64(time.Second)))
+		Expect(writer.lines()).To(HaveLen(1))
+	})
+
+	It("reports when there are no new envelopes", func() {
+		httpClient.responseBody = []string{emptyMetaResponseBody(), emptyResponseBody()}
+
+		cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+
+		Expect(writer.lines()).To(Equal([]string{"No new envelopes for app-name."}))
+	})
+
+	It("exports every source matching a pattern", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("router-b", "doppler", "router-a"),
+			emptyMetaResponseBody(),
+			logResponseBody(startTime, "a"),
+			emptyResponseBody(),
+			logResponseBody(startTime, "b"),
+			emptyResponseBody(),
+		}
+
+		cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--workers", "1", "router*"}, httpClient, logger, writer)
+
+		Expect(writer.lines()).To(Equal([]string{
+			fmt.Sprintf("Exported 1 envelopes for router-a in 1 chunks to %s.", dir),
+			fmt.Sprintf("Exported 1 envelopes for router-b in 1 chunks to %s.", dir),
+		}))
+		Expect(readManifest(dir).Sources).To(HaveKey("router-a"))
+		Expect(readManifest(dir).Sources).To(HaveKey("router-b"))
+	})
+
+	It("uses the name as source ID when it is not an app", func() {
+		cliConn.cliCommandResult = [][]string{{""}}
+		cliConn.cliCommandErr = []error{fmt.Errorf("App doppler not found")}
+
+		cf.Export(context.Background(), cliConn, []string{"--dir", dir, "doppler"}, httpClient, logger, writer)
+
+		Expect(httpClient.requestURLs[1]).To(ContainSubstring("/v1/read/doppler"))
+		Expect(readManifest(dir).Sources).To(HaveKey("doppler"))
+	})
+
+	It("scrubs envelopes with the given scrubber", func() {
+		httpClient.responseBody = []string{
+			emptyMetaResponseBody(),
+			logResponseBody(startTime, "login password=hunter2"),
+			emptyResponseBody(),
+		}
+		scrubber := writeScrubFile("fields: [password]\n")
+
+		cf.Export(
+			context.Background(),
+			cliConn,
+			[]string{"--dir", dir, "app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithExportScrubber(scrubber),
+		)
+
+		source := readManifest(dir).Sources["app-guid"]
+		Expect(readChunkPayloads(filepath.Join(dir, source.Chunks[0].File))).To(Equal([]string{"login password=****"}))
+	})
+
+	It("fatally logs without --dir", func() {
+		Expect(func() {
+			cf.Export(context.Background(), cliConn, []string{"app-name"}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--dir is required."))
+	})
+
+	It("fatally logs for an invalid chunk size", func() {
+		Expect(func() {
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--chunk-size", "0", "app-name"}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--chunk-size must be greater than 0."))
+	})
+
+	It("fatally logs for an invalid manifest", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.json"), []byte("{"), 0644)).To(Succeed())
+
+		Expect(func() {
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(HavePrefix("Could not read export manifest: "))
+	})
+
+	It("fatally logs for a manifest with a newer schema version", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"schema_version":2,"sources":{}}`), 0644)).To(Succeed())
+
+		Expect(func() {
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Could not read export manifest: schema version 2 is newer than the supported version 1"))
+	})
+
+	It("fatally logs with the wrong number of arguments", func() {
+		Expect(func() {
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Expected at least 1 argument, got 0."))
+	})
+
+	Context("with a filename template", func() {
+		It("names chunk files with the template", func() {
+			cf.Export(
+				context.Background(),
+				cliConn,
+				[]string{"--dir", dir, "--filename-template", "{{.AppName}}/{{.Date}}/{{.Hour}}/{{.ChunkStart}}-{{.ChunkEnd}}.ndjson.gz", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			utc := startTime.UTC()
+			file := fmt.Sprintf(
+				"app-name/%s/%s/%d-%d.ndjson.gz",
+				utc.Format("2006-01-02"),
+				utc.Format("15"),
+				startTime.UnixNano(),
+				startTime.Add(2*time.Second).UnixNano(),
+			)
+
+			source := readManifest(dir).Sources["app-guid"]
+			Expect(source.Chunks).To(HaveLen(1))
+			Expect(source.Chunks[0].File).To(Equal(file))
+			Expect(readChunkPayloads(filepath.Join(dir, file))).To(ConsistOf("first", "second", "third"))
+		})
+
+		It("fatally logs for an invalid template", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--filename-template", "{{.Unknown}}", "app-name"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(HavePrefix("Invalid --filename-template: "))
+		})
+
+		It("fatally logs for a template outside the export directory", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--filename-template", "../{{.ChunkStart}}.ndjson.gz", "app-name"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal(`Invalid --filename-template: rendered "../1.ndjson.gz", which is not inside the export directory`))
+		})
+
+		It("fatally logs for a template that overwrites the manifest", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--filename-template", "./manifest.json", "app-name"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal(`Invalid --filename-template: rendered "./manifest.json", which is reserved`))
+		})
+	})
+
+	Context("with --dry-run", func() {
+		metaResponse := func(count int, oldest, newest time.Time) string {
+			return fmt.Sprintf(`{"meta": {"app-guid": {
+				"count": "%d",
+				"oldestTimestamp": "%d",
+				"newestTimestamp": "%d"
+			}}}`, count, oldest.UnixNano(), newest.UnixNano())
+		}
+
+		It("estimates the export without writing files", func() {
+			exportDir := filepath.Join(dir, "new")
+			httpClient.responseBody = []string{
+				metaResponse(500, time.Now().Add(-30*time.Minute), time.Now().Add(-10*time.Minute)),
+				logResponseBody(startTime, "first", "second", "third"),
+			}
+
+			cf.Export(context.Background(), cliConn, []string{"--dir", exportDir, "--dry-run", "app-name"}, httpClient, logger, writer)
+
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			Expect(httpClient.requestURLs[0]).To(HaveSuffix("/v1/meta"))
+			Expect(httpClient.requestURLs[1]).To(ContainSubstring("limit=100"))
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(4))
+			Expect(lines[0]).To(MatchRegexp(`^Source\s+Envelopes\s+Size\s+Duration$`))
+			Expect(lines[1]).To(MatchRegexp(`^app-name\s+500\s+\d+\.\d KB\s+<1s$`))
+			Expect(lines[3]).To(Equal("Dry run: estimated from Log Cache metadata and up to 100 sampled envelopes per source. Nothing was written."))
+
+			_, err := os.Stat(exportDir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("scales the count to the part of the cache inside the window", func() {
+			httpClient.responseBody = []string{
+				metaResponse(3000, time.Now().Add(-3*time.Hour), time.Now()),
+				logResponseBody(startTime, "first"),
+			}
+
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--dry-run", "app-name"}, httpClient, logger, writer)
+
+			Expect(writer.lines()[1]).To(MatchRegexp(`^app-name\s+(999|1000)\s+`))
+		})
+
+		It("reports sources without cached envelopes", func() {
+			httpClient.responseBody = []string{`{"meta": {}}`}
+
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--dry-run", "app-name"}, httpClient, logger, writer)
+
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+			Expect(writer.lines()[1]).To(MatchRegexp(`^app-name\s+0\s+0 B\s+<1s$`))
+		})
+	})
+
+	Context("with a checkpoint", func() {
+		It("removes the checkpoint after a successful export", func() {
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+
+			_, err := os.Stat(filepath.Join(dir, "checkpoint.json"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("keeps the progress of an interrupted export", func() {
+			httpClient.responseBody = []string{
+				emptyMetaResponseBody(),
+				logResponseBody(startTime, "first", "second"),
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			cf.Export(
+				ctx,
+				cliConn,
+				[]string{"--dir", dir, "--chunk-size", "1", "app-name"},
+				&cancelingHTTPClient{c: httpClient, cancel: cancel},
+				logger,
+				writer,
+			)
+
+			Expect(writer.lines()).To(Equal([]string{"Export interrupted. Run again with --resume to continue."}))
+
+			data, err := ioutil.ReadFile(filepath.Join(dir, "checkpoint.json"))
+			Expect(err).ToNot(HaveOccurred())
+
+			var cp struct {
+				End     int64 `json:"end"`
+				Sources []struct {
+					Name          string   `json:"name"`
+					SourceID      string   `json:"source_id"`
+					LastTimestamp int64    `json:"last_timestamp"`
+					Chunks        []string `json:"chunks"`
+					Done          bool     `json:"done"`
+				} `json:"sources"`
+			}
+			Expect(json.Unmarshal(data, &cp)).To(Succeed())
+			Expect(cp.End).To(BeNumerically("~", time.Now().UnixNano(), int64(time.Second)))
+			Expect(cp.Sources).To(HaveLen(1))
+			Expect(cp.Sources[0].SourceID).To(Equal("app-guid"))
+			Expect(cp.Sources[0].LastTimestamp).To(Equal(startTime.Add(time.Second).UnixNano()))
+			Expect(cp.Sources[0].Chunks).To(HaveLen(2))
+			Expect(cp.Sources[0].Done).To(BeFalse())
+		})
+
+		It("resumes the sources that were not completely exported", func() {
+			end := time.Now().Add(-30 * time.Second).UnixNano()
+			checkpoint := fmt.Sprintf(`{
+				"end": %d,
+				"sources": [
+					{"name": "alpha", "source_id": "alpha-guid", "start": 1, "last_timestamp": 5, "done": true},
+					{"name": "beta", "source_id": "beta-guid", "start": 1, "last_timestamp": %d}
+				]
+			}`, end, startTime.UnixNano())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "checkpoint.json"), []byte(checkpoint), 0644)).To(Succeed())
+			httpClient.responseBody = []string{
+				emptyMetaResponseBody(),
+				logResponseBody(startTime.Add(time.Second), "beta 2"),
+				emptyResponseBody(),
+			}
+
+			cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--resume"}, httpClient, logger, writer)
+
+			Expect(cliConn.cliCommandArgs).To(BeEmpty())
+			requestURL, err := url.Parse(httpClient.requestURLs[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Path).To(HaveSuffix("/v1/read/beta-guid"))
+			Expect(requestURL.Query().Get("start_time")).To(Equal(strconv.FormatInt(startTime.UnixNano()+1, 10)))
+			Expect(requestURL.Query().Get("end_time")).To(Equal(strconv.FormatInt(end, 10)))
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("Resuming export of beta from %s.", time.Unix(0, startTime.UnixNano()+1).Format(time.RFC3339Nano)),
+				fmt.Sprintf("Exported 1 envelopes for beta in 1 chunks to %s.", dir),
+			}))
+			Expect(readManifest(dir).Sources).To(HaveKey("beta-guid"))
+
+			_, err = os.Stat(filepath.Join(dir, "checkpoint.json"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("fatally logs when there is nothing to resume", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--resume"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal(fmt.Sprintf("No interrupted export to resume in %s.", dir)))
+		})
+
+		It("fatally logs when starting a new export over an interrupted one", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "checkpoint.json"), []byte(`{"sources":[]}`), 0644)).To(Succeed())
+
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal(fmt.Sprintf("An interrupted export exists in %s. Use --resume to continue it.", dir)))
+		})
+
+		It("fatally logs when --resume is used with sources", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--resume", "app-name"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--resume cannot be used with source arguments or --scope."))
+		})
+	})
+
+	Context("with multiple sources", func() {
+		BeforeEach(func() {
+			cliConn.cliCommandResult = [][]string{{"alpha-guid"}, {"beta-guid"}}
+			httpClient.responseBody = []string{
+				emptyMetaResponseBody(),
+				logResponseBody(startTime, "alpha 1", "alpha 2"),
+				emptyResponseBody(),
+				logResponseBody(startTime, "beta 1"),
+				emptyResponseBody(),
+			}
+		})
+
+		It("exports every source", func() {
+			cf.Export(
+				context.Background(),
+				cliConn,
+				[]string{"--dir", dir, "--workers", "1", "alpha", "beta"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("Exported 2 envelopes for alpha in 1 chunks to %s.", dir),
+				fmt.Sprintf("Exported 1 envelopes for beta in 1 chunks to %s.", dir),
+			}))
+
+			m := readManifest(dir)
+			Expect(m.Sources).To(HaveLen(2))
+			Expect(readChunkPayloads(filepath.Join(dir, m.Sources["alpha-guid"].Chunks[0].File))).To(ConsistOf("alpha 1", "alpha 2"))
+			Expect(readChunkPayloads(filepath.Join(dir, m.Sources["beta-guid"].Chunks[0].File))).To(Equal([]string{"beta 1"}))
+		})
+
+		It("exports sources in parallel", func() {
+			httpClient.responseBody = []string{emptyMetaResponseBody()}
+			for i := 0; i < 4; i++ {
+				httpClient.responseBody = append(httpClient.responseBody, emptyResponseBody())
+			}
+
+			cf.Export(
+				context.Background(),
+				cliConn,
+				[]string{"--dir", dir, "--workers", "2", "alpha", "beta"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(httpClient.requestURLs[0]).To(HaveSuffix("/v1/meta"))
+			Expect(httpClient.requestURLs[1:]).To(ConsistOf(
+				ContainSubstring("/v1/read/alpha-guid"),
+				ContainSubstring("/v1/read/beta-guid"),
+			))
+			Expect(writer.lines()).To(Equal([]string{
+				"No new envelopes for alpha.",
+				"No new envelopes for beta.",
+			}))
+		})
+
+		It("exports every app in the space with --scope applications", func() {
+			cliConn.spaceName = "space"
+			cliConn.spaceGUID = "space-guid"
+			cliConn.cliCommandResult = [][]string{{`{"resources": [
+				{"guid": "alpha-guid", "name": "alpha"},
+				{"guid": "beta-guid", "name": "beta"}
+			]}`}}
+
+			cf.Export(
+				context.Background(),
+				cliConn,
+				[]string{"--dir", dir, "--workers", "1", "--scope", "applications"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(cliConn.cliCommandArgs).To(Equal([][]string{{
+				"curl",
+				"/v3/apps?space_guids=space-guid&order_by=name&per_page=5000",
+			}}))
+			Expect(readManifest(dir).Sources).To(HaveLen(2))
+			Expect(writer.lines()).To(HaveLen(2))
+		})
+
+		It("draws a progress bar per source", func() {
+			cf.Export(
+				context.Background(),
+				cliConn,
+				[]string{"--dir", dir, "--workers", "1", "alpha", "beta"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithExportProgress(),
+			)
+
+			output := string(writer.bytes)
+			Expect(output).To(ContainSubstring("\x1b[2A"))
+			Expect(output).To(ContainSubstring("alpha [##############################] 100%  2 envelopes"))
+			Expect(output).To(ContainSubstring("beta  [##############################] 100%  1 envelopes"))
+		})
+
+		It("writes a line per 10% of progress instead of redrawing with --accessible", func() {
+			cf.Export(
+				context.Background(),
+				cliConn,
+				[]string{"--dir", dir, "--workers", "1", "--accessible", "alpha", "beta"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithExportProgress(),
+			)
+
+			output := string(writer.bytes)
+			Expect(output).ToNot(ContainSubstring("\x1b["))
+			Expect(output).ToNot(ContainSubstring("\r"))
+			Expect(writer.lines()).To(ContainElement("alpha: 100% exported, 2 envelopes"))
+			Expect(writer.lines()).To(ContainElement("beta: 100% exported, 1 envelopes"))
+		})
+
+		It("writes progress events with --progress json", func() {
+			var events bytes.Buffer
+			cf.Export(
+				context.Background(),
+				cliConn,
+				[]string{"--dir", dir, "--workers", "1", "--progress", "json", "alpha", "beta"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithExportProgress(),
+				cf.WithExportEventWriter(&events),
+			)
+
+			type event struct {
+				Event            string `json:"event"`
+				Source           string `json:"source"`
+				Percent          *int   `json:"percent"`
+				Envelopes        int    `json:"envelopes"`
+				SourcesCompleted int    `json:"sources_completed"`
+				Sources          int    `json:"sources"`
+			}
+			var got []event
+			for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+				var e event
+				Expect(json.Unmarshal([]byte(line), &e)).To(Succeed())
+				got = append(got, e)
+			}
+
+			Expect(got[0]).To(Equal(event{Event: "start", Sources: 2}))
+			Expect(got[len(got)-1]).To(Equal(event{Event: "done", Envelopes: 3, SourcesCompleted: 2, Sources: 2}))
+
+			var done []string
+			for _, e := range got {
+				if e.Event == "source_done" {
+					Expect(*e.Percent).To(Equal(100))
+					done = append(done, fmt.Sprintf("%s:%d", e.Source, e.Envelopes))
+				}
+			}
+			Expect(done).To(Equal([]string{"alpha:2", "beta:1"}))
+			Expect(string(writer.bytes)).ToNot(ContainSubstring("\x1b["))
+		})
+
+		It("writes an event per retry of a failed read with --progress json", func() {
+			var events bytes.Buffer
+			cf.Export(
+				context.Background(),
+				cliConn,
+				[]string{"--dir", dir, "--workers", "1", "--progress", "json", "alpha", "beta"},
+				&failingReadsHTTPClient{stubHTTPClient: httpClient, failures: 2},
+				logger,
+				writer,
+				cf.WithExportEventWriter(&events),
+			)
+
+			type event struct {
+				Event   string `json:"event"`
+				Source  string `json:"source"`
+				Attempt int    `json:"attempt"`
+				Error   string `json:"error"`
+			}
+			var retries []event
+			for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+				var e event
+				Expect(json.Unmarshal([]byte(line), &e)).To(Succeed())
+				if e.Event == "retry" {
+					retries = append(retries, e)
+				}
+			}
+
+			Expect(retries).To(Equal([]event{
+				{Event: "retry", Source: "alpha", Attempt: 1, Error: "unexpected status code 503"},
+				{Event: "retry", Source: "alpha", Attempt: 2, Error: "unexpected status code 503"},
+			}))
+			Expect(writer.lines()).To(ContainElement(ContainSubstring("Exported 2 envelopes for alpha")))
+		})
+
+		It("fatally logs for an unknown progress format", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--progress", "xml", "alpha"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--progress must be 'bar', 'json' or 'none'."))
+		})
+
+		It("fatally logs for an unknown scope", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--scope", "services"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--scope must be 'applications'."))
+		})
+
+		It("fatally logs when --scope is used with sources", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--scope", "applications", "alpha"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--scope cannot be used with source arguments."))
+		})
+
+		It("fatally logs for an invalid worker count", func() {
+			Expect(func() {
+				cf.Export(context.Background(), cliConn, []string{"--dir", dir, "--workers", "0", "alpha"}, httpClient, logger, writer)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--workers must be greater than 0."))
+		})
+	})
+})
+
+type testExportManifest struct {
+	SchemaVersion int `json:"schema_version"`
+	Sources       map[string]struct {
+		Name          string `json:"name"`
+		LastTimestamp int64  `json:"last_timestamp"`
+		Chunks        []struct {
+			File      string `json:"file"`
+			Envelopes int    `json:"envelopes"`
+		} `json:"chunks"`
+	} `json:"sources"`
+}
+
+// exportTestArchive exports the response body as the envelopes of app-name
+// to a new directory and returns the directory.
+func exportTestArchive(responseBody string) string {
+	dir, err := ioutil.TempDir("", "archive")
+	Expect(err).ToNot(HaveOccurred())
+
+	httpClient := newStubHTTPClient()
+	httpClient.responseBody = []string{emptyMetaResponseBody(), responseBody, emptyResponseBody()}
+	cliConn := newStubCliConnection()
+	cliConn.cliCommandResult = [][]string{{"app-guid"}}
+
+	cf.Export(context.Background(), cliConn, []string{"--dir", dir, "app-name"}, httpClient, &stubLogger{}, &stubWriter{})
+
+	return dir
+}
+
+func readManifest(dir string) testExportManifest {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	Expect(err).ToNot(HaveOccurred())
+
+	var m testExportManifest
+	Expect(json.Unmarshal(data, &m)).To(Succeed())
+
+	return m
+}
+
+func readChunkPayloads(path string) []string {
+	f, err := os.Open(path)
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	Expect(err).ToNot(HaveOccurred())
+
+	var payloads []string
+	s := bufio.NewScanner(gz)
+	for s.Scan() {
+		var e struct {
+			Log struct {
+				Payload []byte `json:"payload"`
+			} `json:"log"`
+		}
+		Expect(json.Unmarshal(s.Bytes(), &e)).To(Succeed())
+		payloads = append(payloads, string(e.Log.Payload))
+	}
+	Expect(s.Err()).ToNot(HaveOccurred())
+
+	return payloads
+}
+
+// cancelingHTTPClient cancels a context after the first read response.
+type cancelingHTTPClient struct {
+	c      *stubHTTPClient
+	cancel context.CancelFunc
+}
+
+func (c *cancelingHTTPClient) Do(r *http.Request) (*http.Response, error) {
+	if strings.Contains(r.URL.Path, "/v1/read/") {
+		defer c.cancel()
+	}
+	return c.c.Do(r)
+}
+
+// failingReadsHTTPClient fails the first reads of envelopes with a 503
+// before passing requests on to the stub.
+type failingReadsHTTPClient struct {
+	*stubHTTPClient
+	failures int
+}
+
+func (c *failingReadsHTTPClient) Do(r *http.Request) (*http.Response, error) {
+	if c.failures > 0 && strings.HasPrefix(r.URL.Path, "/v1/read/") {
+		c.failures--
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+
+	return c.stubHTTPClient.Do(r)
+}
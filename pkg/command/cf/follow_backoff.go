@@ -0,0 +1,57 @@
+package cf
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	followPollInterval = 250 * time.Millisecond
+	followMaxBackoff   = 30 * time.Second
+)
+
+// followBackoff keeps a followed walk alive. Empty reads are polled at a
+// fixed interval, while failed reads are retried after an exponentially
+// growing delay with jitter, so an unavailable Log Cache or gorouter isn't
+// hammered by every follower at once. The walk resumes from the last
+// envelope it saw, so nothing is skipped while retrying.
+type followBackoff struct {
+	ctx     context.Context
+	attempt uint
+}
+
+func newFollowBackoff(ctx context.Context) *followBackoff {
+	return &followBackoff{ctx: ctx}
+}
+
+func (b *followBackoff) OnErr(error) bool {
+	delay := followPollInterval << b.attempt
+	if delay <= 0 || delay > followMaxBackoff {
+		delay = followMaxBackoff
+	} else {
+		b.attempt++
+	}
+
+	// Wait between half and all of the delay.
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	return b.wait(delay)
+}
+
+func (b *followBackoff) OnEmpty() bool {
+	return b.wait(followPollInterval)
+}
+
+func (b *followBackoff) Reset() {
+	b.attempt = 0
+}
+
+func (b *followBackoff) wait(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-b.ctx.Done():
+		return false
+	}
+}
@@ -2,8 +2,10 @@ package cf
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
+	"regexp"
 	"sort"
 	"strings"
 	"text/template"
@@ -11,18 +13,24 @@ import (
 
 	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
 )
 
 const (
 	prettyFormat formatterKind = iota
 	jsonFormat
 	templateFormat
+	tableFormat
+	ndjsonFormat
+	logfmtFormat
+	syslogFormat
 )
 
 const (
 	appHeaderFormat     = "Retrieving logs for app %s in org %s / space %s as %s..."
 	serviceHeaderFormat = "Retrieving logs for service %s in org %s / space %s as %s..."
 	sourceHeaderFormat  = "Retrieving logs for source %s as %s..."
+	spaceHeaderFormat   = "Retrieving logs for apps in org %s / space %s as %s..."
 )
 
 type formatterKind int
@@ -31,11 +39,12 @@ type formatter interface {
 	appHeader(app, org, space, user string) (string, bool)
 	serviceHeader(service, org, space, user string) (string, bool)
 	sourceHeader(sourceID, _, _, user string) (string, bool)
+	spaceHeader(_, org, space, user string) (string, bool)
 	formatEnvelope(e *loggregator_v2.Envelope) (string, bool)
 	flush() (string, bool)
 }
 
-func newFormatter(sourceID string, following bool, kind formatterKind, log Logger, t *template.Template, newLineReplacer rune) formatter {
+func newFormatter(sourceID string, kind formatterKind, log Logger, o options) formatter {
 	bf := baseFormatter{
 		log: log,
 	}
@@ -45,17 +54,44 @@ func newFormatter(sourceID string, following bool, kind formatterKind, log Logge
 		return prettyFormatter{
 			baseFormatter: bf,
 			sourceID:      sourceID,
-			newLine:       newLineReplacer,
+			newLine:       o.newLineReplacer,
+			prettyJSON:    o.prettyJSON,
+			following:     o.follow,
+			color:         o.color,
+			rawUnits:      o.rawUnits,
+			showTags:      o.showTags,
 		}
 	case jsonFormat:
 		return &jsonFormatter{
-			following:     following,
+			following:     o.follow,
+			baseFormatter: bf,
+		}
+	case ndjsonFormat:
+		return ndjsonFormatter{
+			baseFormatter: bf,
+		}
+	case logfmtFormat:
+		return logfmtFormatter{
+			baseFormatter: bf,
+		}
+	case syslogFormat:
+		return syslogFormatter{
 			baseFormatter: bf,
 		}
 	case templateFormat:
 		return templateFormatter{
 			baseFormatter:  bf,
-			outputTemplate: t,
+			outputTemplate: o.outputTemplate,
+			templateData:   o.templateData,
+		}
+	case tableFormat:
+		return &tableFormatter{
+			prettyFormatter: prettyFormatter{
+				baseFormatter: bf,
+				sourceID:      sourceID,
+			},
+			fields: o.tableFields,
+			style:  o.tableStyle,
 		}
 	default:
 		log.Fatalf("Unknown formatter kind")
@@ -83,19 +119,28 @@ func (f baseFormatter) sourceHeader(_, _, _, _ string) (string, bool) {
 	return "", false
 }
 
+func (f baseFormatter) spaceHeader(_, _, _, _ string) (string, bool) {
+	return "", false
+}
+
 func (f baseFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, bool) {
 	return "", false
 }
 
 type prettyFormatter struct {
 	baseFormatter
-	sourceID string
-	newLine  rune
+	sourceID   string
+	newLine    rune
+	prettyJSON bool
+	following  bool
+	color      bool
+	rawUnits   bool
+	showTags   bool
 }
 
 func (f prettyFormatter) appHeader(app, org, space, user string) (string, bool) {
 	return fmt.Sprintf(
-		appHeaderFormat,
+		translate(appHeaderFormat),
 		app,
 		org,
 		space,
@@ -105,7 +150,7 @@ func (f prettyFormatter) appHeader(app, org, space, user string) (string, bool)
 
 func (f prettyFormatter) serviceHeader(service, org, space, user string) (string, bool) {
 	return fmt.Sprintf(
-		serviceHeaderFormat,
+		translate(serviceHeaderFormat),
 		service,
 		org,
 		space,
@@ -115,14 +160,68 @@ func (f prettyFormatter) serviceHeader(service, org, space, user string) (string
 
 func (f prettyFormatter) sourceHeader(sourceID, _, _, user string) (string, bool) {
 	return fmt.Sprintf(
-		sourceHeaderFormat,
+		translate(sourceHeaderFormat),
 		sourceID,
 		user,
 	), true
 }
 
+func (f prettyFormatter) spaceHeader(_, org, space, user string) (string, bool) {
+	return fmt.Sprintf(
+		translate(spaceHeaderFormat),
+		org,
+		space,
+		user,
+	), true
+}
+
 func (f prettyFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, bool) {
-	return fmt.Sprintf("%s", envelopeWrapper{sourceID: f.sourceID, Envelope: e, newLine: f.newLine}), true
+	line := fmt.Sprintf("%s", envelopeWrapper{
+		sourceID:   f.sourceID,
+		Envelope:   e,
+		newLine:    f.newLine,
+		prettyJSON: f.prettyJSON,
+		indentJSON: !f.following,
+		color:      f.color,
+		rawUnits:   f.rawUnits,
+	})
+
+	if f.showTags {
+		if tags, ok := formatTags(e); ok {
+			line += " " + tags
+		}
+	}
+
+	return line, true
+}
+
+// formatTags returns the tags of an envelope as sorted key=value pairs in
+// braces, including deprecated text tags.
+func formatTags(e *loggregator_v2.Envelope) (string, bool) {
+	tags := make(map[string]string)
+	for k, v := range e.GetDeprecatedTags() {
+		tags[k] = v.GetText()
+	}
+	for k, v := range e.GetTags() {
+		tags[k] = v
+	}
+
+	if len(tags) == 0 {
+		return "", false
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var l logfmtLine
+	for _, k := range keys {
+		l.add(k, tags[k])
+	}
+
+	return "{" + l.String() + "}", true
 }
 
 type jsonFormatter struct {
@@ -135,13 +234,13 @@ type jsonFormatter struct {
 
 func (f *jsonFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, bool) {
 	if f.following {
-		output, err := f.marshaler.MarshalToString(e)
+		output, err := f.marshal(e)
 		if err != nil {
 			log.Printf("failed to marshal envelope: %s", err)
 			return "", false
 		}
 
-		return string(output), true
+		return withSchemaVersion(output), true
 	}
 
 	f.es = append(f.es, e)
@@ -154,7 +253,7 @@ func (f *jsonFormatter) flush() (string, bool) {
 		return "", false
 	}
 
-	output, err := f.marshaler.MarshalToString(&loggregator_v2.EnvelopeBatch{
+	output, err := f.marshal(&loggregator_v2.EnvelopeBatch{
 		Batch: f.es,
 	})
 	if err != nil {
@@ -162,18 +261,137 @@ func (f *jsonFormatter) flush() (string, bool) {
 		return "", false
 	}
 
+	return withSchemaVersion(output), true
+}
+
+func (f *jsonFormatter) marshal(m proto.Message) (string, error) {
+	output, err := f.marshaler.MarshalToString(m)
+	if err != nil {
+		return "", err
+	}
+
+	stable, err := stableJSON([]byte(output))
+	if err != nil {
+		return "", err
+	}
+
+	return string(stable), nil
+}
+
+// ndjsonEnvelope is an envelope as written by --ndjson. Unlike --json,
+// the payload is flattened so every envelope type reads the same way.
+type ndjsonEnvelope struct {
+	SchemaVersion int               `json:"schema_version"`
+	Timestamp     string            `json:"timestamp"`
+	SourceID      string            `json:"source_id"`
+	InstanceID    string            `json:"instance_id"`
+	Type          string            `json:"type"`
+	Payload       interface{}       `json:"payload"`
+	Tags          map[string]string `json:"tags"`
+}
+
+type ndjsonFormatter struct {
+	baseFormatter
+}
+
+func (f ndjsonFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, bool) {
+	typ, payload := ndjsonPayload(e)
+	tags := e.GetTags()
+	if tags == nil {
+		tags = map[string]string{}
+	}
+
+	output, err := marshalJSON(ndjsonEnvelope{
+		SchemaVersion: schemaVersion,
+		Timestamp:     time.Unix(0, e.GetTimestamp()).UTC().Format(time.RFC3339Nano),
+		SourceID:      e.GetSourceId(),
+		InstanceID:    e.GetInstanceId(),
+		Type:          typ,
+		Payload:       payload,
+		Tags:          tags,
+	})
+	if err != nil {
+		log.Printf("failed to marshal envelope: %s", err)
+		return "", false
+	}
+
 	return string(output), true
 }
 
+// ndjsonPayload returns the type and payload of an envelope for --ndjson.
+func ndjsonPayload(e *loggregator_v2.Envelope) (string, interface{}) {
+	switch e.Message.(type) {
+	case *loggregator_v2.Envelope_Log:
+		return "log", string(e.GetLog().GetPayload())
+	case *loggregator_v2.Envelope_Counter:
+		c := e.GetCounter()
+		return "counter", map[string]interface{}{
+			"name":  c.GetName(),
+			"delta": c.GetDelta(),
+			"total": c.GetTotal(),
+		}
+	case *loggregator_v2.Envelope_Gauge:
+		metrics := make(map[string]interface{})
+		for name, v := range e.GetGauge().GetMetrics() {
+			metrics[name] = map[string]interface{}{
+				"unit":  v.GetUnit(),
+				"value": v.GetValue(),
+			}
+		}
+		return "gauge", metrics
+	case *loggregator_v2.Envelope_Timer:
+		t := e.GetTimer()
+		return "timer", map[string]interface{}{
+			"name":  t.GetName(),
+			"start": t.GetStart(),
+			"stop":  t.GetStop(),
+		}
+	case *loggregator_v2.Envelope_Event:
+		return "event", map[string]interface{}{
+			"title": e.GetEvent().GetTitle(),
+			"body":  e.GetEvent().GetBody(),
+		}
+	default:
+		return "unknown", nil
+	}
+}
+
 type templateFormatter struct {
 	baseFormatter
 
 	outputTemplate *template.Template
+	templateData   bool
+}
+
+// templateEnvelope is the data --output-template renders. Type and Payload
+// are the same as written by --ndjson.
+type templateEnvelope struct {
+	Timestamp  time.Time
+	SourceID   string
+	InstanceID string
+	Tags       map[string]string
+	Type       string
+	Payload    interface{}
+	Envelope   *loggregator_v2.Envelope
+}
+
+func newTemplateEnvelope(e *loggregator_v2.Envelope) templateEnvelope {
+	typ, payload := ndjsonPayload(e)
+
+	return templateEnvelope{
+		Timestamp:  time.Unix(0, e.GetTimestamp()).UTC(),
+		SourceID:   e.GetSourceId(),
+		InstanceID: e.GetInstanceId(),
+		Tags:       e.GetTags(),
+		Type:       typ,
+		Payload:    payload,
+		Envelope:   e,
+	}
 }
 
 func (f templateFormatter) appHeader(app, org, space, user string) (string, bool) {
 	return fmt.Sprintf(
-		appHeaderFormat,
+		translate(appHeaderFormat),
 		app,
 		org,
 		space,
@@ -183,7 +401,7 @@ func (f templateFormatter) appHeader(app, org, space, user string) (string, bool
 
 func (f templateFormatter) serviceHeader(service, org, space, user string) (string, bool) {
 	return fmt.Sprintf(
-		serviceHeaderFormat,
+		translate(serviceHeaderFormat),
 		service,
 		org,
 		space,
@@ -193,15 +411,20 @@ func (f templateFormatter) serviceHeader(service, org, space, user string) (stri
 
 func (f templateFormatter) sourceHeader(sourceID, _, _, user string) (string, bool) {
 	return fmt.Sprintf(
-		sourceHeaderFormat,
+		translate(sourceHeaderFormat),
 		sourceID,
 		user,
 	), true
 }
 
 func (f templateFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, bool) {
+	var data interface{} = e
+	if f.templateData {
+		data = newTemplateEnvelope(e)
+	}
+
 	b := bytes.Buffer{}
-	if err := f.outputTemplate.Execute(&b, e); err != nil {
+	if err := f.outputTemplate.Execute(&b, data); err != nil {
 		f.log.Fatalf("Output template parsed, but failed to execute: %s", err)
 	}
 
@@ -212,10 +435,93 @@ func (f templateFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, b
 	return b.String(), true
 }
 
+// tableFormatter renders fields extracted from JSON log payloads as a
+// table with one column per field. Envelopes without a JSON payload are
+// skipped.
+type tableFormatter struct {
+	prettyFormatter
+
+	fields []string
+	style  tableStyle
+	rows   [][]string
+}
+
+func (f *tableFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, bool) {
+	var payload map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(e.GetLog().GetPayload()))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return "", false
+	}
+
+	row := make([]string, 0, len(f.fields))
+	for _, field := range f.fields {
+		row = append(row, tableValue(payload, field))
+	}
+	f.rows = append(f.rows, row)
+
+	return "", false
+}
+
+func (f *tableFormatter) flush() (string, bool) {
+	if len(f.rows) == 0 {
+		return "", false
+	}
+
+	b := bytes.Buffer{}
+	tw := newTableWriter(&b, f.style, true)
+	fmt.Fprintln(tw, strings.Join(f.fields, "\t"))
+	for _, row := range f.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+
+	return b.String(), true
+}
+
+// tableValue looks up a field in a JSON payload. Nested fields are
+// separated by dots. Missing fields are shown as a dash.
+func tableValue(payload map[string]interface{}, field string) string {
+	var v interface{} = payload
+	for _, key := range strings.Split(field, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "-"
+		}
+
+		v, ok = m[key]
+		if !ok {
+			return "-"
+		}
+	}
+
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case json.Number:
+		s = t.String()
+	case nil:
+		return "-"
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return "-"
+		}
+		s = string(b)
+	}
+
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
 type envelopeWrapper struct {
 	*loggregator_v2.Envelope
-	sourceID string
-	newLine  rune
+	sourceID   string
+	newLine    rune
+	prettyJSON bool
+	indentJSON bool
+	color      bool
+	rawUnits   bool
 }
 
 func (e envelopeWrapper) String() string {
@@ -230,15 +536,19 @@ func (e envelopeWrapper) String() string {
 			}
 			return r
 		}
-		if e.newLine != 0 {
+
+		if formatted, ok := e.formatJSON(payload); ok {
+			payload = formatted
+		} else if e.newLine != 0 {
 			payload = strings.Map(sanitizer, payload)
 		}
 
-		return fmt.Sprintf("%s%s %s",
-			e.header(ts),
-			e.GetLog().GetType(),
-			payload,
-		)
+		header := fmt.Sprintf("%s%s", e.header(ts), e.GetLog().GetType())
+		if c := severityColor(e.GetLog()); e.color && c != "" {
+			header = c + header + colorReset
+		}
+
+		return header + " " + payload
 	case *loggregator_v2.Envelope_Counter:
 		return fmt.Sprintf("%sCOUNTER %s:%d",
 			e.header(ts),
@@ -248,7 +558,14 @@ func (e envelopeWrapper) String() string {
 	case *loggregator_v2.Envelope_Gauge:
 		var values []string
 		for k, v := range e.GetGauge().GetMetrics() {
-			values = append(values, fmt.Sprintf("%s:%f %s", k, v.Value, v.Unit))
+			value, unit := v.Value, v.Unit
+			if !e.rawUnits {
+				value, unit = normalizeUnit(value, unit)
+			}
+			if unit != "%" {
+				unit = " " + unit
+			}
+			values = append(values, fmt.Sprintf("%s:%f%s", k, value, unit))
 		}
 
 		sort.Sort(sort.StringSlice(values))
@@ -259,11 +576,20 @@ func (e envelopeWrapper) String() string {
 		)
 	case *loggregator_v2.Envelope_Timer:
 		timer := e.GetTimer()
-		return fmt.Sprintf("%sTIMER %s %f ms",
-			e.header(ts),
-			timer.GetName(),
-			float64(timer.GetStop()-timer.GetStart())/1000000.0,
-		)
+		d := time.Duration(timer.GetStop() - timer.GetStart())
+		duration := formatTimerDuration(d)
+		if e.rawUnits {
+			duration = fmt.Sprintf("%d ns", d)
+		}
+
+		line := fmt.Sprintf("%sTIMER %s %s", e.header(ts), timer.GetName(), duration)
+		for _, tag := range timerTags {
+			if v := e.GetTags()[tag]; v != "" {
+				line += " " + v
+			}
+		}
+
+		return line
 	case *loggregator_v2.Envelope_Event:
 		return fmt.Sprintf("%sEVENT %s:%s",
 			e.header(ts),
@@ -275,6 +601,43 @@ func (e envelopeWrapper) String() string {
 	}
 }
 
+// timerTags are the tags shown after the duration of a timer, in the order
+// of an access log line.
+var timerTags = []string{"method", "uri", "status_code"}
+
+const (
+	colorFatal = "\x1b[1;31m"
+	colorError = "\x1b[31m"
+	colorWarn  = "\x1b[33m"
+)
+
+var logLevel = regexp.MustCompile(`(?i)\b(fatal|error|warn|warning)\b`)
+
+// severityColor returns the color of the header of a log line. The most
+// severe level mentioned in the payload wins, otherwise lines written to
+// stderr are shown as errors. Other lines are not colored.
+func severityColor(l *loggregator_v2.Log) string {
+	var color string
+	for _, m := range logLevel.FindAllString(string(l.GetPayload()), -1) {
+		switch strings.ToLower(m) {
+		case "fatal":
+			return colorFatal
+		case "error":
+			color = colorError
+		default:
+			if color == "" {
+				color = colorWarn
+			}
+		}
+	}
+
+	if color == "" && l.GetType() == loggregator_v2.Log_ERR {
+		color = colorError
+	}
+
+	return color
+}
+
 func (e envelopeWrapper) header(ts time.Time) string {
 	if e.InstanceId == "" {
 		return fmt.Sprintf("   %s [%s] ",
@@ -0,0 +1,215 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+type httpErrorsOptionFlags struct {
+	Last       time.Duration `long:"last" default:"1h"`
+	Threshold  float64       `long:"threshold" default:"5"`
+	TableStyle string        `long:"table-style" default:"plain"`
+}
+
+type routeStats struct {
+	route    string
+	requests int
+	classes  [6]int
+}
+
+func (r *routeStats) serverErrorRate() float64 {
+	return float64(r.classes[5]) / float64(r.requests) * 100
+}
+
+// HTTPErrorsOption configures the HTTPErrors command.
+type HTTPErrorsOption func(*httpErrorsOptions)
+
+type httpErrorsOptions struct {
+	noHeaders bool
+}
+
+// WithHTTPErrorsNoHeaders omits the banner and table header.
+func WithHTTPErrorsNoHeaders() HTTPErrorsOption {
+	return func(o *httpErrorsOptions) {
+		o.noHeaders = true
+	}
+}
+
+// HTTPErrors reads the HTTP timers the router emits for an app and writes a
+// table of request counts per route and status class. Routes whose 5xx rate
+// is at or above the threshold are flagged as elevated.
+func HTTPErrors(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	args []string,
+	c HTTPClient,
+	log Logger,
+	w io.Writer,
+	opts ...HTTPErrorsOption,
+) {
+	o := httpErrorsOptionFlags{}
+
+	args, err := parseFlags("log-http-errors", &o, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) != 1 {
+		log.Fatalf("Expected 1 argument, got %d.", len(args))
+	}
+
+	ho := httpErrorsOptions{}
+	for _, opt := range opts {
+		opt(&ho)
+	}
+
+	if o.Last <= 0 {
+		log.Fatalf("--last must be greater than 0.")
+	}
+
+	if o.Threshold < 0 || o.Threshold > 100 {
+		log.Fatalf("--threshold must be between 0 and 100.")
+	}
+
+	style, err := parseTableStyle(o.TableStyle)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	appName := args[0]
+	appGUID := getAppGUID(appName, cli, log)
+	if appGUID == "" {
+		log.Fatalf(translate("App %s not found."), appName)
+	}
+
+	logCacheEndpoint, err := logCacheEndpoint(cli)
+	if err != nil {
+		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+	}
+
+	client := logcache.NewClient(
+		logCacheEndpoint,
+		logcache.WithHTTPClient(authenticatedClient(cli, c, log)),
+	)
+
+	if !ho.noHeaders {
+		writeAppHeader(w, cli, log, "Retrieving HTTP errors for app %s in org %s / space %s as %s...", appName)
+	}
+
+	routes := make(map[string]*routeStats)
+	end := time.Now()
+
+	logcache.Walk(
+		ctx,
+		appGUID,
+		logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+			for _, e := range envelopes {
+				route, class, ok := httpRequest(e)
+				if !ok {
+					continue
+				}
+
+				r, ok := routes[route]
+				if !ok {
+					r = &routeStats{route: route}
+					routes[route] = r
+				}
+
+				r.requests++
+				r.classes[class]++
+			}
+			return true
+		}),
+		client.Read,
+		logcache.WithWalkStartTime(end.Add(-o.Last)),
+		logcache.WithWalkEndTime(end),
+		logcache.WithWalkEnvelopeTypes(logcache_v1.EnvelopeType_TIMER),
+		logcache.WithWalkBackoff(newBackoff(log)),
+	)
+
+	if len(routes) == 0 {
+		fmt.Fprintf(w, translate("No HTTP requests found in the last %s.")+"\n", o.Last)
+		return
+	}
+
+	var rows []*routeStats
+	for _, r := range routes {
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		ri, rj := rows[i].serverErrorRate(), rows[j].serverErrorRate()
+		if ri != rj {
+			return ri > rj
+		}
+		if rows[i].requests != rows[j].requests {
+			return rows[i].requests > rows[j].requests
+		}
+		return rows[i].route < rows[j].route
+	})
+
+	tw := newTableWriter(w, style, !ho.noHeaders)
+	fmt.Fprintf(tw, "Route\tRequests\t2xx\t3xx\t4xx\t5xx\t5xx Rate\n")
+	for _, r := range rows {
+		rate := fmt.Sprintf("%.1f%%", r.serverErrorRate())
+		if r.classes[5] > 0 && r.serverErrorRate() >= o.Threshold {
+			rate += " (elevated)"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.route,
+			formatCount(r.requests),
+			formatCount(r.classes[2]),
+			formatCount(r.classes[3]),
+			formatCount(r.classes[4]),
+			formatCount(r.classes[5]),
+			rate,
+		)
+	}
+
+	if err = tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+// isRouterHTTPTimer reports whether the envelope is an HTTP timer emitted by
+// the router. Timers emitted by the app itself are ignored so requests are
+// not counted twice.
+func isRouterHTTPTimer(e *loggregator_v2.Envelope) bool {
+	return e.GetTimer().GetName() == "http" && e.GetTags()["peer_type"] != "Server"
+}
+
+// httpRequest returns the route and status class of a router HTTP timer.
+func httpRequest(e *loggregator_v2.Envelope) (string, int, bool) {
+	if !isRouterHTTPTimer(e) {
+		return "", 0, false
+	}
+
+	tags := e.GetTags()
+	status, err := strconv.Atoi(tags["status_code"])
+	if err != nil || status < 100 || status > 599 {
+		return "", 0, false
+	}
+
+	route := tags["uri"]
+	if u, err := url.Parse(route); err == nil && u.Host != "" {
+		route = u.Host + u.Path
+	} else if err == nil {
+		route = u.Path
+	}
+
+	if route == "" {
+		route = "/"
+	}
+
+	return route, status / 100, true
+}
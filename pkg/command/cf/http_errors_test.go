@@ -0,0 +1,188 @@
+package cf_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPErrors", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		startTime  time.Time
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+		cliConn.usernameResp = "a-user"
+		cliConn.orgName = "organization"
+		cliConn.spaceName = "space"
+
+		httpClient.responseBody = []string{
+			httpTimerResponseBody(
+				startTime,
+				httpTimer("https://app.example.com/api/orders?page=2", 200, "Client"),
+				httpTimer("https://app.example.com/api/orders", 500, "Client"),
+				httpTimer("https://app.example.com/api/orders", 503, "Client"),
+				httpTimer("https://app.example.com/api/orders", 201, "Client"),
+				httpTimer("https://app.example.com/api/orders", 500, "Server"),
+				httpTimer("https://app.example.com/", 200, "Client"),
+				httpTimer("https://app.example.com/", 302, "Client"),
+				httpTimer("https://app.example.com/login", 404, "Client"),
+				httpTimer("https://app.example.com/login", 0, "Client"),
+			),
+			emptyResponseBody(),
+		}
+	})
+
+	It("summarizes requests by route and status class", func() {
+		cf.HTTPErrors(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Retrieving HTTP errors for app app-name in org organization / space space as a-user...",
+			"",
+			"Route                       Requests  2xx  3xx  4xx  5xx  5xx Rate",
+			"app.example.com/api/orders  4         2    0    0    2    50.0% (elevated)",
+			"app.example.com/            2         1    1    0    0    0.0%",
+			"app.example.com/login       1         0    0    1    0    0.0%",
+		}))
+	})
+
+	It("flags routes using the given threshold", func() {
+		cf.HTTPErrors(
+			context.Background(),
+			cliConn,
+			[]string{"--threshold", "60", "app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithHTTPErrorsNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"app.example.com/api/orders  4  2  0  0  2  50.0%",
+			"app.example.com/            2  1  1  0  0  0.0%",
+			"app.example.com/login       1  0  0  1  0  0.0%",
+		}))
+	})
+
+	It("reads timer envelopes for the requested window", func() {
+		cf.HTTPErrors(
+			context.Background(),
+			cliConn,
+			[]string{"--last", "10m", "app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithHTTPErrorsNoHeaders(),
+		)
+
+		Expect(httpClient.requestURLs[0]).To(ContainSubstring("/v1/read/app-guid"))
+		Expect(httpClient.requestURLs[0]).To(ContainSubstring("envelope_types=TIMER"))
+		start, end := readTimeRange(httpClient.requestURLs[0])
+		Expect(end.Sub(start)).To(Equal(10 * time.Minute))
+	})
+
+	It("reports when there are no requests", func() {
+		httpClient.responseBody = []string{emptyResponseBody()}
+
+		cf.HTTPErrors(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithHTTPErrorsNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"No HTTP requests found in the last 1h0m0s.",
+		}))
+	})
+
+	It("fatally logs when the threshold is out of range", func() {
+		Expect(func() {
+			cf.HTTPErrors(
+				context.Background(),
+				cliConn,
+				[]string{"--threshold", "101", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--threshold must be between 0 and 100."))
+	})
+
+	It("fatally logs when the app does not exist", func() {
+		cliConn.cliCommandResult = [][]string{nil}
+		cliConn.cliCommandErr = []error{fmt.Errorf("App app-name not found")}
+
+		Expect(func() {
+			cf.HTTPErrors(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("App app-name not found."))
+	})
+})
+
+func httpTimer(uri string, statusCode int, peerType string) string {
+	return fmt.Sprintf(`{
+		"source_id": "app-guid",
+		"instance_id": "0",
+		"tags": {
+			"uri": %q,
+			"status_code": "%d",
+			"peer_type": %q
+		},
+		"timer": {
+			"name": "http",
+			"start": "1",
+			"stop": "2"
+		}
+	}`, uri, statusCode, peerType)
+}
+
+// httpTimerResponseBody returns a response with the given timer envelopes
+// one second apart.
+func httpTimerResponseBody(startTime time.Time, timers ...string) string {
+	var envelopes []string
+	for i, t := range timers {
+		envelopes = append(envelopes, fmt.Sprintf(`{"timestamp": "%d", %s`,
+			startTime.Add(time.Duration(i)*time.Second).UnixNano(),
+			strings.TrimPrefix(strings.TrimSpace(t), "{"),
+		))
+	}
+
+	return fmt.Sprintf(`{"envelopes":{"batch":[%s]}}`, strings.Join(envelopes, ","))
+}
@@ -0,0 +1,141 @@
+package cf
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultLocale = "en"
+
+// translations maps a language to translations of the user-facing format
+// strings. Strings without a translation are shown in English.
+var translations = map[string]map[string]string{
+	"de": {
+		"Retrieving logs for app %s in org %s / space %s as %s...":                        "Logs für App %s in Org %s / Space %s werden als %s abgerufen...",
+		"Retrieving logs for service %s in org %s / space %s as %s...":                    "Logs für Service %s in Org %s / Space %s werden als %s abgerufen...",
+		"Retrieving logs for source %s as %s...":                                          "Logs für Quelle %s werden als %s abgerufen...",
+		"Retrieving logs for apps in org %s / space %s as %s...":                          "Logs für Apps in Org %s / Space %s werden als %s abgerufen...",
+		"Retrieving log cache metadata as %s...":                                          "Log-Cache-Metadaten werden als %s abgerufen...",
+		"Retrieving crashes for app %s in org %s / space %s as %s...":                     "Abstürze für App %s in Org %s / Space %s werden als %s abgerufen...",
+		"Retrieving HTTP errors for app %s in org %s / space %s as %s...":                 "HTTP-Fehler für App %s in Org %s / Space %s werden als %s abgerufen...",
+		"Retrieving latency for app %s in org %s / space %s as %s...":                     "Latenz für App %s in Org %s / Space %s wird als %s abgerufen...",
+		"Comparing logs before and after deploy for app %s in org %s / space %s as %s...": "Logs vor und nach dem Deployment für App %s in Org %s / Space %s werden als %s verglichen...",
+		"Tracing request %s for app %s in org %s / space %s as %s...":                     "Request %s für App %s in Org %s / Space %s wird als %s verfolgt...",
+		"No crashes found in the last %s.":                                                "Keine Abstürze in den letzten %s gefunden.",
+		"No HTTP requests found in the last %s.":                                          "Keine HTTP-Requests in den letzten %s gefunden.",
+		"No envelopes found for request %s in the last %s.":                               "Keine Envelopes für Request %s in den letzten %s gefunden.",
+		"App %s not found.": "App %s nicht gefunden.",
+	},
+	"es": {
+		"Retrieving logs for app %s in org %s / space %s as %s...":     "Obteniendo logs de la app %s en la org %s / espacio %s como %s...",
+		"Retrieving logs for service %s in org %s / space %s as %s...": "Obteniendo logs del servicio %s en la org %s / espacio %s como %s...",
+		"Retrieving logs for source %s as %s...":                       "Obteniendo logs de la fuente %s como %s...",
+		"Retrieving logs for apps in org %s / space %s as %s...":       "Obteniendo logs de las apps en la org %s / espacio %s como %s...",
+		"Retrieving log cache metadata as %s...":                       "Obteniendo metadatos de log cache como %s...",
+		"App %s not found.":                                            "No se encontró la app %s.",
+	},
+}
+
+// digitSeparators holds the digit grouping separator of languages that
+// group digits differently from English.
+var digitSeparators = map[string]string{
+	"de": ".",
+	"es": ".",
+}
+
+// timeLayouts holds the layout of timestamps in tables and messages for
+// languages that write dates differently from English.
+var timeLayouts = map[string]string{
+	"de": "02.01.2006 15:04:05",
+	"es": "02/01/2006 15:04:05",
+}
+
+var (
+	localeMu sync.RWMutex
+	language = defaultLocale
+)
+
+// DetectLocale returns the locale configured in the environment. LC_ALL
+// takes precedence over LC_MESSAGES and LANG.
+func DetectLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l := os.Getenv(env); l != "" && l != "C" && l != "POSIX" {
+			return l
+		}
+	}
+
+	return defaultLocale
+}
+
+// SetLocale sets the locale used for user-facing messages and number and
+// date formatting. Locales such as "de_DE.UTF-8" and "de-DE" are accepted.
+// Unsupported locales fall back to English.
+func SetLocale(locale string) {
+	l := strings.ToLower(locale)
+	if i := strings.IndexAny(l, ".@"); i >= 0 {
+		l = l[:i]
+	}
+	if i := strings.IndexAny(l, "_-"); i >= 0 {
+		l = l[:i]
+	}
+
+	if _, ok := translations[l]; !ok {
+		l = defaultLocale
+	}
+
+	localeMu.Lock()
+	defer localeMu.Unlock()
+	language = l
+}
+
+func currentLanguage() string {
+	localeMu.RLock()
+	defer localeMu.RUnlock()
+
+	return language
+}
+
+// translate returns the translation of an English format string for the
+// current locale.
+func translate(format string) string {
+	if t, ok := translations[currentLanguage()][format]; ok {
+		return t
+	}
+
+	return format
+}
+
+// formatCount formats a count with the digit grouping of the current
+// locale. English output is left ungrouped so it stays machine readable.
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+
+	sep, ok := digitSeparators[currentLanguage()]
+	if !ok {
+		return s
+	}
+
+	var sign string
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + sep + s[i:]
+	}
+
+	return sign + s
+}
+
+// formatTime formats a timestamp of a table or message in the layout of the
+// current locale. English output uses the given layout.
+func formatTime(t time.Time, layout string) string {
+	if l, ok := timeLayouts[currentLanguage()]; ok {
+		layout = l
+	}
+
+	return t.Format(layout)
+}
@@ -0,0 +1,142 @@
+package cf_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locale", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		startTime  time.Time
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+		cliConn.usernameResp = "a-user"
+		cliConn.orgName = "organization"
+		cliConn.spaceName = "space"
+	})
+
+	AfterEach(func() {
+		cf.SetLocale("en")
+	})
+
+	It("translates banners", func() {
+		cf.SetLocale("de_DE.UTF-8")
+		httpClient.responseBody = []string{emptyResponseBody()}
+
+		cf.Tail(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Logs für App app-name in Org organization / Space space werden als a-user abgerufen...",
+		}))
+	})
+
+	It("groups digits of counts", func() {
+		cf.SetLocale("es-ES")
+
+		// The timers are a second apart and have to be inside the last hour.
+		startTime = startTime.Add(-30 * time.Minute)
+		var timers []string
+		for i := 0; i < 1200; i++ {
+			timers = append(timers, latencyTimer("0", time.Millisecond, "Client"))
+		}
+		httpClient.responseBody = []string{
+			httpTimerResponseBody(startTime, timers...),
+			emptyResponseBody(),
+		}
+
+		cf.Latency(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithLatencyNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"1.200  0.33  1.00ms  1.00ms  1.00ms",
+		}))
+	})
+
+	It("formats timestamps in tables", func() {
+		cf.SetLocale("de_DE.UTF-8")
+		httpClient.responseBody = []string{
+			logResponseBody(startTime, crashPayload("CRASHED", "APP/PROC/WEB: Exited with status 1")),
+			emptyResponseBody(),
+		}
+
+		cf.Crashes(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithCrashesNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"APP/PROC/WEB: Exited with status 1  1  " + startTime.Format("02.01.2006 15:04:05"),
+		}))
+	})
+
+	It("falls back to English for unsupported locales", func() {
+		cf.SetLocale("ja_JP.UTF-8")
+
+		cliConn.cliCommandResult = [][]string{nil}
+		cliConn.cliCommandErr = []error{fmt.Errorf("App app-name not found")}
+
+		Expect(func() {
+			cf.Crashes(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("App app-name not found."))
+	})
+
+	It("detects the locale from the environment", func() {
+		for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			defer os.Setenv(env, os.Getenv(env))
+		}
+		os.Setenv("LC_ALL", "")
+		os.Setenv("LC_MESSAGES", "de_AT.UTF-8")
+		os.Setenv("LANG", "es_ES.UTF-8")
+
+		Expect(cf.DetectLocale()).To(Equal("de_AT.UTF-8"))
+
+		os.Setenv("LC_MESSAGES", "C")
+		Expect(cf.DetectLocale()).To(Equal("es_ES.UTF-8"))
+	})
+})
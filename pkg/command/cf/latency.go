@@ -0,0 +1,222 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+const groupByInstance = "instance"
+
+type latencyOptionFlags struct {
+	Last       time.Duration `long:"last" default:"1h"`
+	GroupBy    string        `long:"group-by"`
+	TableStyle string        `long:"table-style" default:"plain"`
+
+	FromArchive string `long:"from-archive"`
+}
+
+// LatencyOption configures the Latency command.
+type LatencyOption func(*latencyOptions)
+
+type latencyOptions struct {
+	noHeaders bool
+}
+
+// WithLatencyNoHeaders omits the banner and table header.
+func WithLatencyNoHeaders() LatencyOption {
+	return func(o *latencyOptions) {
+		o.noHeaders = true
+	}
+}
+
+// Latency reads the HTTP timers the router emits for an app and writes the
+// request throughput and latency percentiles, either for the whole app or
+// for each app instance side by side. With --from-archive the timers are
+// read from a log-export directory and --last counts back from the newest
+// exported envelope.
+func Latency(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	args []string,
+	c HTTPClient,
+	log Logger,
+	w io.Writer,
+	opts ...LatencyOption,
+) {
+	o := latencyOptionFlags{}
+
+	args, err := parseFlags("log-latency", &o, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) != 1 {
+		log.Fatalf("Expected 1 argument, got %d.", len(args))
+	}
+
+	lo := latencyOptions{}
+	for _, opt := range opts {
+		opt(&lo)
+	}
+
+	if o.Last <= 0 {
+		log.Fatalf("--last must be greater than 0.")
+	}
+
+	groupBy := strings.ToLower(o.GroupBy)
+	if groupBy != "" && groupBy != groupByInstance {
+		log.Fatalf("Group by must be 'instance'.")
+	}
+
+	style, err := parseTableStyle(o.TableStyle)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	appName := args[0]
+
+	var (
+		appGUID string
+		read    logcache.Reader
+		end     time.Time
+	)
+	if o.FromArchive != "" {
+		archive, err := openExportArchive(o.FromArchive)
+		if err != nil {
+			log.Fatalf("Could not read archive: %s", err)
+		}
+
+		var ok bool
+		if appGUID, ok = archive.sourceID(appName); !ok {
+			log.Fatalf("%s is not in the archive %s.", appName, o.FromArchive)
+		}
+
+		read = archive.read
+		end = archive.newest(appGUID).Add(time.Nanosecond)
+	} else {
+		appGUID = getAppGUID(appName, cli, log)
+		if appGUID == "" {
+			log.Fatalf(translate("App %s not found."), appName)
+		}
+
+		logCacheEndpoint, err := logCacheEndpoint(cli)
+		if err != nil {
+			log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+		}
+
+		client := logcache.NewClient(
+			logCacheEndpoint,
+			logcache.WithHTTPClient(authenticatedClient(cli, c, log)),
+		)
+
+		if !lo.noHeaders {
+			writeAppHeader(w, cli, log, "Retrieving latency for app %s in org %s / space %s as %s...", appName)
+		}
+
+		read = client.Read
+		end = time.Now()
+	}
+
+	durations := make(map[string][]time.Duration)
+
+	logcache.Walk(
+		ctx,
+		appGUID,
+		logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+			for _, e := range envelopes {
+				if !isRouterHTTPTimer(e) {
+					continue
+				}
+
+				var group string
+				if groupBy == groupByInstance {
+					group = e.GetInstanceId()
+				}
+
+				t := e.GetTimer()
+				durations[group] = append(durations[group], time.Duration(t.GetStop()-t.GetStart()))
+			}
+			return true
+		}),
+		read,
+		logcache.WithWalkStartTime(end.Add(-o.Last)),
+		logcache.WithWalkEndTime(end),
+		logcache.WithWalkEnvelopeTypes(logcache_v1.EnvelopeType_TIMER),
+		logcache.WithWalkBackoff(newBackoff(log)),
+	)
+
+	if len(durations) == 0 {
+		fmt.Fprintf(w, translate("No HTTP requests found in the last %s.")+"\n", o.Last)
+		return
+	}
+
+	var groups []string
+	for group, ds := range durations {
+		groups = append(groups, group)
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return instanceLess(groups[i], groups[j])
+	})
+
+	tw := newTableWriter(w, style, !lo.noHeaders)
+	if groupBy == groupByInstance {
+		fmt.Fprintf(tw, "Instance\t")
+	}
+	fmt.Fprintf(tw, "Requests\tReq/s\tp50\tp95\tp99\n")
+	for _, group := range groups {
+		ds := durations[group]
+
+		if groupBy == groupByInstance {
+			fmt.Fprintf(tw, "%s\t", group)
+		}
+		fmt.Fprintf(tw, "%s\t%.2f\t%s\t%s\t%s\n",
+			formatCount(len(ds)),
+			float64(len(ds))/o.Last.Seconds(),
+			formatLatency(percentile(ds, 50)),
+			formatLatency(percentile(ds, 95)),
+			formatLatency(percentile(ds, 99)),
+		)
+	}
+
+	if err = tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+// instanceLess orders numeric instance IDs numerically and everything else
+// lexically.
+func instanceLess(a, b string) bool {
+	ai, aErr := strconv.Atoi(a)
+	bi, bErr := strconv.Atoi(b)
+	if aErr == nil && bErr == nil && ai != bi {
+		return ai < bi
+	}
+
+	return a < b
+}
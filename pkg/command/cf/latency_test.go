@@ -0,0 +1,150 @@
+package cf_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Latency", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		startTime  time.Time
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+		cliConn.usernameResp = "a-user"
+		cliConn.orgName = "organization"
+		cliConn.spaceName = "space"
+
+		httpClient.responseBody = []string{
+			httpTimerResponseBody(
+				startTime,
+				latencyTimer("0", 10*time.Millisecond, "Client"),
+				latencyTimer("0", 20*time.Millisecond, "Client"),
+				latencyTimer("10", 30*time.Millisecond, "Client"),
+				latencyTimer("2", 400*time.Millisecond, "Client"),
+				latencyTimer("2", 500*time.Millisecond, "Client"),
+				latencyTimer("2", 600*time.Millisecond, "Client"),
+				latencyTimer("2", 900*time.Millisecond, "Server"),
+			),
+			emptyResponseBody(),
+		}
+	})
+
+	It("writes percentiles and throughput for the app", func() {
+		cf.Latency(
+			context.Background(),
+			cliConn,
+			[]string{"--last", "1m", "app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Retrieving latency for app app-name in org organization / space space as a-user...",
+			"",
+			"Requests  Req/s  p50      p95       p99",
+			"6         0.10   30.00ms  600.00ms  600.00ms",
+		}))
+	})
+
+	It("writes percentiles and throughput per instance", func() {
+		cf.Latency(
+			context.Background(),
+			cliConn,
+			[]string{"--last", "1m", "--group-by", "instance", "app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Retrieving latency for app app-name in org organization / space space as a-user...",
+			"",
+			"Instance  Requests  Req/s  p50       p95       p99",
+			"0         2         0.03   10.00ms   20.00ms   20.00ms",
+			"2         3         0.05   500.00ms  600.00ms  600.00ms",
+			"10        1         0.02   30.00ms   30.00ms   30.00ms",
+		}))
+	})
+
+	It("reads timer envelopes for the requested window", func() {
+		cf.Latency(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithLatencyNoHeaders(),
+		)
+
+		Expect(httpClient.requestURLs[0]).To(ContainSubstring("envelope_types=TIMER"))
+		start, end := readTimeRange(httpClient.requestURLs[0])
+		Expect(end.Sub(start)).To(Equal(time.Hour))
+	})
+
+	It("reads timers from an archive relative to the newest envelope", func() {
+		dir := exportTestArchive(httpClient.responseBody[0])
+		defer os.RemoveAll(dir)
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+
+		cf.Latency(
+			context.Background(),
+			cliConn,
+			[]string{"--last", "1m", "--from-archive", dir, "app-name"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Requests  Req/s  p50      p95       p99",
+			"6         0.10   30.00ms  600.00ms  600.00ms",
+		}))
+		Expect(httpClient.requestCount()).To(Equal(0))
+		Expect(cliConn.cliCommandArgs).To(BeEmpty())
+	})
+
+	It("fatally logs for an unknown group", func() {
+		Expect(func() {
+			cf.Latency(
+				context.Background(),
+				cliConn,
+				[]string{"--group-by", "route", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Group by must be 'instance'."))
+	})
+})
+
+func latencyTimer(instanceID string, d time.Duration, peerType string) string {
+	return fmt.Sprintf(`{
+		"source_id": "app-guid",
+		"instance_id": %q,
+		"tags": {"peer_type": %q},
+		"timer": {"name": "http", "start": "1000", "stop": "%d"}
+	}`, instanceID, peerType, 1000+d.Nanoseconds())
+}
@@ -0,0 +1,127 @@
+package cf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localCache is a category of state the plugin caches in the config
+// directory between invocations.
+type localCache struct {
+	category    string
+	file        string
+	description string
+}
+
+var localCaches = []localCache{
+	{category: "token", file: tokenCacheFile, description: "Access token and its expiry"},
+	{category: "names", file: nameCacheFile, description: "Names of app and service GUIDs"},
+}
+
+type localCacheOptionFlags struct {
+	Show     bool   `long:"show"`
+	Clear    bool   `long:"clear"`
+	Category string `long:"category"`
+}
+
+// LocalCache shows the local state the plugin caches between invocations.
+// With --clear the state is removed so it is rebuilt on the next command.
+// --category restricts either to a comma separated list of categories.
+func LocalCache(args []string, log Logger, w io.Writer) {
+	o := localCacheOptionFlags{}
+
+	args, err := parseFlags("log-local-cache", &o, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) > 0 {
+		log.Fatalf("Invalid arguments, expected 0, got %d.", len(args))
+	}
+
+	if o.Show && o.Clear {
+		log.Fatalf("--show cannot be used with --clear")
+	}
+
+	caches, err := selectLocalCaches(o.Category)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		log.Fatalf("Could not determine config directory: %s", err)
+	}
+
+	if o.Clear {
+		for _, c := range caches {
+			err := os.Remove(filepath.Join(dir, c.file))
+			if os.IsNotExist(err) {
+				fmt.Fprintf(w, "The %s cache is empty.\n", c.category)
+				continue
+			}
+			if err != nil {
+				log.Fatalf("Could not clear the %s cache: %s", c.category, err)
+			}
+
+			fmt.Fprintf(w, "Cleared the %s cache.\n", c.category)
+		}
+		return
+	}
+
+	tw := newTableWriter(w, tableStylePlain, true)
+	fmt.Fprintf(tw, "Category\tDescription\tPath\tSize\tUpdated\n")
+	for _, c := range caches {
+		path := filepath.Join(dir, c.file)
+		size, updated := "-", "-"
+
+		info, err := os.Stat(path)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Could not read the %s cache: %s", c.category, err)
+		}
+		if err == nil {
+			size = formatBytes(info.Size())
+			updated = info.ModTime().Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.category, c.description, path, size, updated)
+	}
+
+	if err := tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+func selectLocalCaches(categories string) ([]localCache, error) {
+	if categories == "" {
+		return localCaches, nil
+	}
+
+	var selected []localCache
+	for _, category := range strings.Split(categories, ",") {
+		category = strings.ToLower(strings.TrimSpace(category))
+
+		var found bool
+		for _, c := range localCaches {
+			if c.category == category {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			var names []string
+			for _, c := range localCaches {
+				names = append(names, "'"+c.category+"'")
+			}
+			return nil, fmt.Errorf("Unknown cache category %s. Available: %s.", category, strings.Join(names, ", "))
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,85 @@
+package cf_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LocalCache", func() {
+	var (
+		home      string
+		origHome  string
+		tokenFile string
+		logger    *stubLogger
+		writer    *stubWriter
+	)
+
+	BeforeEach(func() {
+		var err error
+		home, err = ioutil.TempDir("", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		origHome = os.Getenv("HOME")
+		Expect(os.Setenv("HOME", home)).To(Succeed())
+
+		dir := filepath.Join(home, ".log-cache-cli")
+		Expect(os.MkdirAll(dir, 0700)).To(Succeed())
+		tokenFile = filepath.Join(dir, "token.json")
+		Expect(ioutil.WriteFile(tokenFile, []byte(`{"access_token":"bearer x"}`), 0600)).To(Succeed())
+
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+	})
+
+	AfterEach(func() {
+		Expect(os.Setenv("HOME", origHome)).To(Succeed())
+		Expect(os.RemoveAll(home)).To(Succeed())
+	})
+
+	It("shows every cache", func() {
+		cf.LocalCache(nil, logger, writer)
+
+		lines := writer.lines()
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(MatchRegexp(`^Category\s+Description\s+Path\s+Size\s+Updated$`))
+		Expect(lines[1]).To(MatchRegexp(`^token\s+Access token and its expiry\s+%s\s+27 B\s+\d{4}-`, tokenFile))
+		Expect(lines[2]).To(MatchRegexp(`^names\s+Names of app and service GUIDs\s+\S+names.json\s+-\s+-$`))
+	})
+
+	It("clears the selected categories", func() {
+		cf.LocalCache([]string{"--clear", "--category", "token"}, logger, writer)
+
+		Expect(writer.lines()).To(Equal([]string{"Cleared the token cache."}))
+		_, err := os.Stat(tokenFile)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		writer = &stubWriter{}
+		cf.LocalCache([]string{"--clear"}, logger, writer)
+		Expect(writer.lines()).To(Equal([]string{
+			"The token cache is empty.",
+			"The names cache is empty.",
+		}))
+	})
+
+	It("fatally logs for an unknown category", func() {
+		Expect(func() {
+			cf.LocalCache([]string{"--category", "cursors"}, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Unknown cache category cursors. Available: 'token', 'names'."))
+	})
+
+	It("fatally logs when --show and --clear are combined", func() {
+		Expect(func() {
+			cf.LocalCache([]string{"--show", "--clear"}, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--show cannot be used with --clear"))
+	})
+})
@@ -10,13 +10,12 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"text/tabwriter"
+	"text/template"
 	"time"
 
 	"code.cloudfoundry.org/cli/plugin"
 	logcache "code.cloudfoundry.org/log-cache/client"
 	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
-	flags "github.com/jessevdk/go-flags"
 )
 
 const (
@@ -25,7 +24,14 @@ const (
 	sourceTypePlatform    sourceType = "platform"
 	sourceTypeAll         sourceType = "all"
 	sourceTypeUnknown     sourceType = "unknown"
-	MaximumBatchSize      int        = 1000
+	sourceTypeTask        sourceType = "task"
+
+	// capiGUIDBatchSize is the number of GUIDs looked up per CAPI request
+	// and capiGUIDQueryLength the length of the guids parameter, which
+	// keeps the query string within the limits of CAPI and its routers
+	// for long platform source IDs.
+	capiGUIDBatchSize   = 50
+	capiGUIDQueryLength = 2000
 )
 
 type sourceType string
@@ -33,11 +39,13 @@ type sourceType string
 const (
 	sortBySourceID      sortBy = "source-id"
 	sortBySource        sortBy = "source"
+	sortByAppName       sortBy = "app-name"
 	sortBySourceType    sortBy = "source-type"
 	sortByCount         sortBy = "count"
 	sortByExpired       sortBy = "expired"
 	sortByCacheDuration sortBy = "cache-duration"
 	sortByRate          sortBy = "rate"
+	sortByExpiredRatio  sortBy = "expired-ratio"
 )
 
 type sortBy string
@@ -60,20 +68,9 @@ type sourceInfo struct {
 	Resources []source `json:"resources"`
 }
 
-type serviceInstance struct {
-	Metadata struct {
-		GUID string `json:"guid"`
-	} `json:"metadata"`
-	Entity struct {
-		Name string `json:"name"`
-	} `json:"entity"`
-}
-
-type servicesResponse struct {
-	Resources []serviceInstance `json:"resources"`
-}
-
-type Tailer func(sourceID string) []string
+// Tailer returns up to limit of the newest envelopes of a source over the
+// last window as JSON batches.
+type Tailer func(sourceID string, window time.Duration, limit int) []string
 
 type calculator struct {
 	ctx    context.Context
@@ -81,31 +78,57 @@ type calculator struct {
 	c      HTTPClient
 	log    Logger
 	tailer Tailer
+	window time.Duration
+
+	// readLimit is the most envelopes read from a source, the batch limit
+	// of the Log Cache server.
+	readLimit int
+
+	// deltas holds the rates measured from meta snapshots with
+	// --noise-mode meta. The tailer is not used when it is set.
+	deltas map[string]int
 }
 
-func newCalculator(ctx context.Context, cli plugin.CliConnection, c HTTPClient, log Logger, tailer Tailer) *calculator {
+func newCalculator(ctx context.Context, cli plugin.CliConnection, c HTTPClient, log Logger, tailer Tailer, window time.Duration, readLimit int) *calculator {
 	return &calculator{
-		ctx:    ctx,
-		cli:    cli,
-		c:      c,
-		log:    log,
-		tailer: tailer,
+		ctx:       ctx,
+		cli:       cli,
+		c:         c,
+		log:       log,
+		tailer:    tailer,
+		window:    window,
+		readLimit: readLimit,
 	}
 }
 
-func (calc *calculator) rate(sourceID string) int {
+// rate returns the envelopes per minute of the source over the window.
+// Sources that reach the batch limit within the window have more envelopes
+// than were read, their rate is a lower bound, which is reported as well.
+func (calc *calculator) rate(sourceID string) (int, bool) {
+	if calc.deltas != nil {
+		return calc.deltas[sourceID], false
+	}
+
 	batch := struct {
 		Results []string `json:"batch"`
 	}{}
 
 	var results []string
 
-	for _, lines := range calc.tailer(sourceID) {
+	for _, lines := range calc.tailer(sourceID, calc.window, calc.readLimit) {
 		json.NewDecoder(strings.NewReader(lines)).Decode(&batch)
 		results = append(results, batch.Results...)
 	}
 
-	return len(results)
+	rate := int(int64(len(results)) * int64(time.Minute) / int64(calc.window))
+
+	return rate, len(results) >= calc.readLimit
+}
+
+// maxMeasuredRate is the highest rate measured by tailing a source over
+// the window, the rate of a source that reaches the batch limit.
+func maxMeasuredRate(window time.Duration, readLimit int) int {
+	return int(int64(readLimit) * int64(time.Minute) / int64(window))
 }
 
 type optionsFlags struct {
@@ -113,8 +136,52 @@ type optionsFlags struct {
 	EnableNoise bool   `long:"noise"`
 	ShowGUID    bool   `long:"guid"`
 	SortBy      string `long:"sort-by"`
-
-	noHeaders bool
+	Desc        bool   `long:"desc"`
+	Limit       int    `long:"limit"`
+	Top         int    `long:"top"`
+	TableStyle  string `long:"table-style" default:"plain"`
+	Exclude     string `long:"exclude-source"`
+	Filter      string `long:"source-id-filter"`
+	MinCount    int64  `long:"min-count"`
+	MinRate     int    `long:"min-rate"`
+	GroupBy     string `long:"group-by"`
+	Scope       string `long:"scope"`
+	Placement   bool   `long:"include-placement"`
+	Ratio       bool   `long:"expired-ratio"`
+	Timestamps  bool   `long:"timestamps"`
+	Precise     bool   `long:"precise-durations"`
+	Jobs        bool   `long:"include-job"`
+	Copy        bool   `long:"copy"`
+	Accessible  bool   `long:"accessible"`
+	NoHeaders   bool   `long:"no-headers"`
+	Refresh     bool   `long:"refresh-names"`
+
+	FailRate  int     `long:"fail-if-rate-above"`
+	FailRatio float64 `long:"fail-if-expired-ratio-above"`
+
+	NoiseInterval time.Duration `long:"noise-interval" default:"1m"`
+	NoiseMode     string        `long:"noise-mode" default:"tail"`
+	NoiseWorkers  int           `long:"noise-concurrency" default:"10"`
+
+	JSON           bool   `long:"json"`
+	Output         string `long:"output" default:"table"`
+	OutputTemplate string `long:"output-template"`
+	Columns        string `long:"columns"`
+	Totals         bool   `long:"totals"`
+	Breakdown      bool   `long:"envelope-breakdown"`
+	Stream         bool   `long:"stream"`
+
+	Record   string        `long:"record"`
+	Report   string        `long:"report"`
+	Interval time.Duration `long:"interval" default:"1m"`
+	Duration time.Duration `long:"duration" default:"1h"`
+	Watch    time.Duration `long:"watch"`
+	Diff     string        `long:"diff"`
+
+	noHeaders    bool
+	clipboard    Clipboard
+	watching     bool
+	nameCacheTTL time.Duration
 }
 
 var (
@@ -129,7 +196,17 @@ func WithMetaNoHeaders() MetaOption {
 	}
 }
 
-// Meta returns the metadata from Log Cache
+// WithMetaNameCache caches the names of app and service GUIDs in the config
+// directory for the given TTL.
+func WithMetaNameCache(ttl time.Duration) MetaOption {
+	return func(o *optionsFlags) {
+		o.nameCacheTTL = ttl
+	}
+}
+
+// Meta returns the metadata from Log Cache. With --record it instead
+// appends periodic snapshots of the metadata to a file and with --report it
+// tabulates the growth of every source in such a file.
 func Meta(
 	ctx context.Context,
 	cli plugin.CliConnection,
@@ -147,7 +224,8 @@ func Meta(
 		SortBy:      "source",
 	}
 
-	args, err := flags.ParseArgs(&opts, args)
+	rawArgs := args
+	args, err := parseFlags("log-meta", &opts, args)
 	if err != nil {
 		log.Fatalf("Could not parse flags: %s", err)
 	}
@@ -156,8 +234,28 @@ func Meta(
 		o(&opts)
 	}
 
-	if len(args) > 0 {
-		log.Fatalf("Invalid arguments, expected 0, got %d.", len(args))
+	// --no-headers drops the banner and the header row so the table can
+	// be piped into other tools.
+	if opts.NoHeaders {
+		opts.noHeaders = true
+	}
+
+	if len(args) > 1 {
+		log.Fatalf("Invalid arguments, expected 0 or 1, got %d.", len(args))
+	}
+
+	// A single source is shown with every detail, including its rate.
+	var sourceName, sourceID string
+	if len(args) == 1 {
+		if opts.GroupBy != "" || opts.Scope != "" || opts.Record != "" || opts.Report != "" || opts.Diff != "" || !sourceTypeAll.Equal(strings.ToLower(opts.SourceType)) || opts.Filter != "" || opts.Exclude != "" || opts.Limit != 0 || opts.Top != 0 || opts.Columns != "" || opts.Totals || opts.Breakdown {
+			log.Fatalf("A source cannot be used with --group-by, --scope, --record, --report, --diff, --source-type, --source-id-filter, --exclude-source, --limit, --top, --columns, --totals or --envelope-breakdown")
+		}
+
+		sourceName = args[0]
+		opts.ShowGUID = true
+		opts.EnableNoise = true
+		opts.Ratio = true
+		opts.Timestamps = true
 	}
 
 	sourceType := strings.ToLower(opts.SourceType)
@@ -165,33 +263,271 @@ func Meta(
 		log.Fatalf("Source type must be 'platform', 'application', 'service', or 'all'.")
 	}
 
+	// --top is a shorthand for the noisiest sources.
+	if opts.Top != 0 {
+		if opts.Top < 0 {
+			log.Fatalf("--top must be greater than 0.")
+		}
+
+		if !opts.EnableNoise {
+			log.Fatalf("Can't show the noisiest sources without --noise flag")
+		}
+
+		if !sortBySource.Equal(strings.ToLower(opts.SortBy)) || opts.Desc || opts.Limit != 0 {
+			log.Fatalf("--top cannot be used with --sort-by, --desc or --limit")
+		}
+
+		opts.SortBy = string(sortByRate)
+		opts.Desc = true
+		opts.Limit = opts.Top
+	}
+
 	sortBy := strings.ToLower(opts.SortBy)
 	if invalidSortBy(sortBy) {
-		log.Fatalf("Sort by must be 'source-id', 'source', 'source-type', 'count', 'expired', 'cache-duration', or 'rate'.")
+		log.Fatalf("Sort by must be 'source-id', 'source', 'app-name', 'source-type', 'count', 'expired', 'cache-duration', 'rate', or 'expired-ratio'.")
 	}
+	if sortByAppName.Equal(sortBy) {
+		sortBy = string(sortBySource)
+	}
+	opts.SortBy = sortBy
 
 	if sortByRate.Equal(sortBy) && !opts.EnableNoise {
 		log.Fatalf("Can't sort by rate column without --noise flag")
 	}
 
+	if sortByExpiredRatio.Equal(sortBy) && !opts.Ratio {
+		log.Fatalf("Can't sort by expired ratio column without --expired-ratio flag")
+	}
+
 	if sortBySourceID.Equal(sortBy) && !opts.ShowGUID {
 		log.Fatalf("Can't sort by source id column without --guid flag")
 	}
 
-	logCacheEndpoint, err := logCacheEndpoint(cli)
+	groupBy := strings.ToLower(opts.GroupBy)
+	if groupBy != "" {
+		if groupBy != groupByOrg && groupBy != groupBySpace {
+			log.Fatalf("Group by must be 'org' or 'space'.")
+		}
+
+		if opts.ShowGUID || !sortBySource.Equal(sortBy) || opts.Desc || !sourceTypeAll.Equal(sourceType) {
+			log.Fatalf("--group-by cannot be used with --guid, --sort-by, --desc or --source-type")
+		}
+	}
+
+	scope := strings.ToLower(opts.Scope)
+	if scope != "" {
+		if scope != metaScopeOrg && scope != metaScopeSpace {
+			log.Fatalf("--scope must be 'org' or 'space'.")
+		}
+
+		if groupBy != "" || !sourceTypeAll.Equal(sourceType) || opts.Record != "" || opts.Report != "" {
+			log.Fatalf("--scope cannot be used with --group-by, --source-type, --record or --report")
+		}
+	}
+
+	if opts.Record != "" && opts.Report != "" {
+		log.Fatalf("--record cannot be used with --report")
+	}
+
+	if opts.Limit < 0 {
+		log.Fatalf("--limit cannot be negative.")
+	}
+
+	if opts.Limit > 0 && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--limit cannot be used with --group-by, --record or --report")
+	}
+
+	if opts.NoiseInterval <= 0 {
+		log.Fatalf("--noise-interval must be greater than 0.")
+	}
+
+	if opts.NoiseWorkers <= 0 {
+		log.Fatalf("--noise-concurrency must be greater than 0.")
+	}
+
+	noiseMode := strings.ToLower(opts.NoiseMode)
+	if noiseMode != noiseModeTail && noiseMode != noiseModeMeta {
+		log.Fatalf("--noise-mode must be 'tail' or 'meta'.")
+	}
+
+	if opts.MinRate > 0 && !opts.EnableNoise {
+		log.Fatalf("Can't filter by rate without --noise flag")
+	}
+
+	if opts.FailRate > 0 && !opts.EnableNoise {
+		log.Fatalf("Can't check the rate without --noise flag")
+	}
+
+	if opts.FailRate < 0 || opts.FailRatio < 0 {
+		log.Fatalf("--fail-if-rate-above and --fail-if-expired-ratio-above cannot be negative.")
+	}
+
+	if (opts.FailRate > 0 || opts.FailRatio > 0) && (groupBy != "" || opts.Record != "" || opts.Report != "" || opts.Diff != "") {
+		log.Fatalf("--fail-if-rate-above and --fail-if-expired-ratio-above cannot be used with --group-by, --record, --report or --diff")
+	}
+
+	if (opts.MinCount > 0 || opts.MinRate > 0) && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--min-count and --min-rate cannot be used with --group-by, --record or --report")
+	}
+
+	if opts.Placement && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--include-placement cannot be used with --group-by, --record or --report")
+	}
+
+	if opts.Ratio && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--expired-ratio cannot be used with --group-by, --record or --report")
+	}
+
+	if opts.Timestamps && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--timestamps cannot be used with --group-by, --record or --report")
+	}
+
+	if opts.Precise && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--precise-durations cannot be used with --group-by, --record or --report")
+	}
+
+	if opts.Jobs && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--include-job cannot be used with --group-by, --record or --report")
+	}
+
+	if opts.Filter != "" && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--source-id-filter cannot be used with --group-by, --record or --report")
+	}
+
+	if opts.Copy && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--copy cannot be used with --group-by, --record or --report")
+	}
+
+	output := strings.ToLower(opts.Output)
+	if output != metaOutputTable && output != metaOutputCSV && output != metaOutputJSON && output != metaOutputYAML && output != metaOutputPrometheus {
+		log.Fatalf("--output must be 'table', 'csv', 'json', 'yaml' or 'prometheus'.")
+	}
+
+	if opts.JSON {
+		if output != metaOutputTable && output != metaOutputJSON {
+			log.Fatalf("--json cannot be used with --output %s", output)
+		}
+		output = metaOutputJSON
+	}
+
+	if output != metaOutputTable && (groupBy != "" || opts.Record != "" || opts.Report != "") {
+		log.Fatalf("--json and --output cannot be used with --group-by, --record or --report")
+	}
+
+	var outputTemplate *template.Template
+	if opts.OutputTemplate != "" {
+		if output != metaOutputTable || groupBy != "" || opts.Record != "" || opts.Report != "" {
+			log.Fatalf("--output-template cannot be used with --json, --output, --group-by, --record or --report")
+		}
+
+		outputTemplate, err = parseOutputFormat(opts.OutputTemplate)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+
+	if opts.Record != "" {
+		if opts.Interval <= 0 {
+			log.Fatalf("--interval must be greater than 0.")
+		}
+
+		if opts.Duration < 0 {
+			log.Fatalf("--duration cannot be negative.")
+		}
+	}
+
+	style, err := parseTableStyle(opts.TableStyle)
 	if err != nil {
-		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+		log.Fatalf("%s", err)
+	}
+
+	if opts.Diff != "" {
+		if groupBy != "" || opts.Record != "" || opts.Report != "" || output != metaOutputTable || outputTemplate != nil || opts.Copy || opts.Watch != 0 {
+			log.Fatalf("--diff cannot be used with --group-by, --record, --report, --json, --output, --output-template, --copy or --watch")
+		}
+	}
+
+	var columns []string
+	if opts.Columns != "" {
+		if (output != metaOutputTable && output != metaOutputCSV) || outputTemplate != nil || groupBy != "" || opts.Record != "" || opts.Report != "" || opts.Diff != "" {
+			log.Fatalf("--columns can only be used with table or CSV output")
+		}
+
+		columns, err = parseMetaColumns(opts.Columns, opts)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+
+	if opts.Totals && (output != metaOutputTable || outputTemplate != nil || columns != nil || groupBy != "" || opts.Record != "" || opts.Report != "" || opts.Diff != "") {
+		log.Fatalf("--totals can only be used with the default table")
+	}
+
+	if opts.Stream && (output != metaOutputTable || outputTemplate != nil || (style != tableStylePlain && style != tableStyleTSV) || columns != nil || opts.Totals || opts.Breakdown || groupBy != "" || scope != "" || opts.Record != "" || opts.Report != "" || opts.Diff != "" || opts.Copy || sourceName != "" ||
+		!sortBySource.Equal(sortBy) || opts.Desc || opts.Limit != 0 || opts.Placement || opts.Jobs || opts.FailRate > 0 || opts.FailRatio > 0) {
+		log.Fatalf("--stream can only be used with the default plain or TSV table, sorted by source, without --limit, --totals, --include-placement, --include-job, --copy or thresholds")
+	}
+
+	if opts.Breakdown && (output != metaOutputTable || outputTemplate != nil || columns != nil || opts.Totals || groupBy != "" || opts.Record != "" || opts.Report != "" || opts.Diff != "") {
+		log.Fatalf("--envelope-breakdown can only be used with the default table")
+	}
+
+	if opts.Watch != 0 && !opts.watching {
+		if opts.Watch < 0 {
+			log.Fatalf("--watch cannot be negative.")
+		}
+
+		if output != metaOutputTable || outputTemplate != nil || opts.Copy || opts.Record != "" || opts.Report != "" {
+			log.Fatalf("--watch cannot be used with --json, --output, --output-template, --copy, --record or --report")
+		}
+
+		watchMeta(ctx, opts.Watch, tableWriter, log, func(ctx context.Context, w io.Writer, log Logger) {
+			Meta(ctx, cli, tailer, rawArgs, c, log, w, append(mopts, withMetaWatching())...)
+		})
+		return
+	}
+
+	if opts.Report != "" {
+		writeMetaTrendReport(opts.Report, newTableWriter(tableWriter, style, !opts.noHeaders), opts.Accessible, log)
+		return
+	}
+
+	var exclude *regexp.Regexp
+	if opts.Exclude != "" {
+		exclude, err = regexp.Compile(opts.Exclude)
+		if err != nil {
+			log.Fatalf("Invalid --exclude-source pattern: %s", err)
+		}
 	}
 
-	if strings.ToLower(os.Getenv("LOG_CACHE_SKIP_AUTH")) != "true" {
-		token, err := cli.AccessToken()
+	var filter *regexp.Regexp
+	if opts.Filter != "" {
+		filter, err = regexp.Compile(opts.Filter)
 		if err != nil {
-			log.Fatalf("Unable to get Access Token: %s", err)
+			log.Fatalf("Invalid --source-id-filter pattern: %s", err)
 		}
+	}
+
+	logCacheEndpoint, err := logCacheEndpoint(cli)
+	if err != nil {
+		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+	}
+
+	c = authenticatedClient(cli, c, log)
+	readLimit := requireFeature(ctx, c, logCacheEndpoint, featureMeta, log).readLimit()
 
-		c = &tokenHTTPClient{
-			c:           c,
-			accessToken: token,
+	// Tailed sources are read up to the batch limit, a rate at or above
+	// the limit is a lower bound that can't be compared with a threshold.
+	if opts.FailRate > 0 && noiseMode == noiseModeTail && opts.FailRate >= maxMeasuredRate(opts.NoiseInterval, readLimit) {
+		log.Fatalf("--fail-if-rate-above must be below %d, the highest rate measured over a --noise-interval of %s.", maxMeasuredRate(opts.NoiseInterval, readLimit), opts.NoiseInterval)
+	}
+
+	if sourceName != "" {
+		sourceID = sourceName
+		if !appOrServiceRegex.MatchString(sourceName) {
+			if id, _ := getGUID(sourceName, cli, log); id != "" {
+				sourceID = id
+			}
 		}
 	}
 
@@ -200,14 +536,63 @@ func Meta(
 		logcache.WithHTTPClient(c),
 	)
 
+	if opts.Diff != "" {
+		diffMeta(ctx, client, opts.Diff, exclude, newTableWriter(tableWriter, style, !opts.noHeaders), tableWriter, log)
+		return
+	}
+
+	if opts.Record != "" {
+		recordMetaTrends(ctx, client, opts.Record, opts.Interval, opts.Duration, exclude, tableWriter, log)
+		return
+	}
+
 	meta, err := client.Meta(ctx)
 	if err != nil {
 		log.Fatalf("Failed to read Meta information: %s", err)
 	}
 
-	resources, err := getSourceInfo(meta, cli)
-	if err != nil {
-		log.Fatalf("Failed to read application information: %s", err)
+	if sourceID != "" {
+		m, ok := meta[sourceID]
+		if !ok {
+			log.Fatalf("%s has no envelopes in Log Cache.", sourceName)
+		}
+		meta = map[string]*logcache_v1.MetaInfo{sourceID: m}
+	}
+
+	if exclude != nil {
+		for sourceID := range meta {
+			if exclude.MatchString(sourceID) {
+				delete(meta, sourceID)
+			}
+		}
+	}
+
+	// Only GUIDs have names in CAPI, other sources that don't match the
+	// filter are dropped before they are looked up.
+	if filter != nil {
+		for sourceID := range meta {
+			if !appOrServiceRegex.MatchString(sourceID) && !filter.MatchString(sourceID) {
+				delete(meta, sourceID)
+			}
+		}
+	}
+
+	var deltas map[string]int
+	if opts.EnableNoise && noiseMode == noiseModeMeta {
+		deltas = metaDeltaRates(ctx, client, meta, opts.NoiseInterval, log)
+	}
+
+	var resources []source
+	if scope != "" {
+		resources = scopedSources(scope, meta, cli, log)
+	}
+
+	// Streamed rows resolve their names batch by batch.
+	if groupBy == "" && scope == "" && !opts.Stream {
+		resources, err = getCachedSourceInfo(meta, cli, opts.nameCacheTTL, opts.Refresh)
+		if err != nil {
+			log.Fatalf("Failed to read application information: %s", err)
+		}
 	}
 
 	username, err := cli.Username()
@@ -215,36 +600,164 @@ func Meta(
 		log.Fatalf("Could not get username: %s", err)
 	}
 
-	if !opts.noHeaders {
-		fmt.Fprintf(tableWriter, fmt.Sprintf(
-			"Retrieving log cache metadata as %s...\n\n",
-			username,
-		))
+	if !opts.noHeaders && output == metaOutputTable && outputTemplate == nil {
+		fmt.Fprintf(tableWriter, translate("Retrieving log cache metadata as %s...")+"\n\n", username)
+	}
+
+	if groupBy != "" {
+		calculator := newCalculator(ctx, cli, c, log, tailer, opts.NoiseInterval, readLimit)
+		calculator.deltas = deltas
+		writeMetaGroups(meta, groupBy, opts, calculator, cli, newTableWriter(tableWriter, style, !opts.noHeaders), log)
+		return
+	}
+
+	// Org and space are only known for applications and left empty for
+	// other sources.
+	var placements map[string]placement
+	if opts.Placement {
+		var appGUIDs []string
+		for _, s := range resources {
+			if s.Type == sourceTypeApplication {
+				appGUIDs = append(appGUIDs, s.GUID)
+			}
+		}
+		sort.Strings(appGUIDs)
+
+		placements, err = getPlacements(appGUIDs, cli)
+		if err != nil {
+			log.Fatalf("Failed to read application information: %s", err)
+		}
+	}
+
+	// The BOSH job is only known for platform sources and left empty for
+	// other sources.
+	var jobs map[string]platformJob
+	if opts.Jobs {
+		resolved := make(map[string]bool, len(resources))
+		for _, s := range resources {
+			resolved[s.GUID] = true
+		}
+
+		var platformIDs []string
+		if sourceTypePlatform.Equal(sourceType) || sourceTypeAll.Equal(sourceType) {
+			for sourceID := range meta {
+				if !resolved[sourceID] && !appOrServiceRegex.MatchString(sourceID) {
+					platformIDs = append(platformIDs, sourceID)
+				}
+			}
+		}
+		sort.Strings(platformIDs)
+
+		jobs = platformJobs(ctx, client, platformIDs, log)
 	}
 
-	headerArgs := []interface{}{"Source", "Source Type", "Count", "Expired", "Cache Duration"}
-	headerFormat := "%s\t%s\t%s\t%s\t%s\n"
-	tableFormat := "%s\t%s\t%d\t%d\t%s\n"
+	calculator := newCalculator(ctx, cli, c, log, tailer, opts.NoiseInterval, readLimit)
+	calculator.deltas = deltas
 
-	if opts.ShowGUID {
-		headerArgs = append([]interface{}{"Source ID"}, headerArgs...)
-		headerFormat = "%s\t" + headerFormat
-		tableFormat = "%s\t" + tableFormat
+	if opts.Stream {
+		streamMeta(opts, sourceType, meta, exclude, filter, calculator, cli, tableWriter, style, log)
+		return
 	}
 
+	records := buildMetaRecords(opts, sourceType, resources, meta, placements, jobs, exclude, filter)
+
+	// Rates are measured once every record is known, so sources can be
+	// tailed concurrently.
 	if opts.EnableNoise {
-		headerArgs = append(headerArgs, "Rate")
-		headerFormat = strings.Replace(headerFormat, "\n", "\t%s\n", 1)
-		tableFormat = strings.Replace(tableFormat, "\n", "\t%s\n", 1)
+		fillRates(records, calculator, opts.NoiseWorkers)
 	}
 
-	tw := tabwriter.NewWriter(tableWriter, 0, 2, 2, ' ', 0)
-	if !opts.noHeaders {
-		fmt.Fprintf(tw, headerFormat, headerArgs...)
+	records = filterIdleRecords(opts, records)
+	sortRecords(opts, records)
+
+	// Thresholds are checked against every source, including those left
+	// out by --limit.
+	violations := metaThresholdViolations(opts, records)
+
+	var footer string
+	if opts.Limit > 0 && len(records) > opts.Limit {
+		var count, expired int64
+		for _, r := range records {
+			count += r.Count
+			expired += r.Expired
+		}
+
+		footer = fmt.Sprintf(
+			translate("Showing %s of %s sources, %s envelopes in total, %s expired."),
+			formatCount(opts.Limit),
+			formatCount(len(records)),
+			formatCount(int(count)),
+			formatCount(int(expired)),
+		)
+		records = records[:opts.Limit]
+	}
+
+	if columns == nil {
+		columns = metaColumns(opts)
+	}
+
+	switch {
+	case outputTemplate != nil:
+		writeMetaTemplate(tableWriter, outputTemplate, records, log)
+	case output == metaOutputJSON:
+		writeMetaJSON(tableWriter, records, log)
+	case output == metaOutputYAML:
+		writeMetaYAML(tableWriter, records, log)
+	case output == metaOutputPrometheus:
+		writeMetaPrometheus(tableWriter, records, log)
+	case output == metaOutputCSV:
+		writeMetaCSV(tableWriter, opts, columns, records, log)
+	case sourceName != "" && len(records) > 0:
+		writeMetaSource(newTableWriter(tableWriter, style, !opts.noHeaders), opts, columns, records[0], log)
+	case opts.Breakdown:
+		writeMetaBreakdown(ctx, client, records, newTableWriter(tableWriter, style, !opts.noHeaders), log)
+	default:
+		tw := newTableWriter(tableWriter, style, !opts.noHeaders)
+		writeMetaTable(tw, opts, columns, records)
+
+		if opts.Totals {
+			for _, t := range metaTotals(records, sourceTypeAll.Equal(sourceType)) {
+				fmt.Fprintln(tw, strings.Join(t.row(columns), "\t"))
+			}
+		}
+
+		if err = tw.Flush(); err != nil {
+			log.Fatalf("Error writing results")
+		}
+
+		// The footer tells that sources were left out by --limit.
+		if footer != "" && !opts.noHeaders {
+			fmt.Fprintf(tableWriter, "\n%s\n", footer)
+		}
+	}
+
+	if opts.Copy {
+		var sourceIDs []string
+		for _, r := range records {
+			sourceIDs = append(sourceIDs, r.SourceID)
+		}
+		copySourceIDs(opts.clipboard, sourceIDs, log)
 	}
-	var rows [][]interface{}
-	calculator := newCalculator(ctx, cli, c, log, tailer)
 
+	if len(violations) > 0 {
+		log.Fatalf("%s", strings.Join(violations, "\n"))
+	}
+}
+
+// buildMetaRecords returns a record for every source in meta that is
+// displayed. Sources are deleted from meta once they have a record. Rates
+// are left unset.
+func buildMetaRecords(
+	opts optionsFlags,
+	sourceType string,
+	resources []source,
+	meta map[string]*logcache_v1.MetaInfo,
+	placements map[string]placement,
+	jobs map[string]platformJob,
+	exclude *regexp.Regexp,
+	filter *regexp.Regexp,
+) []metaRecord {
+	var records []metaRecord
 	for _, source := range resources {
 		m, ok := meta[source.GUID]
 		if !ok {
@@ -252,34 +765,30 @@ func Meta(
 		}
 		delete(meta, source.GUID)
 
+		if exclude != nil && exclude.MatchString(source.Name) {
+			continue
+		}
+
+		if !matchesSourceFilter(filter, source.GUID, source.Name) {
+			continue
+		}
+
 		displayApplication := sourceTypeApplication.Equal(sourceType) && source.Type == sourceTypeApplication
 		displayService := sourceTypeService.Equal(sourceType) && source.Type == sourceTypeService
 		if sourceTypeAll.Equal(sourceType) || displayApplication || displayService {
-			args := []interface{}{source.Name, source.Type, m.Count, m.Expired, cacheDuration(m)}
-			if opts.ShowGUID {
-				args = append([]interface{}{source.GUID}, args...)
-			}
-			if opts.EnableNoise {
-				args = append(args, displayRate(calculator.rate(source.GUID)))
-			}
+			r := newMetaRecord(opts, source.GUID, source.Name, source.Type, m)
+			p := placements[source.GUID]
+			r.Org, r.Space = p.org, p.space
 
-			rows = append(rows, args)
+			records = append(records, r)
 		}
 	}
 
 	// Source IDs that aren't apps or services
 	if sourceTypeAll.Equal(sourceType) {
 		for sourceID, m := range meta {
-			if appOrServiceRegex.MatchString(sourceID) {
-				args := []interface{}{sourceID, sourceTypeUnknown, m.Count, m.Expired, cacheDuration(m)}
-				if opts.ShowGUID {
-					args = append([]interface{}{sourceID}, args...)
-				}
-				if opts.EnableNoise {
-					args = append(args, displayRate(calculator.rate(sourceID)))
-				}
-
-				rows = append(rows, args)
+			if appOrServiceRegex.MatchString(sourceID) && matchesSourceFilter(filter, sourceID, sourceID) {
+				records = append(records, newMetaRecord(opts, sourceID, sourceID, sourceTypeUnknown, m))
 			}
 		}
 	}
@@ -287,81 +796,97 @@ func Meta(
 	if sourceTypePlatform.Equal(sourceType) || sourceTypeAll.Equal(sourceType) {
 		for sourceID, m := range meta {
 			if !appOrServiceRegex.MatchString(sourceID) {
-				args := []interface{}{sourceID, sourceTypePlatform, m.Count, m.Expired, cacheDuration(m)}
-				if opts.ShowGUID {
-					args = append([]interface{}{sourceID}, args...)
-				}
-				if opts.EnableNoise {
-					args = append(args, displayRate(calculator.rate(sourceID)))
-				}
+				r := newMetaRecord(opts, sourceID, sourceID, sourceTypePlatform, m)
+				r.job = jobs[sourceID]
 
-				rows = append(rows, args)
+				records = append(records, r)
 			}
 		}
 	}
 
-	sortRows(opts, rows)
+	return records
+}
 
-	for _, r := range rows {
-		fmt.Fprintf(tw, tableFormat, r...)
+// matchesSourceFilter reports whether a source is shown with
+// --source-id-filter, which matches the source ID or name.
+func matchesSourceFilter(filter *regexp.Regexp, sourceID, name string) bool {
+	return filter == nil || filter.MatchString(sourceID) || filter.MatchString(name)
+}
+
+// filterIdleRecords drops the records of sources with fewer envelopes
+// than --min-count or a lower rate than --min-rate.
+func filterIdleRecords(opts optionsFlags, records []metaRecord) []metaRecord {
+	if opts.MinCount <= 0 && opts.MinRate <= 0 {
+		return records
 	}
 
-	if err = tw.Flush(); err != nil {
-		log.Fatalf("Error writing results")
+	var active []metaRecord
+	for _, r := range records {
+		if r.Count < opts.MinCount {
+			continue
+		}
+		if r.Rate != nil && *r.Rate < opts.MinRate {
+			continue
+		}
+
+		active = append(active, r)
 	}
-}
 
-func displayRate(rate int) string {
-	var output string
+	return active
+}
 
-	if rate >= MaximumBatchSize {
-		output = fmt.Sprintf(">%d", MaximumBatchSize-1)
-	} else {
-		output = strconv.Itoa(rate)
+// displayRate formats a rate. A lower bound is shown as more than one
+// below the rate.
+func displayRate(rate int, lowerBound bool) string {
+	if lowerBound {
+		return fmt.Sprintf(">%d", rate-1)
 	}
 
-	return output
+	return strconv.Itoa(rate)
 }
 
-func sortRows(opts optionsFlags, rows [][]interface{}) {
-	var sorter sort.Interface
-	var columnPadding int
+// sortRecords sorts the records by --sort-by. Ties are broken on the
+// source ID, so records with equal values are not left in map iteration
+// order.
+func sortRecords(opts optionsFlags, records []metaRecord) {
+	less := metaRecordLess(opts.SortBy)
+	sort.Slice(records, func(i, j int) bool {
+		if opts.Desc {
+			i, j = j, i
+		}
 
-	// if we're sending the --guid flag, we prepend the source id column,
-	// which pushes over all the other columns by 1
-	if opts.ShowGUID {
-		columnPadding += 1
-	}
+		if less(records[i], records[j]) {
+			return true
+		}
+		if less(records[j], records[i]) {
+			return false
+		}
 
-	switch opts.SortBy {
+		return records[i].SourceID < records[j].SourceID
+	})
+}
+
+func metaRecordLess(sb string) func(a, b metaRecord) bool {
+	switch sb {
 	case string(sortBySourceID):
-		sorter = newColumnSorterWithLesser(&sourceLesser{
-			colToSortOn: 0,
-			rows:        rows,
-		}, rows)
-	case string(sortBySource):
-		sorter = newColumnSorterWithLesser(&sourceLesser{
-			colToSortOn: 0 + columnPadding,
-			rows:        rows,
-		}, rows)
+		return func(a, b metaRecord) bool { return sourceLess(a.SourceID, b.SourceID) }
 	case string(sortBySourceType):
-		sorter = newColumnSorter(1+columnPadding, rows)
+		return func(a, b metaRecord) bool { return a.SourceType < b.SourceType }
 	case string(sortByCount):
-		sorter = newColumnSorter(2+columnPadding, rows)
+		return func(a, b metaRecord) bool { return a.Count < b.Count }
 	case string(sortByExpired):
-		sorter = newColumnSorter(3+columnPadding, rows)
+		return func(a, b metaRecord) bool { return a.Expired < b.Expired }
 	case string(sortByCacheDuration):
-		sorter = newColumnSorter(4+columnPadding, rows)
+		return func(a, b metaRecord) bool { return a.CacheDuration < b.CacheDuration }
 	case string(sortByRate):
-		sorter = newColumnSorter(5+columnPadding, rows)
+		return func(a, b metaRecord) bool { return a.rate() < b.rate() }
+	case string(sortByExpiredRatio):
+		return func(a, b metaRecord) bool {
+			return newExpiredRatio(a.Count, a.Expired) < newExpiredRatio(b.Count, b.Expired)
+		}
 	default:
-		sorter = newColumnSorterWithLesser(&sourceLesser{
-			colToSortOn: 0 + columnPadding,
-			rows:        rows,
-		}, rows)
+		return func(a, b metaRecord) bool { return sourceLess(a.Name, b.Name) }
 	}
-
-	sort.Sort(sorter)
 }
 
 func getSourceInfo(metaInfo map[string]*logcache_v1.MetaInfo, cli plugin.CliConnection) ([]source, error) {
@@ -375,6 +900,7 @@ func getSourceInfo(metaInfo map[string]*logcache_v1.MetaInfo, cli plugin.CliConn
 		meta[k] = 1
 		sourceIDs = append(sourceIDs, k)
 	}
+	sort.Strings(sourceIDs)
 
 	appInfo, err := getSourceInfoFromCAPI(sourceIDs, "/v3/apps", cli)
 	if err != nil {
@@ -400,37 +926,47 @@ func getSourceInfo(metaInfo map[string]*logcache_v1.MetaInfo, cli plugin.CliConn
 	for id := range meta {
 		s = append(s, id)
 	}
+	sort.Strings(s)
 
-	serviceInfo, err := getSourceInfoFromCAPI(s, "/v2/service_instances", cli)
+	serviceInfo, err := getSourceInfoFromCAPI(s, "/v3/service_instances", cli)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, rb := range serviceInfo {
-		var r servicesResponse
+		var r sourceInfo
 		err := json.NewDecoder(strings.NewReader(rb)).Decode(&r)
 		if err != nil {
 			return nil, err
 		}
+
 		for _, res := range r.Resources {
-			resources = append(resources, source{
-				GUID: res.Metadata.GUID,
-				Name: res.Entity.Name,
-				Type: sourceTypeService,
-			})
+			res.Type = sourceTypeService
+			resources = append(resources, res)
+			delete(meta, res.GUID)
 		}
 	}
 
-	return resources, nil
+	// Other GUIDs may be tasks, which log with their own GUID.
+	var guids []string
+	for _, id := range s {
+		if _, ok := meta[id]; ok && appOrServiceRegex.MatchString(id) {
+			guids = append(guids, id)
+		}
+	}
+
+	tasks, err := getTaskSources(guids, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(resources, tasks...), nil
 }
 
 func getSourceInfoFromCAPI(sourceIDs []string, endpoint string, cli plugin.CliConnection) ([]string, error) {
 	var responses []string
 	for len(sourceIDs) > 0 {
-		n := 50
-		if len(sourceIDs) < 50 {
-			n = len(sourceIDs)
-		}
+		n := capiBatchLength(sourceIDs)
 
 		lines, err := cli.CliCommandWithoutTerminalOutput(
 			"curl",
@@ -447,6 +983,22 @@ func getSourceInfoFromCAPI(sourceIDs []string, endpoint string, cli plugin.CliCo
 	return responses, nil
 }
 
+// capiBatchLength returns how many of the source IDs fit in the next CAPI
+// request. A batch always has at least one source ID.
+func capiBatchLength(sourceIDs []string) int {
+	length := len(sourceIDs[0])
+	n := 1
+	for n < len(sourceIDs) && n < capiGUIDBatchSize {
+		length += len(",") + len(sourceIDs[n])
+		if length > capiGUIDQueryLength {
+			break
+		}
+		n++
+	}
+
+	return n
+}
+
 func cacheDuration(m *logcache_v1.MetaInfo) time.Duration {
 	new := time.Unix(0, m.NewestTimestamp)
 	old := time.Unix(0, m.OldestTimestamp)
@@ -454,6 +1006,28 @@ func cacheDuration(m *logcache_v1.MetaInfo) time.Duration {
 	return maxDuration(time.Second, new.Sub(old).Truncate(time.Second))
 }
 
+// expiredRatio is the share of the envelopes of a source that have expired,
+// Expired / (Count + Expired), in percent.
+type expiredRatio float64
+
+func newExpiredRatio(count, expired int64) expiredRatio {
+	if count+expired <= 0 {
+		return 0
+	}
+
+	return expiredRatio(float64(expired) * 100 / float64(count+expired))
+}
+
+func (r expiredRatio) String() string {
+	return fmt.Sprintf("%.1f%%", float64(r))
+}
+
+// preciseCacheDuration is the cache duration without truncation to
+// seconds.
+func preciseCacheDuration(m *logcache_v1.MetaInfo) time.Duration {
+	return time.Unix(0, m.NewestTimestamp).Sub(time.Unix(0, m.OldestTimestamp))
+}
+
 func maxDuration(a, b time.Duration) time.Duration {
 	if a < b {
 		return b
@@ -461,17 +1035,6 @@ func maxDuration(a, b time.Duration) time.Duration {
 	return a
 }
 
-func truncate(count int, entries map[string]*logcache_v1.MetaInfo) map[string]*logcache_v1.MetaInfo {
-	truncated := make(map[string]*logcache_v1.MetaInfo)
-	for k, v := range entries {
-		if len(truncated) >= count {
-			break
-		}
-		truncated[k] = v
-	}
-	return truncated
-}
-
 func logCacheEndpoint(cli plugin.CliConnection) (string, error) {
 	logCacheAddr := os.Getenv("LOG_CACHE_ADDR")
 
@@ -512,11 +1075,13 @@ func invalidSortBy(sb string) bool {
 	validSortBy := []sortBy{
 		sortBySourceID,
 		sortBySource,
+		sortByAppName,
 		sortBySourceType,
 		sortByCount,
 		sortByExpired,
 		sortByCacheDuration,
 		sortByRate,
+		sortByExpiredRatio,
 	}
 
 	if sb == "" {
@@ -532,109 +1097,9 @@ func invalidSortBy(sb string) bool {
 	return true
 }
 
-func (s *columnLesser) Less(i, j int) bool {
-	if sourceI, ok := s.rows[i][s.colToSortOn].(int); ok {
-		sourceJ := s.rows[j][s.colToSortOn].(int)
-
-		return sourceI < sourceJ
-	}
-
-	if sourceI, ok := s.rows[i][s.colToSortOn].(int64); ok {
-		sourceJ := s.rows[j][s.colToSortOn].(int64)
-
-		return sourceI < sourceJ
-	}
-
-	if sourceI, ok := s.rows[i][s.colToSortOn].(string); ok {
-		sourceJ := s.rows[j][s.colToSortOn].(string)
-
-		// We might be sorting a rate that is ">999", which will return an
-		// error when we try to convert to an integer. Catch those rates and
-		// explicitly treat those as the greater value, returning true or
-		// false as appropriate depending on which side of the comparison it
-		// falls on.
-		numSourceI, err := strconv.Atoi(sourceI)
-		if err != nil {
-			return false
-		}
-
-		numSourceJ, err := strconv.Atoi(sourceJ)
-		if err != nil {
-			return true
-		}
-
-		return numSourceI < numSourceJ
-	}
-
-	if sourceI, ok := s.rows[i][s.colToSortOn].(time.Duration); ok {
-		sourceJ := s.rows[j][s.colToSortOn].(time.Duration)
-
-		return sourceI < sourceJ
-	}
-
-	if sourceI, ok := s.rows[i][s.colToSortOn].(sourceType); ok {
-		sourceJ := s.rows[j][s.colToSortOn].(sourceType)
-
-		return sourceI < sourceJ
-	}
-
-	return false
-}
-
-type lesser interface {
-	Less(i, j int) bool
-}
-
-type columnLesser struct {
-	colToSortOn int
-	rows        [][]interface{}
-}
-
-type columnSorter struct {
-	l    lesser
-	rows [][]interface{}
-}
-
-func newColumnSorterWithLesser(l lesser, rows [][]interface{}) *columnSorter {
-	return &columnSorter{
-		l:    l,
-		rows: rows,
-	}
-}
-
-func newColumnSorter(colToSortOn int, rows [][]interface{}) *columnSorter {
-	return &columnSorter{
-		l: &columnLesser{
-			colToSortOn: colToSortOn,
-			rows:        rows,
-		},
-		rows: rows,
-	}
-}
-
-func (s *columnSorter) Len() int {
-	return len(s.rows)
-}
-
-func (s *columnSorter) Less(i, j int) bool {
-	return s.l.Less(i, j)
-}
-
-func (s *columnSorter) Swap(i, j int) {
-	t := s.rows[i]
-	s.rows[i] = s.rows[j]
-	s.rows[j] = t
-}
-
-type sourceLesser struct {
-	colToSortOn int
-	rows        [][]interface{}
-}
-
-func (s *sourceLesser) Less(i, j int) bool {
-	sourceI := s.rows[i][s.colToSortOn].(string)
-	sourceJ := s.rows[j][s.colToSortOn].(string)
-
+// sourceLess orders source names and IDs alphabetically, with GUIDs after
+// every other source.
+func sourceLess(sourceI, sourceJ string) bool {
 	isGuidI := appOrServiceRegex.MatchString(sourceI)
 	isGuidJ := appOrServiceRegex.MatchString(sourceJ)
 
@@ -0,0 +1,73 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+// metaBreakdownSampleSize is the number of the newest envelopes read from
+// every source to tell which envelope types it stores.
+const metaBreakdownSampleSize = 100
+
+type envelopeBreakdown struct {
+	logs, counters, gauges, timers, events int
+}
+
+// writeMetaBreakdown reads a sample of the newest envelopes of every
+// record's source and writes how many of them are logs, counters, gauges,
+// timers and events.
+func writeMetaBreakdown(
+	ctx context.Context,
+	client *logcache.Client,
+	records []metaRecord,
+	tw *tableWriter,
+	log Logger,
+) {
+	fmt.Fprintf(tw, "Source\tSampled\tLogs\tCounters\tGauges\tTimers\tEvents\n")
+	for _, r := range records {
+		b, sampled, err := sampleEnvelopeTypes(ctx, client, r.SourceID)
+		if err != nil {
+			log.Fatalf("Failed to read envelopes of %s: %s", r.Name, err)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", r.Name, sampled, b.logs, b.counters, b.gauges, b.timers, b.events)
+	}
+
+	if err := tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+func sampleEnvelopeTypes(ctx context.Context, client *logcache.Client, sourceID string) (envelopeBreakdown, int, error) {
+	envelopes, err := client.Read(
+		ctx,
+		sourceID,
+		time.Unix(0, 0),
+		logcache.WithLimit(metaBreakdownSampleSize),
+		logcache.WithDescending(),
+	)
+	if err != nil {
+		return envelopeBreakdown{}, 0, err
+	}
+
+	var b envelopeBreakdown
+	for _, e := range envelopes {
+		switch {
+		case e.GetLog() != nil:
+			b.logs++
+		case e.GetCounter() != nil:
+			b.counters++
+		case e.GetGauge() != nil:
+			b.gauges++
+		case e.GetTimer() != nil:
+			b.timers++
+		case e.GetEvent() != nil:
+			b.events++
+		}
+	}
+
+	return b, len(envelopes), nil
+}
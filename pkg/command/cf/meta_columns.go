@@ -0,0 +1,162 @@
+package cf
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metaColumnHeaders maps the columns that can be selected with --columns
+// to their headers.
+var metaColumnHeaders = map[string]string{
+	"source-id":      "Source ID",
+	"source":         "Source",
+	"app-name":       "Source",
+	"source-type":    "Source Type",
+	"count":          "Count",
+	"expired":        "Expired",
+	"expired-ratio":  "Expired %",
+	"cache-duration": "Cache Duration",
+	"rate":           "Rate",
+	"org":            "Org",
+	"space":          "Space",
+	"newest":         "Newest",
+	"oldest":         "Oldest",
+}
+
+// metaJobColumnHeaders maps the columns of --include-job, which can't be
+// selected with --columns, to their headers.
+var metaJobColumnHeaders = map[string]string{
+	"deployment": "Deployment",
+	"job":        "Job",
+	"index":      "Index",
+}
+
+// parseMetaColumns parses a comma separated list of columns. The rate and
+// placement columns are only available when they are fetched.
+func parseMetaColumns(s string, opts optionsFlags) ([]string, error) {
+	var columns []string
+	for _, c := range strings.Split(s, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if _, ok := metaColumnHeaders[c]; !ok {
+			return nil, fmt.Errorf("Unknown column '%s'. Available: 'source-id', 'source' (or 'app-name'), 'source-type', 'count', 'expired', 'expired-ratio', 'cache-duration', 'rate', 'org', 'space', 'newest' and 'oldest'.", c)
+		}
+
+		if c == "rate" && !opts.EnableNoise {
+			return nil, fmt.Errorf("Can't show the rate column without --noise flag")
+		}
+
+		if (c == "org" || c == "space") && !opts.Placement {
+			return nil, fmt.Errorf("Can't show the %s column without --include-placement flag", c)
+		}
+
+		columns = append(columns, c)
+	}
+
+	return columns, nil
+}
+
+// metaColumns returns the columns of the default table. The optional
+// columns follow the cache duration when enabled.
+func metaColumns(opts optionsFlags) []string {
+	var columns []string
+	if opts.ShowGUID {
+		columns = append(columns, "source-id")
+	}
+	columns = append(columns, "source", "source-type", "count", "expired", "cache-duration")
+	if opts.EnableNoise {
+		columns = append(columns, "rate")
+	}
+	if opts.Placement {
+		columns = append(columns, "org", "space")
+	}
+	if opts.Ratio {
+		columns = append(columns, "expired-ratio")
+	}
+	if opts.Timestamps {
+		columns = append(columns, "oldest", "newest")
+	}
+	if opts.Jobs {
+		columns = append(columns, "deployment", "job", "index")
+	}
+
+	return columns
+}
+
+// metaHeader returns the headers of the columns.
+func metaHeader(columns []string) []string {
+	header := make([]string, 0, len(columns))
+	for _, c := range columns {
+		h, ok := metaColumnHeaders[c]
+		if !ok {
+			h = metaJobColumnHeaders[c]
+		}
+		header = append(header, h)
+	}
+
+	return header
+}
+
+// metaRow formats the columns of a record as they are displayed.
+func metaRow(opts optionsFlags, columns []string, r metaRecord) []string {
+	row := make([]string, 0, len(columns))
+	for _, c := range columns {
+		var v string
+		switch c {
+		case "source-id":
+			v = r.SourceID
+		case "source", "app-name":
+			v = r.Name
+		case "source-type":
+			v = r.SourceType
+		case "count":
+			v = strconv.FormatInt(r.Count, 10)
+		case "expired":
+			v = strconv.FormatInt(r.Expired, 10)
+		case "expired-ratio":
+			v = newExpiredRatio(r.Count, r.Expired).String()
+		case "cache-duration":
+			v = r.CacheDuration.String()
+		case "rate":
+			v = displayRate(r.rate(), r.RateLowerBound)
+		case "org":
+			v = r.Org
+		case "space":
+			v = r.Space
+		case "newest":
+			v = formatMetaTimestamp(r.newest, opts.Precise)
+		case "oldest":
+			v = formatMetaTimestamp(r.oldest, opts.Precise)
+		case "deployment":
+			v = r.job.deployment
+		case "job":
+			v = r.job.job
+		case "index":
+			v = r.job.index
+		}
+		row = append(row, v)
+	}
+
+	return row
+}
+
+// writeMetaTable writes a header row and a row per record with the
+// columns, separated by tabs for a tableWriter.
+func writeMetaTable(w io.Writer, opts optionsFlags, columns []string, records []metaRecord) {
+	fmt.Fprintln(w, strings.Join(metaHeader(columns), "\t"))
+	for _, r := range records {
+		fmt.Fprintln(w, strings.Join(metaRow(opts, columns, r), "\t"))
+	}
+}
+
+// formatMetaTimestamp formats a timestamp in RFC3339, or as the raw
+// nanoseconds with --precise-durations.
+func formatMetaTimestamp(ns int64, precise bool) string {
+	if precise {
+		return strconv.FormatInt(ns, 10)
+	}
+
+	return time.Unix(0, ns).UTC().Format(time.RFC3339)
+}
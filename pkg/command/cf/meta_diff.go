@@ -0,0 +1,130 @@
+package cf
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+type metaDiff struct {
+	sourceID      string
+	count         int64
+	countChange   int64
+	expired       int64
+	expiredChange int64
+}
+
+// diffMeta compares the meta of every source with the last snapshot in the
+// file, sources whose expired count grew the most first. Without a file it
+// saves a snapshot that later runs are compared against. The snapshot is
+// left as it is, so every run compares against the same baseline.
+func diffMeta(
+	ctx context.Context,
+	client *logcache.Client,
+	path string,
+	exclude *regexp.Regexp,
+	tw *tableWriter,
+	w io.Writer,
+	log Logger,
+) {
+	meta, err := client.Meta(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read Meta information: %s", err)
+	}
+	current := newMetaSnapshot(meta, exclude)
+
+	baseline, err := readLastMetaSnapshot(path)
+	if os.IsNotExist(err) {
+		line, err := marshalJSON(current)
+		if err != nil {
+			log.Fatalf("Could not write snapshot: %s", err)
+		}
+		if err := writeFileAtomic(path, append(line, '\n')); err != nil {
+			log.Fatalf("Could not write snapshot: %s", err)
+		}
+
+		fmt.Fprintf(w, "Saved a snapshot of %d sources to %s. Run again with --diff %s to compare.\n", len(current.Sources), path, path)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Could not read %s: %s", path, err)
+	}
+
+	var diffs []metaDiff
+	for sourceID, now := range current.Sources {
+		before := baseline.Sources[sourceID]
+		diffs = append(diffs, metaDiff{
+			sourceID:      sourceID,
+			count:         now.Count,
+			countChange:   now.Count - before.Count,
+			expired:       now.Expired,
+			expiredChange: now.Expired - before.Expired,
+		})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].expiredChange != diffs[j].expiredChange {
+			return diffs[i].expiredChange > diffs[j].expiredChange
+		}
+		return diffs[i].sourceID < diffs[j].sourceID
+	})
+
+	fmt.Fprintf(tw, "Source\tCount\tCount Change\tExpired\tExpired Change\n")
+	var expiring int
+	for _, d := range diffs {
+		if d.expiredChange > 0 {
+			expiring++
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%+d\t%d\t%+d\n", d.sourceID, d.count, d.countChange, d.expired, d.expiredChange)
+	}
+
+	if err := tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+
+	fmt.Fprintf(w, "\n%d of %d sources expired envelopes since %s.\n", expiring, len(diffs), formatTime(baseline.Time, "2006-01-02 15:04:05 MST"))
+}
+
+// readLastMetaSnapshot returns the last snapshot in a file written with
+// --diff or --record.
+func readLastMetaSnapshot(path string) (metaSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return metaSnapshot{}, err
+	}
+	defer f.Close()
+
+	var last metaSnapshot
+	var found bool
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var s metaSnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return metaSnapshot{}, fmt.Errorf("line %d: %s", n, err)
+		}
+		if s.SchemaVersion > schemaVersion {
+			return metaSnapshot{}, fmt.Errorf("line %d: schema version %d is newer than the supported version %d", n, s.SchemaVersion, schemaVersion)
+		}
+
+		last, found = s, true
+	}
+	if err := scanner.Err(); err != nil {
+		return metaSnapshot{}, err
+	}
+
+	if !found {
+		return metaSnapshot{}, errors.New("no snapshot recorded")
+	}
+
+	return last, nil
+}
@@ -0,0 +1,123 @@
+package cf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/cli/plugin"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+type metaGroup struct {
+	placement
+	sources int
+	count   int64
+	expired int64
+	rate    int
+
+	// saturated is set when the rate of a source is a lower bound, which
+	// makes the sum a lower bound.
+	saturated bool
+}
+
+// writeMetaGroups sums the meta of application sources per org or space.
+// Sources that are not CAPI applications are left out.
+func writeMetaGroups(
+	meta map[string]*logcache_v1.MetaInfo,
+	groupBy string,
+	opts optionsFlags,
+	calculator *calculator,
+	cli plugin.CliConnection,
+	tw *tableWriter,
+	log Logger,
+) {
+	var sourceIDs []string
+	for sourceID := range meta {
+		if appOrServiceRegex.MatchString(sourceID) {
+			sourceIDs = append(sourceIDs, sourceID)
+		}
+	}
+	sort.Strings(sourceIDs)
+
+	placements, err := getPlacements(sourceIDs, cli)
+	if err != nil {
+		log.Fatalf("Failed to read application information: %s", err)
+	}
+
+	groups := make(map[placement]*metaGroup)
+	for _, sourceID := range sourceIDs {
+		p, ok := placements[sourceID]
+		if !ok {
+			continue
+		}
+		if groupBy == groupByOrg {
+			p.space = ""
+		}
+
+		g, ok := groups[p]
+		if !ok {
+			g = &metaGroup{placement: p}
+			groups[p] = g
+		}
+
+		m := meta[sourceID]
+		g.sources++
+		g.count += m.Count
+		g.expired += m.Expired
+		if opts.EnableNoise {
+			rate, lowerBound := calculator.rate(sourceID)
+			g.rate += rate
+			g.saturated = g.saturated || lowerBound
+		}
+	}
+
+	var rows []*metaGroup
+	for _, g := range groups {
+		rows = append(rows, g)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].org != rows[j].org {
+			return rows[i].org < rows[j].org
+		}
+		return rows[i].space < rows[j].space
+	})
+
+	headerArgs := []interface{}{"Org", "Sources", "Count", "Expired"}
+	headerFormat := "%s\t%s\t%s\t%s\n"
+	tableFormat := "%s\t%d\t%d\t%d\n"
+
+	if groupBy == groupBySpace {
+		headerArgs = append([]interface{}{"Org", "Space"}, headerArgs[1:]...)
+		headerFormat = "%s\t" + headerFormat
+		tableFormat = "%s\t" + tableFormat
+	}
+
+	if opts.EnableNoise {
+		headerArgs = append(headerArgs, "Rate")
+		headerFormat = strings.Replace(headerFormat, "\n", "\t%s\n", 1)
+		tableFormat = strings.Replace(tableFormat, "\n", "\t%s\n", 1)
+	}
+
+	fmt.Fprintf(tw, headerFormat, headerArgs...)
+	for _, g := range rows {
+		args := []interface{}{g.org}
+		if groupBy == groupBySpace {
+			args = append(args, g.space)
+		}
+		args = append(args, g.sources, g.count, g.expired)
+		if opts.EnableNoise {
+			rate := strconv.Itoa(g.rate)
+			if g.saturated {
+				rate = ">" + rate
+			}
+			args = append(args, rate)
+		}
+		fmt.Fprintf(tw, tableFormat, args...)
+	}
+
+	if err := tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
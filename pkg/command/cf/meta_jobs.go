@@ -0,0 +1,60 @@
+package cf
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+// platformJob is the BOSH deployment, job and index the newest envelope of
+// a platform source was emitted from.
+type platformJob struct {
+	deployment string
+	job        string
+	index      string
+}
+
+// platformJobs reads the newest envelope of every platform source and
+// returns the BOSH job it belongs to. Sources without envelopes or tags are
+// left out.
+func platformJobs(
+	ctx context.Context,
+	client *logcache.Client,
+	sourceIDs []string,
+	log Logger,
+) map[string]platformJob {
+	jobs := make(map[string]platformJob, len(sourceIDs))
+	for _, sourceID := range sourceIDs {
+		envelopes, err := client.Read(
+			ctx,
+			sourceID,
+			time.Unix(0, 0),
+			logcache.WithLimit(1),
+			logcache.WithDescending(),
+		)
+		if err != nil {
+			log.Fatalf("Failed to read envelopes of %s: %s", sourceID, err)
+		}
+		if len(envelopes) == 0 {
+			continue
+		}
+
+		jobs[sourceID] = platformJob{
+			deployment: envelopeTag(envelopes[0], "deployment"),
+			job:        envelopeTag(envelopes[0], "job"),
+			index:      envelopeTag(envelopes[0], "index"),
+		}
+	}
+
+	return jobs
+}
+
+func envelopeTag(e *loggregator_v2.Envelope, name string) string {
+	if v, ok := e.GetTags()[name]; ok {
+		return v
+	}
+
+	return e.GetDeprecatedTags()[name].GetText()
+}
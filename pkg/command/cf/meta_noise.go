@@ -0,0 +1,78 @@
+package cf
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+const (
+	noiseModeTail = "tail"
+	noiseModeMeta = "meta"
+)
+
+// metaDeltaRates measures the envelopes per minute of every source from a
+// second meta snapshot taken the interval after the first. Envelopes
+// ingested in between are either still cached or have expired since, so
+// the rate is derived from the growth of count and expired together.
+func metaDeltaRates(
+	ctx context.Context,
+	client *logcache.Client,
+	first map[string]*logcache_v1.MetaInfo,
+	interval time.Duration,
+	log Logger,
+) map[string]int {
+	select {
+	case <-time.After(interval):
+	case <-ctx.Done():
+		log.Fatalf("%s", ctx.Err())
+	}
+
+	second, err := client.Meta(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read Meta information: %s", err)
+	}
+
+	rates := make(map[string]int, len(first))
+	for sourceID, before := range first {
+		after, ok := second[sourceID]
+		if !ok {
+			continue
+		}
+
+		ingested := (after.Count - before.Count) + (after.Expired - before.Expired)
+		if ingested < 0 {
+			ingested = 0
+		}
+
+		rates[sourceID] = int(ingested * int64(time.Minute) / int64(interval))
+	}
+
+	return rates
+}
+
+// fillRates sets the rate of every record. Up to the given number of
+// sources are measured at the same time.
+func fillRates(records []metaRecord, calc *calculator, workers int) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(records); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				rate, lowerBound := calc.rate(records[j].SourceID)
+				records[j].Rate = &rate
+				records[j].RateLowerBound = lowerBound
+			}
+		}()
+	}
+	for i := range records {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,383 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	metaOutputTable      = "table"
+	metaOutputCSV        = "csv"
+	metaOutputJSON       = "json"
+	metaOutputYAML       = "yaml"
+	metaOutputPrometheus = "prometheus"
+)
+
+// metaRecord is a single source in the output of Meta. The table is
+// formatted from the same records as the machine-readable output.
+type metaRecord struct {
+	SourceID      string        `json:"source_id"`
+	Name          string        `json:"name"`
+	SourceType    string        `json:"source_type"`
+	Count         int64         `json:"count"`
+	Expired       int64         `json:"expired"`
+	CacheDuration time.Duration `json:"-"`
+	Rate          *int          `json:"rate,omitempty"`
+	Org           string        `json:"org,omitempty"`
+	Space         string        `json:"space,omitempty"`
+
+	CacheDurationSeconds int64 `json:"cache_duration_seconds"`
+
+	// RateLowerBound is set for sources that reached the batch limit
+	// within --noise-interval, whose rate is higher than measured.
+	RateLowerBound bool `json:"rate_lower_bound,omitempty"`
+
+	// The timestamps, in nanoseconds, and the BOSH job are only written
+	// by the table and YAML output.
+	oldest int64
+	newest int64
+	job    platformJob
+}
+
+// newMetaRecord returns the record of a source. The cache duration is
+// truncated to seconds unless --precise-durations is set.
+func newMetaRecord(opts optionsFlags, sourceID, name string, st sourceType, m *logcache_v1.MetaInfo) metaRecord {
+	duration := cacheDuration(m)
+	if opts.Precise {
+		duration = preciseCacheDuration(m)
+	}
+
+	return metaRecord{
+		SourceID:             sourceID,
+		Name:                 name,
+		SourceType:           string(st),
+		Count:                m.Count,
+		Expired:              m.Expired,
+		CacheDuration:        duration,
+		CacheDurationSeconds: int64(duration / time.Second),
+		oldest:               m.OldestTimestamp,
+		newest:               m.NewestTimestamp,
+	}
+}
+
+// rate returns the rate of the record, or 0 without --noise.
+func (r metaRecord) rate() int {
+	if r.Rate == nil {
+		return 0
+	}
+
+	return *r.Rate
+}
+
+type metaDocument struct {
+	Sources []metaRecord `json:"sources"`
+}
+
+// writeMetaSource writes the record of a single source lookup with one
+// line per column.
+func writeMetaSource(tw *tableWriter, opts optionsFlags, columns []string, r metaRecord, log Logger) {
+	row := metaRow(opts, columns, r)
+
+	fmt.Fprintf(tw, "Field\tValue\n")
+	for i, h := range metaHeader(columns) {
+		fmt.Fprintf(tw, "%s\t%s\n", h, row[i])
+	}
+
+	if err := tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+// writeMetaJSON writes the records as a single JSON document.
+func writeMetaJSON(w io.Writer, records []metaRecord, log Logger) {
+	data, err := marshalJSON(metaDocument{Sources: records})
+	if err != nil {
+		log.Fatalf("Could not encode meta information: %s", err)
+	}
+
+	if _, err := w.Write(append([]byte(withSchemaVersion(string(data))), '\n')); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+// metaYAMLRecord is a single source in the YAML output of Meta. Besides
+// the fields of the JSON output it has the raw timestamps, in nanoseconds,
+// of the newest and oldest envelope in the cache.
+type metaYAMLRecord struct {
+	SchemaVersion        int    `yaml:"schema_version"`
+	SourceID             string `yaml:"source_id"`
+	Name                 string `yaml:"name"`
+	SourceType           string `yaml:"source_type"`
+	Count                int64  `yaml:"count"`
+	Expired              int64  `yaml:"expired"`
+	CacheDurationSeconds int64  `yaml:"cache_duration_seconds"`
+	Rate                 *int   `yaml:"rate,omitempty"`
+	RateLowerBound       bool   `yaml:"rate_lower_bound,omitempty"`
+	Org                  string `yaml:"org,omitempty"`
+	Space                string `yaml:"space,omitempty"`
+	NewestTimestamp      int64  `yaml:"newest_timestamp"`
+	OldestTimestamp      int64  `yaml:"oldest_timestamp"`
+}
+
+// writeMetaYAML writes a YAML document per record.
+func writeMetaYAML(w io.Writer, records []metaRecord, log Logger) {
+	enc := yaml.NewEncoder(w)
+
+	for _, r := range records {
+		record := metaYAMLRecord{
+			SchemaVersion:        schemaVersion,
+			SourceID:             r.SourceID,
+			Name:                 r.Name,
+			SourceType:           r.SourceType,
+			Count:                r.Count,
+			Expired:              r.Expired,
+			CacheDurationSeconds: r.CacheDurationSeconds,
+			Rate:                 r.Rate,
+			RateLowerBound:       r.RateLowerBound,
+			Org:                  r.Org,
+			Space:                r.Space,
+			NewestTimestamp:      r.newest,
+			OldestTimestamp:      r.oldest,
+		}
+
+		if err := enc.Encode(record); err != nil {
+			log.Fatalf("Error writing results")
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+// writeMetaCSV writes the records with the columns of the table. The
+// header is always written so spreadsheets can label the columns.
+func writeMetaCSV(w io.Writer, opts optionsFlags, columns []string, records []metaRecord, log Logger) {
+	cw := csv.NewWriter(w)
+
+	cw.Write(metaHeader(columns))
+	for _, r := range records {
+		cw.Write(metaRow(opts, columns, r))
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+// metaPrometheusMetrics are the metrics of --output prometheus with a
+// function returning the value of a record. Rate is only written with
+// --noise.
+var metaPrometheusMetrics = []struct {
+	name  string
+	help  string
+	value func(metaRecord) (int64, bool)
+}{
+	{
+		name:  "log_cache_source_count",
+		help:  "Number of envelopes cached for the source.",
+		value: func(r metaRecord) (int64, bool) { return r.Count, true },
+	},
+	{
+		name:  "log_cache_source_expired",
+		help:  "Number of envelopes of the source that expired from the cache.",
+		value: func(r metaRecord) (int64, bool) { return r.Expired, true },
+	},
+	{
+		name:  "log_cache_source_cache_duration_seconds",
+		help:  "Time between the oldest and the newest cached envelope of the source.",
+		value: func(r metaRecord) (int64, bool) { return r.CacheDurationSeconds, true },
+	},
+	{
+		name: "log_cache_source_rate",
+		help: "Envelopes per minute of the source.",
+		value: func(r metaRecord) (int64, bool) {
+			if r.Rate == nil {
+				return 0, false
+			}
+			return int64(*r.Rate), true
+		},
+	},
+}
+
+// writeMetaPrometheus writes the records in the Prometheus text exposition
+// format, e.g. for the textfile collector of the node exporter.
+func writeMetaPrometheus(w io.Writer, records []metaRecord, log Logger) {
+	var buf bytes.Buffer
+	for _, m := range metaPrometheusMetrics {
+		var samples []string
+		for _, r := range records {
+			v, ok := m.value(r)
+			if !ok {
+				continue
+			}
+			samples = append(samples, fmt.Sprintf("%s{%s} %d\n", m.name, prometheusLabels(r), v))
+		}
+		if len(samples) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&buf, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n", m.name)
+		buf.WriteString(strings.Join(samples, ""))
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+func prometheusLabels(r metaRecord) string {
+	labels := []string{
+		prometheusLabel("source_id", r.SourceID),
+		prometheusLabel("app_name", r.Name),
+		prometheusLabel("source_type", r.SourceType),
+	}
+	if r.Org != "" || r.Space != "" {
+		labels = append(labels, prometheusLabel("org", r.Org), prometheusLabel("space", r.Space))
+	}
+
+	return strings.Join(labels, ",")
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func prometheusLabel(name, value string) string {
+	return name + `="` + prometheusLabelEscaper.Replace(value) + `"`
+}
+
+// metaTemplateRow is the data --output-template is executed with for
+// every source. Rate is formatted as in the table and empty without
+// --noise, Org and Space are empty without --include-placement.
+type metaTemplateRow struct {
+	SourceID      string
+	AppName       string
+	SourceType    string
+	Count         int64
+	Expired       int64
+	CacheDuration time.Duration
+	Rate          string
+	Org           string
+	Space         string
+}
+
+// writeMetaTemplate writes a line per record formatted with the template.
+// Records the template formats as an empty string are skipped.
+func writeMetaTemplate(w io.Writer, t *template.Template, records []metaRecord, log Logger) {
+	for _, r := range records {
+		row := metaTemplateRow{
+			SourceID:      r.SourceID,
+			AppName:       r.Name,
+			SourceType:    r.SourceType,
+			Count:         r.Count,
+			Expired:       r.Expired,
+			CacheDuration: r.CacheDuration,
+			Org:           r.Org,
+			Space:         r.Space,
+		}
+		if r.Rate != nil {
+			row.Rate = displayRate(*r.Rate, r.RateLowerBound)
+		}
+
+		var b bytes.Buffer
+		if err := t.Execute(&b, row); err != nil {
+			log.Fatalf("Output template parsed, but failed to execute: %s", err)
+		}
+
+		if b.Len() == 0 {
+			continue
+		}
+
+		fmt.Fprintln(w, b.String())
+	}
+}
+
+// metaTotal is the sum of count, expired and rate of a set of records.
+// Rates that are a lower bound make the sum a lower bound, which is prefixed
+// with ">".
+type metaTotal struct {
+	name      string
+	count     int64
+	expired   int64
+	rate      int
+	saturated bool
+}
+
+func (t *metaTotal) add(r metaRecord) {
+	t.count += r.Count
+	t.expired += r.Expired
+	if r.Rate != nil {
+		t.rate += *r.Rate
+		t.saturated = t.saturated || r.RateLowerBound
+	}
+}
+
+// row formats the columns of the total. Columns without a sum are left
+// empty.
+func (t *metaTotal) row(columns []string) []string {
+	row := make([]string, 0, len(columns))
+	for _, c := range columns {
+		var v string
+		switch c {
+		case "source":
+			v = t.name
+		case "count":
+			v = strconv.FormatInt(t.count, 10)
+		case "expired":
+			v = strconv.FormatInt(t.expired, 10)
+		case "rate":
+			v = strconv.Itoa(t.rate)
+			if t.saturated {
+				v = ">" + v
+			}
+		case "expired-ratio":
+			v = newExpiredRatio(t.count, t.expired).String()
+		}
+		row = append(row, v)
+	}
+
+	return row
+}
+
+// metaTotals returns the total of the records, preceded by a subtotal per
+// source type when the records have more than one.
+func metaTotals(records []metaRecord, subtotals bool) []*metaTotal {
+	all := &metaTotal{name: "Total"}
+	bySourceType := make(map[string]*metaTotal)
+	for _, r := range records {
+		all.add(r)
+
+		t, ok := bySourceType[r.SourceType]
+		if !ok {
+			t = &metaTotal{name: "Total " + r.SourceType}
+			bySourceType[r.SourceType] = t
+		}
+		t.add(r)
+	}
+
+	var totals []*metaTotal
+	if subtotals && len(bySourceType) > 1 {
+		var sourceTypes []string
+		for st := range bySourceType {
+			sourceTypes = append(sourceTypes, st)
+		}
+		sort.Strings(sourceTypes)
+
+		for _, st := range sourceTypes {
+			totals = append(totals, bySourceType[st])
+		}
+	}
+
+	return append(totals, all)
+}
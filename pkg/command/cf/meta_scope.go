@@ -0,0 +1,64 @@
+package cf
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+const (
+	metaScopeOrg   = "org"
+	metaScopeSpace = "space"
+)
+
+// scopedSources returns the apps in the targeted org or space and drops
+// every other source from meta. The names come from the same request, so
+// no further CAPI lookups are needed.
+func scopedSources(
+	scope string,
+	meta map[string]*logcache_v1.MetaInfo,
+	cli plugin.CliConnection,
+	log Logger,
+) []source {
+	var apps []v3Resource
+	switch scope {
+	case metaScopeOrg:
+		org, err := cli.GetCurrentOrg()
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		apps, err = getOrgApps(org.Guid, cli)
+		if err != nil {
+			log.Fatalf("Failed to read apps in org %s: %s", org.Name, err)
+		}
+	default:
+		space, err := cli.GetCurrentSpace()
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		apps, err = getSpaceApps(space.Guid, cli)
+		if err != nil {
+			log.Fatalf("Failed to read apps in space %s: %s", space.Name, err)
+		}
+	}
+
+	inScope := make(map[string]bool)
+	var sources []source
+	for _, app := range apps {
+		inScope[app.GUID] = true
+		sources = append(sources, source{
+			GUID: app.GUID,
+			Name: app.Name,
+			Type: sourceTypeApplication,
+		})
+	}
+
+	for sourceID := range meta {
+		if !inScope[sourceID] {
+			delete(meta, sourceID)
+		}
+	}
+
+	return sources
+}
@@ -0,0 +1,88 @@
+package cf
+
+import (
+	"io"
+	"regexp"
+	"sort"
+
+	"code.cloudfoundry.org/cli/plugin"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+// streamMeta writes the rows of meta batch by batch, each as soon as the
+// names and rates of its sources are known, instead of waiting for every
+// source. A batch holds the GUIDs of a single CAPI request, platform
+// sources come last. Rows are only sorted, and columns only aligned,
+// within their batch.
+func streamMeta(
+	opts optionsFlags,
+	sourceType string,
+	meta map[string]*logcache_v1.MetaInfo,
+	exclude *regexp.Regexp,
+	filter *regexp.Regexp,
+	calc *calculator,
+	cli plugin.CliConnection,
+	w io.Writer,
+	style tableStyle,
+	log Logger,
+) {
+	columns := metaColumns(opts)
+
+	for i, batch := range metaStreamBatches(meta) {
+		sources := make(map[string]*logcache_v1.MetaInfo, len(batch))
+		for _, sourceID := range batch {
+			sources[sourceID] = meta[sourceID]
+		}
+
+		var resources []source
+		if appOrServiceRegex.MatchString(batch[0]) {
+			var err error
+			resources, err = getCachedSourceInfo(sources, cli, opts.nameCacheTTL, opts.Refresh)
+			if err != nil {
+				log.Fatalf("Failed to read application information: %s", err)
+			}
+		}
+
+		records := buildMetaRecords(opts, sourceType, resources, sources, nil, nil, exclude, filter)
+		if opts.EnableNoise {
+			fillRates(records, calc, opts.NoiseWorkers)
+		}
+		records = filterIdleRecords(opts, records)
+		sortRecords(opts, records)
+
+		// Only the first batch has a header row.
+		tw := newTableWriter(w, style, i == 0 && !opts.noHeaders)
+		writeMetaTable(tw, opts, columns, records)
+
+		if err := tw.Flush(); err != nil {
+			log.Fatalf("Error writing results")
+		}
+	}
+}
+
+// metaStreamBatches splits the source IDs of meta into the batches of
+// streamMeta.
+func metaStreamBatches(meta map[string]*logcache_v1.MetaInfo) [][]string {
+	var guids, platform []string
+	for sourceID := range meta {
+		if appOrServiceRegex.MatchString(sourceID) {
+			guids = append(guids, sourceID)
+			continue
+		}
+		platform = append(platform, sourceID)
+	}
+	sort.Strings(guids)
+	sort.Strings(platform)
+
+	var batches [][]string
+	for len(guids) > 0 {
+		n := capiBatchLength(guids)
+		batches = append(batches, guids[:n])
+		guids = guids[n:]
+	}
+	if len(platform) > 0 {
+		batches = append(batches, platform)
+	}
+
+	return batches
+}
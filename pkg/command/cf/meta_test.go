@@ -3,11 +3,17 @@ package cf_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
 
@@ -36,7 +42,7 @@ var _ = Describe("Meta", func() {
 
 	Context("when specifying a sort by flag", func() {
 		It("specifying `--sort-by rate` sorts by the rate column", func() {
-			tailer := func(sourceID string) []string {
+			tailer := func(sourceID string, _ time.Duration, _ int) []string {
 				switch sourceID {
 				case "source-1":
 					return generateBatch(5)
@@ -98,7 +104,7 @@ var _ = Describe("Meta", func() {
 		})
 
 		It("returns rate of >999 when the batch limit of 1000 is reached, while still sorting correctly", func() {
-			tailer := func(sourceID string) []string {
+			tailer := func(sourceID string, _ time.Duration, _ int) []string {
 				switch sourceID {
 				case "source-1":
 					return generateBatch(1000)
@@ -156,6 +162,38 @@ var _ = Describe("Meta", func() {
 			Expect(httpClient.requestCount()).To(Equal(1))
 		})
 
+		It("reads up to the read limit reported by Log Cache", func() {
+			httpClient.maxReadLimit = 500
+
+			var limits []int
+			tailer := func(sourceID string, _ time.Duration, limit int) []string {
+				limits = append(limits, limit)
+				return generateBatch(500)
+			}
+
+			httpClient.responseBody = []string{
+				metaResponseInfo("source-1"),
+			}
+			cliConn.cliCommandResult = [][]string{
+				{capiAppsResponse(map[string]string{"source-1": "app-1"})},
+				{capiServiceInstancesResponse(map[string]string{})},
+			}
+			cliConn.cliCommandErr = nil
+
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				tailer,
+				[]string{"--noise"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+
+			Expect(limits).To(Equal([]int{500}))
+			Expect(tableWriter.String()).To(MatchRegexp(`app-1\s+application\s+100000\s+85008\s+\S+\s+>499\n`))
+		})
+
 		It("specifying `--sort-by source-type` sorts by the source type column", func() {
 			httpClient.responseBody = []string{
 				variedMetaResponseInfo("source-1", "source-2", "source-3"),
@@ -339,6 +377,78 @@ var _ = Describe("Meta", func() {
 			Expect(httpClient.requestCount()).To(Equal(1))
 		})
 
+		It("specifying `--desc` reverses the sort order", func() {
+			httpClient.responseBody = []string{
+				variedMetaResponseInfo("source-1", "source-2", "source-3", "source-4"),
+			}
+
+			cliConn.cliCommandResult = [][]string{
+				{
+					capiAppsResponse(map[string]string{
+						"source-1": "app-1",
+						"source-2": "app-2",
+						"source-3": "app-3",
+						"source-4": "app-4",
+					}),
+				},
+			}
+			cliConn.cliCommandErr = nil
+
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--sort-by", "count", "--desc"},
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaNoHeaders(),
+			)
+
+			Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+				"app-2  application  100002  84998  4m30s",
+				"app-1  application  100001  84999  1s",
+				"app-3  application  99997   85003  9m0s",
+				"app-4  application  99996   85004  13m30s",
+				"",
+			}))
+		})
+
+		It("specifying `--sort-by app-name` sorts by the source column", func() {
+			httpClient.responseBody = []string{
+				metaResponseInfo("source-1", "source-2", "source-3"),
+			}
+
+			cliConn.cliCommandResult = [][]string{
+				{
+					capiAppsResponse(map[string]string{
+						"source-1": "app-b",
+						"source-2": "app-a",
+						"source-3": "app-a",
+					}),
+				},
+			}
+			cliConn.cliCommandErr = nil
+
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--sort-by", "app-name", "--guid"},
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaNoHeaders(),
+			)
+
+			Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+				"source-2  app-a  application  100000  85008  11m45s",
+				"source-3  app-a  application  100000  85008  11m45s",
+				"source-1  app-b  application  100000  85008  1s",
+				"",
+			}))
+		})
+
 		It("specifying `--sort-by expired` sorts by the expired column", func() {
 			httpClient.responseBody = []string{
 				variedMetaResponseInfo("source-1", "source-2", "source-3", "source-4"),
@@ -440,7 +550,7 @@ var _ = Describe("Meta", func() {
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("Sort by must be 'source-id', 'source', 'source-type', 'count', 'expired', 'cache-duration', or 'rate'."))
+			Expect(logger.fatalfMessage).To(Equal("Sort by must be 'source-id', 'source', 'app-name', 'source-type', 'count', 'expired', 'cache-duration', 'rate', or 'expired-ratio'."))
 		})
 
 		It("fatally logs when --sort-by source-id is used without --guid", func() {
@@ -505,12 +615,9 @@ var _ = Describe("Meta", func() {
 		Expect(cliConn.cliCommandArgs[0]).To(HaveLen(2))
 		Expect(cliConn.cliCommandArgs[0][0]).To(Equal("curl"))
 
-		// Or is required because we don't know the order the keys will come
-		// out of the map.
-		Expect(cliConn.cliCommandArgs[0][1]).To(Or(
-			Equal("/v3/apps?guids=source-1,source-2"),
-			Equal("/v3/apps?guids=source-2,source-1"),
-		))
+		// Source IDs are looked up in order, so repeated runs send the same
+		// requests.
+		Expect(cliConn.cliCommandArgs[0][1]).To(Equal("/v3/apps?guids=source-1,source-2"))
 
 		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
 			fmt.Sprintf(
@@ -524,40 +631,2112 @@ var _ = Describe("Meta", func() {
 			"",
 		}))
 
-		Expect(httpClient.requestCount()).To(Equal(1))
-	})
+		Expect(httpClient.requestCount()).To(Equal(1))
+	})
+
+	It("removes headers when not printing to a tty", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--guid"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"source-2  app-1  application  100000  85008  11m45s",
+			"source-1  app-2  application  100000  85008  1s",
+			"",
+		}))
+	})
+
+	It("keeps the header for markdown tables when not printing to a tty", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--table-style", "markdown"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"| Source | Source Type | Count | Expired | Cache Duration |",
+			"| --- | --- | --- | --- | --- |",
+			"| app-1 | application | 100000 | 85008 | 11m45s |",
+			"| app-2 | application | 100000 | 85008 | 1s |",
+			"",
+		}))
+	})
+
+	It("excludes sources whose ID or name matches --exclude-source", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2", "source-3"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--exclude-source", "^app-1$|source-3"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-2  application  100000  85008  1s",
+			"",
+		}))
+	})
+
+	It("shows only sources whose ID or name matches --source-id-filter", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo(
+				"11111111-1111-1111-1111-111111111111",
+				"22222222-2222-2222-2222-222222222222",
+				"doppler",
+				"gorouter",
+			),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"11111111-1111-1111-1111-111111111111": "router-app",
+					"22222222-2222-2222-2222-222222222222": "other-app",
+				}),
+			},
+			{
+				capiServiceInstancesResponse(map[string]string{}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--source-id-filter", "router"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"gorouter    platform     100000  85008  11m45s",
+			"router-app  application  100000  85008  1s",
+			"",
+		}))
+
+		Expect(cliConn.cliCommandArgs).To(HaveLen(2))
+		for _, args := range cliConn.cliCommandArgs {
+			Expect(args[1]).ToNot(ContainSubstring("doppler"))
+		}
+	})
+
+	It("hides sources with fewer envelopes than --min-count", func() {
+		httpClient.responseBody = []string{
+			variedMetaResponseInfo("source-1", "source-2", "source-3", "source-4"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+					"source-3": "app-3",
+					"source-4": "app-4",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--min-count", "100000"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100001  84999  1s",
+			"app-2  application  100002  84998  4m30s",
+			"",
+		}))
+	})
+
+	It("hides sources with a lower rate than --min-rate", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		tailer := func(sourceID string, _ time.Duration, _ int) []string {
+			if sourceID == "source-1" {
+				return generateBatch(2)
+			}
+			return generateBatch(10)
+		}
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			tailer,
+			[]string{"--noise", "--min-rate", "5"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-2  application  100000  85008  11m45s  10",
+			"",
+		}))
+	})
+
+	It("measures the rate over --noise-interval", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		var windows []time.Duration
+		tailer := func(sourceID string, window time.Duration, _ int) []string {
+			windows = append(windows, window)
+			return generateBatch(10)
+		}
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			tailer,
+			[]string{"--noise", "--noise-interval", "5m"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(windows).To(Equal([]time.Duration{5 * time.Minute}))
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  1s  2",
+			"",
+		}))
+	})
+
+	It("shows the rate of sources at the batch limit as a lower bound over --noise-interval", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			func(string, time.Duration, int) []string { return generateBatch(1000) },
+			[]string{"--noise", "--noise-interval", "5m"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  1s  >199",
+			"",
+		}))
+	})
+
+	It("marks rates at the batch limit as a lower bound in the JSON output", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			func(string, time.Duration, int) []string { return generateBatch(1000) },
+			[]string{"--json", "--noise", "--noise-interval", "30s"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(tableWriter.String()).To(MatchJSON(`{
+			"schema_version": 1,
+			"sources": [
+				{"source_id": "source-1", "name": "app-1", "source_type": "application", "count": 100000, "expired": 85008, "cache_duration_seconds": 1, "rate": 2000, "rate_lower_bound": true}
+			]
+		}`))
+	})
+
+	It("redraws the table every --watch interval", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{capiAppsResponse(map[string]string{"source-1": "app-1"})},
+			{capiAppsResponse(map[string]string{"source-1": "app-1"})},
+		}
+		cliConn.cliCommandErr = nil
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := &cancelingWriter{cancel: cancel, marker: "app-1", count: 2}
+
+		cf.Meta(
+			ctx,
+			cliConn,
+			nil,
+			[]string{"--watch", "1ms"},
+			httpClient,
+			logger,
+			w,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(w.String()).To(Equal(
+			"\033[H\033[2J" + "app-1  application  100000  85008  1s\n" +
+				"\033[H\033[2J" + "app-1  application  100000  85008  1s\n",
+		))
+	})
+
+	It("keeps the previous table on screen while the next one is fetched", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{capiAppsResponse(map[string]string{"source-1": "app-1"})},
+			{capiAppsResponse(map[string]string{"source-1": "app-1"})},
+		}
+		cliConn.cliCommandErr = nil
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := &cancelingWriter{cancel: cancel, marker: "app-1", count: 2}
+		client := &screenRecordingHTTPClient{stubHTTPClient: httpClient, w: w}
+
+		cf.Meta(
+			ctx,
+			cliConn,
+			nil,
+			[]string{"--watch", "1ms"},
+			client,
+			logger,
+			w,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(client.screens).To(Equal([]string{
+			"",
+			"\033[H\033[2J" + "app-1  application  100000  85008  1s\n",
+		}))
+	})
+
+	It("fatally logs when --watch is combined with --json", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--watch", "5s", "--json"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--watch cannot be used with --json, --output, --output-template, --copy, --record or --report"))
+	})
+
+	It("shows the noisiest sources with --top", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2", "source-3"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+					"source-3": "app-3",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		tailer := func(sourceID string, _ time.Duration, _ int) []string {
+			switch sourceID {
+			case "source-1":
+				return generateBatch(5)
+			case "source-2":
+				return generateBatch(1000)
+			default:
+				return generateBatch(20)
+			}
+		}
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			tailer,
+			[]string{"--noise", "--top", "2"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-2  application  100000  85008  11m45s  >999",
+			"app-3  application  100000  85008  11m45s  20",
+			"",
+		}))
+	})
+
+	It("fatally logs when --top is used without --noise", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--top", "5"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Can't show the noisiest sources without --noise flag"))
+	})
+
+	It("tails at most --noise-concurrency sources at the same time", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2", "source-3", "source-4"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+					"source-3": "app-3",
+					"source-4": "app-4",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		var (
+			mu        sync.Mutex
+			active    int
+			maxActive int
+		)
+		tailer := func(sourceID string, _ time.Duration, _ int) []string {
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+
+			return generateBatch(3)
+		}
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			tailer,
+			[]string{"--noise", "--noise-concurrency", "2"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(maxActive).To(Equal(2))
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  1s      3",
+			"app-2  application  100000  85008  11m45s  3",
+			"app-3  application  100000  85008  11m45s  3",
+			"app-4  application  100000  85008  11m45s  3",
+			"",
+		}))
+	})
+
+	It("measures the rate from two meta snapshots with --noise-mode meta", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+			`{"meta": {
+				"source-1": {"count": "99990", "expired": "85018", "oldestTimestamp": "1519256863100000000", "newestTimestamp": "1519256863110000000"},
+				"source-2": {"count": "100001", "expired": "85008", "oldestTimestamp": "1519256157847077020", "newestTimestamp": "1519256863126668345"}
+			}}`,
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--noise", "--noise-mode", "meta", "--noise-interval", "60ms"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(httpClient.requestCount()).To(Equal(2))
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  1s      0",
+			"app-2  application  100000  85008  11m45s  1000",
+			"",
+		}))
+	})
+
+	It("fatally logs for an unknown --noise-mode", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--noise", "--noise-mode", "guess"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--noise-mode must be 'tail' or 'meta'."))
+	})
+
+	It("fatally logs when --min-rate is used without --noise", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--min-rate", "5"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Can't filter by rate without --noise flag"))
+	})
+
+	It("shows only the apps in the targeted space with --scope space", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2", "doppler"),
+		}
+
+		cliConn.spaceName = "space-name"
+		cliConn.spaceGUID = "space-guid"
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-2": "app-2",
+					"source-3": "app-3",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--scope", "space"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-2  application  100000  85008  11m45s",
+			"",
+		}))
+
+		Expect(cliConn.cliCommandArgs).To(Equal([][]string{
+			{"curl", "/v3/apps?space_guids=space-guid&order_by=name&per_page=5000"},
+		}))
+	})
+
+	It("shows only the apps in the targeted org with --scope org", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2", "doppler"),
+		}
+
+		cliConn.orgName = "org-name"
+		cliConn.orgGUID = "org-guid"
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--scope", "org"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  1s",
+			"",
+		}))
+
+		Expect(cliConn.cliCommandArgs).To(Equal([][]string{
+			{"curl", "/v3/apps?organization_guids=org-guid&order_by=name&per_page=5000"},
+		}))
+	})
+
+	It("adds org and space columns with --include-placement", func() {
+		const appA = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+
+		httpClient.responseBody = []string{
+			metaResponseInfo(appA),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{capiAppsResponse(map[string]string{appA: "app-a"})},
+			{capiV3AppsResponse(map[string]string{appA: "space-1"})},
+			{capiV3SpacesResponse(map[string]string{"space-1": "org-1"})},
+			{capiV3OrgsResponse("org-1")},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--include-placement"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			fmt.Sprintf("Retrieving log cache metadata as %s...", cliConn.usernameResp),
+			"",
+			"Source  Source Type  Count   Expired  Cache Duration  Org         Space",
+			"app-a   application  100000  85008    1s              org-1-name  space-1-name",
+			"",
+		}))
+	})
+
+	It("fatally logs for an unknown --scope", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--scope", "foundation"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--scope must be 'org' or 'space'."))
+	})
+
+	It("fatally logs for an invalid --exclude-source pattern", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--exclude-source", "("},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(HavePrefix("Invalid --exclude-source pattern: "))
+	})
+
+	It("copies the source IDs of the displayed sources with --copy", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		var copied string
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--copy"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+			cf.WithMetaClipboard(func(text string) error {
+				copied = text
+				return nil
+			}),
+		)
+
+		Expect(copied).To(Equal("source-2\nsource-1"))
+		Expect(logger.printfMessages).To(ContainElement("Copied 2 source IDs to the clipboard."))
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  11m45s",
+			"app-2  application  100000  85008  1s",
+			"",
+		}))
+	})
+
+	It("fatally logs when the clipboard is unavailable", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--copy"},
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaClipboard(func(string) error {
+					return errors.New("no clipboard utilities available")
+				}),
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Could not copy to the clipboard: no clipboard utilities available"))
+	})
+
+	It("writes the sources as JSON with --json", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2", "doppler"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+			{
+				capiServiceInstancesResponse(map[string]string{}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			func(string, time.Duration, int) []string { return generateBatch(3) },
+			[]string{"--json", "--noise"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(tableWriter.String()).To(MatchJSON(`{
+			"schema_version": 1,
+			"sources": [
+				{"source_id": "source-2", "name": "app-1", "source_type": "application", "count": 100000, "expired": 85008, "cache_duration_seconds": 705, "rate": 3},
+				{"source_id": "source-1", "name": "app-2", "source_type": "application", "count": 100000, "expired": 85008, "cache_duration_seconds": 1, "rate": 3},
+				{"source_id": "doppler", "name": "doppler", "source_type": "platform", "count": 100000, "expired": 85008, "cache_duration_seconds": 705, "rate": 3}
+			]
+		}`))
+	})
+
+	It("writes the table columns as CSV with --output csv", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			func(string, time.Duration, int) []string { return generateBatch(3) },
+			[]string{"--output", "csv", "--guid", "--noise"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"Source ID,Source,Source Type,Count,Expired,Cache Duration,Rate",
+			"source-2,app-1,application,100000,85008,11m45s,3",
+			"source-1,app-2,application,100000,85008,1s,3",
+			"",
+		}))
+	})
+
+	It("writes a YAML document per source with --output yaml", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "doppler"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+			{
+				capiServiceInstancesResponse(map[string]string{}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--output", "yaml"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"schema_version: 1",
+			"source_id: source-1",
+			"name: app-1",
+			"source_type: application",
+			"count: 100000",
+			"expired: 85008",
+			"cache_duration_seconds: 1",
+			"newest_timestamp: 1519256863110000000",
+			"oldest_timestamp: 1519256863100000000",
+			"---",
+			"schema_version: 1",
+			"source_id: doppler",
+			"name: doppler",
+			"source_type: platform",
+			"count: 100000",
+			"expired: 85008",
+			"cache_duration_seconds: 705",
+			"newest_timestamp: 1519256863126668345",
+			"oldest_timestamp: 1519256157847077020",
+			"",
+		}))
+	})
+
+	It("writes Prometheus metrics with --output prometheus", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "doppler"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+			{
+				capiServiceInstancesResponse(map[string]string{}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			func(string, time.Duration, int) []string { return generateBatch(3) },
+			[]string{"--output", "prometheus", "--noise"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"# HELP log_cache_source_count Number of envelopes cached for the source.",
+			"# TYPE log_cache_source_count gauge",
+			`log_cache_source_count{source_id="source-1",app_name="app-1",source_type="application"} 100000`,
+			`log_cache_source_count{source_id="doppler",app_name="doppler",source_type="platform"} 100000`,
+			"# HELP log_cache_source_expired Number of envelopes of the source that expired from the cache.",
+			"# TYPE log_cache_source_expired gauge",
+			`log_cache_source_expired{source_id="source-1",app_name="app-1",source_type="application"} 85008`,
+			`log_cache_source_expired{source_id="doppler",app_name="doppler",source_type="platform"} 85008`,
+			"# HELP log_cache_source_cache_duration_seconds Time between the oldest and the newest cached envelope of the source.",
+			"# TYPE log_cache_source_cache_duration_seconds gauge",
+			`log_cache_source_cache_duration_seconds{source_id="source-1",app_name="app-1",source_type="application"} 1`,
+			`log_cache_source_cache_duration_seconds{source_id="doppler",app_name="doppler",source_type="platform"} 705`,
+			"# HELP log_cache_source_rate Envelopes per minute of the source.",
+			"# TYPE log_cache_source_rate gauge",
+			`log_cache_source_rate{source_id="source-1",app_name="app-1",source_type="application"} 3`,
+			`log_cache_source_rate{source_id="doppler",app_name="doppler",source_type="platform"} 3`,
+			"",
+		}))
+	})
+
+	It("appends subtotals per source type and a total with --totals", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2", "doppler"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+			{
+				capiServiceInstancesResponse(map[string]string{}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--totals"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1              application  100000  85008   11m45s",
+			"app-2              application  100000  85008   1s",
+			"doppler            platform     100000  85008   11m45s",
+			"Total application               200000  170016  ",
+			"Total platform                  100000  85008   ",
+			"Total                           300000  255024  ",
+			"",
+		}))
+	})
+
+	It("writes the rows batch by batch with --stream", func() {
+		guid := "11111111-1111-1111-1111-111111111111"
+		httpClient.responseBody = []string{
+			metaResponseInfo(guid, "doppler"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					guid: "app-a",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--stream"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(cliConn.cliCommandArgs).To(HaveLen(1))
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			fmt.Sprintf("Retrieving log cache metadata as %s...", cliConn.usernameResp),
+			"",
+			"Source  Source Type  Count   Expired  Cache Duration",
+			"app-a   application  100000  85008    1s",
+			"doppler  platform  100000  85008  11m45s",
+			"",
+		}))
+	})
+
+	It("fatally logs when --stream is used with --sort-by", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--stream", "--sort-by", "count"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(HavePrefix("--stream can only be used with the default plain or TSV table"))
+	})
+
+	It("fatally logs after the table when a rate is above --fail-if-rate-above", func() {
+		tailer := func(sourceID string, _ time.Duration, _ int) []string {
+			if sourceID == "source-1" {
+				return generateBatch(5)
+			}
+			return generateBatch(3)
+		}
+
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				tailer,
+				[]string{"--noise", "--fail-if-rate-above", "4"},
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaNoHeaders(),
+			)
+		}).To(Panic())
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  1s      5",
+			"app-2  application  100000  85008  11m45s  3",
+			"",
+		}))
+		Expect(logger.fatalfMessage).To(Equal("app-1 has a rate of 5, above 4"))
+	})
+
+	It("fatally logs when a rate at the batch limit is above --fail-if-rate-above", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				func(string, time.Duration, int) []string { return generateBatch(1000) },
+				[]string{"--noise", "--noise-interval", "5m", "--fail-if-rate-above", "150"},
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaNoHeaders(),
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("app-1 has a rate of >199, above 150"))
+	})
+
+	It("fatally logs when --fail-if-rate-above is not below the highest measured rate", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--noise", "--noise-interval", "5m", "--fail-if-rate-above", "200"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--fail-if-rate-above must be below 200, the highest rate measured over a --noise-interval of 5m0s."))
+	})
+
+	It("measures the highest rate with the read limit reported by Log Cache", func() {
+		httpClient.maxReadLimit = 500
+
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--noise", "--fail-if-rate-above", "500"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--fail-if-rate-above must be below 500, the highest rate measured over a --noise-interval of 1m0s."))
+	})
+
+	It("fatally logs when an expired ratio is above --fail-if-expired-ratio-above", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--fail-if-expired-ratio-above", "40", "--limit", "1"},
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaNoHeaders(),
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal(
+			"app-1 has 45.9% expired, above 40.0%\napp-2 has 45.9% expired, above 40.0%",
+		))
+	})
+
+	It("does not fail when every source is below the thresholds", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--fail-if-expired-ratio-above", "50"},
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaNoHeaders(),
+			)
+		}).ToNot(Panic())
+	})
+
+	It("shows the BOSH job of platform sources with --include-job", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "doppler"),
+			`{"envelopes":{"batch":[{
+				"timestamp":"1",
+				"source_id":"doppler",
+				"tags":{"deployment":"cf","job":"doppler","index":"0"},
+				"counter":{"name":"ingress","total":"1"}
+			}]}}`,
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+			{
+				capiServiceInstancesResponse(map[string]string{}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--include-job"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(httpClient.requestURLs[1]).To(ContainSubstring("/v1/read/doppler"))
+		Expect(httpClient.requestURLs[1]).To(ContainSubstring("limit=1"))
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1    application  100000  85008  1s                   ",
+			"doppler  platform     100000  85008  11m45s  cf  doppler  0",
+			"",
+		}))
+	})
+
+	It("shows every detail of a single source looked up by app name", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{"source-1"},
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			func(string, time.Duration, int) []string { return generateBatch(5) },
+			[]string{"app-1"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(cliConn.cliCommandArgs[0]).To(Equal([]string{"app", "app-1", "--guid"}))
+		Expect(cliConn.cliCommandArgs[1][1]).To(Equal("/v3/apps?guids=source-1"))
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			fmt.Sprintf("Retrieving log cache metadata as %s...", cliConn.usernameResp),
+			"",
+			"Field           Value",
+			"Source ID       source-1",
+			"Source          app-1",
+			"Source Type     application",
+			"Count           100000",
+			"Expired         85008",
+			"Cache Duration  1s",
+			"Rate            5",
+			"Expired %       45.9%",
+			"Oldest          2018-02-21T23:47:43Z",
+			"Newest          2018-02-21T23:47:43Z",
+			"",
+		}))
+	})
+
+	It("fatally logs when the single source has no envelopes", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{"source-9"},
+		}
+		cliConn.cliCommandErr = nil
+
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"app-9"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("app-9 has no envelopes in Log Cache."))
+	})
+
+	It("fatally logs when a single source is used with --group-by", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"app-1", "--group-by", "org"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(HavePrefix("A source cannot be used with --group-by"))
+	})
+
+	It("leaves out the banner and the header row with --no-headers", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--no-headers"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  1s",
+			"",
+		}))
+	})
+
+	It("shows the oldest and newest timestamp of every source with --timestamps", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--timestamps"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  1s  2018-02-21T23:47:43Z  2018-02-21T23:47:43Z",
+			"",
+		}))
+	})
+
+	It("shows untruncated durations and raw timestamps with --precise-durations", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--precise-durations", "--timestamps"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1  application  100000  85008  10ms  1519256863100000000  1519256863110000000",
+			"",
+		}))
+	})
+
+	It("shows the share of expired envelopes with --expired-ratio", func() {
+		httpClient.responseBody = []string{
+			`{"meta": {
+				"source-1": {"count": "900", "expired": "100", "oldestTimestamp": "1519256863100000000", "newestTimestamp": "1519256864100000000"},
+				"source-2": {"count": "250", "expired": "750", "oldestTimestamp": "1519256863100000000", "newestTimestamp": "1519256864100000000"},
+				"source-3": {"count": "0", "expired": "0", "oldestTimestamp": "1519256863100000000", "newestTimestamp": "1519256864100000000"}
+			}}`,
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+					"source-3": "app-3",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--expired-ratio", "--sort-by", "expired-ratio", "--desc"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			fmt.Sprintf("Retrieving log cache metadata as %s...", cliConn.usernameResp),
+			"",
+			"Source  Source Type  Count  Expired  Cache Duration  Expired %",
+			"app-2   application  250    750      1s              75.0%",
+			"app-1   application  900    100      1s              10.0%",
+			"app-3   application  0      0        1s              0.0%",
+			"",
+		}))
+	})
+
+	It("fatally logs when sorting by expired-ratio without --expired-ratio", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--sort-by", "expired-ratio"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Can't sort by expired ratio column without --expired-ratio flag"))
+	})
+
+	It("shows the envelope types of a sample per source with --envelope-breakdown", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1"),
+			`{"envelopes":{"batch":[
+				{"timestamp":"5","source_id":"source-1","log":{"payload":"aGk="}},
+				{"timestamp":"4","source_id":"source-1","log":{"payload":"aGk="}},
+				{"timestamp":"3","source_id":"source-1","counter":{"name":"requests","total":"1"}},
+				{"timestamp":"2","source_id":"source-1","gauge":{"metrics":{"cpu":{"unit":"percentage","value":1}}}},
+				{"timestamp":"1","source_id":"source-1","event":{"title":"crash","body":"exited"}}
+			]}}`,
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--envelope-breakdown"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(httpClient.requestURLs[1]).To(ContainSubstring("/v1/read/source-1"))
+		Expect(httpClient.requestURLs[1]).To(ContainSubstring("descending=true"))
+		Expect(httpClient.requestURLs[1]).To(ContainSubstring("limit=100"))
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			fmt.Sprintf("Retrieving log cache metadata as %s...", cliConn.usernameResp),
+			"",
+			"Source  Sampled  Logs  Counters  Gauges  Timers  Events",
+			"app-1   5        2     1         1       0       1",
+			"",
+		}))
+	})
+
+	It("fatally logs when --envelope-breakdown is used with --json", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--envelope-breakdown", "--json"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--envelope-breakdown can only be used with the default table"))
+	})
+
+	It("shows only the selected --columns", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			func(string, time.Duration, int) []string { return generateBatch(3) },
+			[]string{"--noise", "--columns", "source-id,app-name,rate,oldest"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			fmt.Sprintf("Retrieving log cache metadata as %s...", cliConn.usernameResp),
+			"",
+			"Source ID  Source  Rate  Oldest",
+			"source-2   app-1   3     2018-02-21T23:35:57Z",
+			"source-1   app-2   3     2018-02-21T23:47:43Z",
+			"",
+		}))
+	})
+
+	It("fatally logs for an unknown column in --columns", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--columns", "count,size"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(HavePrefix("Unknown column 'size'."))
+	})
+
+	It("formats every source with --output-template", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-2",
+					"source-2": "app-1",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			func(string, time.Duration, int) []string { return generateBatch(3) },
+			[]string{"--noise", "--output-template", "{{.AppName}} ({{.SourceID}}) {{.Count}}/{{.Expired}} {{.CacheDuration}} {{.Rate}}/min"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-1 (source-2) 100000/85008 11m45s 3/min",
+			"app-2 (source-1) 100000/85008 1s 3/min",
+			"",
+		}))
+	})
+
+	It("fatally logs for an invalid --output-template", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--output-template", "{{.AppName"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(HavePrefix("template: OutputFormat:1: "))
+	})
+
+	It("fatally logs when --output-template is combined with --json", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--output-template", "{{.AppName}}", "--json"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--output-template cannot be used with --json, --output, --group-by, --record or --report"))
+	})
+
+	It("fatally logs for an unknown --output", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--output", "xml"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--output must be 'table', 'csv', 'json', 'yaml' or 'prometheus'."))
+	})
+
+	It("fatally logs when --json is combined with --output csv", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--json", "--output", "csv"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--json cannot be used with --output csv"))
+	})
+
+	It("fatally logs when --json is combined with --report", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--json", "--report", "trends.db"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--json and --output cannot be used with --group-by, --record or --report"))
+	})
+
+	It("fatally logs when --copy is combined with --group-by", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--copy", "--group-by", "org"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--copy cannot be used with --group-by, --record or --report"))
+	})
+
+	Describe("--group-by", func() {
+		const (
+			appA = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+			appB = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+			appC = "cccccccc-cccc-cccc-cccc-cccccccccccc"
+		)
+
+		BeforeEach(func() {
+			httpClient.responseBody = []string{
+				metaResponseInfo(appA, appB, appC, "doppler"),
+			}
+
+			cliConn.cliCommandResult = [][]string{
+				{capiV3AppsResponse(map[string]string{
+					appA: "space-1",
+					appB: "space-2",
+					appC: "space-2",
+				})},
+				{capiV3SpacesResponse(map[string]string{
+					"space-1": "org-1",
+					"space-2": "org-1",
+				})},
+				{capiV3OrgsResponse("org-1")},
+			}
+			cliConn.cliCommandErr = nil
+		})
+
+		It("sums application sources per org", func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--group-by", "org"},
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaNoHeaders(),
+			)
+
+			Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+				"org-1-name  3  300000  255024",
+				"",
+			}))
+			Expect(cliConn.cliCommandArgs[0][1]).To(Equal(fmt.Sprintf("/v3/apps?guids=%s,%s,%s", appA, appB, appC)))
+		})
+
+		It("sums application sources per space", func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--group-by", "space"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+
+			Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+				fmt.Sprintf("Retrieving log cache metadata as %s...", cliConn.usernameResp),
+				"",
+				"Org         Space         Sources  Count   Expired",
+				"org-1-name  space-1-name  1        100000  85008",
+				"org-1-name  space-2-name  2        200000  170016",
+				"",
+			}))
+		})
+
+		It("fatally logs for an invalid group", func() {
+			Expect(func() {
+				cf.Meta(
+					context.Background(),
+					cliConn,
+					nil,
+					[]string{"--group-by", "app"},
+					httpClient,
+					logger,
+					tableWriter,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("Group by must be 'org' or 'space'."))
+		})
+
+		It("fatally logs when combined with --guid", func() {
+			Expect(func() {
+				cf.Meta(
+					context.Background(),
+					cliConn,
+					nil,
+					[]string{"--group-by", "org", "--guid"},
+					httpClient,
+					logger,
+					tableWriter,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--group-by cannot be used with --guid, --sort-by, --desc or --source-type"))
+		})
+	})
+
+	Describe("name cache", func() {
+		const guid = "11111111-1111-1111-1111-111111111111"
+
+		var (
+			home     string
+			origHome string
+		)
+
+		BeforeEach(func() {
+			var err error
+			home, err = ioutil.TempDir("", "")
+			Expect(err).ToNot(HaveOccurred())
+
+			origHome = os.Getenv("HOME")
+			Expect(os.Setenv("HOME", home)).To(Succeed())
+
+			httpClient.responseBody = []string{
+				metaResponseInfo(guid),
+				metaResponseInfo(guid),
+			}
+			cliConn.cliCommandErr = nil
+		})
+
+		AfterEach(func() {
+			Expect(os.Setenv("HOME", origHome)).To(Succeed())
+			Expect(os.RemoveAll(home)).To(Succeed())
+		})
+
+		meta := func(args ...string) {
+			tableWriter.Reset()
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				args,
+				httpClient,
+				logger,
+				tableWriter,
+				cf.WithMetaNoHeaders(),
+				cf.WithMetaNameCache(time.Hour),
+			)
+		}
+
+		It("reads names resolved within the TTL from the cache", func() {
+			cliConn.cliCommandResult = [][]string{
+				{capiAppsResponse(map[string]string{guid: "app-a"})},
+			}
+
+			meta()
+			meta()
+
+			Expect(cliConn.cliCommandArgs).To(HaveLen(1))
+			Expect(tableWriter.String()).To(Equal("app-a  application  100000  85008  1s\n"))
+			Expect(filepath.Join(home, ".log-cache-cli", "names.json")).To(BeAnExistingFile())
+		})
+
+		It("looks up every name again with --refresh-names", func() {
+			cliConn.cliCommandResult = [][]string{
+				{capiAppsResponse(map[string]string{guid: "app-a"})},
+				{capiAppsResponse(map[string]string{guid: "app-renamed"})},
+			}
+
+			meta()
+			meta("--refresh-names")
+
+			Expect(cliConn.cliCommandArgs).To(HaveLen(2))
+			Expect(tableWriter.String()).To(Equal("app-renamed  application  100000  85008  1s\n"))
+		})
+	})
+
+	Describe("trends", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "trends")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("appends a snapshot every interval to the --record file", func() {
+			path := filepath.Join(dir, "trends.db")
+			httpClient.responseBody = []string{
+				`{"meta": {"source-1": {"count": "10", "expired": "0"}, "platform": {"count": "5", "expired": "1"}}}`,
+				`{"meta": {"source-1": {"count": "20", "expired": "0"}, "platform": {"count": "5", "expired": "2"}}}`,
+				`{"meta": {"source-1": {"count": "30", "expired": "5"}, "platform": {"count": "5", "expired": "3"}}}`,
+			}
+
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--record", path, "--interval", "1ms", "--duration", "2ms", "--exclude-source", "^platform$"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+
+			Expect(httpClient.requestURLs).To(HaveLen(3))
+			Expect(strings.Split(strings.TrimSpace(tableWriter.String()), "\n")).To(HaveLen(3))
+
+			data, err := ioutil.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			Expect(lines).To(HaveLen(3))
+
+			var snapshot struct {
+				SchemaVersion int `json:"schema_version"`
+				Sources       map[string]struct {
+					Count   int64 `json:"count"`
+					Expired int64 `json:"expired"`
+				} `json:"sources"`
+			}
+			Expect(json.Unmarshal([]byte(lines[2]), &snapshot)).To(Succeed())
+			Expect(snapshot.SchemaVersion).To(Equal(1))
+			Expect(snapshot.Sources).To(HaveLen(1))
+			Expect(snapshot.Sources["source-1"].Count).To(Equal(int64(30)))
+			Expect(snapshot.Sources["source-1"].Expired).To(Equal(int64(5)))
+		})
+
+		It("saves a snapshot with --diff when the file does not exist", func() {
+			path := filepath.Join(dir, "baseline.db")
+			httpClient.responseBody = []string{
+				`{"meta": {"source-1": {"count": "10", "expired": "0"}}}`,
+			}
+
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--diff", path},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+
+			Expect(tableWriter.String()).To(Equal(fmt.Sprintf(
+				"Saved a snapshot of 1 sources to %s. Run again with --diff %s to compare.\n", path, path,
+			)))
+
+			data, err := ioutil.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"sources":{"source-1":{"count":10,"expired":0}}`))
+		})
+
+		It("shows the changes since the snapshot with --diff", func() {
+			path := filepath.Join(dir, "baseline.db")
+			Expect(ioutil.WriteFile(path, []byte(
+				`{"schema_version":1,"time":"2026-10-01T10:00:00Z","sources":{"source-1":{"count":100,"expired":0},"source-2":{"count":10,"expired":5}}}`+"\n",
+			), 0644)).To(Succeed())
+			httpClient.responseBody = []string{
+				`{"meta": {"source-1": {"count": "150", "expired": "0"}, "source-2": {"count": "10", "expired": "25"}, "source-3": {"count": "3", "expired": "0"}}}`,
+			}
+
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--diff", path},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+
+			Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+				"Source    Count  Count Change  Expired  Expired Change",
+				"source-2  10     +0            25       +20",
+				"source-1  150    +50           0        +0",
+				"source-3  3      +3            0        +0",
+				"",
+				"1 of 3 sources expired envelopes since 2026-10-01 10:00:00 UTC.",
+				"",
+			}))
+		})
+
+		It("tabulates the growth per source with --report", func() {
+			path := filepath.Join(dir, "trends.db")
+			Expect(ioutil.WriteFile(path, []byte(strings.Join([]string{
+				`{"schema_version":1,"time":"2026-10-01T10:00:00Z","sources":{"source-1":{"count":100,"expired":0},"source-2":{"count":10,"expired":0}}}`,
+				`{"schema_version":1,"time":"2026-10-01T10:01:00Z","sources":{"source-1":{"count":150,"expired":20},"source-2":{"count":10,"expired":0}}}`,
+				`{"schema_version":1,"time":"2026-10-01T10:02:00Z","sources":{"source-1":{"count":200,"expired":60},"source-2":{"count":12,"expired":2}}}`,
+			}, "\n")+"\n"), 0644)).To(Succeed())
+
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--report", path},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+
+			Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+				"Source    Snapshots  Count  Count Change  Expired/min  Trend",
+				"source-1  3          200    +100          30.0         ▁▄█",
+				"source-2  3          12     +2            1.0          ▁▁█",
+				"",
+			}))
+			Expect(httpClient.requestURLs).To(BeEmpty())
+		})
+
+		It("describes the trend in words with --accessible", func() {
+			path := filepath.Join(dir, "trends.db")
+			Expect(ioutil.WriteFile(path, []byte(strings.Join([]string{
+				`{"schema_version":1,"time":"2026-10-01T10:00:00Z","sources":{"source-1":{"count":100,"expired":0},"source-2":{"count":10,"expired":0}}}`,
+				`{"schema_version":1,"time":"2026-10-01T10:01:00Z","sources":{"source-1":{"count":150,"expired":20},"source-2":{"count":14,"expired":0}}}`,
+				`{"schema_version":1,"time":"2026-10-01T10:02:00Z","sources":{"source-1":{"count":200,"expired":60},"source-2":{"count":12,"expired":2}}}`,
+			}, "\n")+"\n"), 0644)).To(Succeed())
 
-	It("removes headers when not printing to a tty", func() {
-		httpClient.responseBody = []string{
-			metaResponseInfo("source-1", "source-2"),
-		}
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--report", path, "--accessible"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
 
-		cliConn.cliCommandResult = [][]string{
-			{
-				capiAppsResponse(map[string]string{
-					"source-1": "app-2",
-					"source-2": "app-1",
-				}),
-			},
-		}
-		cliConn.cliCommandErr = nil
+			Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+				"Source    Snapshots  Count  Count Change  Expired/min  Trend",
+				"source-1  3          200    +100          30.0         rising",
+				"source-2  3          12     +2            1.0          fluctuating",
+				"",
+			}))
+		})
 
-		cf.Meta(
-			context.Background(),
-			cliConn,
-			nil,
-			[]string{"--guid"},
-			httpClient,
-			logger,
-			tableWriter,
-			cf.WithMetaNoHeaders(),
-		)
+		It("fatally logs when --record and --report are combined", func() {
+			Expect(func() {
+				cf.Meta(
+					context.Background(),
+					cliConn,
+					nil,
+					[]string{"--record", "a.db", "--report", "b.db"},
+					httpClient,
+					logger,
+					tableWriter,
+				)
+			}).To(Panic())
 
-		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
-			"source-2  app-1  application  100000  85008  11m45s",
-			"source-1  app-2  application  100000  85008  1s",
-			"",
-		}))
+			Expect(logger.fatalfMessage).To(Equal("--record cannot be used with --report"))
+		})
 	})
 
 	It("returns service instance names with service source guids", func() {
@@ -605,12 +2784,9 @@ var _ = Describe("Meta", func() {
 		Expect(cliConn.cliCommandArgs[1]).To(HaveLen(2))
 		Expect(cliConn.cliCommandArgs[1][0]).To(Equal("curl"))
 
-		// Or is required because we don't know the order the keys will come
-		// out of the map.
-		Expect(cliConn.cliCommandArgs[1][1]).To(Or(
-			Equal("/v2/service_instances?guids=source-2,source-3"),
-			Equal("/v2/service_instances?guids=source-3,source-2"),
-		))
+		// Source IDs are looked up in order, so repeated runs send the same
+		// requests.
+		Expect(cliConn.cliCommandArgs[1][1]).To(Equal("/v3/service_instances?guids=source-2,source-3"))
 
 		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
 			fmt.Sprintf(
@@ -670,7 +2846,7 @@ var _ = Describe("Meta", func() {
 	})
 
 	It("displays the rate column for each service type", func() {
-		tailer := func(sourceID string) []string {
+		tailer := func(sourceID string, _ time.Duration, _ int) []string {
 			switch sourceID {
 			case "source-1":
 				return generateBatch(5)
@@ -766,7 +2942,7 @@ var _ = Describe("Meta", func() {
 
 		Expect(cliConn.cliCommandArgs[1]).To(HaveLen(2))
 		Expect(cliConn.cliCommandArgs[1][0]).To(Equal("curl"))
-		Expect(cliConn.cliCommandArgs[1][1]).To(Equal("/v2/service_instances?guids=source-2"))
+		Expect(cliConn.cliCommandArgs[1][1]).To(Equal("/v3/service_instances?guids=source-2"))
 
 		Expect(httpClient.requestCount()).To(Equal(1))
 		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
@@ -800,6 +2976,9 @@ var _ = Describe("Meta", func() {
 			{
 				capiServiceInstancesResponse(nil),
 			},
+			{
+				capiTasksResponse(nil),
+			},
 		}
 		cliConn.cliCommandErr = nil
 
@@ -842,6 +3021,9 @@ var _ = Describe("Meta", func() {
 			{
 				capiServiceInstancesResponse(map[string]string{"source-2": "service-2"}),
 			},
+			{
+				capiTasksResponse(nil),
+			},
 		}
 		cliConn.cliCommandErr = nil
 
@@ -884,6 +3066,9 @@ var _ = Describe("Meta", func() {
 			{
 				capiServiceInstancesResponse(nil),
 			},
+			{
+				capiTasksResponse(nil),
+			},
 		}
 		cliConn.cliCommandErr = nil
 
@@ -925,6 +3110,9 @@ var _ = Describe("Meta", func() {
 			{
 				capiServiceInstancesResponse(nil),
 			},
+			{
+				capiTasksResponse(nil),
+			},
 		}
 		cliConn.cliCommandErr = nil
 
@@ -952,6 +3140,54 @@ var _ = Describe("Meta", func() {
 		}))
 	})
 
+	It("names GUIDs that are tasks after the task and its app", func() {
+		const (
+			taskGUID = "11111111-1111-1111-1111-111111111111"
+			appGUID  = "22222222-2222-2222-2222-222222222222"
+		)
+		httpClient.responseBody = []string{
+			metaResponseInfo(taskGUID),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(nil),
+			},
+			{
+				capiServiceInstancesResponse(nil),
+			},
+			{
+				capiTasksResponse(map[string][2]string{
+					taskGUID: {"migrate", appGUID},
+				}),
+			},
+			{
+				capiAppsResponse(map[string]string{
+					appGUID: "web",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			nil,
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(cliConn.cliCommandArgs[2][1]).To(Equal("/v3/tasks?guids=" + taskGUID))
+		Expect(cliConn.cliCommandArgs[3][1]).To(Equal("/v3/apps?guids=" + appGUID))
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"migrate (web)  task  100000  85008  1s",
+			"",
+		}))
+	})
+
 	It("prints meta scoped to platform with source GUIDs", func() {
 		httpClient.responseBody = []string{
 			metaResponseInfo(
@@ -968,6 +3204,9 @@ var _ = Describe("Meta", func() {
 			{
 				capiServiceInstancesResponse(nil),
 			},
+			{
+				capiTasksResponse(nil),
+			},
 		}
 		cliConn.cliCommandErr = nil
 
@@ -994,6 +3233,97 @@ var _ = Describe("Meta", func() {
 		}))
 	})
 
+	It("shows only the first sources after sorting with --limit", func() {
+		httpClient.responseBody = []string{
+			variedMetaResponseInfo("source-1", "source-2", "source-3", "source-4"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+					"source-3": "app-3",
+					"source-4": "app-4",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--sort-by", "count", "--limit", "2"},
+			httpClient,
+			logger,
+			tableWriter,
+			cf.WithMetaNoHeaders(),
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			"app-4  application  99996  85004  13m30s",
+			"app-3  application  99997  85003  9m0s",
+			"",
+		}))
+	})
+
+	It("shows the number of sources and totals when --limit drops sources", func() {
+		httpClient.responseBody = []string{
+			metaResponseInfo("source-1", "source-2", "source-3"),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{
+					"source-1": "app-1",
+					"source-2": "app-2",
+					"source-3": "app-3",
+				}),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			[]string{"--limit", "1"},
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(strings.Split(tableWriter.String(), "\n")).To(Equal([]string{
+			fmt.Sprintf(
+				"Retrieving log cache metadata as %s...",
+				cliConn.usernameResp,
+			),
+			"",
+			"Source  Source Type  Count   Expired  Cache Duration",
+			"app-1   application  100000  85008    1s",
+			"",
+			"Showing 1 of 3 sources, 300000 envelopes in total, 255024 expired.",
+			"",
+		}))
+	})
+
+	It("fatally logs for a negative --limit", func() {
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				[]string{"--limit", "-1"},
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--limit cannot be negative."))
+	})
+
 	It("does not request more than 50 guids at a time", func() {
 		var guids []string
 		for i := 0; i < 51; i++ {
@@ -1050,14 +3380,14 @@ var _ = Describe("Meta", func() {
 		Expect(cliConn.cliCommandArgs[2][0]).To(Equal("curl"))
 		uri, err = url.Parse(cliConn.cliCommandArgs[2][1])
 		Expect(err).ToNot(HaveOccurred())
-		Expect(uri.Path).To(Equal("/v2/service_instances"))
+		Expect(uri.Path).To(Equal("/v3/service_instances"))
 		Expect(strings.Split(uri.Query().Get("guids"), ",")).To(HaveLen(50))
 
 		Expect(cliConn.cliCommandArgs[3]).To(HaveLen(2))
 		Expect(cliConn.cliCommandArgs[3][0]).To(Equal("curl"))
 		uri, err = url.Parse(cliConn.cliCommandArgs[3][1])
 		Expect(err).ToNot(HaveOccurred())
-		Expect(uri.Path).To(Equal("/v2/service_instances"))
+		Expect(uri.Path).To(Equal("/v3/service_instances"))
 		Expect(strings.Split(uri.Query().Get("guids"), ",")).To(HaveLen(1))
 
 		// 51 entries, 2 blank lines, "Retrieving..." preamble and table
@@ -1065,6 +3395,53 @@ var _ = Describe("Meta", func() {
 		Expect(strings.Split(tableWriter.String(), "\n")).To(HaveLen(55))
 	})
 
+	It("splits lookups of long source IDs to keep the query string short", func() {
+		var sourceIDs []string
+		for i := 0; i < 3; i++ {
+			sourceIDs = append(sourceIDs, fmt.Sprintf("%d-%s", i, strings.Repeat("a", 898)))
+		}
+
+		httpClient.responseBody = []string{
+			metaResponseInfo(sourceIDs...),
+		}
+
+		cliConn.cliCommandResult = [][]string{
+			{
+				capiAppsResponse(map[string]string{}),
+			},
+			{
+				capiAppsResponse(map[string]string{}),
+			},
+			{
+				capiServiceInstancesResponse(nil),
+			},
+			{
+				capiServiceInstancesResponse(nil),
+			},
+		}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			nil,
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(cliConn.cliCommandArgs).To(HaveLen(4))
+
+		var batches []int
+		for _, args := range cliConn.cliCommandArgs {
+			uri, err := url.Parse(args[1])
+			Expect(err).ToNot(HaveOccurred())
+			batches = append(batches, len(strings.Split(uri.Query().Get("guids"), ",")))
+		}
+		Expect(batches).To(Equal([]int{2, 1, 2, 1}))
+	})
+
 	It("uses the LOG_CACHE_ADDR environment variable", func() {
 		_ = os.Setenv("LOG_CACHE_ADDR", "https://different-log-cache:8080")
 		defer func() { _ = os.Unsetenv("LOG_CACHE_ADDR") }()
@@ -1131,14 +3508,14 @@ var _ = Describe("Meta", func() {
 				context.Background(),
 				cliConn,
 				nil,
-				[]string{"extra-arg"},
+				[]string{"extra-arg", "another-arg"},
 				httpClient,
 				logger,
 				tableWriter,
 			)
 		}).To(Panic())
 
-		Expect(logger.fatalfMessage).To(Equal("Invalid arguments, expected 0, got 1."))
+		Expect(logger.fatalfMessage).To(Equal("Invalid arguments, expected 0 or 1, got 2."))
 	})
 
 	It("fatally logs when scope is not 'platform', 'application' or 'all'", func() {
@@ -1251,6 +3628,44 @@ var _ = Describe("Meta", func() {
 		Expect(logger.fatalfMessage).To(HavePrefix(`Failed to read application information: `))
 	})
 
+	It("fatally logs when Log Cache is too old for meta information", func() {
+		httpClient.version = "1.0.2"
+
+		Expect(func() {
+			cf.Meta(
+				context.Background(),
+				cliConn,
+				nil,
+				nil,
+				httpClient,
+				logger,
+				tableWriter,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Log Cache 1.0.2 is too old for meta information. Log Cache 1.1.0 or later is required."))
+		Expect(httpClient.requestURLs).To(BeEmpty())
+	})
+
+	It("reads meta information when Log Cache does not report a version", func() {
+		httpClient.version = "unknown"
+		httpClient.responseBody = []string{metaResponseInfo("source-1")}
+		cliConn.cliCommandResult = [][]string{{capiAppsResponse(map[string]string{"source-1": "app-1"})}}
+		cliConn.cliCommandErr = nil
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			nil,
+			httpClient,
+			logger,
+			tableWriter,
+		)
+
+		Expect(httpClient.requestURLs).To(HaveLen(1))
+	})
+
 	It("fatally logs when Meta fails", func() {
 		httpClient.responseErr = errors.New("some-error")
 
@@ -1277,6 +3692,37 @@ func generateBatch(count int) []string {
 	return []string{fmt.Sprintf(`{"batch": [%s]}`, x)}
 }
 
+// cancelingWriter cancels once the marker has been written count times.
+type cancelingWriter struct {
+	bytes.Buffer
+	cancel func()
+	marker string
+	count  int
+}
+
+func (w *cancelingWriter) Write(p []byte) (int, error) {
+	n, err := w.Buffer.Write(p)
+	if strings.Count(w.String(), w.marker) >= w.count {
+		w.cancel()
+	}
+	return n, err
+}
+
+// screenRecordingHTTPClient records what was written to the screen when
+// every meta request is made.
+type screenRecordingHTTPClient struct {
+	*stubHTTPClient
+	w       *cancelingWriter
+	screens []string
+}
+
+func (c *screenRecordingHTTPClient) Do(r *http.Request) (*http.Response, error) {
+	if r.URL.Path == "/v1/meta" {
+		c.screens = append(c.screens, c.w.String())
+	}
+	return c.stubHTTPClient.Do(r)
+}
+
 func metaResponseInfo(sourceIDs ...string) string {
 	var metaInfos []string
 	metaInfos = append(metaInfos, fmt.Sprintf(`"%s": {
@@ -1330,11 +3776,18 @@ func capiAppsResponse(apps map[string]string) string {
 	return fmt.Sprintf(`{ "resources": [%s] }`, strings.Join(resources, ","))
 }
 
+func capiTasksResponse(tasks map[string][2]string) string {
+	var resources []string
+	for taskID, t := range tasks {
+		resources = append(resources, fmt.Sprintf(`{"guid": %q, "name": %q, "relationships": {"app": {"data": {"guid": %q}}}}`, taskID, t[0], t[1]))
+	}
+	return fmt.Sprintf(`{ "resources": [%s] }`, strings.Join(resources, ","))
+}
+
 func capiServiceInstancesResponse(services map[string]string) string {
 	var resources []string
 	for serviceID, serviceName := range services {
-		resource := fmt.Sprintf(`{"metadata": {"guid": "%s"}, "entity": {"name": "%s"}}`, serviceID, serviceName)
-		resources = append(resources, resource)
+		resources = append(resources, fmt.Sprintf(`{"guid": "%s", "name": "%s"}`, serviceID, serviceName))
 	}
 	return fmt.Sprintf(`{ "resources": [%s] }`, strings.Join(resources, ","))
 }
@@ -0,0 +1,26 @@
+package cf
+
+import (
+	"fmt"
+)
+
+// metaThresholdViolations describes every source whose rate or expired
+// ratio is above --fail-if-rate-above or --fail-if-expired-ratio-above.
+func metaThresholdViolations(opts optionsFlags, records []metaRecord) []string {
+	if opts.FailRate <= 0 && opts.FailRatio <= 0 {
+		return nil
+	}
+
+	var violations []string
+	for _, r := range records {
+		if opts.FailRate > 0 && r.Rate != nil && *r.Rate > opts.FailRate {
+			violations = append(violations, fmt.Sprintf("%s has a rate of %s, above %d", r.Name, displayRate(*r.Rate, r.RateLowerBound), opts.FailRate))
+		}
+
+		if ratio := newExpiredRatio(r.Count, r.Expired); opts.FailRatio > 0 && float64(ratio) > opts.FailRatio {
+			violations = append(violations, fmt.Sprintf("%s has %s expired, above %s", r.Name, ratio, expiredRatio(opts.FailRatio)))
+		}
+	}
+
+	return violations
+}
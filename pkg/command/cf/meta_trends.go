@@ -0,0 +1,252 @@
+package cf
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// metaSnapshot is a single line of a --record file.
+type metaSnapshot struct {
+	SchemaVersion int                           `json:"schema_version"`
+	Time          time.Time                     `json:"time"`
+	Sources       map[string]metaSnapshotSource `json:"sources"`
+}
+
+type metaSnapshotSource struct {
+	Count   int64 `json:"count"`
+	Expired int64 `json:"expired"`
+}
+
+type metaTrend struct {
+	sourceID string
+	first    time.Time
+	last     time.Time
+	counts   []int64
+	expired  []int64
+}
+
+// expiredPerMinute is the rate at which envelopes were pushed out of the
+// cache between the first and last snapshot. With a full cache this is the
+// rate at which the source emits envelopes.
+func (t metaTrend) expiredPerMinute() float64 {
+	minutes := t.last.Sub(t.first).Minutes()
+	if minutes <= 0 {
+		return 0
+	}
+
+	return float64(t.expired[len(t.expired)-1]-t.expired[0]) / minutes
+}
+
+// recordMetaTrends appends a snapshot of the meta of every source to the
+// file every interval until the duration has passed or the context is
+// done. A snapshot is taken at the start and at the end of the duration.
+func recordMetaTrends(
+	ctx context.Context,
+	client *logcache.Client,
+	path string,
+	interval time.Duration,
+	duration time.Duration,
+	exclude *regexp.Regexp,
+	w io.Writer,
+	log Logger,
+) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("Could not open %s: %s", path, err)
+	}
+	defer f.Close()
+
+	snapshots := int(duration/interval) + 1
+	for i := 0; i < snapshots; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+
+		meta, err := client.Meta(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Fatalf("Failed to read Meta information: %s", err)
+		}
+
+		snapshot := newMetaSnapshot(meta, exclude)
+		line, err := marshalJSON(snapshot)
+		if err != nil {
+			log.Fatalf("Could not write snapshot: %s", err)
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			log.Fatalf("Could not write snapshot: %s", err)
+		}
+
+		fmt.Fprintf(w, "Recorded %d sources at %s (%d/%d).\n", len(snapshot.Sources), snapshot.Time.Format(time.RFC3339), i+1, snapshots)
+	}
+}
+
+// newMetaSnapshot takes a snapshot of the meta of every source that is not
+// excluded.
+func newMetaSnapshot(meta map[string]*logcache_v1.MetaInfo, exclude *regexp.Regexp) metaSnapshot {
+	snapshot := metaSnapshot{
+		SchemaVersion: schemaVersion,
+		Time:          time.Now().UTC(),
+		Sources:       make(map[string]metaSnapshotSource),
+	}
+	for sourceID, m := range meta {
+		if exclude != nil && exclude.MatchString(sourceID) {
+			continue
+		}
+		snapshot.Sources[sourceID] = metaSnapshotSource{Count: m.Count, Expired: m.Expired}
+	}
+
+	return snapshot
+}
+
+// writeMetaTrendReport tabulates the growth of every source in a file
+// written with --record, fastest expiring sources first.
+func writeMetaTrendReport(path string, tw *tableWriter, accessible bool, log Logger) {
+	trends, err := readMetaTrends(path)
+	if err != nil {
+		log.Fatalf("Could not read %s: %s", path, err)
+	}
+
+	if len(trends) == 0 {
+		log.Fatalf("No snapshots recorded in %s.", path)
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		ri, rj := trends[i].expiredPerMinute(), trends[j].expiredPerMinute()
+		if ri != rj {
+			return ri > rj
+		}
+		return trends[i].sourceID < trends[j].sourceID
+	})
+
+	fmt.Fprintf(tw, "Source\tSnapshots\tCount\tCount Change\tExpired/min\tTrend\n")
+	for _, t := range trends {
+		trend := sparkline(t.counts)
+		if accessible {
+			trend = describeTrend(t.counts)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\t%.1f\t%s\n",
+			t.sourceID,
+			len(t.counts),
+			t.counts[len(t.counts)-1],
+			t.counts[len(t.counts)-1]-t.counts[0],
+			t.expiredPerMinute(),
+			trend,
+		)
+	}
+
+	if err := tw.Flush(); err != nil {
+		log.Fatalf("Error writing results")
+	}
+}
+
+func readMetaTrends(path string) ([]metaTrend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	trends := make(map[string]*metaTrend)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var s metaSnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		if s.SchemaVersion > schemaVersion {
+			return nil, fmt.Errorf("line %d: schema version %d is newer than the supported version %d", n, s.SchemaVersion, schemaVersion)
+		}
+
+		for sourceID, m := range s.Sources {
+			t, ok := trends[sourceID]
+			if !ok {
+				t = &metaTrend{sourceID: sourceID, first: s.Time}
+				trends[sourceID] = t
+			}
+			t.last = s.Time
+			t.counts = append(t.counts, m.Count)
+			t.expired = append(t.expired, m.Expired)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []metaTrend
+	for _, t := range trends {
+		result = append(result, *t)
+	}
+
+	return result, nil
+}
+
+// sparkline scales the values between their minimum and maximum.
+func sparkline(values []int64) string {
+	min, max := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+
+	var line []rune
+	for _, v := range values {
+		i := 0
+		if max > min {
+			i = int((v - min) * int64(len(sparkBlocks)-1) / (max - min))
+		}
+		line = append(line, sparkBlocks[i])
+	}
+
+	return string(line)
+}
+
+// describeTrend describes the direction of the values in words, for
+// output that is read by screen readers instead of a sparkline.
+func describeTrend(values []int64) string {
+	var up, down bool
+	for i := 1; i < len(values); i++ {
+		switch {
+		case values[i] > values[i-1]:
+			up = true
+		case values[i] < values[i-1]:
+			down = true
+		}
+	}
+
+	switch {
+	case up && down:
+		return "fluctuating"
+	case up:
+		return "rising"
+	case down:
+		return "falling"
+	default:
+		return "flat"
+	}
+}
@@ -0,0 +1,42 @@
+package cf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// clearScreen moves the cursor to the top left corner and clears the
+// terminal.
+const clearScreen = "\033[H\033[2J"
+
+func withMetaWatching() MetaOption {
+	return func(o *optionsFlags) {
+		o.watching = true
+	}
+}
+
+// watchMeta renders the meta table every interval until the context is
+// done. Every table is rendered before the screen is cleared, so the
+// previous table stays visible while the next one is being fetched. The
+// table is drawn before a fatal log, too.
+func watchMeta(ctx context.Context, interval time.Duration, w io.Writer, log Logger, render func(context.Context, io.Writer, Logger)) {
+	for {
+		var buf bytes.Buffer
+		draw := func() {
+			fmt.Fprint(w, clearScreen)
+			w.Write(buf.Bytes())
+		}
+
+		render(ctx, &buf, restoringLogger{Logger: log, restore: draw})
+		draw()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
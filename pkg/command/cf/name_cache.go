@@ -0,0 +1,143 @@
+package cf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+)
+
+const nameCacheFile = "names.json"
+
+type cachedSourceNames struct {
+	APIEndpoint string                      `json:"api_endpoint"`
+	Sources     map[string]cachedSourceName `json:"sources"`
+}
+
+type cachedSourceName struct {
+	Name       string     `json:"name"`
+	Type       sourceType `json:"type"`
+	ResolvedAt time.Time  `json:"resolved_at"`
+}
+
+// getCachedSourceInfo returns the names of the sources like getSourceInfo.
+// Names resolved within the TTL are read from the name cache in the config
+// directory and only the other sources are looked up in CAPI. With refresh
+// every source is looked up again. Failing to read or write the cache never
+// fails the command.
+func getCachedSourceInfo(
+	metaInfo map[string]*logcache_v1.MetaInfo,
+	cli plugin.CliConnection,
+	ttl time.Duration,
+	refresh bool,
+) ([]source, error) {
+	if ttl <= 0 {
+		return getSourceInfo(metaInfo, cli)
+	}
+
+	endpoint, err := cli.ApiEndpoint()
+	if err != nil {
+		return getSourceInfo(metaInfo, cli)
+	}
+
+	path, err := nameCachePath()
+	if err != nil {
+		return getSourceInfo(metaInfo, cli)
+	}
+
+	cache := cachedSourceNames{
+		APIEndpoint: endpoint,
+		Sources:     make(map[string]cachedSourceName),
+	}
+	if c, ok := readCachedSourceNames(path); ok && c.APIEndpoint == endpoint && c.Sources != nil {
+		cache.Sources = c.Sources
+	}
+
+	now := time.Now()
+	for sourceID, n := range cache.Sources {
+		if now.Sub(n.ResolvedAt) >= ttl {
+			delete(cache.Sources, sourceID)
+		}
+	}
+
+	var resources []source
+	uncached := make(map[string]*logcache_v1.MetaInfo)
+	for sourceID, m := range metaInfo {
+		// Only GUIDs have names in CAPI, other sources are not looked up
+		// at all.
+		if !appOrServiceRegex.MatchString(sourceID) {
+			continue
+		}
+
+		if n, ok := cache.Sources[sourceID]; ok && !refresh {
+			resources = append(resources, source{
+				GUID: sourceID,
+				Name: n.Name,
+				Type: n.Type,
+			})
+			continue
+		}
+
+		uncached[sourceID] = m
+	}
+
+	if len(uncached) == 0 {
+		return resources, nil
+	}
+
+	resolved, err := getSourceInfo(uncached, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range resolved {
+		cache.Sources[s.GUID] = cachedSourceName{
+			Name:       s.Name,
+			Type:       s.Type,
+			ResolvedAt: now,
+		}
+	}
+	_ = writeCachedSourceNames(path, cache)
+
+	return append(resources, resolved...), nil
+}
+
+func nameCachePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, nameCacheFile), nil
+}
+
+func readCachedSourceNames(path string) (cachedSourceNames, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cachedSourceNames{}, false
+	}
+
+	var c cachedSourceNames
+	if err := json.Unmarshal(data, &c); err != nil {
+		return cachedSourceNames{}, false
+	}
+
+	return c, true
+}
+
+func writeCachedSourceNames(path string, c cachedSourceNames) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,90 @@
+package cf
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+// parseNames returns the names of every value of a repeatable flag, each of
+// which may be a comma separated list.
+func parseNames(values []string) []string {
+	var names []string
+	for _, v := range values {
+		for _, n := range strings.Split(v, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, n)
+			}
+		}
+	}
+
+	return names
+}
+
+// nameFilterPattern returns the pattern matching the counter or gauge
+// names to read. Envelopes are still filtered locally, so Log Cache
+// versions that don't support the name filter return the same output, just
+// slower. Gauge globs with character classes or escapes aren't translated
+// and are only filtered locally.
+func (o options) nameFilterPattern() (string, bool) {
+	var alternatives []string
+	for _, n := range o.counterNames {
+		alternatives = append(alternatives, regexp.QuoteMeta(n))
+	}
+
+	for _, n := range o.gaugeNames {
+		if strings.ContainsAny(n, "[\\") {
+			return "", false
+		}
+
+		alternatives = append(alternatives, globPattern(n))
+	}
+
+	if len(alternatives) == 0 {
+		return "", false
+	}
+
+	return "^(" + strings.Join(alternatives, "|") + ")$", true
+}
+
+// globPattern translates a glob with only * and ? wildcards to a regular
+// expression. Like path.Match, the wildcards don't match a slash.
+func globPattern(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	return b.String()
+}
+
+// withNameFilter only reads envelopes with a name matching the pattern.
+func withNameFilter(pattern string) logcache.ReadOption {
+	return func(_ *url.URL, q url.Values) {
+		q.Set("name_filter", pattern)
+	}
+}
+
+// withReadOptions adds the options to every read.
+func withReadOptions(r logcache.Reader, extra ...logcache.ReadOption) logcache.Reader {
+	return func(
+		ctx context.Context,
+		sourceID string,
+		start time.Time,
+		opts ...logcache.ReadOption,
+	) ([]*loggregator_v2.Envelope, error) {
+		return r(ctx, sourceID, start, append(opts, extra...)...)
+	}
+}
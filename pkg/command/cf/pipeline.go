@@ -0,0 +1,430 @@
+package cf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"text/template"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	defaultPipelineQueryWindow = 15 * time.Minute
+	defaultPipelineChunkSize   = 10000
+	defaultPipelineMessage     = "{{.Matches}} matching envelopes for {{.Source}}"
+)
+
+// pipelineFile is the format of a pipeline.yml file. The steps run in
+// order against a single source.
+type pipelineFile struct {
+	Source string         `yaml:"source"`
+	Steps  []pipelineStep `yaml:"steps"`
+}
+
+// pipelineStep holds exactly one step.
+type pipelineStep struct {
+	Query     *pipelineQuery     `yaml:"query"`
+	Threshold *pipelineThreshold `yaml:"threshold"`
+	Export    *pipelineExport    `yaml:"export"`
+	Notify    *pipelineNotify    `yaml:"notify"`
+}
+
+// pipelineQuery reads the source's logs and records the envelopes whose
+// payload matches the regular expression. An empty expression matches
+// every log.
+type pipelineQuery struct {
+	Last  time.Duration `yaml:"last"`
+	Match string        `yaml:"match"`
+}
+
+// pipelineThreshold stops the pipeline when the query matched fewer than
+// the minimum number of envelopes.
+type pipelineThreshold struct {
+	Min int `yaml:"min"`
+}
+
+// pipelineExport exports every envelope of the source between the first
+// and the last match in the same format as log-export.
+type pipelineExport struct {
+	Dir       string `yaml:"dir"`
+	ChunkSize int    `yaml:"chunk_size"`
+}
+
+// pipelineNotify shows a desktop notification. The message is a Go
+// template with the fields Source, Matches, Start and End.
+type pipelineNotify struct {
+	Title   string `yaml:"title"`
+	Message string `yaml:"message"`
+}
+
+// pipelineResult is the outcome of the query step. It is passed to the
+// notify message template.
+type pipelineResult struct {
+	Source  string
+	Matches int
+	Start   time.Time
+	End     time.Time
+}
+
+// PipelineOption configures the Pipeline command.
+type PipelineOption func(*pipelineOptions)
+
+type pipelineOptions struct {
+	scrubber *Scrubber
+	notifier Notifier
+}
+
+// WithPipelineScrubber masks sensitive data in exported envelopes.
+func WithPipelineScrubber(s *Scrubber) PipelineOption {
+	return func(o *pipelineOptions) {
+		o.scrubber = s
+	}
+}
+
+// WithPipelineNotifier replaces the notifier used by notify steps.
+func WithPipelineNotifier(n Notifier) PipelineOption {
+	return func(o *pipelineOptions) {
+		o.notifier = n
+	}
+}
+
+type pipelineRun struct {
+	client   *logcache.Client
+	name     string
+	sourceID string
+	opts     pipelineOptions
+	log      Logger
+	w        io.Writer
+
+	result pipelineResult
+}
+
+// Pipeline runs the steps of a pipeline.yml file, such as querying a
+// source, checking a threshold, exporting the matching window and
+// notifying, with one authenticated client and a shared context.
+func Pipeline(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	args []string,
+	c HTTPClient,
+	log Logger,
+	w io.Writer,
+	opts ...PipelineOption,
+) {
+	args, err := parseFlags("log-pipeline", &struct{}{}, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) != 1 {
+		log.Fatalf("Expected 1 argument, got %d.", len(args))
+	}
+
+	po := pipelineOptions{notifier: desktopNotify}
+	for _, opt := range opts {
+		opt(&po)
+	}
+
+	p, err := loadPipeline(args[0])
+	if err != nil {
+		log.Fatalf("Invalid pipeline %s: %s", args[0], err)
+	}
+
+	logCacheEndpoint, err := logCacheEndpoint(cli)
+	if err != nil {
+		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+	}
+
+	client := logcache.NewClient(
+		logCacheEndpoint,
+		logcache.WithHTTPClient(authenticatedClient(cli, c, log)),
+	)
+
+	name := p.Source
+	var sourceID string
+	if isSourcePattern(name) {
+		sourceIDs, err := expandSourcePattern(ctx, client, name)
+		if err != nil {
+			log.Fatalf("Could not expand source pattern %s: %s", name, err)
+		}
+
+		if len(sourceIDs) != 1 {
+			log.Fatalf("Source pattern %s matches %d sources, expected 1.", name, len(sourceIDs))
+		}
+		name, sourceID = sourceIDs[0], sourceIDs[0]
+	} else {
+		sourceID = getAppGUID(name, cli, log)
+		if sourceID == "" {
+			sourceID = name
+		}
+	}
+
+	run := &pipelineRun{
+		client:   client,
+		name:     name,
+		sourceID: sourceID,
+		opts:     po,
+		log:      log,
+		w:        w,
+	}
+
+	for i, step := range p.Steps {
+		if ctx.Err() != nil {
+			fmt.Fprintf(w, "Pipeline interrupted before step %d.\n", i+1)
+			return
+		}
+
+		var next bool
+		switch {
+		case step.Query != nil:
+			next = run.query(ctx, i+1, step.Query)
+		case step.Threshold != nil:
+			next = run.threshold(i+1, step.Threshold)
+		case step.Export != nil:
+			next = run.export(ctx, i+1, step.Export)
+		case step.Notify != nil:
+			next = run.notify(i+1, step.Notify)
+		}
+
+		if !next {
+			return
+		}
+	}
+}
+
+// loadPipeline reads and validates a pipeline file.
+func loadPipeline(path string) (*pipelineFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pipelineFile{}
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, err
+	}
+
+	if p.Source == "" {
+		return nil, fmt.Errorf("source is required")
+	}
+
+	if len(p.Steps) == 0 {
+		return nil, fmt.Errorf("at least one step is required")
+	}
+
+	var queried bool
+	for i, s := range p.Steps {
+		var n int
+		for _, set := range []bool{s.Query != nil, s.Threshold != nil, s.Export != nil, s.Notify != nil} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			return nil, fmt.Errorf("step %d must have exactly one of query, threshold, export or notify", i+1)
+		}
+
+		switch {
+		case s.Query != nil:
+			if s.Query.Last < 0 {
+				return nil, fmt.Errorf("step %d: last must be greater than 0", i+1)
+			}
+			if s.Query.Last == 0 {
+				s.Query.Last = defaultPipelineQueryWindow
+			}
+			if _, err := regexp.Compile(s.Query.Match); err != nil {
+				return nil, fmt.Errorf("step %d: invalid match: %s", i+1, err)
+			}
+			queried = true
+		case !queried:
+			return nil, fmt.Errorf("step %d needs a query step before it", i+1)
+		case s.Threshold != nil:
+			if s.Threshold.Min <= 0 {
+				return nil, fmt.Errorf("step %d: min must be greater than 0", i+1)
+			}
+		case s.Export != nil:
+			if s.Export.Dir == "" {
+				return nil, fmt.Errorf("step %d: dir is required", i+1)
+			}
+			if s.Export.ChunkSize < 0 {
+				return nil, fmt.Errorf("step %d: chunk_size must be greater than 0", i+1)
+			}
+			if s.Export.ChunkSize == 0 {
+				s.Export.ChunkSize = defaultPipelineChunkSize
+			}
+		case s.Notify != nil:
+			if s.Notify.Message == "" {
+				s.Notify.Message = defaultPipelineMessage
+			}
+			if _, err := template.New("Message").Parse(s.Notify.Message); err != nil {
+				return nil, fmt.Errorf("step %d: invalid message: %s", i+1, err)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+func (r *pipelineRun) query(ctx context.Context, step int, q *pipelineQuery) bool {
+	match := regexp.MustCompile(q.Match)
+	end := time.Now()
+
+	r.result = pipelineResult{Source: r.name}
+
+	var first, last int64
+	logcache.Walk(
+		ctx,
+		r.sourceID,
+		logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+			for _, e := range envelopes {
+				if !match.Match(e.GetLog().GetPayload()) {
+					continue
+				}
+
+				r.result.Matches++
+				if first == 0 || e.Timestamp < first {
+					first = e.Timestamp
+				}
+				if e.Timestamp > last {
+					last = e.Timestamp
+				}
+			}
+			return ctx.Err() == nil
+		}),
+		r.client.Read,
+		logcache.WithWalkStartTime(end.Add(-q.Last)),
+		logcache.WithWalkEndTime(end),
+		logcache.WithWalkEnvelopeTypes(logcache_v1.EnvelopeType_LOG),
+		logcache.WithWalkBackoff(newBackoff(r.log)),
+	)
+
+	if r.result.Matches > 0 {
+		r.result.Start = time.Unix(0, first)
+		r.result.End = time.Unix(0, last)
+	}
+
+	fmt.Fprintf(r.w, "Step %d: query matched %d envelopes of %s in the last %s.\n", step, r.result.Matches, r.name, q.Last)
+
+	return ctx.Err() == nil
+}
+
+func (r *pipelineRun) threshold(step int, t *pipelineThreshold) bool {
+	if r.result.Matches < t.Min {
+		fmt.Fprintf(r.w, "Step %d: %d matches are below the threshold of %d, stopping.\n", step, r.result.Matches, t.Min)
+		return false
+	}
+
+	fmt.Fprintf(r.w, "Step %d: %d matches reached the threshold of %d.\n", step, r.result.Matches, t.Min)
+	return true
+}
+
+// export writes the matching window with the log-export machinery so the
+// directory can later be appended to or resumed with log-export.
+func (r *pipelineRun) export(ctx context.Context, step int, x *pipelineExport) bool {
+	if r.result.Matches == 0 {
+		fmt.Fprintf(r.w, "Step %d: nothing to export, the query matched no envelopes.\n", step)
+		return true
+	}
+
+	manifest, err := readExportManifest(x.Dir)
+	if err != nil {
+		r.log.Fatalf("Could not read export manifest: %s", err)
+	}
+
+	checkpoint, err := readExportCheckpoint(x.Dir)
+	if err != nil {
+		r.log.Fatalf("Could not read export checkpoint: %s", err)
+	}
+	if checkpoint != nil {
+		r.log.Fatalf("An interrupted export exists in %s. Use cf log-export --resume to continue it.", x.Dir)
+	}
+
+	source, ok := manifest.Sources[r.sourceID]
+	if !ok {
+		source = &exportedSource{Name: r.name}
+		manifest.Sources[r.sourceID] = source
+	}
+
+	start := r.result.Start
+	if source.LastTimestamp >= start.UnixNano() {
+		start = time.Unix(0, source.LastTimestamp+1)
+	}
+	end := r.result.End.Add(time.Nanosecond)
+
+	targets := []exportTarget{{name: r.name, sourceID: r.sourceID, source: source, start: start}}
+	checkpoint = newExportCheckpoint(end, targets)
+
+	if err := os.MkdirAll(x.Dir, 0755); err != nil {
+		r.log.Fatalf("Could not create export directory: %s", err)
+	}
+	if err := writeExportCheckpoint(x.Dir, checkpoint); err != nil {
+		r.log.Fatalf("Could not write export checkpoint: %s", err)
+	}
+
+	filename, err := parseExportFilenameTemplate(defaultExportFilenameTemplate)
+	if err != nil {
+		r.log.Fatalf("Invalid --filename-template: %s", err)
+	}
+
+	e := &exporter{
+		client:     r.client,
+		dir:        x.Dir,
+		chunkSize:  x.ChunkSize,
+		filename:   filename,
+		scrubber:   r.opts.scrubber,
+		log:        r.log,
+		manifest:   manifest,
+		checkpoint: checkpoint,
+	}
+	result := e.export(ctx, targets[0], end, func(int64, int) {}, func(int, error) {})
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(r.w, "Step %d: export interrupted. Run cf log-export --dir %s --resume to continue.\n", step, x.Dir)
+		return false
+	}
+
+	if err := removeExportCheckpoint(x.Dir); err != nil {
+		r.log.Fatalf("Could not remove export checkpoint: %s", err)
+	}
+
+	fmt.Fprintf(
+		r.w,
+		"Step %d: exported %d envelopes from %s to %s to %s.\n",
+		step,
+		result.envelopes,
+		r.result.Start.Format(time.RFC3339),
+		r.result.End.Format(time.RFC3339),
+		x.Dir,
+	)
+
+	return true
+}
+
+func (r *pipelineRun) notify(step int, n *pipelineNotify) bool {
+	title := n.Title
+	if title == "" {
+		title = "cf log-pipeline " + r.name
+	}
+
+	var msg bytes.Buffer
+	if err := template.Must(template.New("Message").Parse(n.Message)).Execute(&msg, r.result); err != nil {
+		r.log.Fatalf("Notify message parsed, but failed to execute: %s", err)
+	}
+
+	if err := r.opts.notifier(title, msg.String()); err != nil {
+		r.log.Fatalf("Could not show desktop notification: %s", err)
+	}
+
+	fmt.Fprintf(r.w, "Step %d: notified %q.\n", step, msg.String())
+	return true
+}
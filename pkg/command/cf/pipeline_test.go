@@ -0,0 +1,223 @@
+package cf_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pipeline", func() {
+	var (
+		logger        *stubLogger
+		writer        *stubWriter
+		httpClient    *stubHTTPClient
+		cliConn       *stubCliConnection
+		startTime     time.Time
+		dir           string
+		notifications []string
+		notifier      cf.Notifier
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+
+		var err error
+		dir, err = ioutil.TempDir("", "pipeline")
+		Expect(err).ToNot(HaveOccurred())
+
+		httpClient.responseBody = []string{
+			logResponseBody(startTime, "ERROR first", "ok", "ERROR second"),
+			emptyResponseBody(),
+			logResponseBody(startTime, "ERROR first", "ok", "ERROR second"),
+			emptyResponseBody(),
+		}
+
+		notifications = nil
+		notifier = func(title, message string) error {
+			notifications = append(notifications, title+": "+message)
+			return nil
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	writePipeline := func(content string) string {
+		path := filepath.Join(dir, "pipeline.yml")
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	It("runs the steps in order", func() {
+		exportDir := filepath.Join(dir, "incident")
+		path := writePipeline(fmt.Sprintf(`
+source: app-name
+steps:
+- query:
+    last: 1h
+    match: ERROR
+- threshold:
+    min: 2
+- export:
+    dir: %s
+- notify:
+    message: "{{.Matches}} errors in {{.Source}}"
+`, exportDir))
+
+		cf.Pipeline(
+			context.Background(),
+			cliConn,
+			[]string{path},
+			httpClient,
+			logger,
+			writer,
+			cf.WithPipelineNotifier(notifier),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Step 1: query matched 2 envelopes of app-name in the last 1h0m0s.",
+			"Step 2: 2 matches reached the threshold of 2.",
+			fmt.Sprintf(
+				"Step 3: exported 3 envelopes from %s to %s to %s.",
+				startTime.Format(time.RFC3339),
+				startTime.Add(2*time.Second).Format(time.RFC3339),
+				exportDir,
+			),
+			`Step 4: notified "2 errors in app-name".`,
+		}))
+		Expect(notifications).To(Equal([]string{"cf log-pipeline app-name: 2 errors in app-name"}))
+
+		start, end := readTimeRange(httpClient.requestURLs[2])
+		Expect(start).To(Equal(startTime))
+		Expect(end).To(Equal(startTime.Add(2*time.Second + time.Nanosecond)))
+
+		m := readManifest(exportDir)
+		Expect(m.Sources["app-guid"].Chunks).To(HaveLen(1))
+		_, err := os.Stat(filepath.Join(exportDir, "checkpoint.json"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("stops when the threshold is not reached", func() {
+		path := writePipeline(`
+source: app-name
+steps:
+- query:
+    match: ERROR
+- threshold:
+    min: 3
+- notify: {}
+`)
+
+		cf.Pipeline(
+			context.Background(),
+			cliConn,
+			[]string{path},
+			httpClient,
+			logger,
+			writer,
+			cf.WithPipelineNotifier(notifier),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Step 1: query matched 2 envelopes of app-name in the last 15m0s.",
+			"Step 2: 2 matches are below the threshold of 3, stopping.",
+		}))
+		Expect(notifications).To(BeEmpty())
+	})
+
+	It("uses the same client for every step", func() {
+		path := writePipeline(`
+source: app-name
+steps:
+- query: {}
+- query: {}
+`)
+
+		cf.Pipeline(context.Background(), cliConn, []string{path}, httpClient, logger, writer)
+
+		Expect(httpClient.requestCount()).To(Equal(4))
+		Expect(cliConn.accessTokenCount).To(Equal(1))
+	})
+
+	It("fatally logs when a source pattern does not match exactly one source", func() {
+		httpClient.responseBody = []string{metaResponseInfo("router-a", "router-b")}
+		path := writePipeline(`
+source: router*
+steps:
+- query: {}
+`)
+
+		Expect(func() {
+			cf.Pipeline(context.Background(), cliConn, []string{path}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Source pattern router* matches 2 sources, expected 1."))
+	})
+
+	It("fatally logs for a step without a query before it", func() {
+		path := writePipeline(`
+source: app-name
+steps:
+- threshold:
+    min: 1
+`)
+
+		Expect(func() {
+			cf.Pipeline(context.Background(), cliConn, []string{path}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal(fmt.Sprintf("Invalid pipeline %s: step 1 needs a query step before it", path)))
+	})
+
+	It("fatally logs for a step with more than one kind", func() {
+		path := writePipeline(`
+source: app-name
+steps:
+- query: {}
+  notify: {}
+`)
+
+		Expect(func() {
+			cf.Pipeline(context.Background(), cliConn, []string{path}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal(fmt.Sprintf("Invalid pipeline %s: step 1 must have exactly one of query, threshold, export or notify", path)))
+	})
+
+	It("fatally logs for unknown fields", func() {
+		path := writePipeline(`
+source: app-name
+steps:
+- query:
+    lats: 1h
+`)
+
+		Expect(func() {
+			cf.Pipeline(context.Background(), cliConn, []string{path}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(ContainSubstring("field lats not found"))
+	})
+
+	It("fatally logs when not given a pipeline", func() {
+		Expect(func() {
+			cf.Pipeline(context.Background(), cliConn, []string{}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Expected 1 argument, got 0."))
+	})
+})
@@ -0,0 +1,99 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	colorReset   = "\x1b[0m"
+	colorKey     = "\x1b[36m"
+	colorString  = "\x1b[32m"
+	colorNumber  = "\x1b[33m"
+	colorLiteral = "\x1b[35m"
+)
+
+// formatJSON re-emits a JSON object or array payload indented, or compacted
+// onto a single line when indentJSON is false. It reports false when pretty
+// printing is disabled or the payload is not JSON.
+func (e envelopeWrapper) formatJSON(payload string) (string, bool) {
+	if !e.prettyJSON {
+		return "", false
+	}
+
+	trimmed := strings.TrimSpace(payload)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+
+	if !json.Valid([]byte(trimmed)) {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if e.indentJSON {
+		err = json.Indent(&buf, []byte(trimmed), "", "  ")
+	} else {
+		err = json.Compact(&buf, []byte(trimmed))
+	}
+	if err != nil {
+		return "", false
+	}
+
+	if !e.color {
+		return buf.String(), true
+	}
+
+	return highlightJSON(buf.String()), true
+}
+
+// highlightJSON wraps keys, strings, numbers and literals of valid JSON in
+// ANSI color codes.
+func highlightJSON(s string) string {
+	var out bytes.Buffer
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+
+			color := colorString
+			if rest := strings.TrimLeft(s[end:], " \n"); strings.HasPrefix(rest, ":") {
+				color = colorKey
+			}
+
+			out.WriteString(color + s[i:end] + colorReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+				end++
+			}
+
+			out.WriteString(colorNumber + s[i:end] + colorReset)
+			i = end
+		case c == 't' || c == 'f' || c == 'n':
+			end := i + 1
+			for end < len(s) && s[end] >= 'a' && s[end] <= 'z' {
+				end++
+			}
+
+			out.WriteString(colorLiteral + s[i:end] + colorReset)
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,214 @@
+package cf
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const slowestEndpoints = 5
+
+// RequestStats records the HTTP requests of a single command invocation and
+// summarizes them when the command ends, to help diagnose slow runs.
+type RequestStats struct {
+	w     io.Writer
+	start time.Time
+
+	mu        sync.Mutex
+	requests  int
+	retries   int
+	failures  int
+	sent      int64
+	received  int64
+	failed    map[string]bool
+	endpoints map[string]*endpointStats
+	written   bool
+}
+
+type endpointStats struct {
+	name     string
+	requests int
+	total    time.Duration
+	slowest  time.Duration
+}
+
+// NewRequestStats starts recording requests. The summary is written to w.
+func NewRequestStats(w io.Writer) *RequestStats {
+	return &RequestStats{
+		w:         w,
+		start:     time.Now(),
+		failed:    make(map[string]bool),
+		endpoints: make(map[string]*endpointStats),
+	}
+}
+
+// HTTPClient wraps the given client to record every request.
+func (s *RequestStats) HTTPClient(c HTTPClient) HTTPClient {
+	return &statsHTTPClient{c: c, s: s}
+}
+
+// Logger wraps the given logger to write the summary before the wrapped
+// logger exits.
+func (s *RequestStats) Logger(l Logger) Logger {
+	return &statsLogger{Logger: l, s: s}
+}
+
+// Write writes the summary. Only the first call writes a summary.
+func (s *RequestStats) Write() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.written {
+		return
+	}
+	s.written = true
+
+	fmt.Fprintf(
+		s.w,
+		"Requests: %d (%d retries, %d failed), sent %s, received %s in %s\n",
+		s.requests,
+		s.retries,
+		s.failures,
+		formatBytes(s.sent),
+		formatBytes(s.received),
+		time.Since(s.start).Round(time.Millisecond),
+	)
+
+	if len(s.endpoints) == 0 {
+		return
+	}
+
+	var endpoints []*endpointStats
+	for _, e := range s.endpoints {
+		endpoints = append(endpoints, e)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].slowest != endpoints[j].slowest {
+			return endpoints[i].slowest > endpoints[j].slowest
+		}
+		return endpoints[i].name < endpoints[j].name
+	})
+	if len(endpoints) > slowestEndpoints {
+		endpoints = endpoints[:slowestEndpoints]
+	}
+
+	tw := newTableWriter(s.w, tableStylePlain, true)
+	fmt.Fprintf(tw, "Endpoint\tRequests\tAverage\tSlowest\n")
+	for _, e := range endpoints {
+		fmt.Fprintf(
+			tw,
+			"%s\t%d\t%s\t%s\n",
+			e.name,
+			e.requests,
+			(e.total / time.Duration(e.requests)).Round(time.Millisecond),
+			e.slowest.Round(time.Millisecond),
+		)
+	}
+	tw.Flush()
+}
+
+// record adds a request that took d. A request for the same URL as an
+// earlier failed request is counted as a retry.
+func (s *RequestStats) record(req *http.Request, d time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := req.Method + " " + req.URL.String()
+	if s.failed[key] {
+		s.retries++
+	}
+	s.failed[key] = failed
+
+	s.requests++
+	if failed {
+		s.failures++
+	}
+	if req.ContentLength > 0 {
+		s.sent += req.ContentLength
+	}
+
+	name := req.Method + " " + req.URL.Host + req.URL.Path
+	e, ok := s.endpoints[name]
+	if !ok {
+		e = &endpointStats{name: name}
+		s.endpoints[name] = e
+	}
+	e.requests++
+	e.total += d
+	if d > e.slowest {
+		e.slowest = d
+	}
+}
+
+func (s *RequestStats) addReceived(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received += int64(n)
+}
+
+type statsHTTPClient struct {
+	c HTTPClient
+	s *RequestStats
+}
+
+func (c *statsHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.c.Do(req)
+
+	failed := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	c.s.record(req, time.Since(start), failed)
+
+	if err == nil {
+		resp.Body = &countingReadCloser{ReadCloser: resp.Body, s: c.s}
+	}
+
+	return resp, err
+}
+
+// countingReadCloser counts the response bytes as they are read.
+type countingReadCloser struct {
+	io.ReadCloser
+	s *RequestStats
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.s.addReceived(n)
+	return n, err
+}
+
+type statsLogger struct {
+	Logger
+	s *RequestStats
+}
+
+func (l *statsLogger) Fatalf(format string, args ...interface{}) {
+	l.s.Write()
+	l.Logger.Fatalf(format, args...)
+}
+
+// StatsFlag removes --stats and --verbose from the arguments of a command
+// and reports whether either was given. Arguments after "--" are kept as
+// they are.
+func StatsFlag(args []string) ([]string, bool) {
+	var (
+		rest  []string
+		stats bool
+	)
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if arg == "--stats" || arg == "--verbose" {
+			stats = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	return rest, stats
+}
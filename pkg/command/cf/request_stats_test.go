@@ -0,0 +1,100 @@
+package cf_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequestStats", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+	)
+
+	BeforeEach(func() {
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+	})
+
+	It("summarizes the requests of a command", func() {
+		startTime := time.Now().Truncate(time.Second).Add(-time.Minute)
+		httpClient.responseBody = []string{logResponseBody(startTime, "one", "two")}
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+
+		stats := cf.NewRequestStats(writer)
+		cf.Tail(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			stats.HTTPClient(httpClient),
+			stats.Logger(logger),
+			&stubWriter{},
+			cf.WithTailNoHeaders(),
+		)
+		stats.Write()
+		stats.Write()
+
+		lines := writer.lines()
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(MatchRegexp(`^Requests: 1 \(0 retries, 0 failed\), sent 0 B, received [1-9]\d* B in `))
+		Expect(lines[1]).To(MatchRegexp(`^Endpoint\s+Requests\s+Average\s+Slowest$`))
+		Expect(lines[2]).To(MatchRegexp(`^GET log-cache.some-system.com/v1/read/app-guid\s+1\s+`))
+	})
+
+	It("counts requests for the URL of a failed request as retries", func() {
+		httpClient.responseCode = http.StatusServiceUnavailable
+
+		stats := cf.NewRequestStats(writer)
+		c := stats.HTTPClient(httpClient)
+		for i := 0; i < 3; i++ {
+			req, err := http.NewRequest(http.MethodGet, "https://log-cache.example.com/api/v1/read/app-guid?start_time=1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		stats.Write()
+
+		Expect(writer.lines()[0]).To(HavePrefix("Requests: 3 (2 retries, 3 failed)"))
+	})
+
+	It("writes the summary before failing", func() {
+		httpClient.responseErr = errors.New("some-error")
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+
+		stats := cf.NewRequestStats(writer)
+		Expect(func() {
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				stats.HTTPClient(httpClient),
+				stats.Logger(logger),
+				&stubWriter{},
+				cf.WithTailNoHeaders(),
+			)
+		}).To(Panic())
+
+		Expect(writer.lines()[0]).To(HavePrefix("Requests: 1 (0 retries, 1 failed)"))
+	})
+
+	It("removes --stats and --verbose from the arguments", func() {
+		args, stats := cf.StatsFlag([]string{"--stats", "app-name", "--verbose", "--", "--stats"})
+		Expect(stats).To(BeTrue())
+		Expect(args).To(Equal([]string{"app-name", "--", "--stats"}))
+
+		args, stats = cf.StatsFlag([]string{"app-name"})
+		Expect(stats).To(BeFalse())
+		Expect(args).To(Equal([]string{"app-name"}))
+	})
+})
@@ -0,0 +1,60 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+// retentionWindow is the start of a window requested for a source.
+type retentionWindow struct {
+	name     string
+	sourceID string
+	start    time.Time
+}
+
+// checkRetention compares the start of each window with the oldest envelope
+// Log Cache still holds for the source. When envelopes of the source have
+// already been evicted after the start of the window it warns that the
+// results are incomplete or, when strict, fails. The windows are not
+// checked when the meta information cannot be read.
+func checkRetention(ctx context.Context, client *logcache.Client, windows []retentionWindow, strict bool, log Logger) {
+	if len(windows) == 0 {
+		return
+	}
+
+	meta, err := client.Meta(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, w := range windows {
+		m, ok := meta[w.sourceID]
+		if !ok || m.Expired == 0 {
+			continue
+		}
+
+		oldest := time.Unix(0, m.OldestTimestamp)
+		if !w.start.Before(oldest) {
+			continue
+		}
+
+		evicted := oldest.Sub(w.start).Round(time.Second)
+		if evicted >= time.Minute {
+			evicted = evicted.Round(time.Minute)
+		}
+
+		msg := fmt.Sprintf(
+			"The first %s of the requested window for %s have been evicted from Log Cache. The oldest envelope is from %s.",
+			evicted,
+			w.name,
+			oldest.Format(time.RFC3339),
+		)
+		if strict {
+			log.Fatalf("%s", msg)
+		}
+		log.Printf("Warning: %s", msg)
+	}
+}
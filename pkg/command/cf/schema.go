@@ -0,0 +1,124 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// schemaVersion is written as "schema_version" in every JSON and NDJSON
+// document the plugin outputs. Within a version fields are only added.
+// Renaming or removing a field or changing its type or meaning increments
+// the version. The keys of every object are written in sorted order after
+// the schema version.
+const schemaVersion = 1
+
+// withSchemaVersion adds the schema version as the first field of a JSON
+// object.
+func withSchemaVersion(object string) string {
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(object), "{"))
+	if rest != "}" {
+		rest = "," + rest
+	}
+
+	return fmt.Sprintf(`{"schema_version":%d%s`, schemaVersion, rest)
+}
+
+// stableJSON re-encodes a JSON document with the keys of every object in
+// sorted order, except schema_version, which always comes first, so the
+// same data is always written the same way. Numbers are kept as written.
+func stableJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeStableJSON(&buf, v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// marshalJSON is like json.Marshal but writes the keys in the order of
+// stableJSON.
+func marshalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return stableJSON(data)
+}
+
+// marshalJSONIndent is like json.MarshalIndent but writes the keys in the
+// order of stableJSON.
+func marshalJSONIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, prefix, indent); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeStableJSON(buf *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i] == "schema_version" || keys[j] == "schema_version" {
+				return keys[i] == "schema_version"
+			}
+			return keys[i] < keys[j]
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeStableJSON(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeStableJSON(buf, t[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeStableJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package cf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const defaultScrubMask = "****"
+
+// Scrubber masks sensitive data in envelopes before they are written. A nil
+// Scrubber leaves envelopes untouched.
+type Scrubber struct {
+	mask     string
+	fields   map[string]bool
+	fieldsRe *regexp.Regexp
+	patterns []scrubPattern
+}
+
+type scrubPattern struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+type scrubRules struct {
+	Mask     string   `yaml:"mask"`
+	Fields   []string `yaml:"fields"`
+	Patterns []struct {
+		Pattern     string `yaml:"pattern"`
+		Replacement string `yaml:"replacement"`
+	} `yaml:"patterns"`
+}
+
+// LoadScrubber reads the masking rules from a scrub.yml file. Field rules
+// mask the value of matching keys in JSON payloads, key=value pairs and
+// tags. Pattern rules replace every match of a regular expression.
+func LoadScrubber(path string) (*Scrubber, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules scrubRules
+	if err := yaml.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("Failed to parse scrub file %s: %s", path, err)
+	}
+
+	s := &Scrubber{
+		mask:   rules.Mask,
+		fields: make(map[string]bool),
+	}
+	if s.mask == "" {
+		s.mask = defaultScrubMask
+	}
+
+	var quoted []string
+	for _, field := range rules.Fields {
+		s.fields[strings.ToLower(field)] = true
+		quoted = append(quoted, regexp.QuoteMeta(field))
+	}
+
+	if len(quoted) > 0 {
+		s.fieldsRe = regexp.MustCompile(
+			`(?i)("?\b(?:` + strings.Join(quoted, "|") + `)\b"?\s*[:=]\s*)("(?:[^"\\]|\\.)*"|[^\s,;&]+)`,
+		)
+	}
+
+	for _, p := range rules.Patterns {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid scrub pattern %q: %s", p.Pattern, err)
+		}
+
+		replacement := p.Replacement
+		if replacement == "" {
+			replacement = s.mask
+		}
+
+		s.patterns = append(s.patterns, scrubPattern{
+			re:          re,
+			replacement: replacement,
+		})
+	}
+
+	return s, nil
+}
+
+func (s *Scrubber) scrubEnvelope(e *loggregator_v2.Envelope) {
+	if s == nil {
+		return
+	}
+
+	switch m := e.Message.(type) {
+	case *loggregator_v2.Envelope_Log:
+		m.Log.Payload = []byte(s.scrub(string(m.Log.GetPayload())))
+	case *loggregator_v2.Envelope_Event:
+		m.Event.Body = s.scrub(m.Event.GetBody())
+	}
+
+	for k := range e.Tags {
+		if s.fields[strings.ToLower(k)] {
+			e.Tags[k] = s.mask
+		}
+	}
+}
+
+func (s *Scrubber) scrub(text string) string {
+	if len(s.fields) > 0 {
+		if scrubbed, ok := s.scrubJSON(text); ok {
+			text = scrubbed
+		} else {
+			mask := strings.Replace(s.mask, "$", "$$", -1)
+			text = s.fieldsRe.ReplaceAllString(text, "${1}"+mask)
+		}
+	}
+
+	for _, p := range s.patterns {
+		text = p.re.ReplaceAllString(text, p.replacement)
+	}
+
+	return text
+}
+
+// scrubJSON masks the values of configured fields when the given text is
+// a JSON object. The rest of the text is kept byte for byte, so keys stay
+// in their order. It reports false when the text is not JSON.
+func (s *Scrubber) scrubJSON(text string) (string, bool) {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") || !json.Valid([]byte(trimmed)) {
+		return "", false
+	}
+
+	mask, err := json.Marshal(s.mask)
+	if err != nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		if text[i] != '"' {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
+
+		end := jsonStringEnd(text, i)
+		b.WriteString(text[i:end])
+
+		// Only keys are followed by a colon.
+		colon := skipJSONSpace(text, end)
+		if colon == len(text) || text[colon] != ':' {
+			i = end
+			continue
+		}
+
+		var key string
+		if err := json.Unmarshal([]byte(text[i:end]), &key); err != nil || !s.fields[strings.ToLower(key)] {
+			i = end
+			continue
+		}
+
+		value := skipJSONSpace(text, colon+1)
+		b.WriteString(text[end:value])
+		b.Write(mask)
+		i = jsonValueEnd(text, value)
+	}
+
+	return b.String(), true
+}
+
+// jsonStringEnd returns the index after the closing quote of the JSON
+// string starting at i.
+func jsonStringEnd(text string, i int) int {
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+
+	return len(text)
+}
+
+// jsonValueEnd returns the index after the JSON value starting at i.
+func jsonValueEnd(text string, i int) int {
+	if i == len(text) {
+		return i
+	}
+
+	switch text[i] {
+	case '"':
+		return jsonStringEnd(text, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(text); j++ {
+			switch text[j] {
+			case '"':
+				j = jsonStringEnd(text, j) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+
+		return len(text)
+	default:
+		j := i
+		for j < len(text) && !strings.ContainsRune(",}] \t\r\n", rune(text[j])) {
+			j++
+		}
+
+		return j
+	}
+}
+
+// skipJSONSpace returns the index of the first non-whitespace byte from i.
+func skipJSONSpace(text string, i int) int {
+	for i < len(text) && strings.ContainsRune(" \t\r\n", rune(text[i])) {
+		i++
+	}
+
+	return i
+}
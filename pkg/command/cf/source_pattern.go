@@ -0,0 +1,39 @@
+package cf
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+// isSourcePattern reports whether a source argument is a wildcard pattern
+// such as 'router*' or a GUID prefix such as '6f2c*'.
+func isSourcePattern(s string) bool {
+	return strings.Contains(s, "*")
+}
+
+// expandSourcePattern returns the source IDs in Log Cache that match the
+// pattern, sorted.
+func expandSourcePattern(ctx context.Context, client *logcache.Client, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	meta, err := client.Meta(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceIDs []string
+	for sourceID := range meta {
+		if ok, _ := path.Match(pattern, sourceID); ok {
+			sourceIDs = append(sourceIDs, sourceID)
+		}
+	}
+	sort.Strings(sourceIDs)
+
+	return sourceIDs, nil
+}
@@ -0,0 +1,150 @@
+package cf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+type tableStyle string
+
+const (
+	tableStylePlain    tableStyle = "plain"
+	tableStyleGitHub   tableStyle = "github"
+	tableStyleMarkdown tableStyle = "markdown"
+	tableStyleTSV      tableStyle = "tsv"
+)
+
+func parseTableStyle(s string) (tableStyle, error) {
+	switch st := tableStyle(strings.ToLower(s)); st {
+	case "", tableStylePlain:
+		return tableStylePlain, nil
+	case tableStyleGitHub, tableStyleMarkdown, tableStyleTSV:
+		return st, nil
+	default:
+		return "", errors.New("--table-style must be 'plain', 'github', 'markdown' or 'tsv'.")
+	}
+}
+
+// tableWriter collects tab separated rows, like a tabwriter, and renders
+// them in a table style when flushed. The first row is the header. It is
+// left out when headers are disabled, except for the GitHub and Markdown
+// styles which need a header to form a table.
+type tableWriter struct {
+	w          io.Writer
+	style      tableStyle
+	showHeader bool
+	buf        bytes.Buffer
+}
+
+func newTableWriter(w io.Writer, style tableStyle, showHeader bool) *tableWriter {
+	return &tableWriter{
+		w:          w,
+		style:      style,
+		showHeader: showHeader,
+	}
+}
+
+func (t *tableWriter) Write(p []byte) (int, error) {
+	return t.buf.Write(p)
+}
+
+// Flush renders the collected rows.
+func (t *tableWriter) Flush() error {
+	lines := strings.Split(strings.TrimSuffix(t.buf.String(), "\n"), "\n")
+	t.buf.Reset()
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+
+	if !t.showHeader && t.style != tableStyleGitHub && t.style != tableStyleMarkdown {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	switch t.style {
+	case tableStyleTSV:
+		return t.writeTSV(lines)
+	case tableStyleGitHub, tableStyleMarkdown:
+		return t.writeMarkdown(lines, t.style == tableStyleGitHub)
+	default:
+		tw := tabwriter.NewWriter(t.w, 0, 2, 2, ' ', 0)
+		for _, line := range lines {
+			if _, err := io.WriteString(tw, line+"\n"); err != nil {
+				return err
+			}
+		}
+		return tw.Flush()
+	}
+}
+
+func (t *tableWriter) writeTSV(lines []string) error {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(strings.TrimRight(line, "\t"))
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+// writeMarkdown writes a pipe table. With padding the columns are aligned
+// so the table also reads well as plain text.
+func (t *tableWriter) writeMarkdown(lines []string, padding bool) error {
+	var rows [][]string
+	var widths []int
+	for _, line := range lines {
+		cells := strings.Split(strings.TrimRight(line, "\t"), "\t")
+		for i, c := range cells {
+			cells[i] = strings.Replace(strings.TrimSpace(c), "|", `\|`, -1)
+			for len(widths) <= i {
+				widths = append(widths, 3)
+			}
+			if n := utf8.RuneCountInString(cells[i]); n > widths[i] {
+				widths[i] = n
+			}
+		}
+		rows = append(rows, cells)
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for i := range widths {
+			var c string
+			if i < len(cells) {
+				c = cells[i]
+			}
+
+			b.WriteString(" " + c)
+			if padding {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c)))
+			}
+			b.WriteString(" |")
+		}
+		b.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	b.WriteString("|")
+	for _, width := range widths {
+		if padding {
+			b.WriteString(strings.Repeat("-", width+2) + "|")
+		} else {
+			b.WriteString(" --- |")
+		}
+	}
+	b.WriteString("\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
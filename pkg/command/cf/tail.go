@@ -7,7 +7,10 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path"
+	"regexp"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 	"unicode/utf8"
@@ -16,7 +19,6 @@ import (
 	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
 	logcache "code.cloudfoundry.org/log-cache/client"
 	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
-	flags "github.com/jessevdk/go-flags"
 )
 
 const (
@@ -45,6 +47,49 @@ func WithTailNoHeaders() TailOption {
 	}
 }
 
+// WithTailControls reads key presses from in while following. Space pauses
+// and resumes the output, '/' sets a highlight pattern and 's' prints
+// stats. The setup function is called before reading the first key.
+func WithTailControls(in io.Reader, setup ControlsSetup) TailOption {
+	return func(o *options) {
+		o.controlsIn = in
+		o.controlsSetup = setup
+	}
+}
+
+// WithTailColor enables ANSI colors for output that supports it.
+func WithTailColor() TailOption {
+	return func(o *options) {
+		o.color = true
+	}
+}
+
+// WithTailScrubber masks sensitive data in each envelope before it is
+// formatted.
+func WithTailScrubber(s *Scrubber) TailOption {
+	return func(o *options) {
+		o.scrubber = s
+	}
+}
+
+// WithTailNoRetentionCheck skips checking the start time against the
+// retention of the source. Callers that tail many sources over a window
+// they picked themselves use it to avoid a meta request per source.
+func WithTailNoRetentionCheck() TailOption {
+	return func(o *options) {
+		o.noRetentionCheck = true
+	}
+}
+
+// WithTailReadLimit sets the most envelopes a single read returns instead
+// of asking the Log Cache server for it. Callers that tail many sources
+// and know the limit use it to avoid an info request per source.
+func WithTailReadLimit(limit int) TailOption {
+	return func(o *options) {
+		o.readLimit = limit
+	}
+}
+
 // Tail will fetch the logs for a given application guid and write them to
 // stdout.
 func Tail(
@@ -65,30 +110,51 @@ func Tail(
 		opt(&o)
 	}
 
+	// Colors and reverse video are the only signal for highlighted and
+	// pretty printed content, which screen readers don't announce.
+	if o.accessible {
+		o.color = false
+	}
+
+	// See https://no-color.org.
+	if o.noColor || os.Getenv("NO_COLOR") != "" {
+		o.color = false
+	}
+
 	sourceID := o.guid
-	formatter := newFormatter(o.providedName, o.follow, formatterKindFromOptions(o), log, o.outputTemplate, o.newLineReplacer)
 	lw := lineWriter{w: w}
 
+	// Alerts are raised for lines as they reach the terminal, after the
+	// controls have held them back while paused.
+	var out lineOutput = &lw
+	if o.alertPattern != nil {
+		out = newAlertOutput(out, o, w, log)
+	}
+
+	// Fatal logs exit without running deferred functions, the logger
+	// restores the terminal first.
+	if o.follow && o.controlsIn != nil {
+		if controls, restore, ok := startControls(ctx, o, out, log); ok {
+			defer restore()
+			log = restoringLogger{Logger: log, restore: restore}
+			out = controls
+		}
+	}
+
+	formatter := newFormatter(o.providedName, formatterKindFromOptions(o), log, o)
+
 	defer func() {
 		if value, ok := formatter.flush(); ok {
 			lw.Write(value)
 		}
 	}()
 
-	if strings.ToLower(os.Getenv("LOG_CACHE_SKIP_AUTH")) != "true" {
-		token, err := cli.AccessToken()
-		if err != nil {
-			log.Fatalf("Unable to get Access Token: %s", err)
-		}
-
-		c = &tokenHTTPClient{
-			c:           c,
-			accessToken: token,
-		}
+	logCacheAddr := os.Getenv("LOG_CACHE_ADDR")
+	if o.archive == nil {
+		c = authenticatedClient(cli, c, log)
 	}
 
-	logCacheAddr := os.Getenv("LOG_CACHE_ADDR")
-	if logCacheAddr == "" {
+	if logCacheAddr == "" && o.archive == nil {
 		hasAPI, err := cli.HasAPIEndpoint()
 		if err != nil {
 			log.Fatalf("%s", err)
@@ -128,6 +194,9 @@ func Tail(
 			// not an app or service, use generic header
 			headerPrinter = formatter.sourceHeader
 		}
+		if o.space {
+			headerPrinter = formatter.spaceHeader
+		}
 
 		if !o.noHeaders {
 			header, ok := headerPrinter(o.providedName, org.Name, space.Name, user)
@@ -138,16 +207,16 @@ func Tail(
 		}
 	}
 
-	if o.gaugeName != "" {
-		o.envelopeType = logcache_v1.EnvelopeType_GAUGE
+	if len(o.gaugeNames) > 0 {
+		o.envelopeTypes = []logcache_v1.EnvelopeType{logcache_v1.EnvelopeType_GAUGE}
 	}
 
-	if o.counterName != "" {
-		o.envelopeType = logcache_v1.EnvelopeType_COUNTER
+	if len(o.counterNames) > 0 {
+		o.envelopeTypes = []logcache_v1.EnvelopeType{logcache_v1.EnvelopeType_COUNTER}
 	}
 
 	filterAndFormat := func(e *loggregator_v2.Envelope) (string, bool) {
-		if !nameFilter(e, o) || !typeFilter(e, o) {
+		if !o.filterAndScrub(e) {
 			return "", false
 		}
 
@@ -155,53 +224,204 @@ func Tail(
 	}
 	client := logcache.NewClient(logCacheAddr, logcache.WithHTTPClient(c))
 
+	// Only --lines above the usual read limit are read in pages, the
+	// server is asked for its limit just for those.
+	if o.readLimit == 0 {
+		o.readLimit = defaultReadLimit
+		if o.lines > defaultReadLimit && o.archive == nil {
+			if caps, err := getCapabilities(ctx, c, logCacheAddr); err == nil {
+				o.readLimit = caps.readLimit()
+			}
+		}
+	}
+
+	read := client.Read
+	if o.archive != nil {
+		read = o.archive.read
+	}
+
+	walkRead := client.Read
+	if pattern, ok := o.nameFilterPattern(); ok {
+		read = withReadOptions(read, withNameFilter(pattern))
+		walkRead = withReadOptions(walkRead, withNameFilter(pattern))
+	}
+
+	if o.highlight != nil {
+		out = newHighlightOutput(out, o)
+	}
+
+	if o.space {
+		tailSpace(ctx, cli, client, o, out, log)
+		return
+	}
+
+	if o.sourcePattern {
+		sourceIDs, err := expandSourcePattern(ctx, client, o.providedName)
+		if err != nil {
+			log.Fatalf("Could not expand source pattern %s: %s", o.providedName, err)
+		}
+
+		if len(sourceIDs) == 0 {
+			log.Fatalf("No sources match %s.", o.providedName)
+		}
+
+		var sources []v3Resource
+		for _, id := range sourceIDs {
+			if o.excluded(id, id) {
+				continue
+			}
+			sources = append(sources, v3Resource{GUID: id, Name: id})
+		}
+
+		if len(sources) == 0 {
+			log.Fatalf("Every source matching %s is excluded by --exclude-source.", o.providedName)
+		}
+
+		tailSources(ctx, client, o, sources, out, log)
+		return
+	}
+
+	if len(o.sources) > 0 {
+		tailSources(ctx, client, o, o.sources, out, log)
+		return
+	}
+
 	if sourceID == "" {
 		// fall back to provided name
 		sourceID = o.providedName
 	}
 
+	if o.startTime.UnixNano() > 0 && o.archive == nil && !o.noRetentionCheck {
+		checkRetention(ctx, client, []retentionWindow{{
+			name:     o.providedName,
+			sourceID: sourceID,
+			start:    o.startTime,
+		}}, o.strictWindow, log)
+	}
+
+	var marker *deployMarker
+	if o.markDeploys {
+		marker = newDeployMarker(cli, sourceID, log)
+	}
+
+	// writeMu serializes the envelopes and the markers written while
+	// following.
+	var writeMu sync.Mutex
+	writeEnvelope := func(e *loggregator_v2.Envelope) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+
+		if marker != nil {
+			for _, m := range marker.markersBefore(e.Timestamp) {
+				out.Write(m)
+			}
+		}
+
+		if formatted, ok := filterAndFormat(e); ok {
+			out.Write(formatted)
+		}
+	}
+
+	if o.all {
+		if marker != nil {
+			marker.fetch(o.startTime.Truncate(time.Second).Add(-time.Second))
+		}
+
+		logcache.Walk(
+			ctx,
+			sourceID,
+			logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+				for _, e := range envelopes {
+					writeEnvelope(e)
+				}
+				return ctx.Err() == nil
+			}),
+			read,
+			logcache.WithWalkStartTime(o.startTime),
+			logcache.WithWalkEndTime(o.endTime),
+			logcache.WithWalkEnvelopeTypes(o.envelopeTypes...),
+			logcache.WithWalkBackoff(newBackoff(log)),
+		)
+
+		if marker != nil {
+			for _, m := range marker.markersBefore(o.endTime.UnixNano()) {
+				out.Write(m)
+			}
+		}
+
+		return
+	}
+
 	walkStartTime := time.Now().Add(-5 * time.Second).UnixNano()
 	if o.lines > 0 {
-		envelopes, err := client.Read(
+		envelopes, err := readRecent(
 			context.Background(),
+			read,
 			sourceID,
 			o.startTime,
-			logcache.WithEndTime(o.endTime),
-			logcache.WithEnvelopeTypes(o.envelopeType),
-			logcache.WithLimit(o.lines),
-			logcache.WithDescending(),
+			o.endTime,
+			o.envelopeTypes,
+			o.lines,
+			o.readLimit,
 		)
 
 		if err != nil && !o.follow {
 			log.Fatalf("%s", err)
 		}
 
+		if marker != nil && len(envelopes) > 0 {
+			oldest := time.Unix(0, envelopes[len(envelopes)-1].Timestamp)
+			marker.fetch(oldest.Truncate(time.Second).Add(-time.Second))
+		}
+
 		// we get envelopes in descending order but want to print them ascending
 		for i := len(envelopes) - 1; i >= 0; i-- {
 			walkStartTime = envelopes[i].Timestamp + 1
-			if formatted, ok := filterAndFormat(envelopes[i]); ok {
-				lw.Write(formatted)
+			writeEnvelope(envelopes[i])
+		}
+
+		if marker != nil && !o.follow {
+			for _, m := range marker.markersBefore(o.endTime.UnixNano()) {
+				out.Write(m)
 			}
 		}
 	}
 
 	if o.follow {
+		armAlerts(out)
+
+		var polling sync.WaitGroup
+		if marker != nil {
+			after := time.Unix(0, walkStartTime).Truncate(time.Second).Add(-time.Second)
+			polling.Add(1)
+			go func() {
+				defer polling.Done()
+				marker.poll(ctx, after, func(markers []string) {
+					writeMu.Lock()
+					defer writeMu.Unlock()
+
+					for _, m := range markers {
+						out.Write(m)
+					}
+				})
+			}()
+		}
+
 		logcache.Walk(
 			ctx,
 			sourceID,
 			logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
 				for _, e := range envelopes {
-					if formatted, ok := filterAndFormat(e); ok {
-						lw.Write(formatted)
-					}
+					writeEnvelope(e)
 				}
 				return true
 			}),
-			client.Read,
+			walkRead,
 			logcache.WithWalkStartTime(time.Unix(0, walkStartTime)),
-			logcache.WithWalkEnvelopeTypes(o.envelopeType),
-			logcache.WithWalkBackoff(logcache.NewAlwaysRetryBackoff(250*time.Millisecond)),
+			logcache.WithWalkEnvelopeTypes(o.envelopeTypes...),
+			logcache.WithWalkBackoff(newFollowBackoff(ctx)),
 		)
+		polling.Wait()
 
 		return
 	}
@@ -228,74 +448,320 @@ type envelopeClass int
 type options struct {
 	startTime     time.Time
 	endTime       time.Time
-	envelopeType  logcache_v1.EnvelopeType
+	envelopeTypes []logcache_v1.EnvelopeType
 	envelopeClass envelopeClass
 	lines         int
 	follow        bool
+	all           bool
 
 	guid           string
 	isService      bool
 	providedName   string
 	outputTemplate *template.Template
+	templateData   bool
 	jsonOutput     bool
-
-	gaugeName   string
-	counterName string
-
-	noHeaders       bool
-	newLineReplacer rune
+	ndjson         bool
+	output         formatterKind
+
+	gaugeNames   []string
+	counterNames []string
+	instances    []string
+	tags         []tagMatch
+	filter       *regexp.Regexp
+	invertMatch  bool
+
+	noHeaders        bool
+	newLineReplacer  rune
+	scrubber         *Scrubber
+	markDeploys      bool
+	space            bool
+	sourcePattern    bool
+	sources          []v3Resource
+	excludeSource    *regexp.Regexp
+	tableFields      []string
+	tableStyle       tableStyle
+	prettyJSON       bool
+	color            bool
+	rawUnits         bool
+	showTags         bool
+	strictWindow     bool
+	noRetentionCheck bool
+	accessible       bool
+	noColor          bool
+
+	controlsIn    io.Reader
+	controlsSetup ControlsSetup
+
+	// readLimit is the most envelopes a single read returns, as reported
+	// by the Log Cache server unless set with WithTailReadLimit.
+	readLimit int
+
+	highlight    *regexp.Regexp
+	alertPattern *regexp.Regexp
+	alert        string
+	notifier     Notifier
+
+	archive *exportArchive
 }
 
 type optionFlags struct {
-	StartTime     int64  `long:"start-time"`
-	EndTime       int64  `long:"end-time"`
-	EnvelopeType  string `long:"envelope-type"`
-	Lines         uint   `long:"lines" short:"n" default:"10"`
-	Follow        bool   `long:"follow" short:"f"`
-	OutputFormat  string `long:"output-format" short:"o"`
-	JSONOutput    bool   `long:"json"`
-	GaugeName     string `long:"gauge-name"`
-	CounterName   string `long:"counter-name"`
-	EnvelopeClass string `long:"type"`
-	NewLine       string `long:"new-line" optional:"true" optional-value:"\\u2028"`
+	StartTime     string        `long:"start-time"`
+	EndTime       string        `long:"end-time"`
+	Since         time.Duration `long:"since"`
+	Until         time.Duration `long:"until"`
+	EnvelopeType  []string      `long:"envelope-type"`
+	Lines         uint          `long:"lines" short:"n" default:"10"`
+	Follow        bool          `long:"follow" short:"f"`
+	All           bool          `long:"all"`
+	OutputFormat  string        `long:"output-format" short:"o"`
+	Template      string        `long:"output-template"`
+	JSONOutput    bool          `long:"json"`
+	NDJSON        bool          `long:"ndjson"`
+	Output        string        `long:"output"`
+	GaugeName     []string      `long:"gauge-name"`
+	CounterName   []string      `long:"counter-name"`
+	Instance      []string      `long:"instance"`
+	Tag           []string      `long:"tag"`
+	Filter        string        `long:"filter"`
+	InvertMatch   bool          `long:"invert-match"`
+	EnvelopeClass string        `long:"type"`
+	NewLine       string        `long:"new-line" optional:"true" optional-value:"\\u2028"`
+	MarkDeploys   bool          `long:"mark-deploys"`
+	Space         bool          `long:"space"`
+	TableFields   string        `long:"table-fields"`
+	TableStyle    string        `long:"table-style"`
+	PrettyJSON    bool          `long:"pretty-json"`
+	ExcludeSource string        `long:"exclude-source"`
+	Highlight     string        `long:"highlight"`
+	AlertOn       string        `long:"alert-on"`
+	Alert         string        `long:"alert" default:"bell"`
+	FromArchive   string        `long:"from-archive"`
+	RawUnits      bool          `long:"raw-units"`
+	ShowTags      bool          `long:"show-tags"`
+	StrictWindow  bool          `long:"strict-window"`
+	Accessible    bool          `long:"accessible"`
+	NoColor       bool          `long:"no-color"`
 }
 
 func newOptions(cli plugin.CliConnection, args []string, log Logger) (options, error) {
-	opts := optionFlags{
-		EndTime: time.Now().UnixNano(),
+	var opts optionFlags
+	args, err := parseFlags("tail", &opts, args)
+	if err != nil {
+		return options{}, err
 	}
 
-	args, err := flags.ParseArgs(&opts, args)
+	if opts.Space {
+		if len(args) != 0 {
+			return options{}, errors.New("--space cannot be used with an app or source argument")
+		}
+	} else if len(args) == 0 {
+		return options{}, fmt.Errorf("Expected at least 1 argument, got %d.", len(args))
+	}
+
+	// --output-template renders the same way as --output-format, only with
+	// friendlier data, so it shares its restrictions.
+	templateData := opts.Template != ""
+	if templateData {
+		if opts.OutputFormat != "" {
+			return options{}, errors.New("--output-template cannot be used with --output-format")
+		}
+		opts.OutputFormat = opts.Template
+	}
+
+	if opts.Space && (opts.JSONOutput || opts.OutputFormat != "" || opts.MarkDeploys) {
+		return options{}, errors.New("--space cannot be used with --json, --output-format or --mark-deploys")
+	}
+
+	multipleSources := !opts.Space && len(args) > 1
+	if multipleSources && (opts.JSONOutput || opts.NDJSON || opts.OutputFormat != "" || opts.TableFields != "" || opts.MarkDeploys || opts.All || opts.FromArchive != "") {
+		return options{}, errors.New("Several sources cannot be used with --json, --ndjson, --output-format, --table-fields, --mark-deploys, --all or --from-archive")
+	}
+
+	if multipleSources {
+		for _, a := range args {
+			if isSourcePattern(a) {
+				return options{}, errors.New("A source pattern cannot be combined with other sources")
+			}
+		}
+	}
+
+	sourcePattern := !opts.Space && !multipleSources && isSourcePattern(args[0])
+	if sourcePattern && (opts.JSONOutput || opts.OutputFormat != "" || opts.TableFields != "" || opts.MarkDeploys) {
+		return options{}, errors.New("A source pattern cannot be used with --json, --output-format, --table-fields or --mark-deploys")
+	}
+
+	if opts.ExcludeSource != "" && !opts.Space && !sourcePattern {
+		return options{}, errors.New("--exclude-source can only be used with --space or a source pattern")
+	}
+
+	var excludeSource *regexp.Regexp
+	if opts.ExcludeSource != "" {
+		excludeSource, err = regexp.Compile(opts.ExcludeSource)
+		if err != nil {
+			return options{}, fmt.Errorf("Invalid --exclude-source pattern: %s", err)
+		}
+	}
+
+	if opts.TableFields != "" && (opts.Follow || opts.Space || opts.MarkDeploys || opts.JSONOutput || opts.OutputFormat != "") {
+		return options{}, errors.New("--table-fields cannot be used with --follow, --space, --mark-deploys, --json or --output-format")
+	}
+
+	if opts.TableStyle != "" && opts.TableFields == "" {
+		return options{}, errors.New("--table-style can only be used with --table-fields")
+	}
+
+	tableStyle, err := parseTableStyle(opts.TableStyle)
 	if err != nil {
 		return options{}, err
 	}
 
-	if len(args) != 1 {
-		return options{}, fmt.Errorf("Expected 1 argument, got %d.", len(args))
+	if opts.PrettyJSON && (opts.TableFields != "" || opts.JSONOutput || opts.OutputFormat != "") {
+		return options{}, errors.New("--pretty-json cannot be used with --table-fields, --json or --output-format")
 	}
 
 	if opts.JSONOutput && opts.OutputFormat != "" {
 		return options{}, errors.New("Cannot use output-format and json flags together")
 	}
 
-	if opts.EnvelopeType != "" && opts.CounterName != "" {
+	if opts.NDJSON && (opts.JSONOutput || opts.OutputFormat != "" || opts.TableFields != "" || opts.PrettyJSON || opts.MarkDeploys) {
+		return options{}, errors.New("--ndjson cannot be used with --json, --output-format, --table-fields, --pretty-json or --mark-deploys")
+	}
+
+	if opts.NDJSON && (opts.Space || sourcePattern) {
+		return options{}, errors.New("--ndjson cannot be used with --space or a source pattern")
+	}
+
+	if opts.Output != "" && (opts.JSONOutput || opts.NDJSON || opts.OutputFormat != "" || opts.TableFields != "" || opts.PrettyJSON || opts.MarkDeploys) {
+		return options{}, errors.New("--output cannot be used with --json, --ndjson, --output-format, --table-fields, --pretty-json or --mark-deploys")
+	}
+
+	if opts.Output != "" && (opts.Space || sourcePattern || multipleSources) {
+		return options{}, errors.New("--output cannot be used with --space, a source pattern or several sources")
+	}
+
+	output, err := parseOutput(opts.Output)
+	if err != nil {
+		return options{}, err
+	}
+
+	if opts.ShowTags && (opts.JSONOutput || opts.NDJSON || opts.OutputFormat != "" || opts.Output != "" || opts.TableFields != "") {
+		return options{}, errors.New("--show-tags cannot be used with --json, --ndjson, --output-format, --output or --table-fields")
+	}
+
+	if len(opts.EnvelopeType) > 0 && len(opts.CounterName) > 0 {
 		return options{}, errors.New("--counter-name cannot be used with --envelope-type")
 	}
 
-	if opts.EnvelopeType != "" && opts.GaugeName != "" {
+	if len(opts.EnvelopeType) > 0 && len(opts.GaugeName) > 0 {
 		return options{}, errors.New("--gauge-name cannot be used with --envelope-type")
 	}
 
-	if opts.GaugeName != "" && opts.CounterName != "" {
+	if len(opts.GaugeName) > 0 && len(opts.CounterName) > 0 {
 		return options{}, errors.New("--counter-name cannot be used with --gauge-name")
 	}
 
-	if opts.EnvelopeType != "" && opts.EnvelopeClass != "" {
+	if len(opts.EnvelopeType) > 0 && opts.EnvelopeClass != "" {
 		return options{}, errors.New("--envelope-type cannot be used with --type")
 	}
 
+	if opts.MarkDeploys && (opts.JSONOutput || opts.OutputFormat != "") {
+		return options{}, errors.New("--mark-deploys cannot be used with --json or --output-format")
+	}
+
+	if opts.FromArchive != "" && (opts.Follow || opts.Space || sourcePattern || opts.MarkDeploys) {
+		return options{}, errors.New("--from-archive cannot be used with --follow, --space, --mark-deploys or a source pattern")
+	}
+
+	if opts.Since != 0 && opts.StartTime != "" {
+		return options{}, errors.New("--since cannot be used with --start-time")
+	}
+
+	if opts.Until != 0 && opts.EndTime != "" {
+		return options{}, errors.New("--until cannot be used with --end-time")
+	}
+
+	if opts.Since < 0 || opts.Until < 0 {
+		return options{}, errors.New("--since and --until must be positive durations")
+	}
+
+	now := time.Now()
+	startTime := time.Unix(0, 0)
+	if opts.Since != 0 {
+		startTime = now.Add(-opts.Since)
+	}
+	if opts.StartTime != "" {
+		startTime, err = parseTimestamp(opts.StartTime)
+		if err != nil {
+			return options{}, fmt.Errorf("Invalid --start-time %q, expected RFC3339 or UNIX nanoseconds.", opts.StartTime)
+		}
+	}
+
+	endTime := now.Add(-opts.Until)
+	if opts.EndTime != "" {
+		endTime, err = parseTimestamp(opts.EndTime)
+		if err != nil {
+			return options{}, fmt.Errorf("Invalid --end-time %q, expected RFC3339 or UNIX nanoseconds.", opts.EndTime)
+		}
+	}
+
+	if opts.All && (opts.Follow || opts.Space || sourcePattern) {
+		return options{}, errors.New("--all cannot be used with --follow, --space or a source pattern")
+	}
+
+	if opts.StrictWindow && startTime.UnixNano() == 0 {
+		return options{}, errors.New("--strict-window can only be used with --start-time or --since")
+	}
+
+	if opts.AlertOn != "" && !opts.Follow {
+		return options{}, errors.New("--alert-on can only be used with --follow")
+	}
+
+	if opts.Alert != alertBell && opts.Alert != alertNotify {
+		return options{}, errors.New("--alert must be 'bell' or 'notify'")
+	}
+
+	var highlightPattern *regexp.Regexp
+	if opts.Highlight != "" {
+		highlightPattern, err = regexp.Compile(opts.Highlight)
+		if err != nil {
+			return options{}, fmt.Errorf("Invalid --highlight pattern: %s", err)
+		}
+	}
+
+	var alertPattern *regexp.Regexp
+	if opts.AlertOn != "" {
+		alertPattern, err = regexp.Compile(opts.AlertOn)
+		if err != nil {
+			return options{}, fmt.Errorf("Invalid --alert-on pattern: %s", err)
+		}
+	}
+
 	if opts.EnvelopeClass != "" {
-		opts.EnvelopeType = "ANY"
+		opts.EnvelopeType = []string{"ANY"}
+	}
+
+	if opts.InvertMatch && opts.Filter == "" {
+		return options{}, errors.New("--invert-match can only be used with --filter")
+	}
+
+	var filter *regexp.Regexp
+	if opts.Filter != "" {
+		filter, err = regexp.Compile(opts.Filter)
+		if err != nil {
+			return options{}, fmt.Errorf("Invalid --filter pattern: %s", err)
+		}
+	}
+
+	tags, err := parseTagMatches(opts.Tag)
+	if err != nil {
+		return options{}, err
+	}
+
+	gaugeNames := parseNames(opts.GaugeName)
+	for _, pattern := range gaugeNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return options{}, fmt.Errorf("Invalid --gauge-name pattern %s: %s", pattern, err)
+		}
 	}
 
 	var outputTemplate *template.Template
@@ -306,21 +772,73 @@ func newOptions(cli plugin.CliConnection, args []string, log Logger) (options, e
 		}
 	}
 
-	id, isService := getGUID(args[0], cli, log)
+	var (
+		id, providedName string
+		isService        bool
+		archive          *exportArchive
+		sources          []v3Resource
+	)
+	if !opts.Space {
+		providedName = strings.Join(args, ", ")
+	}
+	if opts.FromArchive != "" {
+		archive, err = openExportArchive(opts.FromArchive)
+		if err != nil {
+			return options{}, fmt.Errorf("Could not read archive: %s", err)
+		}
+
+		var ok bool
+		if id, ok = archive.sourceID(providedName); !ok {
+			return options{}, fmt.Errorf("%s is not in the archive %s", providedName, opts.FromArchive)
+		}
+	} else if multipleSources {
+		sources = resolveSources(args, cli, log)
+	} else if !opts.Space && !sourcePattern {
+		id, isService = getGUID(providedName, cli, log)
+	}
+	if opts.MarkDeploys && (id == "" || isService) {
+		return options{}, errors.New("--mark-deploys can only be used with an app")
+	}
+
 	o := options{
-		startTime:      time.Unix(0, opts.StartTime),
-		endTime:        time.Unix(0, opts.EndTime),
-		envelopeType:   translateEnvelopeType(opts.EnvelopeType, log),
+		startTime:      startTime,
+		endTime:        endTime,
+		envelopeTypes:  translateEnvelopeTypes(opts.EnvelopeType, log),
 		lines:          int(opts.Lines),
 		guid:           id,
 		isService:      isService,
-		providedName:   args[0],
+		providedName:   providedName,
 		follow:         opts.Follow,
+		all:            opts.All,
 		outputTemplate: outputTemplate,
+		templateData:   templateData,
 		jsonOutput:     opts.JSONOutput,
-		gaugeName:      opts.GaugeName,
-		counterName:    opts.CounterName,
+		ndjson:         opts.NDJSON,
+		output:         output,
+		gaugeNames:     gaugeNames,
+		counterNames:   parseNames(opts.CounterName),
+		instances:      parseNames(opts.Instance),
+		tags:           tags,
+		filter:         filter,
+		invertMatch:    opts.InvertMatch,
 		envelopeClass:  toEnvelopeClass(opts.EnvelopeClass),
+		markDeploys:    opts.MarkDeploys,
+		space:          opts.Space,
+		sourcePattern:  sourcePattern,
+		sources:        sources,
+		excludeSource:  excludeSource,
+		tableFields:    parseTableFields(opts.TableFields),
+		tableStyle:     tableStyle,
+		prettyJSON:     opts.PrettyJSON,
+		rawUnits:       opts.RawUnits,
+		showTags:       opts.ShowTags,
+		strictWindow:   opts.StrictWindow,
+		accessible:     opts.Accessible,
+		noColor:        opts.NoColor,
+		highlight:      highlightPattern,
+		alertPattern:   alertPattern,
+		alert:          opts.Alert,
+		archive:        archive,
 	}
 
 	if opts.NewLine != "" {
@@ -333,6 +851,17 @@ func newOptions(cli plugin.CliConnection, args []string, log Logger) (options, e
 	return o, o.validate()
 }
 
+func parseTableFields(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
 func toEnvelopeClass(class string) envelopeClass {
 	switch strings.ToUpper(class) {
 	case "METRICS":
@@ -351,17 +880,41 @@ func formatterKindFromOptions(o options) formatterKind {
 		return jsonFormat
 	}
 
+	if o.ndjson {
+		return ndjsonFormat
+	}
+
+	if o.output != prettyFormat {
+		return o.output
+	}
+
 	if o.outputTemplate != nil {
 		return templateFormat
 	}
 
+	if len(o.tableFields) > 0 {
+		return tableFormat
+	}
+
 	return prettyFormat
 }
 
 func nameFilter(e *loggregator_v2.Envelope, o options) bool {
-	if o.gaugeName != "" {
+	if len(o.gaugeNames) > 0 {
 		for name := range e.GetGauge().GetMetrics() {
-			if name == o.gaugeName {
+			for _, pattern := range o.gaugeNames {
+				if ok, _ := path.Match(pattern, name); ok {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	if len(o.counterNames) > 0 {
+		for _, name := range o.counterNames {
+			if e.GetCounter().GetName() == name {
 				return true
 			}
 		}
@@ -369,8 +922,78 @@ func nameFilter(e *loggregator_v2.Envelope, o options) bool {
 		return false
 	}
 
-	if o.counterName != "" {
-		return e.GetCounter().GetName() == o.counterName
+	return true
+}
+
+// filterAndScrub reports whether the envelope passes the name and type
+// filters and masks it when it does.
+func (o options) filterAndScrub(e *loggregator_v2.Envelope) bool {
+	if !nameFilter(e, o) || !typeFilter(e, o) || !instanceFilter(e, o) || !tagFilter(e, o) {
+		return false
+	}
+	o.scrubber.scrubEnvelope(e)
+
+	return payloadFilter(e, o)
+}
+
+// payloadFilter reports whether the payload of a log envelope matches
+// --filter, or doesn't with --invert-match. Other envelopes always pass.
+func payloadFilter(e *loggregator_v2.Envelope, o options) bool {
+	if o.filter == nil || e.GetLog() == nil {
+		return true
+	}
+
+	return o.filter.Match(e.GetLog().GetPayload()) != o.invertMatch
+}
+
+// instanceFilter reports whether the envelope comes from one of the
+// instances given with --instance.
+func instanceFilter(e *loggregator_v2.Envelope, o options) bool {
+	if len(o.instances) == 0 {
+		return true
+	}
+
+	for _, id := range o.instances {
+		if e.GetInstanceId() == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tagMatch is a key=value pair given with --tag.
+type tagMatch struct {
+	key   string
+	value string
+}
+
+func parseTagMatches(values []string) ([]tagMatch, error) {
+	var matches []tagMatch
+	for _, v := range values {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("Invalid --tag %q, expected key=value.", v)
+		}
+
+		matches = append(matches, tagMatch{key: kv[0], value: kv[1]})
+	}
+
+	return matches, nil
+}
+
+// tagFilter reports whether the envelope has every tag given with --tag.
+// Deprecated text tags are matched as well.
+func tagFilter(e *loggregator_v2.Envelope, o options) bool {
+	for _, m := range o.tags {
+		value, ok := e.GetTags()[m.key]
+		if !ok {
+			value = e.GetDeprecatedTags()[m.key].GetText()
+		}
+
+		if value != m.value {
+			return false
+		}
 	}
 
 	return true
@@ -396,10 +1019,6 @@ func (o options) validate() error {
 		return errors.New("Invalid date/time range. Ensure your start time is prior or equal the end time.")
 	}
 
-	if o.lines > 1000 || o.lines < 0 {
-		return errors.New("Lines cannot be greater than 1000.")
-	}
-
 	return nil
 }
 
@@ -412,6 +1031,37 @@ func parseOutputFormat(f string) (*template.Template, error) {
 	return templ, nil
 }
 
+// translateEnvelopeTypes translates the values of every --envelope-type,
+// each of which may be a comma separated list. ANY, or no value at all,
+// reads every type.
+func translateEnvelopeTypes(values []string, log Logger) []logcache_v1.EnvelopeType {
+	var types []logcache_v1.EnvelopeType
+	seen := make(map[logcache_v1.EnvelopeType]bool)
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t == "" {
+				continue
+			}
+
+			et := translateEnvelopeType(t, log)
+			if et == logcache_v1.EnvelopeType_ANY {
+				return []logcache_v1.EnvelopeType{logcache_v1.EnvelopeType_ANY}
+			}
+
+			if !seen[et] {
+				seen[et] = true
+				types = append(types, et)
+			}
+		}
+	}
+
+	if len(types) == 0 {
+		return []logcache_v1.EnvelopeType{logcache_v1.EnvelopeType_ANY}
+	}
+
+	return types
+}
+
 func translateEnvelopeType(t string, log Logger) logcache_v1.EnvelopeType {
 	t = strings.ToUpper(t)
 
@@ -463,20 +1113,19 @@ func getAppGUID(appName string, cli plugin.CliConnection, log Logger) string {
 }
 
 func getServiceGUID(serviceName string, cli plugin.CliConnection, log Logger) string {
-	r, err := cli.CliCommandWithoutTerminalOutput(
-		"service",
-		serviceName,
-		"--guid",
-	)
+	space, err := cli.GetCurrentSpace()
+	if err != nil {
+		log.Printf("%s", err)
+		return ""
+	}
 
+	guid, err := getServiceInstanceGUID(serviceName, space.Guid, cli)
 	if err != nil {
-		if err.Error() != "Service instance "+serviceName+" not found" {
-			log.Printf("%s", err)
-		}
+		log.Printf("%s", err)
 		return ""
 	}
 
-	return strings.Join(r, "")
+	return guid
 }
 
 func parseNewLineArgument(s string) (rune, error) {
@@ -518,6 +1167,25 @@ func (b backoff) OnErr(err error) bool {
 	return b.AlwaysDoneBackoff.OnErr(err)
 }
 
+// authenticatedClient wraps the given client so that requests carry the CF
+// access token unless LOG_CACHE_SKIP_AUTH is set. The token is cached
+// between invocations until it expires.
+func authenticatedClient(cli plugin.CliConnection, c HTTPClient, log Logger) HTTPClient {
+	if strings.ToLower(os.Getenv("LOG_CACHE_SKIP_AUTH")) == "true" {
+		return c
+	}
+
+	token, err := accessToken(cli)
+	if err != nil {
+		log.Fatalf("Unable to get Access Token: %s", err)
+	}
+
+	return &tokenHTTPClient{
+		c:           c,
+		accessToken: token,
+	}
+}
+
 type tokenHTTPClient struct {
 	c           HTTPClient
 	accessToken string
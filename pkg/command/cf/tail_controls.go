@@ -0,0 +1,239 @@
+package cf
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	keyPause     = ' '
+	keyHighlight = '/'
+	keyStats     = 's'
+	keyEnter     = '\r'
+	keyNewLine   = '\n'
+	keyEscape    = 0x1b
+	keyBackspace = 0x7f
+	keyDelete    = 0x08
+
+	// maxBufferedLines is the number of lines kept while the output is
+	// paused. Older lines are dropped.
+	maxBufferedLines = 10000
+
+	highlightStart = "\x1b[7m"
+	highlightEnd   = "\x1b[27m"
+	highlightMark  = "** "
+)
+
+// ControlsSetup prepares the terminal for reading single key presses and
+// returns a function that restores it.
+type ControlsSetup func() (restore func(), err error)
+
+type lineOutput interface {
+	Write(line string) error
+}
+
+// followControls lets the user pause the output, highlight lines matching
+// a pattern and print stats while following.
+type followControls struct {
+	w     lineOutput
+	color bool
+	start time.Time
+
+	mu        sync.Mutex
+	paused    bool
+	prompting bool
+	pattern   []byte
+	buffered  []string
+	dropped   int
+	highlight *regexp.Regexp
+	written   int
+	matched   int
+}
+
+// startControls prepares the terminal and starts listening for key
+// presses. It reports false when the terminal does not support it.
+func startControls(ctx context.Context, o options, w lineOutput, log Logger) (*followControls, func(), bool) {
+	restore := func() {}
+	if o.controlsSetup != nil {
+		var err error
+		restore, err = o.controlsSetup()
+		if err != nil {
+			log.Printf("Interactive controls are unavailable: %s", err)
+			return nil, nil, false
+		}
+	}
+
+	c := newFollowControls(w, o.color)
+	go c.listen(ctx, o.controlsIn)
+
+	return c, restore, true
+}
+
+// restoringLogger restores the terminal before a fatal log exits, which
+// skips deferred functions.
+type restoringLogger struct {
+	Logger
+	restore func()
+}
+
+func (l restoringLogger) Fatalf(format string, args ...interface{}) {
+	l.restore()
+	l.Logger.Fatalf(format, args...)
+}
+
+func newFollowControls(w lineOutput, color bool) *followControls {
+	return &followControls{
+		w:     w,
+		color: color,
+		start: time.Now(),
+	}
+}
+
+// Write writes the line unless the output is paused, in which case the
+// line is buffered until the output is resumed.
+func (c *followControls) Write(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused || c.prompting {
+		if len(c.buffered) == maxBufferedLines {
+			c.buffered = c.buffered[1:]
+			c.dropped++
+		}
+		c.buffered = append(c.buffered, line)
+		return nil
+	}
+
+	return c.writeLine(line)
+}
+
+func (c *followControls) writeLine(line string) error {
+	c.written++
+
+	if c.highlight == nil || !c.highlight.MatchString(line) {
+		return c.w.Write(line)
+	}
+
+	c.matched++
+
+	return c.w.Write(highlightMatches(line, c.highlight, c.color))
+}
+
+// listen handles key presses read from r until the context is done or r
+// is exhausted.
+func (c *followControls) listen(ctx context.Context, r io.Reader) {
+	br := bufio.NewReader(r)
+	for ctx.Err() == nil {
+		b, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+
+		c.handleKey(b)
+	}
+}
+
+func (c *followControls) handleKey(b byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.prompting {
+		c.handlePromptKey(b)
+		return
+	}
+
+	switch b {
+	case keyPause:
+		if c.paused {
+			c.paused = false
+			c.w.Write("--- resumed ---")
+			c.flushBuffered()
+			return
+		}
+
+		c.paused = true
+		c.w.Write("--- paused, press space to resume ---")
+	case keyHighlight:
+		c.prompting = true
+		c.pattern = c.pattern[:0]
+		c.w.Write("--- type a highlight pattern and press enter, escape to cancel ---")
+	case keyStats:
+		c.w.Write(c.stats())
+	}
+}
+
+func (c *followControls) handlePromptKey(b byte) {
+	switch b {
+	case keyEnter, keyNewLine:
+		c.prompting = false
+		c.setHighlight(string(c.pattern))
+		if !c.paused {
+			c.flushBuffered()
+		}
+	case keyEscape:
+		c.prompting = false
+		c.w.Write("--- highlight unchanged ---")
+		if !c.paused {
+			c.flushBuffered()
+		}
+	case keyBackspace, keyDelete:
+		if len(c.pattern) > 0 {
+			c.pattern = c.pattern[:len(c.pattern)-1]
+		}
+	default:
+		c.pattern = append(c.pattern, b)
+	}
+}
+
+func (c *followControls) setHighlight(pattern string) {
+	if pattern == "" {
+		c.highlight = nil
+		c.w.Write("--- highlight cleared ---")
+		return
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		c.w.Write(fmt.Sprintf("--- invalid highlight %q: %s ---", pattern, err))
+		return
+	}
+
+	c.highlight = re
+	c.matched = 0
+	c.w.Write(fmt.Sprintf("--- highlighting %q ---", pattern))
+}
+
+func (c *followControls) flushBuffered() {
+	if c.dropped > 0 {
+		c.w.Write(fmt.Sprintf("--- %d older lines were dropped while paused ---", c.dropped))
+		c.dropped = 0
+	}
+
+	for _, line := range c.buffered {
+		c.writeLine(line)
+	}
+	c.buffered = nil
+}
+
+func (c *followControls) stats() string {
+	elapsed := time.Since(c.start)
+
+	highlight := "none"
+	if c.highlight != nil {
+		highlight = fmt.Sprintf("%q (%d matches)", c.highlight.String(), c.matched)
+	}
+
+	return fmt.Sprintf(
+		"--- %d lines in %s (%.1f/s), %d buffered, highlight: %s ---",
+		c.written,
+		elapsed.Truncate(time.Second),
+		float64(c.written)/elapsed.Seconds(),
+		len(c.buffered),
+		highlight,
+	)
+}
@@ -0,0 +1,39 @@
+package cf
+
+import "regexp"
+
+// highlightOutput highlights the matches of --highlight in every line
+// written. Lines without a match are written as they are.
+type highlightOutput struct {
+	lineOutput
+	pattern *regexp.Regexp
+	color   bool
+}
+
+func newHighlightOutput(out lineOutput, o options) *highlightOutput {
+	return &highlightOutput{
+		lineOutput: out,
+		pattern:    o.highlight,
+		color:      o.color,
+	}
+}
+
+func (h *highlightOutput) Write(line string) error {
+	if !h.pattern.MatchString(line) {
+		return h.lineOutput.Write(line)
+	}
+
+	return h.lineOutput.Write(highlightMatches(line, h.pattern, h.color))
+}
+
+// highlightMatches shows the matches of the pattern in reverse video.
+// Without colors the whole line is marked instead.
+func highlightMatches(line string, pattern *regexp.Regexp, color bool) string {
+	if !color {
+		return highlightMark + line
+	}
+
+	return pattern.ReplaceAllStringFunc(line, func(m string) string {
+		return highlightStart + m + highlightEnd
+	})
+}
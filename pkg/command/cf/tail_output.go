@@ -0,0 +1,251 @@
+package cf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+// parseOutput returns the formatter for an --output mode.
+func parseOutput(s string) (formatterKind, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return prettyFormat, nil
+	case "logfmt":
+		return logfmtFormat, nil
+	case "syslog":
+		return syslogFormat, nil
+	default:
+		return 0, errors.New("--output must be 'logfmt' or 'syslog'.")
+	}
+}
+
+// logfmtFormatter writes every envelope as a line of key=value pairs.
+type logfmtFormatter struct {
+	baseFormatter
+}
+
+func (f logfmtFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, bool) {
+	var l logfmtLine
+	l.add("ts", time.Unix(0, e.GetTimestamp()).UTC().Format(time.RFC3339Nano))
+	l.add("source", e.GetSourceId())
+	l.add("instance", e.GetInstanceId())
+
+	switch e.Message.(type) {
+	case *loggregator_v2.Envelope_Log:
+		l.add("type", "log")
+		if st, ok := e.GetTags()["source_type"]; ok {
+			l.add("source_type", st)
+		}
+		l.add("stream", strings.ToLower(e.GetLog().GetType().String()))
+		l.add("msg", string(e.GetLog().GetPayload()))
+	case *loggregator_v2.Envelope_Counter:
+		c := e.GetCounter()
+		l.add("type", "counter")
+		l.add("name", c.GetName())
+		l.add("delta", strconv.FormatUint(c.GetDelta(), 10))
+		l.add("total", strconv.FormatUint(c.GetTotal(), 10))
+	case *loggregator_v2.Envelope_Gauge:
+		l.add("type", "gauge")
+		metrics := e.GetGauge().GetMetrics()
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			l.add(name, strconv.FormatFloat(metrics[name].GetValue(), 'f', -1, 64))
+			if unit := metrics[name].GetUnit(); unit != "" {
+				l.add(name+"_unit", unit)
+			}
+		}
+	case *loggregator_v2.Envelope_Timer:
+		t := e.GetTimer()
+		l.add("type", "timer")
+		l.add("name", t.GetName())
+		l.add("duration", time.Duration(t.GetStop()-t.GetStart()).String())
+	case *loggregator_v2.Envelope_Event:
+		l.add("type", "event")
+		l.add("title", e.GetEvent().GetTitle())
+		l.add("body", e.GetEvent().GetBody())
+	}
+
+	return l.String(), true
+}
+
+// logfmtLine builds a logfmt line. Values with spaces, quotes, equal signs
+// or control characters are quoted.
+type logfmtLine struct {
+	b strings.Builder
+}
+
+func (l *logfmtLine) add(key, value string) {
+	if l.b.Len() > 0 {
+		l.b.WriteByte(' ')
+	}
+	l.b.WriteString(key)
+	l.b.WriteByte('=')
+
+	if value == "" || strings.IndexFunc(value, needsLogfmtQuote) >= 0 {
+		value = strconv.Quote(value)
+	}
+	l.b.WriteString(value)
+}
+
+func (l *logfmtLine) String() string {
+	return l.b.String()
+}
+
+func needsLogfmtQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+}
+
+const (
+	// syslogEnterpriseID is the private enterprise number Loggregator uses
+	// for the structured data of its syslog drains.
+	syslogEnterpriseID = 47450
+
+	syslogFacilityUser  = 1
+	syslogSeverityError = 3
+	syslogSeverityInfo  = 6
+)
+
+// syslogFormatter writes every envelope as an RFC5424 syslog line, laid
+// out like the lines of Loggregator syslog drains: the source ID is the
+// app name, the source type and instance are the process ID and the
+// envelope type is the message ID. Tags and the instance are carried in
+// structured data, as are the values of metrics.
+type syslogFormatter struct {
+	baseFormatter
+}
+
+func (f syslogFormatter) formatEnvelope(e *loggregator_v2.Envelope) (string, bool) {
+	typ, _ := ndjsonPayload(e)
+
+	severity := syslogSeverityInfo
+	if e.GetLog().GetType() == loggregator_v2.Log_ERR {
+		severity = syslogSeverityError
+	}
+
+	tags := map[string]string{"instance_id": e.GetInstanceId()}
+	for k, v := range e.GetTags() {
+		tags[k] = v
+	}
+	sd := []string{syslogElement("tags", tags)}
+
+	var msg string
+	switch e.Message.(type) {
+	case *loggregator_v2.Envelope_Log:
+		msg = strings.TrimRight(string(e.GetLog().GetPayload()), "\r\n")
+	case *loggregator_v2.Envelope_Counter:
+		c := e.GetCounter()
+		sd = append(sd, syslogElement("counter", map[string]string{
+			"name":  c.GetName(),
+			"delta": strconv.FormatUint(c.GetDelta(), 10),
+			"total": strconv.FormatUint(c.GetTotal(), 10),
+		}))
+	case *loggregator_v2.Envelope_Gauge:
+		metrics := e.GetGauge().GetMetrics()
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			sd = append(sd, syslogElement("gauge", map[string]string{
+				"name":  name,
+				"value": strconv.FormatFloat(metrics[name].GetValue(), 'f', -1, 64),
+				"unit":  metrics[name].GetUnit(),
+			}))
+		}
+	case *loggregator_v2.Envelope_Timer:
+		t := e.GetTimer()
+		sd = append(sd, syslogElement("timer", map[string]string{
+			"name":  t.GetName(),
+			"start": strconv.FormatInt(t.GetStart(), 10),
+			"stop":  strconv.FormatInt(t.GetStop(), 10),
+		}))
+	case *loggregator_v2.Envelope_Event:
+		msg = e.GetEvent().GetTitle() + ": " + e.GetEvent().GetBody()
+	}
+
+	procID := e.GetInstanceId()
+	if st, ok := e.GetTags()["source_type"]; ok {
+		procID = "[" + st + "/" + procID + "]"
+	}
+
+	line := fmt.Sprintf(
+		"<%d>1 %s - %s %s %s %s",
+		syslogFacilityUser*8+severity,
+		time.Unix(0, e.GetTimestamp()).UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(e.GetSourceId(), 48),
+		syslogHeaderField(procID, 128),
+		syslogHeaderField(typ, 32),
+		strings.Join(sd, ""),
+	)
+	if msg != "" {
+		line += " " + msg
+	}
+
+	return line, true
+}
+
+// syslogHeaderField returns a header field with only printable ASCII, at
+// most max characters long, or the nil value for an empty field.
+func syslogHeaderField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+
+	return s
+}
+
+// syslogElement returns a structured data element with the params sorted
+// by name.
+func syslogElement(id string, params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s@%d", id, syslogEnterpriseID)
+	for _, name := range names {
+		fmt.Fprintf(&b, " %s=\"%s\"", syslogParamName(name), syslogParamEscaper.Replace(params[name]))
+	}
+	b.WriteByte(']')
+
+	return b.String()
+}
+
+// syslogParamName returns a param name without the characters RFC5424
+// doesn't allow, at most 32 characters long.
+func syslogParamName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+
+	return syslogHeaderField(s, 32)
+}
+
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
@@ -0,0 +1,105 @@
+package cf
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+	logcache_v1 "code.cloudfoundry.org/log-cache/rpc/logcache_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// readRecent reads the n most recent envelopes of a source between start
+// and end, newest first. More than readLimit envelopes are read in pages,
+// walking backwards from end. Every page ends right after the oldest
+// envelope of the previous one, so envelopes sharing its timestamp that
+// didn't fit are read as well.
+func readRecent(
+	ctx context.Context,
+	read logcache.Reader,
+	sourceID string,
+	start time.Time,
+	end time.Time,
+	types []logcache_v1.EnvelopeType,
+	n int,
+	readLimit int,
+) ([]*loggregator_v2.Envelope, error) {
+	var (
+		envelopes []*loggregator_v2.Envelope
+
+		// seen holds the envelopes already read at the timestamp the next
+		// page ends at, which the next page returns again.
+		seen []*loggregator_v2.Envelope
+	)
+	for len(envelopes) < n {
+		limit := n - len(envelopes) + len(seen)
+		if limit > readLimit {
+			limit = readLimit
+		}
+
+		page, err := read(
+			ctx,
+			sourceID,
+			start,
+			logcache.WithEndTime(end),
+			logcache.WithEnvelopeTypes(types...),
+			logcache.WithLimit(limit),
+			logcache.WithDescending(),
+		)
+		if err != nil {
+			return envelopes, err
+		}
+
+		unseen := withoutEnvelopes(page, seen)
+		if len(unseen) > n-len(envelopes) {
+			unseen = unseen[:n-len(envelopes)]
+		}
+		envelopes = append(envelopes, unseen...)
+
+		// A page of envelopes that were all read before can't be followed,
+		// more envelopes than the read limit share its timestamp.
+		if n <= readLimit || len(page) < limit || len(unseen) == 0 {
+			break
+		}
+
+		// The end time is exclusive, so the next page ends right after the
+		// oldest envelope of this one.
+		oldest := page[len(page)-1].Timestamp
+		end = time.Unix(0, oldest+1)
+
+		seen = nil
+		for i := len(envelopes) - 1; i >= 0 && envelopes[i].Timestamp == oldest; i-- {
+			seen = append(seen, envelopes[i])
+		}
+	}
+
+	return envelopes, nil
+}
+
+// withoutEnvelopes returns the envelopes of page that are not in seen. Each
+// envelope in seen drops one equal envelope from the page.
+func withoutEnvelopes(page, seen []*loggregator_v2.Envelope) []*loggregator_v2.Envelope {
+	if len(seen) == 0 {
+		return page
+	}
+	seen = append([]*loggregator_v2.Envelope(nil), seen...)
+
+	var unseen []*loggregator_v2.Envelope
+	for _, e := range page {
+		dropped := false
+		for i, s := range seen {
+			if proto.Equal(e, s) {
+				seen = append(seen[:i], seen[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+
+		if !dropped {
+			unseen = append(unseen, e)
+		}
+	}
+
+	return unseen
+}
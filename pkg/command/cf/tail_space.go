@@ -0,0 +1,192 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+type spaceEnvelope struct {
+	app      int
+	envelope *loggregator_v2.Envelope
+}
+
+// tailSpace tails every app in the targeted space. Each line is prefixed
+// with the name of the app it belongs to.
+func tailSpace(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	client *logcache.Client,
+	o options,
+	out lineOutput,
+	log Logger,
+) {
+	space, err := cli.GetCurrentSpace()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	apps, err := getSpaceApps(space.Guid, cli)
+	if err != nil {
+		log.Fatalf("Failed to read apps in space %s: %s", space.Name, err)
+	}
+
+	if len(apps) == 0 {
+		log.Fatalf("No apps found in space %s.", space.Name)
+	}
+
+	var included []v3Resource
+	for _, app := range apps {
+		if !o.excluded(app.GUID, app.Name) {
+			included = append(included, app)
+		}
+	}
+
+	if len(included) == 0 {
+		log.Fatalf("Every app in space %s is excluded by --exclude-source.", space.Name)
+	}
+
+	tailSources(ctx, client, o, included, out, log)
+}
+
+// resolveSources resolves the names of apps and service instances to their
+// GUIDs. Any other name is used as the source ID as it is.
+func resolveSources(names []string, cli plugin.CliConnection, log Logger) []v3Resource {
+	sources := make([]v3Resource, 0, len(names))
+	for _, name := range names {
+		id, _ := getGUID(name, cli, log)
+		if id == "" {
+			id = name
+		}
+
+		sources = append(sources, v3Resource{GUID: id, Name: name})
+	}
+
+	return sources
+}
+
+// excluded reports whether --exclude-source matches the source ID or name.
+func (o options) excluded(sourceID, name string) bool {
+	return o.excludeSource != nil && (o.excludeSource.MatchString(sourceID) || o.excludeSource.MatchString(name))
+}
+
+// tailSources tails several sources at once. Each line is prefixed with the
+// name of the source it belongs to.
+func tailSources(
+	ctx context.Context,
+	client *logcache.Client,
+	o options,
+	sources []v3Resource,
+	out lineOutput,
+	log Logger,
+) {
+	var width int
+	for _, app := range sources {
+		if len(app.Name) > width {
+			width = len(app.Name)
+		}
+	}
+
+	var (
+		mu         sync.Mutex
+		formatters = make([]formatter, len(sources))
+	)
+	for i, app := range sources {
+		formatters[i] = newFormatter(app.Name, prettyFormat, log, o)
+	}
+
+	write := func(app int, e *loggregator_v2.Envelope) {
+		if !o.filterAndScrub(e) {
+			return
+		}
+
+		formatted, ok := formatters[app].formatEnvelope(e)
+		if !ok {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		out.Write(fmt.Sprintf("%-*s %s", width+2, "["+sources[app].Name+"]", strings.TrimLeft(formatted, " ")))
+	}
+
+	walkStartTimes := make([]int64, len(sources))
+	for i := range sources {
+		walkStartTimes[i] = time.Now().Add(-5 * time.Second).UnixNano()
+	}
+
+	if o.lines > 0 {
+		var recent []spaceEnvelope
+		for i, app := range sources {
+			envelopes, err := readRecent(
+				ctx,
+				client.Read,
+				app.GUID,
+				o.startTime,
+				o.endTime,
+				o.envelopeTypes,
+				o.lines,
+				o.readLimit,
+			)
+			if err != nil && !o.follow {
+				log.Fatalf("%s", err)
+			}
+
+			// envelopes are in descending order
+			if len(envelopes) > 0 {
+				walkStartTimes[i] = envelopes[0].Timestamp + 1
+			}
+			for _, e := range envelopes {
+				recent = append(recent, spaceEnvelope{app: i, envelope: e})
+			}
+		}
+
+		sort.SliceStable(recent, func(i, j int) bool {
+			return recent[i].envelope.Timestamp < recent[j].envelope.Timestamp
+		})
+		if len(recent) > o.lines {
+			recent = recent[len(recent)-o.lines:]
+		}
+
+		for _, se := range recent {
+			write(se.app, se.envelope)
+		}
+	}
+
+	if !o.follow {
+		return
+	}
+
+	armAlerts(out)
+
+	var wg sync.WaitGroup
+	for i, app := range sources {
+		wg.Add(1)
+		go func(i int, sourceID string) {
+			defer wg.Done()
+
+			logcache.Walk(
+				ctx,
+				sourceID,
+				logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+					for _, e := range envelopes {
+						write(i, e)
+					}
+					return true
+				}),
+				client.Read,
+				logcache.WithWalkStartTime(time.Unix(0, walkStartTimes[i])),
+				logcache.WithWalkEnvelopeTypes(o.envelopeTypes...),
+				logcache.WithWalkBackoff(newFollowBackoff(ctx)),
+			)
+		}(i, app.GUID)
+	}
+	wg.Wait()
+}
@@ -5,9 +5,14 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
@@ -56,6 +61,87 @@ var _ = Describe("LogCache", func() {
 		}))
 	})
 
+	Context("with --from-archive", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = exportTestArchive(logResponseBody(startTime, "first", "second", "third"))
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("reads the last lines from the archive", func() {
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--from-archive", dir, "--lines", "2", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			logFormat := "   %s [APP/PROC/WEB/0] OUT %s"
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(logFormat, startTime.Add(1*time.Second).Format(timeFormat), "second"),
+				fmt.Sprintf(logFormat, startTime.Add(2*time.Second).Format(timeFormat), "third"),
+			}))
+			Expect(httpClient.requestCount()).To(Equal(0))
+			Expect(cliConn.accessTokenCount).To(Equal(0))
+		})
+
+		It("applies the time range to the archive", func() {
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{
+					"--from-archive", dir,
+					"--start-time", strconv.FormatInt(startTime.Add(time.Second).UnixNano(), 10),
+					"--end-time", strconv.FormatInt(startTime.Add(2*time.Second).UnixNano(), 10),
+					"app-guid",
+				},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT second", startTime.Add(time.Second).Format(timeFormat)),
+			}))
+		})
+
+		It("fatally logs for a source that is not in the archive", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--from-archive", dir, "other-app"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal(fmt.Sprintf("other-app is not in the archive %s", dir)))
+		})
+
+		It("fatally logs when combined with --follow", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--from-archive", dir, "--follow", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--from-archive cannot be used with --follow, --space, --mark-deploys or a source pattern"))
+		})
+	})
+
 	Context("when the source is an app", func() {
 		BeforeEach(func() {
 			cliConn.cliCommandResult = [][]string{
@@ -196,6 +282,95 @@ var _ = Describe("LogCache", func() {
 			}))
 		})
 
+		Context("with gauges in bytes and nanoseconds", func() {
+			BeforeEach(func() {
+				httpClient.responseBody = []string{fmt.Sprintf(`{"envelopes":{"batch":[
+					{"timestamp":"%d","source_id":"app-name","instance_id":"0","gauge":{"metrics":{
+						"memory":{"unit":"bytes","value":536870912},
+						"latency":{"unit":"nanoseconds","value":2500000},
+						"cpu":{"unit":"percentage","value":12.5}
+					}}}
+				]}}`, startTime.UnixNano())}
+			})
+
+			It("normalizes the units", func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"app-name"},
+					httpClient,
+					logger,
+					writer,
+					cf.WithTailNoHeaders(),
+				)
+
+				Expect(writer.lines()).To(Equal([]string{
+					fmt.Sprintf("   %s [app-name/0] GAUGE cpu:12.500000%% latency:2.500000 ms memory:512.000000 MiB", startTime.Format(timeFormat)),
+				}))
+			})
+
+			It("keeps the reported units with --raw-units", func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--raw-units", "app-name"},
+					httpClient,
+					logger,
+					writer,
+					cf.WithTailNoHeaders(),
+				)
+
+				Expect(writer.lines()).To(Equal([]string{
+					fmt.Sprintf("   %s [app-name/0] GAUGE cpu:12.500000 percentage latency:2500000.000000 nanoseconds memory:536870912.000000 bytes", startTime.Format(timeFormat)),
+				}))
+			})
+		})
+
+		Context("when the start time is older than the cache retention", func() {
+			var args []string
+
+			BeforeEach(func() {
+				httpClient.responseBody = []string{
+					evictedMetaResponse("app-guid", startTime),
+					responseBody(startTime),
+				}
+				args = []string{"--start-time", strconv.FormatInt(startTime.Add(-10*time.Minute).UnixNano(), 10), "app-name"}
+			})
+
+			It("warns that the start of the window was evicted", func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+					cf.WithTailNoHeaders(),
+				)
+
+				Expect(logger.printfMessages).To(ContainElement(fmt.Sprintf(
+					"Warning: The first 10m0s of the requested window for app-name have been evicted from Log Cache. The oldest envelope is from %s.",
+					startTime.Format(time.RFC3339),
+				)))
+				Expect(writer.lines()).To(HaveLen(3))
+			})
+
+			It("fatally logs with --strict-window", func() {
+				Expect(func() {
+					cf.Tail(
+						context.Background(),
+						cliConn,
+						append([]string{"--strict-window"}, args...),
+						httpClient,
+						logger,
+						writer,
+					)
+				}).To(Panic())
+
+				Expect(logger.fatalfMessage).To(HavePrefix("The first 10m0s of the requested window for app-name have been evicted"))
+			})
+		})
+
 		It("reports successful results with timer envelopes", func() {
 			httpClient.responseBody = []string{
 				timerResponseBody(startTime),
@@ -217,7 +392,7 @@ var _ = Describe("LogCache", func() {
 			end, err := strconv.ParseInt(requestURL.Query().Get("end_time"), 10, 64)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(end).To(BeNumerically("~", time.Now().UnixNano(), 10000000))
-			logFormat := "   %s [%s/%s] TIMER %s %f ms"
+			logFormat := "   %s [%s/%s] TIMER %s %s"
 			Expect(writer.lines()).To(Equal([]string{
 				fmt.Sprintf(
 					"Retrieving logs for app %s in org %s / space %s as %s...",
@@ -227,7 +402,62 @@ var _ = Describe("LogCache", func() {
 					cliConn.usernameResp,
 				),
 				"",
-				fmt.Sprintf(logFormat, startTime.Format(timeFormat), "app-name", "0", "http", float64(time.Second)/1000000.0),
+				fmt.Sprintf(logFormat, startTime.Format(timeFormat), "app-name", "0", "http", "1000.00 ms"),
+			}))
+		})
+
+		It("shows the request of timers and sub-millisecond durations in µs", func() {
+			httpClient.responseBody = []string{
+				fmt.Sprintf(`{"envelopes":{"batch":[
+					{
+						"timestamp":"%[1]d",
+						"source_id":"app-name",
+						"instance_id":"0",
+						"tags":{"method":"GET","uri":"/v2/apps","status_code":"200","peer_type":"Client"},
+						"timer":{"name":"http","start":"1000000","stop":"3500000"}
+					},
+					{
+						"timestamp":"%[1]d",
+						"source_id":"app-name",
+						"instance_id":"0",
+						"timer":{"name":"lookup","start":"1000","stop":"251000"}
+					}
+				]}}`, startTime.UnixNano()),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("   %s [app-name/0] TIMER lookup 250.00 µs", startTime.Format(timeFormat)),
+				fmt.Sprintf("   %s [app-name/0] TIMER http 2.50 ms GET /v2/apps 200", startTime.Format(timeFormat)),
+			}))
+		})
+
+		It("shows timer durations in nanoseconds with --raw-units", func() {
+			httpClient.responseBody = []string{
+				timerResponseBody(startTime),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--raw-units", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("   %s [app-name/0] TIMER http 1000000000 ns", startTime.Format(timeFormat)),
 			}))
 		})
 
@@ -273,7 +503,7 @@ var _ = Describe("LogCache", func() {
 				writer,
 			)
 
-			Expect(writer.bytes).To(MatchJSON(fmt.Sprintf(`{"batch":[
+			Expect(writer.bytes).To(MatchJSON(fmt.Sprintf(`{"schema_version":1,"batch":[
 				{"timestamp":"%d","source_id":"app-name","instance_id":"0","event":{"title":"some-title","body":"some-body"}},
 				{"timestamp":"%d","source_id":"app-name","instance_id":"0","timer":{"name":"http","start":"1517940773000000000","stop":"1517940773000000000"}},
 				{"timestamp":"%d","source_id":"app-name","instance_id":"0","gauge":{"metrics":{"some-name":{"unit":"my-unit","value":99}}}},
@@ -282,6 +512,49 @@ var _ = Describe("LogCache", func() {
 			]}`, startTime.UnixNano(), startTime.UnixNano(), startTime.UnixNano(), startTime.UnixNano(), startTime.UnixNano())))
 		})
 
+		It("writes out an envelope per line with --ndjson", func() {
+			httpClient.responseBody = []string{
+				mixedResponseBody(startTime),
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			args := []string{"--envelope-type", "any", "--ndjson", "app-name"}
+			cf.Tail(
+				ctx,
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			ts := startTime.UTC().Format(time.RFC3339Nano)
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(5))
+			Expect(lines[0]).To(MatchJSON(fmt.Sprintf(`{"schema_version":1,"timestamp":%q,"source_id":"app-name","instance_id":"0","type":"event","payload":{"title":"some-title","body":"some-body"},"tags":{}}`, ts)))
+			Expect(lines[1]).To(MatchJSON(fmt.Sprintf(`{"schema_version":1,"timestamp":%q,"source_id":"app-name","instance_id":"0","type":"timer","payload":{"name":"http","start":1517940773000000000,"stop":1517940773000000000},"tags":{}}`, ts)))
+			Expect(lines[2]).To(MatchJSON(fmt.Sprintf(`{"schema_version":1,"timestamp":%q,"source_id":"app-name","instance_id":"0","type":"gauge","payload":{"some-name":{"unit":"my-unit","value":99}},"tags":{}}`, ts)))
+			Expect(lines[3]).To(MatchJSON(fmt.Sprintf(`{"schema_version":1,"timestamp":%q,"source_id":"app-name","instance_id":"0","type":"counter","payload":{"name":"some-name","delta":0,"total":99},"tags":{}}`, ts)))
+			Expect(lines[4]).To(MatchJSON(fmt.Sprintf(`{"schema_version":1,"timestamp":%q,"source_id":"app-name","instance_id":"0","type":"log","payload":"log body","tags":{"source_type":"APP/PROC/WEB"}}`, ts)))
+		})
+
+		It("does not allow --ndjson with --json", func() {
+			args := []string{"--ndjson", "--json", "app-name"}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--ndjson cannot be used with --json, --output-format, --table-fields, --pretty-json or --mark-deploys"))
+		})
+
 		It("only returns timer, gauge, and counter when type=metrics", func() {
 			httpClient.responseBody = []string{
 				mixedResponseBody(startTime),
@@ -299,7 +572,7 @@ var _ = Describe("LogCache", func() {
 				writer,
 			)
 
-			Expect(writer.bytes).To(MatchJSON(fmt.Sprintf(`{"batch":[
+			Expect(writer.bytes).To(MatchJSON(fmt.Sprintf(`{"schema_version":1,"batch":[
 				{"timestamp":"%d","source_id":"app-name","instance_id":"0","timer":{"name":"http","start":"1517940773000000000","stop":"1517940773000000000"}},
 				{"timestamp":"%d","source_id":"app-name","instance_id":"0","gauge":{"metrics":{"some-name":{"unit":"my-unit","value":99}}}},
 				{"timestamp":"%d","source_id":"app-name","instance_id":"0","counter":{"name":"some-name","total":"99"}}
@@ -312,6 +585,27 @@ var _ = Describe("LogCache", func() {
 			Expect(envelopeType).To(Equal("ANY"))
 		})
 
+		It("requests every envelope type given with --envelope-type", func() {
+			httpClient.responseBody = []string{
+				mixedResponseBody(startTime),
+			}
+
+			args := []string{"--envelope-type", "log, counter", "--envelope-type", "gauge,log", "app-name"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(httpClient.requestURLs).ToNot(BeEmpty())
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query()["envelope_types"]).To(Equal([]string{"LOG", "COUNTER", "GAUGE"}))
+		})
+
 		It("only returns logs and events when type=logs", func() {
 			httpClient.responseBody = []string{
 				mixedResponseBody(startTime),
@@ -329,7 +623,7 @@ var _ = Describe("LogCache", func() {
 				writer,
 			)
 
-			Expect(writer.bytes).To(MatchJSON(fmt.Sprintf(`{"batch":[
+			Expect(writer.bytes).To(MatchJSON(fmt.Sprintf(`{"schema_version":1,"batch":[
 				{"timestamp":"%d","source_id":"app-name","instance_id":"0","event":{"title":"some-title","body":"some-body"}},
 				{"timestamp":"%d","source_id":"app-name","instance_id":"0","tags":{"source_type":"APP/PROC/WEB"},"log":{"payload":"bG9nIGJvZHk="}}
 			]}`, startTime.UnixNano(), startTime.UnixNano())))
@@ -360,7 +654,7 @@ var _ = Describe("LogCache", func() {
 			)
 
 			Expect(writer.bytes).To(MatchJSON(
-				fmt.Sprintf(`{"batch":[{"timestamp":"%d","source_id":"app-name","instance_id":"0","gauge":{"metrics":{"some-name":{"unit":"my-unit","value":99}}}}]}`, startTime.UnixNano()),
+				fmt.Sprintf(`{"schema_version":1,"batch":[{"timestamp":"%d","source_id":"app-name","instance_id":"0","gauge":{"metrics":{"some-name":{"unit":"my-unit","value":99}}}}]}`, startTime.UnixNano()),
 			))
 
 			Expect(httpClient.requestURLs).ToNot(BeEmpty())
@@ -370,6 +664,45 @@ var _ = Describe("LogCache", func() {
 			Expect(envelopeType).To(Equal("GAUGE"))
 		})
 
+		It("filters when given a gauge-name glob", func() {
+			httpClient.responseBody = []string{
+				mixedResponseBody(startTime),
+			}
+
+			args := []string{"--gauge-name", "other,some-*", "--json", "app-name"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(writer.bytes).To(MatchJSON(
+				fmt.Sprintf(`{"schema_version":1,"batch":[{"timestamp":"%d","source_id":"app-name","instance_id":"0","gauge":{"metrics":{"some-name":{"unit":"my-unit","value":99}}}}]}`, startTime.UnixNano()),
+			))
+
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("name_filter")).To(Equal("^(other|some-[^/]*)$"))
+		})
+
+		It("fatally logs for an invalid gauge-name glob", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--gauge-name", "some-[", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("Invalid --gauge-name pattern some-[: syntax error in pattern"))
+		})
+
 		It("filters when given counter-name flag", func() {
 			httpClient.responseBody = []string{
 				mixedResponseBody(startTime),
@@ -388,7 +721,7 @@ var _ = Describe("LogCache", func() {
 			)
 
 			Expect(writer.bytes).To(MatchJSON(
-				fmt.Sprintf(`{"batch":[{"timestamp":"%d","source_id":"app-name","instance_id":"0","counter":{"name":"some-name","total":"99"}}]}`, startTime.UnixNano()),
+				fmt.Sprintf(`{"schema_version":1,"batch":[{"timestamp":"%d","source_id":"app-name","instance_id":"0","counter":{"name":"some-name","total":"99"}}]}`, startTime.UnixNano()),
 			))
 
 			Expect(httpClient.requestURLs).ToNot(BeEmpty())
@@ -398,6 +731,30 @@ var _ = Describe("LogCache", func() {
 			Expect(envelopeType).To(Equal("COUNTER"))
 		})
 
+		It("passes every counter name to Log Cache as a name filter", func() {
+			httpClient.responseBody = []string{
+				mixedResponseBody(startTime),
+			}
+
+			args := []string{"--counter-name", "dropped,some-name", "--counter-name", "egress", "--json", "app-name"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(writer.bytes).To(MatchJSON(
+				fmt.Sprintf(`{"schema_version":1,"batch":[{"timestamp":"%d","source_id":"app-name","instance_id":"0","counter":{"name":"some-name","total":"99"}}]}`, startTime.UnixNano()),
+			))
+
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("name_filter")).To(Equal("^(dropped|some-name|egress)$"))
+		})
+
 		It("reports successful results when following", func() {
 			httpClient.responseBody = []string{
 				// Lines mode requests WithDescending
@@ -827,6 +1184,29 @@ var _ = Describe("LogCache", func() {
 			Expect(wrapperFunc).To(Panic())
 		})
 
+		It("writes JSON keys and tags in sorted order", func() {
+			httpClient.responseBody = []string{fmt.Sprintf(`{"envelopes":{"batch":[{
+				"timestamp":"%d",
+				"source_id":"app-name",
+				"instance_id":"0",
+				"tags":{"source_type":"APP/PROC/WEB","deployment":"cf","az":"z1"},
+				"log":{"payload":"bG9nIGJvZHk="}
+			}]}}`, startTime.UnixNano())}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--json", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(`{"schema_version":1,"batch":[{"instance_id":"0","log":{"payload":"bG9nIGJvZHk="},"source_id":"app-name","tags":{"az":"z1","deployment":"cf","source_type":"APP/PROC/WEB"},"timestamp":"%d"}]}`, startTime.UnixNano()),
+			}))
+		})
+
 		It("filters when given counter-name flag while following", func() {
 			httpClient.responseBody = []string{
 				mixedResponseBody(startTime),
@@ -845,7 +1225,7 @@ var _ = Describe("LogCache", func() {
 			)
 
 			Expect(writer.lines()).To(ConsistOf(
-				fmt.Sprintf(`{"timestamp":"%d","source_id":"app-name","instance_id":"0","counter":{"name":"some-name","total":"99"}}`, startTime.UnixNano()),
+				fmt.Sprintf(`{"schema_version":1,"counter":{"name":"some-name","total":"99"},"instance_id":"0","source_id":"app-name","timestamp":"%d"}`, startTime.UnixNano()),
 			))
 
 			Expect(httpClient.requestURLs).ToNot(BeEmpty())
@@ -875,9 +1255,18 @@ var _ = Describe("LogCache", func() {
 			Expect(u.Host).To(Equal("different-log-cache:8080"))
 		})
 
-		It("does not send Authorization header with LOG_CACHE_SKIP_AUTH", func() {
-			os.Setenv("LOG_CACHE_SKIP_AUTH", "true")
-			defer os.Unsetenv("LOG_CACHE_SKIP_AUTH")
+		It("scrubs payloads with the given scrubber", func() {
+			httpClient.responseBody = []string{logResponseBody(
+				startTime,
+				`{"user": "bob", "password": "hunter2", "nested": {"token": {"id": "abc"}}, "retries": 3}`,
+				"login password=hunter2 card 4111111111111111",
+			)}
+			scrubber := writeScrubFile(`
+fields: [password, token]
+patterns:
+- pattern: '\d{16}'
+  replacement: '[card]'
+`)
 
 			cf.Tail(
 				context.Background(),
@@ -886,17 +1275,160 @@ var _ = Describe("LogCache", func() {
 				httpClient,
 				logger,
 				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailScrubber(scrubber),
 			)
-			Expect(httpClient.requestHeaders[0]).To(BeEmpty())
+
+			logFormat := "   %s [APP/PROC/WEB/0] OUT %s"
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(logFormat, startTime.Format(timeFormat), `{"user": "bob", "password": "****", "nested": {"token": "****"}, "retries": 3}`),
+				fmt.Sprintf(logFormat, startTime.Add(time.Second).Format(timeFormat), "login password=**** card [card]"),
+			}))
 		})
 
-		It("follow retries for empty responses", func() {
-			httpClient.responseBody = []string{emptyResponseBody()}
+		It("injects app lifecycle markers with --mark-deploys", func() {
+			cliConn.cliCommandResult = [][]string{
+				{"app-guid"},
+				{capiEventsResponse(
+					capiAuditEvent("event-1", "audit.app.deployment.create", "a-user", startTime.Add(time.Second)),
+					capiAuditEvent("event-2", "audit.app.update", "a-user", startTime.Add(time.Second)),
+					capiAuditEvent("event-3", "audit.app.process.crash", "", startTime.Add(3*time.Second)),
+				)},
+			}
 
-			go cf.Tail(
+			cf.Tail(
 				context.Background(),
 				cliConn,
-				[]string{"--follow", "app-name"},
+				[]string{"--mark-deploys", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(cliConn.cliCommandArgs[1]).To(Equal([]string{
+				"curl",
+				fmt.Sprintf(
+					"/v3/audit_events?target_guids=app-guid&created_ats[gt]=%s&order_by=created_at&per_page=100",
+					startTime.Add(-time.Second).UTC().Format(time.RFC3339),
+				),
+			}))
+
+			logFormat := "   %s [APP/PROC/WEB/0] %s log body"
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(logFormat, startTime.Format(timeFormat), "ERR"),
+				fmt.Sprintf("   %s [CAPI] ===== deploy started by a-user (audit.app.deployment.create) =====", startTime.Add(time.Second).Format(timeFormat)),
+				fmt.Sprintf(logFormat, startTime.Add(1*time.Second).Format(timeFormat), "OUT"),
+				fmt.Sprintf(logFormat, startTime.Add(2*time.Second).Format(timeFormat), "OUT"),
+				fmt.Sprintf("   %s [CAPI] ===== crash (audit.app.process.crash) =====", startTime.Add(3*time.Second).Format(timeFormat)),
+			}))
+		})
+
+		It("reads app events from /v2/events when CAPI has no audit events", func() {
+			cliConn.cliCommandResult = [][]string{
+				{"app-guid"},
+				{`{"errors": [{"code": 10000, "title": "CF-NotFound", "detail": "Unknown request"}]}`},
+				{capiEventsResponse(
+					capiEvent("event-1", "audit.app.deployment.create", "a-user", startTime.Add(time.Second)),
+					capiEvent("event-3", "app.crash", "", startTime.Add(3*time.Second)),
+				)},
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--mark-deploys", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(cliConn.cliCommandArgs).To(HaveLen(3))
+			Expect(cliConn.cliCommandArgs[2]).To(Equal([]string{
+				"curl",
+				fmt.Sprintf(
+					"/v2/events?q=actee:app-guid&q=timestamp>%s&order-direction=asc&results-per-page=100",
+					startTime.Add(-time.Second).UTC().Format(time.RFC3339),
+				),
+			}))
+
+			logFormat := "   %s [APP/PROC/WEB/0] %s log body"
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(logFormat, startTime.Format(timeFormat), "ERR"),
+				fmt.Sprintf("   %s [CAPI] ===== deploy started by a-user (audit.app.deployment.create) =====", startTime.Add(time.Second).Format(timeFormat)),
+				fmt.Sprintf(logFormat, startTime.Add(1*time.Second).Format(timeFormat), "OUT"),
+				fmt.Sprintf(logFormat, startTime.Add(2*time.Second).Format(timeFormat), "OUT"),
+				fmt.Sprintf("   %s [CAPI] ===== crash (app.crash) =====", startTime.Add(3*time.Second).Format(timeFormat)),
+			}))
+		})
+
+		It("fetches app events on its own schedule instead of per batch while following with --mark-deploys", func() {
+			cliConn.cliCommandResult = [][]string{
+				{"app-guid"},
+				{capiEventsResponse()},
+				{capiEventsResponse()},
+			}
+			httpClient.responseBody = []string{
+				responseBody(startTime.Add(-30 * time.Second)),
+				responseBodyAsc(startTime),
+				responseBodyAsc(startTime.Add(3 * time.Second)),
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			cf.Tail(
+				ctx,
+				cliConn,
+				[]string{"--follow", "--mark-deploys", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(HaveLen(9))
+			Expect(cliConn.cliCommandArgs).To(HaveLen(2))
+			Expect(cliConn.cliCommandArgs[1][1]).To(HavePrefix("/v3/audit_events?target_guids=app-guid"))
+		})
+
+		It("fatally logs when --mark-deploys is used with --json", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--mark-deploys", "--json", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--mark-deploys cannot be used with --json or --output-format"))
+		})
+
+		It("does not send Authorization header with LOG_CACHE_SKIP_AUTH", func() {
+			os.Setenv("LOG_CACHE_SKIP_AUTH", "true")
+			defer os.Unsetenv("LOG_CACHE_SKIP_AUTH")
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+			Expect(httpClient.requestHeaders[0]).To(BeEmpty())
+		})
+
+		It("follow retries for empty responses", func() {
+			httpClient.responseBody = []string{emptyResponseBody()}
+
+			go cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--follow", "app-name"},
 				httpClient,
 				logger,
 				writer,
@@ -921,6 +1453,34 @@ var _ = Describe("LogCache", func() {
 			Eventually(httpClient.requestCount).Should(BeNumerically(">", 2))
 		})
 
+		It("follow resumes from the last envelope after an error", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "before"),
+				"not-json",
+				logResponseBody(startTime.Add(time.Second), "after"),
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			cf.Tail(
+				ctx,
+				cliConn,
+				[]string{"--follow", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(HaveLen(2))
+			Expect(writer.lines()[0]).To(ContainSubstring("before"))
+			Expect(writer.lines()[1]).To(ContainSubstring("after"))
+
+			start := fmt.Sprintf("start_time=%d", startTime.UnixNano()+1)
+			Expect(httpClient.requestURLs[1]).To(ContainSubstring(start))
+			Expect(httpClient.requestURLs[2]).To(ContainSubstring(start))
+		})
+
 		It("reports successful results with event envelopes", func() {
 			httpClient.responseBody = []string{
 				eventResponseBody(startTime),
@@ -954,6 +1514,7 @@ var _ = Describe("LogCache", func() {
 		})
 
 		It("accepts start-time, end-time, envelope-type, and lines flags", func() {
+			httpClient.responseBody = append([]string{emptyMetaResponseBody()}, httpClient.responseBody...)
 			args := []string{
 				"--start-time", "100",
 				"--end-time", "123",
@@ -970,8 +1531,9 @@ var _ = Describe("LogCache", func() {
 				writer,
 			)
 
-			Expect(httpClient.requestURLs).To(HaveLen(1))
-			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			Expect(httpClient.requestURLs[0]).To(HaveSuffix("/v1/meta"))
+			requestURL, err := url.Parse(httpClient.requestURLs[1])
 			Expect(err).ToNot(HaveOccurred())
 			Expect(requestURL.Scheme).To(Equal("https"))
 			Expect(requestURL.Host).To(Equal("log-cache.some-system.com"))
@@ -983,9 +1545,14 @@ var _ = Describe("LogCache", func() {
 			Expect(requestURL.Query().Get("limit")).To(Equal("99"))
 		})
 
-		It("accepts lines flags (short)", func() {
+		It("accepts RFC3339 start and end times", func() {
+			httpClient.responseBody = []string{
+				emptyMetaResponseBody(),
+				logResponseBody(startTime, "log body"),
+			}
 			args := []string{
-				"-n", "99",
+				"--start-time", "2018-02-06T18:12:53Z",
+				"--end-time", "2018-02-06T18:12:54.5Z",
 				"app-name",
 			}
 			cf.Tail(
@@ -997,660 +1564,2322 @@ var _ = Describe("LogCache", func() {
 				writer,
 			)
 
-			Expect(httpClient.requestURLs).To(HaveLen(1))
-			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			Expect(httpClient.requestURLs[0]).To(HaveSuffix("/v1/meta"))
+			requestURL, err := url.Parse(httpClient.requestURLs[1])
 			Expect(err).ToNot(HaveOccurred())
-			Expect(requestURL.Query().Get("limit")).To(Equal("99"))
+			Expect(requestURL.Query().Get("start_time")).To(Equal("1517940773000000000"))
+			Expect(requestURL.Query().Get("end_time")).To(Equal("1517940774500000000"))
 		})
 
-		It("defaults lines flag to 10", func() {
-			args := []string{
-				"app-name",
+		It("accepts relative start and end times", func() {
+			httpClient.responseBody = []string{
+				emptyMetaResponseBody(),
+				logResponseBody(startTime, "log body"),
 			}
 			cf.Tail(
 				context.Background(),
 				cliConn,
-				args,
+				[]string{"--since", "2h", "--until", "30m", "app-name"},
 				httpClient,
 				logger,
 				writer,
 			)
 
-			Expect(httpClient.requestURLs).To(HaveLen(1))
-			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			Expect(httpClient.requestURLs[0]).To(HaveSuffix("/v1/meta"))
+			requestURL, err := url.Parse(httpClient.requestURLs[1])
 			Expect(err).ToNot(HaveOccurred())
-			Expect(requestURL.Query().Get("limit")).To(Equal("10"))
-		})
 
-		It("requests the app guid", func() {
-			args := []string{"some-app"}
-			cf.Tail(
-				context.Background(),
-				cliConn,
-				args,
-				httpClient,
-				logger,
-				writer,
-			)
+			start, err := strconv.ParseInt(requestURL.Query().Get("start_time"), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(start).To(BeNumerically("~", time.Now().Add(-2*time.Hour).UnixNano(), int64(time.Second)))
 
-			Expect(cliConn.cliCommandArgs).To(HaveLen(1))
-			Expect(cliConn.cliCommandArgs[0]).To(HaveLen(3))
-			Expect(cliConn.cliCommandArgs[0][0]).To(Equal("app"))
-			Expect(cliConn.cliCommandArgs[0][1]).To(Equal("some-app"))
-			Expect(cliConn.cliCommandArgs[0][2]).To(Equal("--guid"))
+			end, err := strconv.ParseInt(requestURL.Query().Get("end_time"), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(end).To(BeNumerically("~", time.Now().Add(-30*time.Minute).UnixNano(), int64(time.Second)))
 		})
 
-		It("places the auth token in the 'Authorization' header", func() {
-			args := []string{"some-app"}
-			cliConn.accessToken = "bearer some-token"
-			cf.Tail(
-				context.Background(),
-				cliConn,
-				args,
-				httpClient,
-				logger,
-				writer,
-			)
+		It("fatally logs when --since is used with --start-time", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--since", "15m", "--start-time", "100", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
 
-			Expect(httpClient.requestHeaders).To(HaveLen(1))
-			Expect(httpClient.requestHeaders[0]).To(HaveLen(1))
-			Expect(httpClient.requestHeaders[0].Get("Authorization")).To(Equal("bearer some-token"))
+			Expect(logger.fatalfMessage).To(Equal("--since cannot be used with --start-time"))
 		})
 
-		It("formats the output via text/template", func() {
-			httpClient.responseBody = []string{responseBody(time.Unix(0, 1))}
-			args := []string{
-				"--output-format", `{{.Timestamp}} {{printf "%s" .GetLog.GetPayload}}`,
-				"app-guid",
-			}
-
-			cf.Tail(
-				context.Background(),
-				cliConn,
-				args,
-				httpClient,
-				logger,
-				writer,
-			)
+		It("fatally logs for an invalid start time", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--start-time", "yesterday", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
 
-			Expect(writer.lines()).To(ContainElement("1 log body"))
+			Expect(logger.fatalfMessage).To(Equal(`Invalid --start-time "yesterday", expected RFC3339 or UNIX nanoseconds.`))
 		})
 
-		It("formats the output via text/template (short flag)", func() {
-			httpClient.responseBody = []string{responseBody(time.Unix(0, 1))}
-			args := []string{
-				"-o", `{{.Timestamp}} {{printf "%s" .GetLog.GetPayload}}`,
-				"app-guid",
+		It("reads the whole range in pages with --all", func() {
+			httpClient.responseBody = []string{
+				emptyMetaResponseBody(),
+				logResponseBody(startTime, "first"),
+				logResponseBody(startTime.Add(time.Second), "second"),
+				emptyResponseBody(),
 			}
-
 			cf.Tail(
 				context.Background(),
 				cliConn,
-				args,
+				[]string{"--all", "--start-time", "100", "app-name"},
 				httpClient,
 				logger,
 				writer,
+				cf.WithTailNoHeaders(),
 			)
 
-			Expect(writer.lines()).To(ContainElement("1 log body"))
-		})
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(ContainSubstring("first"))
+			Expect(lines[1]).To(ContainSubstring("second"))
 
-		It("allows for empty end time with populated start time", func() {
-			args := []string{"--start-time", "1000", "app-name"}
-			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
-			}).ToNot(Panic())
+			Expect(httpClient.requestURLs).To(HaveLen(4))
+			Expect(httpClient.requestURLs[0]).To(HaveSuffix("/v1/meta"))
+			requestURL, err := url.Parse(httpClient.requestURLs[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("start_time")).To(Equal("100"))
+			Expect(requestURL.Query().Get("descending")).To(BeEmpty())
+
+			requestURL, err = url.Parse(httpClient.requestURLs[3])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("start_time")).To(Equal(strconv.FormatInt(startTime.Add(time.Second).UnixNano()+1, 10)))
 		})
 
-		It("fatally logs if envelope-type is invalid", func() {
-			args := []string{"--envelope-type", "invalid", "some-app"}
+		It("fatally logs when --all is used with --follow", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					args,
+					[]string{"--all", "--follow", "app-name"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("--envelope-type must be LOG, COUNTER, GAUGE, TIMER, EVENT or ANY"))
+			Expect(logger.fatalfMessage).To(Equal("--all cannot be used with --follow, --space or a source pattern"))
 		})
 
-		It("fatally logs if gauge-name and envelope-type flags are both set", func() {
-			args := []string{
-				"--gauge-name", "some-name",
-				"--envelope-type", "LOG",
-				"some-app",
+		It("merges several sources ordered by time", func() {
+			cliConn.cliCommandResult = [][]string{{"app-1-guid"}, {"app-2-guid"}}
+			httpClient.responseBody = []string{
+				logResponseBody(startTime.Add(time.Second), "second"),
+				logResponseBody(startTime, "first"),
 			}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-1", "app-2"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(cliConn.cliCommandArgs).To(Equal([][]string{
+				{"app", "app-1", "--guid"},
+				{"app", "app-2", "--guid"},
+			}))
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(HavePrefix("[app-2]"))
+			Expect(lines[0]).To(ContainSubstring("first"))
+			Expect(lines[1]).To(HavePrefix("[app-1]"))
+			Expect(lines[1]).To(ContainSubstring("second"))
+
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			Expect(httpClient.requestURLs[0]).To(ContainSubstring("/v1/read/app-1-guid"))
+			Expect(httpClient.requestURLs[1]).To(ContainSubstring("/v1/read/app-2-guid"))
+		})
+
+		It("fatally logs when several sources are used with --json", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					args,
+					[]string{"--json", "app-1", "app-2"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("--gauge-name cannot be used with --envelope-type"))
+			Expect(logger.fatalfMessage).To(Equal("Several sources cannot be used with --json, --ndjson, --output-format, --table-fields, --mark-deploys, --all or --from-archive"))
 		})
 
-		It("fatally logs when envelope-type and type are both present", func() {
-			args := []string{
-				"--type", "metrics",
-				"--envelope-type", "counter",
-				"--json",
-				"app-name",
+		It("only shows envelopes of the instances given with --instance", func() {
+			httpClient.responseBody = []string{fmt.Sprintf(`{"envelopes":{"batch":[
+				{"timestamp":"%d","source_id":"app-name","instance_id":"2","log":{"payload":"dHdv"}},
+				{"timestamp":"%d","source_id":"app-name","instance_id":"1","log":{"payload":"b25l"}},
+				{"timestamp":"%d","source_id":"app-name","instance_id":"0","log":{"payload":"emVybw=="}}
+			]}}`, startTime.Add(2*time.Second).UnixNano(), startTime.Add(time.Second).UnixNano(), startTime.UnixNano())}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--instance", "0", "--instance", "2", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(ContainSubstring("zero"))
+			Expect(lines[1]).To(ContainSubstring("two"))
+		})
+
+		It("only shows logs matching --filter", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "GET /a 200", "GET /b 500", "POST /c 500"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--filter", " 500$", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(HaveSuffix("GET /b 500"))
+			Expect(lines[1]).To(HaveSuffix("POST /c 500"))
+		})
+
+		It("only shows logs not matching --filter with --invert-match", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "GET /a 200", "GET /b 500", "POST /c 500"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--filter", " 500$", "--invert-match", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(HaveLen(1))
+			Expect(writer.lines()[0]).To(HaveSuffix("GET /a 200"))
+		})
+
+		It("highlights matches of --highlight without dropping other lines", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "request abc-123 done", "other request"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--highlight", "abc-[0-9]+", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailColor(),
+			)
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(HaveSuffix("request \x1b[7mabc-123\x1b[27m done"))
+			Expect(lines[1]).To(HaveSuffix("other request"))
+		})
+
+		It("marks lines matching --highlight without colors", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "request abc-123 done", "other request"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--highlight", "abc-[0-9]+", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(HavePrefix("** "))
+			Expect(lines[1]).ToNot(HavePrefix("** "))
+		})
+
+		It("colors log lines by stream and level", func() {
+			httpClient.responseBody = []string{
+				responseBody(startTime),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailColor(),
+			)
+
+			logFormat := "   %s [APP/PROC/WEB/0] %s log body"
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("\x1b[31m   %s [APP/PROC/WEB/0] ERR\x1b[0m log body", startTime.Format(timeFormat)),
+				fmt.Sprintf(logFormat, startTime.Add(1*time.Second).Format(timeFormat), "OUT"),
+				fmt.Sprintf(logFormat, startTime.Add(2*time.Second).Format(timeFormat), "OUT"),
+			}))
+		})
+
+		It("does not color with --no-color", func() {
+			httpClient.responseBody = []string{
+				responseBody(startTime),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--no-color", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailColor(),
+			)
+
+			Expect(string(writer.bytes)).ToNot(ContainSubstring("\x1b["))
+		})
+
+		It("does not color when NO_COLOR is set", func() {
+			os.Setenv("NO_COLOR", "1")
+			defer os.Unsetenv("NO_COLOR")
+			httpClient.responseBody = []string{
+				responseBody(startTime),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailColor(),
+			)
+
+			Expect(string(writer.bytes)).ToNot(ContainSubstring("\x1b["))
+		})
+
+		It("colors the most severe level in the payload", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "WARN: disk almost full", `{"level":"error","msg":"warn: retrying"}`, "level=FATAL exiting"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailColor(),
+			)
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(3))
+			Expect(lines[0]).To(HavePrefix("\x1b[33m"))
+			Expect(lines[1]).To(HavePrefix("\x1b[31m"))
+			Expect(lines[2]).To(HavePrefix("\x1b[1;31m"))
+		})
+
+		It("renders envelopes with --output-template", func() {
+			httpClient.responseBody = []string{
+				mixedResponseBody(startTime),
 			}
 
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{
+					"--envelope-type", "any",
+					"--output-template", `{{.Timestamp.Format "15:04:05"}} {{.SourceID}}/{{.InstanceID}} {{.Type}}{{with .Tags.source_type}} {{.}}{{end}}{{if eq .Type "log"}}: {{.Payload}}{{else if eq .Type "counter"}}: {{.Payload.name}}={{.Payload.total}}{{end}}`,
+					"app-name",
+				},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			ts := startTime.UTC().Format("15:04:05")
+			Expect(writer.lines()).To(Equal([]string{
+				ts + " app-name/0 event",
+				ts + " app-name/0 timer",
+				ts + " app-name/0 gauge",
+				ts + " app-name/0 counter: some-name=99",
+				ts + " app-name/0 log APP/PROC/WEB: log body",
+			}))
+		})
+
+		It("fatally logs when --output-template is used with --output-format", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					args,
+					[]string{"--output-template", "{{.Type}}", "--output-format", "{{.SourceId}}", "app-name"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("--envelope-type cannot be used with --type"))
+			Expect(logger.fatalfMessage).To(Equal("--output-template cannot be used with --output-format"))
 		})
 
-		It("fatally logs if counter-name and envelope-type flags are both set", func() {
-			args := []string{
-				"--counter-name", "some-name",
-				"--envelope-type", "LOG",
-				"some-app",
+		It("writes envelopes as key/value pairs with --output logfmt", func() {
+			httpClient.responseBody = []string{
+				mixedResponseBody(startTime),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--envelope-type", "any", "--output", "logfmt", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			ts := startTime.UTC().Format(time.RFC3339Nano)
+			Expect(writer.lines()).To(Equal([]string{
+				"ts=" + ts + " source=app-name instance=0 type=event title=some-title body=some-body",
+				"ts=" + ts + " source=app-name instance=0 type=timer name=http duration=0s",
+				"ts=" + ts + " source=app-name instance=0 type=gauge some-name=99 some-name_unit=my-unit",
+				"ts=" + ts + " source=app-name instance=0 type=counter name=some-name delta=0 total=99",
+				"ts=" + ts + " source=app-name instance=0 type=log source_type=APP/PROC/WEB stream=out msg=\"log body\"",
+			}))
+		})
+
+		It("quotes logfmt values that need it", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "plain", `say "hi"`, "a=b", "line\nbreak", ""),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--output", "logfmt", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			var msgs []string
+			for _, l := range writer.lines() {
+				msgs = append(msgs, l[strings.Index(l, " msg=")+5:])
+			}
+			Expect(msgs).To(Equal([]string{
+				`plain`,
+				`"say \"hi\""`,
+				`"a=b"`,
+				`"line\nbreak"`,
+				`""`,
+			}))
+		})
+
+		It("writes RFC5424 syslog lines with --output syslog", func() {
+			httpClient.responseBody = []string{
+				mixedResponseBody(startTime),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--envelope-type", "any", "--output", "syslog", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			ts := startTime.UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+			Expect(writer.lines()).To(Equal([]string{
+				"<14>1 " + ts + ` - app-name 0 event [tags@47450 instance_id="0"] some-title: some-body`,
+				"<14>1 " + ts + ` - app-name 0 timer [tags@47450 instance_id="0"][timer@47450 name="http" start="1517940773000000000" stop="1517940773000000000"]`,
+				"<14>1 " + ts + ` - app-name 0 gauge [tags@47450 instance_id="0"][gauge@47450 name="some-name" unit="my-unit" value="99"]`,
+				"<14>1 " + ts + ` - app-name 0 counter [tags@47450 instance_id="0"][counter@47450 delta="0" name="some-name" total="99"]`,
+				"<14>1 " + ts + ` - app-name [APP/PROC/WEB/0] log [tags@47450 instance_id="0" source_type="APP/PROC/WEB"] log body`,
+			}))
+		})
+
+		It("uses the error severity and escapes tags in syslog lines", func() {
+			httpClient.responseBody = []string{
+				fmt.Sprintf(`{"envelopes":{"batch":[{
+					"timestamp":"%d",
+					"source_id":"app-name",
+					"instance_id":"1",
+					"tags":{"source_type":"APP/PROC/WEB","custom":"a \"quoted\" [value]"},
+					"log":{"payload":"b29wcwo=","type":"ERR"}
+				}]}}`, startTime.UnixNano()),
 			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--output", "syslog", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			ts := startTime.UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+			Expect(writer.lines()).To(Equal([]string{
+				"<11>1 " + ts + ` - app-name [APP/PROC/WEB/1] log [tags@47450 custom="a \"quoted\" [value\]" instance_id="1" source_type="APP/PROC/WEB"] oops`,
+			}))
+		})
+
+		It("fatally logs for an unknown --output", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					args,
+					[]string{"--output", "xml", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--output must be 'logfmt' or 'syslog'."))
+		})
+
+		It("fatally logs when --output is used with --json", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--output", "logfmt", "--json", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--output cannot be used with --json, --ndjson, --output-format, --table-fields, --pretty-json or --mark-deploys"))
+		})
+
+		It("appends envelope tags with --show-tags", func() {
+			httpClient.responseBody = []string{
+				fmt.Sprintf(`{"envelopes":{"batch":[
+					{
+						"timestamp":"%[1]d",
+						"source_id":"app-name",
+						"instance_id":"0",
+						"tags":{"source_type":"APP/PROC/WEB","job":"diego-cell","note":"two words"},
+						"deprecated_tags":{"deployment":{"text":"cf"}},
+						"log":{"payload":"bG9nIGJvZHk="}
+					},
+					{
+						"timestamp":"%[1]d",
+						"source_id":"app-name",
+						"instance_id":"0",
+						"counter":{"name":"some-name","total":99}
+					}
+				]}}`, startTime.UnixNano()),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--envelope-type", "any", "--show-tags", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("   %s [app-name/0] COUNTER some-name:99", startTime.Format(timeFormat)),
+				fmt.Sprintf(`   %s [APP/PROC/WEB/0] OUT log body {deployment=cf job=diego-cell note="two words" source_type=APP/PROC/WEB}`, startTime.Format(timeFormat)),
+			}))
+		})
+
+		It("fatally logs when --show-tags is used with --json", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--show-tags", "--json", "app-name"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("--counter-name cannot be used with --envelope-type"))
+			Expect(logger.fatalfMessage).To(Equal("--show-tags cannot be used with --json, --ndjson, --output-format, --output or --table-fields"))
+		})
+
+		It("only shows envelopes with every tag given with --tag", func() {
+			httpClient.responseBody = []string{
+				fmt.Sprintf(`{"envelopes":{"batch":[
+					{
+						"timestamp":"%[1]d",
+						"source_id":"app-name",
+						"instance_id":"0",
+						"tags":{"source_type":"RTR","job":"router"},
+						"log":{"payload":"cm91dGVy"}
+					},
+					{
+						"timestamp":"%[1]d",
+						"source_id":"app-name",
+						"instance_id":"0",
+						"tags":{"source_type":"RTR","job":"other"},
+						"log":{"payload":"b3RoZXI="}
+					},
+					{
+						"timestamp":"%[1]d",
+						"source_id":"app-name",
+						"instance_id":"0",
+						"tags":{"job":"router"},
+						"deprecated_tags":{"source_type":{"text":"RTR"}},
+						"log":{"payload":"ZGVwcmVjYXRlZA=="}
+					}
+				]}}`, startTime.UnixNano()),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--tag", "job=router", "--tag", "source_type=RTR", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("   %s [RTR/0] OUT deprecated", startTime.Format(timeFormat)),
+				fmt.Sprintf("   %s [RTR/0] OUT router", startTime.Format(timeFormat)),
+			}))
+		})
+
+		It("fatally logs for a --tag without a value", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--tag", "job", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal(`Invalid --tag "job", expected key=value.`))
+		})
+
+		It("accepts lines flags (short)", func() {
+			args := []string{
+				"-n", "99",
+				"app-name",
+			}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("limit")).To(Equal("99"))
+		})
+
+		It("defaults lines flag to 10", func() {
+			args := []string{
+				"app-name",
+			}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("limit")).To(Equal("10"))
+		})
+
+		It("requests the app guid", func() {
+			args := []string{"some-app"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(cliConn.cliCommandArgs).To(HaveLen(1))
+			Expect(cliConn.cliCommandArgs[0]).To(HaveLen(3))
+			Expect(cliConn.cliCommandArgs[0][0]).To(Equal("app"))
+			Expect(cliConn.cliCommandArgs[0][1]).To(Equal("some-app"))
+			Expect(cliConn.cliCommandArgs[0][2]).To(Equal("--guid"))
+		})
+
+		It("places the auth token in the 'Authorization' header", func() {
+			args := []string{"some-app"}
+			cliConn.accessToken = "bearer some-token"
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(httpClient.requestHeaders).To(HaveLen(1))
+			Expect(httpClient.requestHeaders[0]).To(HaveLen(1))
+			Expect(httpClient.requestHeaders[0].Get("Authorization")).To(Equal("bearer some-token"))
+		})
+
+		It("formats the output via text/template", func() {
+			httpClient.responseBody = []string{responseBody(time.Unix(0, 1))}
+			args := []string{
+				"--output-format", `{{.Timestamp}} {{printf "%s" .GetLog.GetPayload}}`,
+				"app-guid",
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(writer.lines()).To(ContainElement("1 log body"))
+		})
+
+		It("formats the output via text/template (short flag)", func() {
+			httpClient.responseBody = []string{responseBody(time.Unix(0, 1))}
+			args := []string{
+				"-o", `{{.Timestamp}} {{printf "%s" .GetLog.GetPayload}}`,
+				"app-guid",
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(writer.lines()).To(ContainElement("1 log body"))
+		})
+
+		It("skips the retention check with WithTailNoRetentionCheck", func() {
+			httpClient.responseBody = []string{logResponseBody(startTime, "log body")}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--start-time", "100", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoRetentionCheck(),
+			)
+
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+			Expect(httpClient.requestURLs[0]).To(ContainSubstring("/v1/read/app-guid"))
+		})
+
+		It("allows for empty end time with populated start time", func() {
+			httpClient.responseBody = append([]string{emptyMetaResponseBody()}, httpClient.responseBody...)
+			args := []string{"--start-time", "1000", "app-name"}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).ToNot(Panic())
+		})
+
+		It("fatally logs if envelope-type is invalid", func() {
+			args := []string{"--envelope-type", "invalid", "some-app"}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--envelope-type must be LOG, COUNTER, GAUGE, TIMER, EVENT or ANY"))
+		})
+
+		It("fatally logs if gauge-name and envelope-type flags are both set", func() {
+			args := []string{
+				"--gauge-name", "some-name",
+				"--envelope-type", "LOG",
+				"some-app",
+			}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--gauge-name cannot be used with --envelope-type"))
+		})
+
+		It("fatally logs when envelope-type and type are both present", func() {
+			args := []string{
+				"--type", "metrics",
+				"--envelope-type", "counter",
+				"--json",
+				"app-name",
+			}
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--envelope-type cannot be used with --type"))
+		})
+
+		It("fatally logs if counter-name and envelope-type flags are both set", func() {
+			args := []string{
+				"--counter-name", "some-name",
+				"--envelope-type", "LOG",
+				"some-app",
+			}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--counter-name cannot be used with --envelope-type"))
+		})
+
+		It("fatally logs if counter-name and gauge-name flags are both set", func() {
+			args := []string{
+				"--counter-name", "some-name",
+				"--gauge-name", "some-name",
+				"some-app",
+			}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--counter-name cannot be used with --gauge-name"))
+		})
+
+		It("fatally logs if output-format and json flags are given", func() {
+			httpClient.responseBody = []string{responseBody(time.Unix(0, 1))}
+			args := []string{
+				"--output-format", `{{.Timestamp}} {{printf "%s" .GetLog.GetPayload}}`,
+				"--json",
+				"app-guid",
+			}
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("Cannot use output-format and json flags together"))
+		})
+
+		It("fatally logs if an output-format is malformed", func() {
+			args := []string{"--output-format", "{{INVALID}}", "app-guid"}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal(`template: OutputFormat:1: function "INVALID" not defined`))
+		})
+
+		It("fatally logs if an output-format won't execute", func() {
+			httpClient.responseBody = []string{`{"envelopes":{"batch":[{"source_id": "a", "timestamp": 1},{"source_id":"b", "timestamp":2}]}}`}
+			args := []string{
+				"--output-format", "{{.invalid 9}}",
+				"app-guid",
+			}
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal(`Output template parsed, but failed to execute: template: OutputFormat:1:2: executing "OutputFormat" at <.invalid>: can't evaluate field invalid in type *loggregator_v2.Envelope`))
+		})
+
+		It("reads more than 1000 lines in pages", func() {
+			payloads := make([]string, 1000)
+			for i := range payloads {
+				payloads[i] = fmt.Sprintf("line-%d", i+1)
+			}
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, payloads...),
+				logResponseBody(startTime.Add(-time.Second), "line-0"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--lines", "1001", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(1001))
+			Expect(lines[0]).To(ContainSubstring("line-0"))
+			Expect(lines[1000]).To(ContainSubstring("line-1000"))
+
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			requestURL, err := url.Parse(httpClient.requestURLs[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("limit")).To(Equal("2"))
+			Expect(requestURL.Query().Get("end_time")).To(Equal(strconv.FormatInt(startTime.UnixNano()+1, 10)))
+		})
+
+		It("reads the envelopes sharing the oldest timestamp of a page with the next page", func() {
+			envelope := func(ts time.Time, payload string) string {
+				return fmt.Sprintf(
+					`{"timestamp":"%d","source_id":"app-name","instance_id":"0","log":{"payload":%q}}`,
+					ts.UnixNano(),
+					base64.StdEncoding.EncodeToString([]byte(payload)),
+				)
+			}
+			httpClient.responseBody = []string{
+				fmt.Sprintf(`{"envelopes":{"batch":[%s,%s,%s]}}`,
+					envelope(startTime.Add(time.Second), "line-c"),
+					envelope(startTime, "line-b"),
+					envelope(startTime, "line-a"),
+				),
+				fmt.Sprintf(`{"envelopes":{"batch":[%s,%s,%s]}}`,
+					envelope(startTime, "line-b"),
+					envelope(startTime, "line-a"),
+					envelope(startTime, "line-x"),
+				),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--lines", "4", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailReadLimit(3),
+			)
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(4))
+			Expect(strings.Join(lines, "\n")).To(ContainSubstring("line-x"))
+
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			requestURL, err := url.Parse(httpClient.requestURLs[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("limit")).To(Equal("3"))
+			Expect(requestURL.Query().Get("end_time")).To(Equal(strconv.FormatInt(startTime.UnixNano()+1, 10)))
+		})
+
+		It("reads pages of the read limit reported by Log Cache", func() {
+			httpClient.maxReadLimit = 600
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "line-1"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--lines", "1001", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("limit")).To(Equal("600"))
+		})
+
+		It("reads pages of the read limit given by the caller", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "line-1", "line-2"),
+				logResponseBody(startTime.Add(-time.Second), "line-0"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--lines", "3", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailReadLimit(2),
+			)
+
+			Expect(writer.lines()).To(HaveLen(3))
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("limit")).To(Equal("2"))
+
+			requestURL, err = url.Parse(httpClient.requestURLs[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("limit")).To(Equal("2"))
+		})
+
+		It("accepts 0 for --lines", func() {
+			args := []string{
+				"--lines", "0",
+				"some-app",
+			}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).ToNot(Panic())
+		})
+
+		It("fatally logs if username cannot be fetched", func() {
+			cliConn.usernameErr = errors.New("unknown user")
+			args := []string{"app-name"}
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("unknown user"))
+		})
+
+		It("fatally logs if org name cannot be fetched", func() {
+			cliConn.orgErr = errors.New("Organization could not be fetched")
+			args := []string{"app-name"}
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("Organization could not be fetched"))
+		})
+
+		It("fatally logs if space cannot be fetched", func() {
+			cliConn.spaceErr = errors.New("unknown space")
+			args := []string{"app-name"}
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("unknown space"))
+		})
+
+		It("fatally logs if the start > end", func() {
+			args := []string{"--start-time", "1000", "--end-time", "100", "app-name"}
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					args,
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("Invalid date/time range. Ensure your start time is prior or equal the end time."))
+		})
+
+		It("fatally logs if a source pattern is combined with other sources", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"one", "router*"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("A source pattern cannot be combined with other sources"))
+		})
+
+		It("fatally logs if not enough arguments are given", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("Expected at least 1 argument, got 0."))
+		})
+
+		It("fatally logs if there is an error while getting API endpoint", func() {
+			cliConn.apiEndpointErr = errors.New("some-error")
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("some-error"))
+		})
+
+		It("fatally logs if there is no API endpoint", func() {
+			cliConn.hasAPIEndpoint = false
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("No API endpoint targeted."))
+		})
+
+		It("fatally logs if there is an error while checking for API endpoint", func() {
+			cliConn.hasAPIEndpoint = true
+			cliConn.hasAPIEndpointErr = errors.New("some-error")
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("some-error"))
+		})
+
+		It("fatally logs if the request returns an error", func() {
+			httpClient.responseErr = errors.New("some-error")
+
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("some-error"))
+		})
+	})
+
+	Context("when the source is a service", func() {
+		BeforeEach(func() {
+			cliConn.usernameResp = "a-user"
+			cliConn.orgName = "organization"
+			cliConn.spaceName = "space"
+
+			cliConn.spaceGUID = "space-guid"
+			cliConn.cliCommandResult = [][]string{
+				{""},
+				{capiServiceInstancesResponse(map[string]string{"service-guid": "service-name"})},
+			}
+
+			httpClient.responseBody = []string{gaugeResponseBody(startTime)}
+
+		})
+
+		It("reports successful results", func() {
+			args := []string{"service-name"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			logFormat := "   %s [%s/%s] GAUGE %s:%f %s %s:%f %s"
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(
+					"Retrieving logs for service %s in org %s / space %s as %s...",
+					"service-name",
+					cliConn.orgName,
+					cliConn.spaceName,
+					cliConn.usernameResp,
+				),
+				"",
+				fmt.Sprintf(logFormat, startTime.Format(timeFormat), "service-name", "0", "some-name", 99.0, "my-unit", "some-other-name", 101.0, "my-unit"),
+			}))
+		})
+
+		It("requests the service guid when app --guid fails", func() {
+			cliConn.cliCommandResult = [][]string{
+				{"not", "an", "app"},
+				{capiServiceInstancesResponse(map[string]string{"service-guid": "app-name"})},
+			}
+			cliConn.cliCommandErr = []error{errors.New("catch this instead")}
+
+			args := []string{"app-name"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			logFormat := "   %s [%s/%s] GAUGE %s:%f %s %s:%f %s"
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(
+					"Retrieving logs for service %s in org %s / space %s as %s...",
+					"app-name",
+					cliConn.orgName,
+					cliConn.spaceName,
+					cliConn.usernameResp,
+				),
+				"",
+				fmt.Sprintf(logFormat, startTime.Format(timeFormat), "app-name", "0", "some-name", 99.0, "my-unit", "some-other-name", 101.0, "my-unit"),
+			}))
+
+			Expect(logger.printfMessages).To(ContainElement("catch this instead"))
+		})
+
+		It("calls the log cache api", func() {
+			args := []string{"service-name"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+
+			end, err := strconv.ParseInt(requestURL.Query().Get("end_time"), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(end).To(BeNumerically("~", time.Now().UnixNano(), 10000000))
+		})
+
+		It("requests the service guid", func() {
+			args := []string{"some-service"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(cliConn.cliCommandArgs).To(HaveLen(2))
+			Expect(cliConn.cliCommandArgs[1]).To(Equal([]string{
+				"curl",
+				"/v3/service_instances?names=some-service&space_guids=space-guid",
+			}))
+			Expect(httpClient.requestURLs[0]).To(ContainSubstring("/v1/read/service-guid"))
+		})
+
+		It("falls back to the source ID when there is no service with the name", func() {
+			cliConn.cliCommandResult = [][]string{
+				{""},
+				{capiServiceInstancesResponse(nil)},
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"some-source"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(httpClient.requestURLs[0]).To(ContainSubstring("/v1/read/some-source"))
+			Expect(logger.printfMessages).To(BeEmpty())
+		})
+	})
+
+	Context("when the source is a component", func() {
+		BeforeEach(func() {
+			cliConn.usernameResp = "a-user"
+			httpClient.responseBody = []string{counterResponseBody(startTime)}
+		})
+
+		It("requests as a source id", func() {
+			cliConn.cliCommandResult = [][]string{{""}, {""}}
+			cliConn.cliCommandErr = []error{errors.New("app not found"), errors.New("service not found")}
+
+			args := []string{"app-name"}
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				args,
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			end, err := strconv.ParseInt(requestURL.Query().Get("end_time"), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(end).To(BeNumerically("~", time.Now().UnixNano(), 10000000))
+
+			Expect(requestURL.Path).To(Equal("/v1/read/app-name"))
+
+			counterFormat := "   %s [%s/%s] COUNTER %s:%d"
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(
+					"Retrieving logs for source %s as %s...",
+					"app-name",
+					cliConn.usernameResp,
+				),
+				"",
+				fmt.Sprintf(counterFormat, startTime.Format(timeFormat), "app-name", "0", "some-name", 99),
+			}))
+
+			Expect(logger.printfMessages).To(ContainElement("app not found"))
+			Expect(logger.printfMessages).To(ContainElement("service not found"))
+		})
+
+		It("uses the LOG_CACHE_ADDR environment variable", func() {
+			os.Setenv("LOG_CACHE_ADDR", "https://different-log-cache:8080")
+			defer os.Unsetenv("LOG_CACHE_ADDR")
+
+			cliConn.cliCommandResult = [][]string{{""}, {""}}
+			cliConn.cliCommandErr = []error{errors.New("app not found"), errors.New("service not found")}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+
+			u, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(u.Scheme).To(Equal("https"))
+			Expect(u.Host).To(Equal("different-log-cache:8080"))
+			Expect(u.Path).To(ContainSubstring("app-name"))
+		})
+	})
+
+	Context("when rendering a table", func() {
+		BeforeEach(func() {
+			cliConn.cliCommandResult = [][]string{{"app-guid"}}
+		})
+
+		It("writes one column per field of JSON payloads", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(
+					startTime,
+					`{"ts":"10:00:01","level":"info","msg":"started","req":{"latency_ms":12}}`,
+					"not json",
+					`{"ts":"10:00:02","level":"error","msg":"db\tdown"}`,
+				),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--table-fields", "ts, level,msg,req.latency_ms", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				"ts        level  msg      req.latency_ms",
+				"10:00:01  info   started  12",
+				"10:00:02  error  db down  -",
+			}))
+		})
+
+		It("renders the table in the requested style", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(
+					startTime,
+					`{"level":"info","msg":"a|b"}`,
+				),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--table-fields", "level,msg", "--table-style", "markdown", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				"| level | msg |",
+				"| --- | --- |",
+				`| info | a\|b |`,
+			}))
+		})
+
+		It("renders tab separated values", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(
+					startTime,
+					`{"level":"info","msg":"started"}`,
+				),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--table-fields", "level,msg", "--table-style", "tsv", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				"level\tmsg",
+				"info\tstarted",
+			}))
+		})
+
+		It("fatally logs when --table-style is used without --table-fields", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--table-style", "tsv", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--table-style can only be used with --table-fields"))
+		})
+
+		It("fatally logs when used with --follow", func() {
+			Expect(func() {
+				cf.Tail(
+					context.Background(),
+					cliConn,
+					[]string{"--table-fields", "msg", "--follow", "app-name"},
+					httpClient,
+					logger,
+					writer,
+				)
+			}).To(Panic())
+
+			Expect(logger.fatalfMessage).To(Equal("--table-fields cannot be used with --follow, --space, --mark-deploys, --json or --output-format"))
+		})
+	})
+
+	Context("when flags are set through environment variables", func() {
+		BeforeEach(func() {
+			cliConn.cliCommandResult = [][]string{{"app-guid"}}
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "log body"),
+			}
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("LOG_CACHE_CLI_TAIL_ENVELOPE_TYPE")
+			os.Unsetenv("LOG_CACHE_CLI_TAIL_JSON")
+		})
+
+		It("uses them as flag defaults", func() {
+			os.Setenv("LOG_CACHE_CLI_TAIL_ENVELOPE_TYPE", "log")
+			os.Setenv("LOG_CACHE_CLI_TAIL_JSON", "true")
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("envelope_types")).To(Equal("LOG"))
+			Expect(string(writer.bytes)).To(HavePrefix(`{"schema_version":1,"batch":[`))
+		})
+
+		It("prefers flags given on the command line", func() {
+			os.Setenv("LOG_CACHE_CLI_TAIL_ENVELOPE_TYPE", "log")
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--envelope-type", "any", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			requestURL, err := url.Parse(httpClient.requestURLs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requestURL.Query().Get("envelope_types")).To(Equal("ANY"))
+		})
+	})
+
+	Context("when pretty printing JSON", func() {
+		BeforeEach(func() {
+			cliConn.cliCommandResult = [][]string{{"app-guid"}}
+			httpClient.responseBody = []string{
+				logResponseBody(
+					startTime,
+					`{"level":"info","count":2,"ok":true,"tags":["a"]}`,
+					"plain text",
+				),
+			}
+		})
+
+		It("indents JSON payloads", func() {
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--pretty-json", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT {", startTime.Format(timeFormat)),
+				`  "level": "info",`,
+				`  "count": 2,`,
+				`  "ok": true,`,
+				`  "tags": [`,
+				`    "a"`,
+				`  ]`,
+				`}`,
+				fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT plain text", startTime.Add(time.Second).Format(timeFormat)),
+			}))
+		})
+
+		It("keeps JSON payloads on one line when following", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, `{ "level": "info",  "count": 2 }`),
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			cf.Tail(
+				ctx,
+				cliConn,
+				[]string{"--pretty-json", "--follow", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(`   %s [APP/PROC/WEB/0] OUT {"level":"info","count":2}`, startTime.Format(timeFormat)),
+			}))
 		})
 
-		It("fatally logs if counter-name and gauge-name flags are both set", func() {
-			args := []string{
-				"--counter-name", "some-name",
-				"--gauge-name", "some-name",
-				"some-app",
+		It("highlights JSON payloads when color is enabled", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, `{"level":"info","count":-2.5,"ok":null}`),
 			}
-			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
-			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("--counter-name cannot be used with --gauge-name"))
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			cf.Tail(
+				ctx,
+				cliConn,
+				[]string{"--pretty-json", "--follow", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailColor(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(
+					"   %s [APP/PROC/WEB/0] OUT {\x1b[36m\"level\"\x1b[0m:\x1b[32m\"info\"\x1b[0m,\x1b[36m\"count\"\x1b[0m:\x1b[33m-2.5\x1b[0m,\x1b[36m\"ok\"\x1b[0m:\x1b[35mnull\x1b[0m}",
+					startTime.Format(timeFormat),
+				),
+			}))
 		})
 
-		It("fatally logs if output-format and json flags are given", func() {
-			httpClient.responseBody = []string{responseBody(time.Unix(0, 1))}
-			args := []string{
-				"--output-format", `{{.Timestamp}} {{printf "%s" .GetLog.GetPayload}}`,
-				"--json",
-				"app-guid",
+		It("does not color JSON payloads with --accessible", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, `{"level":"info"}`),
 			}
 
-			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
-			}).To(Panic())
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
 
-			Expect(logger.fatalfMessage).To(Equal("Cannot use output-format and json flags together"))
+			cf.Tail(
+				ctx,
+				cliConn,
+				[]string{"--pretty-json", "--follow", "--accessible", "app-name"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+				cf.WithTailColor(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf(`   %s [APP/PROC/WEB/0] OUT {"level":"info"}`, startTime.Format(timeFormat)),
+			}))
 		})
 
-		It("fatally logs if an output-format is malformed", func() {
-			args := []string{"--output-format", "{{INVALID}}", "app-guid"}
+		It("fatally logs when used with --json", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					args,
+					[]string{"--pretty-json", "--json", "app-name"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal(`template: OutputFormat:1: function "INVALID" not defined`))
+			Expect(logger.fatalfMessage).To(Equal("--pretty-json cannot be used with --table-fields, --json or --output-format"))
 		})
+	})
 
-		It("fatally logs if an output-format won't execute", func() {
-			httpClient.responseBody = []string{`{"envelopes":{"batch":[{"source_id": "a", "timestamp": 1},{"source_id":"b", "timestamp":2}]}}`}
-			args := []string{
-				"--output-format", "{{.invalid 9}}",
-				"app-guid",
+	Context("when given a source pattern", func() {
+		It("tails every matching source", func() {
+			httpClient.responseBody = []string{
+				metaResponseInfo("router-b", "doppler", "router-a"),
+				logResponseBody(startTime, "a 1"),
+				logResponseBody(startTime.Add(500*time.Millisecond), "b 1"),
 			}
 
-			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
-			}).To(Panic())
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"router*"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
 
-			Expect(logger.fatalfMessage).To(Equal(`Output template parsed, but failed to execute: template: OutputFormat:1:2: executing "OutputFormat" at <.invalid>: can't evaluate field invalid in type *loggregator_v2.Envelope`))
+			Expect(cliConn.cliCommandArgs).To(BeEmpty())
+			Expect(httpClient.requestURLs).To(HaveLen(3))
+			Expect(httpClient.requestURLs[0]).To(HaveSuffix("/v1/meta"))
+			Expect(httpClient.requestURLs[1]).To(ContainSubstring("/v1/read/router-a"))
+			Expect(httpClient.requestURLs[2]).To(ContainSubstring("/v1/read/router-b"))
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("[router-a] %s [APP/PROC/WEB/0] OUT a 1", startTime.Format(timeFormat)),
+				fmt.Sprintf("[router-b] %s [APP/PROC/WEB/0] OUT b 1", startTime.Add(500*time.Millisecond).Format(timeFormat)),
+			}))
 		})
 
-		It("fatally logs if lines is greater than 1000", func() {
-			args := []string{
-				"--lines", "1001",
-				"some-app",
+		It("excludes sources matching --exclude-source", func() {
+			httpClient.responseBody = []string{
+				metaResponseInfo("router-b", "router-a"),
+				logResponseBody(startTime, "a 1"),
 			}
-			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
-			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("Lines cannot be greater than 1000."))
-		})
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--exclude-source", "b$", "router*"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
 
-		It("accepts 0 for --lines", func() {
-			args := []string{
-				"--lines", "0",
-				"some-app",
-			}
-			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
-			}).ToNot(Panic())
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			Expect(httpClient.requestURLs[1]).To(ContainSubstring("/v1/read/router-a"))
 		})
 
-		It("fatally logs if username cannot be fetched", func() {
-			cliConn.usernameErr = errors.New("unknown user")
-			args := []string{"app-name"}
-
+		It("fatally logs when --exclude-source is used with a single source", func() {
 			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
+				cf.Tail(context.Background(), cliConn, []string{"--exclude-source", "x", "app-name"}, httpClient, logger, writer)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("unknown user"))
+			Expect(logger.fatalfMessage).To(Equal("--exclude-source can only be used with --space or a source pattern"))
 		})
 
-		It("fatally logs if org name cannot be fetched", func() {
-			cliConn.orgErr = errors.New("Organization could not be fetched")
-			args := []string{"app-name"}
+		It("fatally logs when no source matches", func() {
+			httpClient.responseBody = []string{metaResponseInfo("doppler")}
 
 			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
+				cf.Tail(context.Background(), cliConn, []string{"router*"}, httpClient, logger, writer)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("Organization could not be fetched"))
+			Expect(logger.fatalfMessage).To(Equal("No sources match router*."))
 		})
 
-		It("fatally logs if space cannot be fetched", func() {
-			cliConn.spaceErr = errors.New("unknown space")
-			args := []string{"app-name"}
-
+		It("fatally logs when used with --json", func() {
 			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
+				cf.Tail(context.Background(), cliConn, []string{"--json", "router*"}, httpClient, logger, writer)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("unknown space"))
+			Expect(logger.fatalfMessage).To(Equal("A source pattern cannot be used with --json, --output-format, --table-fields or --mark-deploys"))
 		})
+	})
 
-		It("fatally logs if the start > end", func() {
-			args := []string{"--start-time", "1000", "--end-time", "100", "app-name"}
-			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					args,
-					httpClient,
-					logger,
-					writer,
-				)
-			}).To(Panic())
+	Context("when tailing a space", func() {
+		BeforeEach(func() {
+			cliConn.usernameResp = "a-user"
+			cliConn.orgName = "organization"
+			cliConn.spaceName = "space"
+			cliConn.spaceGUID = "space-guid"
+			cliConn.cliCommandResult = [][]string{{`{"resources": [
+				{"guid": "alpha-guid", "name": "alpha"},
+				{"guid": "beta-guid", "name": "beta-app"}
+			]}`}}
+		})
 
-			Expect(logger.fatalfMessage).To(Equal("Invalid date/time range. Ensure your start time is prior or equal the end time."))
+		It("merges the most recent envelopes of every app", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "alpha 1", "alpha 2"),
+				logResponseBody(startTime.Add(500*time.Millisecond), "beta 1"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--space", "--lines", "2"},
+				httpClient,
+				logger,
+				writer,
+			)
+
+			Expect(cliConn.cliCommandArgs[0]).To(Equal([]string{
+				"curl",
+				"/v3/apps?space_guids=space-guid&order_by=name&per_page=5000",
+			}))
+			Expect(httpClient.requestURLs).To(HaveLen(2))
+			Expect(httpClient.requestURLs[0]).To(ContainSubstring("/v1/read/alpha-guid"))
+			Expect(httpClient.requestURLs[1]).To(ContainSubstring("/v1/read/beta-guid"))
+
+			Expect(writer.lines()).To(Equal([]string{
+				"Retrieving logs for apps in org organization / space space as a-user...",
+				"",
+				fmt.Sprintf("[beta-app] %s [APP/PROC/WEB/0] OUT beta 1", startTime.Add(500*time.Millisecond).Format(timeFormat)),
+				fmt.Sprintf("[alpha]    %s [APP/PROC/WEB/0] OUT alpha 2", startTime.Add(time.Second).Format(timeFormat)),
+			}))
 		})
 
-		It("fatally logs if too many arguments are given", func() {
-			Expect(func() {
-				cf.Tail(
-					context.Background(),
-					cliConn,
-					[]string{"one", "two"},
-					httpClient,
-					logger,
-					writer,
-				)
-			}).To(Panic())
+		It("excludes apps matching --exclude-source", func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "alpha 1"),
+			}
+
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--space", "--exclude-source", "^beta"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
 
-			Expect(logger.fatalfMessage).To(Equal("Expected 1 argument, got 2."))
+			Expect(httpClient.requestURLs).To(HaveLen(1))
+			Expect(httpClient.requestURLs[0]).To(ContainSubstring("/v1/read/alpha-guid"))
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("[alpha] %s [APP/PROC/WEB/0] OUT alpha 1", startTime.Format(timeFormat)),
+			}))
 		})
 
-		It("fatally logs if not enough arguments are given", func() {
+		It("fatally logs when every app is excluded", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					[]string{},
+					[]string{"--space", "--exclude-source", "."},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("Expected 1 argument, got 0."))
+			Expect(logger.fatalfMessage).To(Equal("Every app in space space is excluded by --exclude-source."))
 		})
 
-		It("fatally logs if there is an error while getting API endpoint", func() {
-			cliConn.apiEndpointErr = errors.New("some-error")
+		It("follows the apps", func() {
+			cliConn.cliCommandResult = [][]string{{`{"resources": [
+				{"guid": "alpha-guid", "name": "alpha"}
+			]}`}}
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "alpha 1"),
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			cf.Tail(
+				ctx,
+				cliConn,
+				[]string{"--space", "--follow", "--lines", "0"},
+				httpClient,
+				logger,
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+
+			Expect(writer.lines()).To(Equal([]string{
+				fmt.Sprintf("[alpha] %s [APP/PROC/WEB/0] OUT alpha 1", startTime.Format(timeFormat)),
+			}))
+		})
 
+		It("fatally logs when given an app", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					[]string{"app-name"},
+					[]string{"--space", "app-name"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("some-error"))
+			Expect(logger.fatalfMessage).To(Equal("--space cannot be used with an app or source argument"))
 		})
 
-		It("fatally logs if there is no API endpoint", func() {
-			cliConn.hasAPIEndpoint = false
-
+		It("fatally logs when used with --json", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					[]string{"app-name"},
+					[]string{"--space", "--json"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("No API endpoint targeted."))
+			Expect(logger.fatalfMessage).To(Equal("--space cannot be used with --json, --output-format or --mark-deploys"))
 		})
 
-		It("fatally logs if there is an error while checking for API endpoint", func() {
-			cliConn.hasAPIEndpoint = true
-			cliConn.hasAPIEndpointErr = errors.New("some-error")
+		It("fatally logs when the space has no apps", func() {
+			cliConn.cliCommandResult = [][]string{{`{"resources": []}`}}
 
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					[]string{"app-name"},
+					[]string{"--space"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("some-error"))
+			Expect(logger.fatalfMessage).To(Equal("No apps found in space space."))
 		})
+	})
 
-		It("fatally logs if the request returns an error", func() {
-			httpClient.responseErr = errors.New("some-error")
+	Context("when alerting on matches", func() {
+		follow := func(args []string, opts ...cf.TailOption) {
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			cf.Tail(
+				ctx,
+				cliConn,
+				append([]string{"--follow"}, args...),
+				httpClient,
+				logger,
+				writer,
+				append([]cf.TailOption{cf.WithTailNoHeaders()}, opts...)...,
+			)
+		}
+
+		BeforeEach(func() {
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "all good", "boom", "boom again"),
+			}
+		})
+
+		It("rings the bell once for a burst of matching lines", func() {
+			follow([]string{"--lines", "0", "--alert-on", "boom", "app-name"})
+
+			Expect(strings.Count(string(writer.bytes), "\a")).To(Equal(1))
+			Expect(strings.Replace(string(writer.bytes), "\a", "", -1)).To(ContainSubstring("OUT boom again"))
+		})
+
+		It("does not alert for lines printed before following", func() {
+			follow([]string{"--alert-on", "boom", "app-name"})
+
+			Expect(writer.lines()).To(HaveLen(3))
+			Expect(string(writer.bytes)).ToNot(ContainSubstring("\a"))
+		})
+
+		It("shows a desktop notification", func() {
+			var titles, messages []string
+			notifier := func(title, message string) error {
+				titles = append(titles, title)
+				messages = append(messages, message)
+				return nil
+			}
 
+			follow(
+				[]string{"--lines", "0", "--alert-on", "b..m", "--alert", "notify", "app-name"},
+				cf.WithTailNotifier(notifier),
+			)
+
+			Expect(titles).To(Equal([]string{"cf tail app-name"}))
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0]).To(MatchRegexp(`\[APP/PROC/WEB/0\] OUT boom`))
+			Expect(string(writer.bytes)).ToNot(ContainSubstring("\a"))
+		})
+
+		It("logs when desktop notifications fail", func() {
+			notifier := func(title, message string) error {
+				return errors.New("notify-send not found")
+			}
+
+			follow(
+				[]string{"--lines", "0", "--alert-on", "boom", "--alert", "notify", "app-name"},
+				cf.WithTailNotifier(notifier),
+			)
+
+			Expect(logger.printfMessages).To(ContainElement("Could not show desktop notification: notify-send not found"))
+		})
+
+		It("fatally logs when used without --follow", func() {
 			Expect(func() {
 				cf.Tail(
 					context.Background(),
 					cliConn,
-					[]string{"app-name"},
+					[]string{"--alert-on", "boom", "app-name"},
 					httpClient,
 					logger,
 					writer,
 				)
 			}).To(Panic())
 
-			Expect(logger.fatalfMessage).To(Equal("some-error"))
+			Expect(logger.fatalfMessage).To(Equal("--alert-on can only be used with --follow"))
 		})
-	})
 
-	Context("when the source is a service", func() {
-		BeforeEach(func() {
-			cliConn.usernameResp = "a-user"
-			cliConn.orgName = "organization"
-			cliConn.spaceName = "space"
+		It("fatally logs for an unknown alert", func() {
+			Expect(func() {
+				follow([]string{"--alert-on", "boom", "--alert", "siren", "app-name"})
+			}).To(Panic())
 
-			cliConn.cliCommandResult = [][]string{{""}, {"service-guid"}}
+			Expect(logger.fatalfMessage).To(Equal("--alert must be 'bell' or 'notify'"))
+		})
 
-			httpClient.responseBody = []string{gaugeResponseBody(startTime)}
+		It("fatally logs for an invalid pattern", func() {
+			Expect(func() {
+				follow([]string{"--alert-on", "(", "app-name"})
+			}).To(Panic())
 
+			Expect(logger.fatalfMessage).To(HavePrefix("Invalid --alert-on pattern: "))
 		})
+	})
 
-		It("reports successful results", func() {
-			cliConn.cliCommandResult = [][]string{
-				{""},
-				{"service-guid"},
+	Context("when using interactive controls", func() {
+		var (
+			keys     *io.PipeWriter
+			controls cf.TailOption
+			gated    *gatedHTTPClient
+		)
+
+		BeforeEach(func() {
+			var in *io.PipeReader
+			in, keys = io.Pipe()
+			controls = cf.WithTailControls(in, nil)
+			gated = &gatedHTTPClient{c: httpClient, gate: make(chan struct{}), second: make(chan struct{})}
+
+			httpClient.responseBody = []string{
+				logResponseBody(startTime, "first OUT", "second", "third OUT"),
 			}
-			args := []string{"service-name"}
+		})
+
+		AfterEach(func() {
+			keys.Close()
+		})
+
+		tail := func(keyPresses string, opts ...cf.TailOption) {
+			go func() {
+				defer close(gated.gate)
+				keys.Write([]byte(keyPresses))
+				// Ignored key, written once the previous keys are handled.
+				keys.Write([]byte("x"))
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
 			cf.Tail(
-				context.Background(),
+				ctx,
 				cliConn,
-				args,
-				httpClient,
+				[]string{"--follow", "app-name"},
+				gated,
 				logger,
 				writer,
+				append([]cf.TailOption{cf.WithTailNoHeaders(), controls}, opts...)...,
 			)
+		}
+
+		It("buffers lines while paused", func() {
+			tail(" ")
 
-			logFormat := "   %s [%s/%s] GAUGE %s:%f %s %s:%f %s"
 			Expect(writer.lines()).To(Equal([]string{
-				fmt.Sprintf(
-					"Retrieving logs for service %s in org %s / space %s as %s...",
-					"service-name",
-					cliConn.orgName,
-					cliConn.spaceName,
-					cliConn.usernameResp,
-				),
-				"",
-				fmt.Sprintf(logFormat, startTime.Format(timeFormat), "service-name", "0", "some-name", 99.0, "my-unit", "some-other-name", 101.0, "my-unit"),
+				"--- paused, press space to resume ---",
 			}))
 		})
 
-		It("requests the service guid when app --guid fails", func() {
-			cliConn.cliCommandResult = [][]string{{"not", "an", "app"}, {"service-guid"}}
-			cliConn.cliCommandErr = []error{errors.New("catch this instead")}
+		It("writes buffered lines when resumed", func() {
+			tail("  ")
 
-			args := []string{"app-name"}
-			cf.Tail(
-				context.Background(),
-				cliConn,
-				args,
-				httpClient,
-				logger,
-				writer,
-			)
+			Expect(writer.lines()).To(Equal([]string{
+				"--- paused, press space to resume ---",
+				"--- resumed ---",
+				fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT first OUT", startTime.Format(timeFormat)),
+				fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT second", startTime.Add(time.Second).Format(timeFormat)),
+				fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT third OUT", startTime.Add(2*time.Second).Format(timeFormat)),
+			}))
+		})
+
+		It("marks lines matching the highlight pattern", func() {
+			tail("/OUT$\n")
 
-			logFormat := "   %s [%s/%s] GAUGE %s:%f %s %s:%f %s"
 			Expect(writer.lines()).To(Equal([]string{
-				fmt.Sprintf(
-					"Retrieving logs for service %s in org %s / space %s as %s...",
-					"app-name",
-					cliConn.orgName,
-					cliConn.spaceName,
-					cliConn.usernameResp,
-				),
-				"",
-				fmt.Sprintf(logFormat, startTime.Format(timeFormat), "app-name", "0", "some-name", 99.0, "my-unit", "some-other-name", 101.0, "my-unit"),
+				"--- type a highlight pattern and press enter, escape to cancel ---",
+				`--- highlighting "OUT$" ---`,
+				fmt.Sprintf("**    %s [APP/PROC/WEB/0] OUT first OUT", startTime.Format(timeFormat)),
+				fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT second", startTime.Add(time.Second).Format(timeFormat)),
+				fmt.Sprintf("**    %s [APP/PROC/WEB/0] OUT third OUT", startTime.Add(2*time.Second).Format(timeFormat)),
 			}))
+		})
 
-			Expect(logger.printfMessages).To(ContainElement("catch this instead"))
+		It("colors highlighted matches when color is enabled", func() {
+			tail("/first\r", cf.WithTailColor())
+
+			Expect(writer.lines()).To(ContainElement(
+				fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT \x1b[7mfirst\x1b[27m OUT", startTime.Format(timeFormat)),
+			))
 		})
 
-		It("calls the log cache api", func() {
-			args := []string{"service-name"}
-			cf.Tail(
-				context.Background(),
-				cliConn,
-				args,
-				httpClient,
-				logger,
-				writer,
-			)
+		It("supports editing and cancelling the highlight pattern", func() {
+			tail("/seco\x7fnd\x1b")
 
-			Expect(httpClient.requestURLs).To(HaveLen(1))
+			Expect(writer.lines()[:2]).To(Equal([]string{
+				"--- type a highlight pattern and press enter, escape to cancel ---",
+				"--- highlight unchanged ---",
+			}))
+			Expect(writer.lines()).ToNot(ContainElement(HavePrefix("**")))
+		})
 
-			requestURL, err := url.Parse(httpClient.requestURLs[0])
-			Expect(err).ToNot(HaveOccurred())
+		It("reports an invalid highlight pattern", func() {
+			tail("/(\n")
 
-			end, err := strconv.ParseInt(requestURL.Query().Get("end_time"), 10, 64)
-			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.lines()[1]).To(HavePrefix(`--- invalid highlight "(": `))
+			Expect(writer.lines()).ToNot(ContainElement(HavePrefix("**")))
+		})
 
-			Expect(end).To(BeNumerically("~", time.Now().UnixNano(), 10000000))
+		It("prints stats", func() {
+			tail(" s")
+
+			Expect(writer.lines()).To(HaveLen(2))
+			Expect(writer.lines()[1]).To(MatchRegexp(
+				`^--- 0 lines in 0s \(0\.0/s\), 0 buffered, highlight: none ---$`,
+			))
 		})
 
-		It("requests the service guid", func() {
-			args := []string{"some-service"}
-			cf.Tail(
-				context.Background(),
-				cliConn,
-				args,
-				httpClient,
-				logger,
-				writer,
-			)
+		It("restores the terminal when done", func() {
+			var restored bool
+			controls = cf.WithTailControls(strings.NewReader(""), func() (func(), error) {
+				return func() { restored = true }, nil
+			})
+			close(gated.gate)
 
-			Expect(cliConn.cliCommandArgs).To(HaveLen(2))
-			Expect(cliConn.cliCommandArgs[1]).To(HaveLen(3))
-			Expect(cliConn.cliCommandArgs[1][0]).To(Equal("service"))
-			Expect(cliConn.cliCommandArgs[1][1]).To(Equal("some-service"))
-			Expect(cliConn.cliCommandArgs[1][2]).To(Equal("--guid"))
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			cf.Tail(ctx, cliConn, []string{"--follow", "app-name"}, gated, logger, writer, controls)
+
+			Expect(restored).To(BeTrue())
 		})
 
-	})
+		It("raises alerts for lines held back while paused once they are shown", func() {
+			httpClient.responseBody = []string{logResponseBody(startTime, "boom")}
 
-	Context("when the source is a component", func() {
-		BeforeEach(func() {
-			cliConn.usernameResp = "a-user"
-			httpClient.responseBody = []string{counterResponseBody(startTime)}
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			// Ignored keys are written once the previous keys are handled.
+			// Following resumes once the first read was written.
+			pressed := make(chan struct{})
+			go func() {
+				defer close(pressed)
+				keys.Write([]byte(" "))
+				keys.Write([]byte("x"))
+				close(gated.gate)
+
+				select {
+				case <-gated.second:
+				case <-ctx.Done():
+					return
+				}
+				keys.Write([]byte(" "))
+				keys.Write([]byte("x"))
+			}()
+
+			cf.Tail(ctx, cliConn, []string{"--follow", "--lines", "0", "--alert-on", "boom", "app-name"}, gated, logger, writer, cf.WithTailNoHeaders(), controls)
+			keys.Close()
+			<-pressed
+
+			output := string(writer.bytes)
+			Expect(strings.Count(output, "\a")).To(Equal(1))
+			Expect(strings.Index(output, "\a")).To(BeNumerically(">", strings.Index(output, "--- resumed ---")))
 		})
 
-		It("requests as a source id", func() {
-			cliConn.cliCommandResult = [][]string{{""}, {""}}
-			cliConn.cliCommandErr = []error{errors.New("app not found"), errors.New("service not found")}
+		It("restores the terminal before a fatal log", func() {
+			var restoredBeforeFatal, restored bool
+			controls = cf.WithTailControls(strings.NewReader(""), func() (func(), error) {
+				return func() {
+					if !restored {
+						restored = true
+						restoredBeforeFatal = logger.fatalfMessage == ""
+					}
+				}, nil
+			})
+			httpClient.responseBody = []string{metaResponseInfo("doppler")}
+			close(gated.gate)
 
-			args := []string{"app-name"}
-			cf.Tail(
-				context.Background(),
-				cliConn,
-				args,
-				httpClient,
-				logger,
-				writer,
-			)
+			Expect(func() {
+				cf.Tail(context.Background(), cliConn, []string{"--follow", "router*"}, gated, logger, writer, controls)
+			}).To(Panic())
 
-			Expect(httpClient.requestURLs).To(HaveLen(1))
+			Expect(logger.fatalfMessage).To(Equal("No sources match router*."))
+			Expect(restoredBeforeFatal).To(BeTrue())
+		})
 
-			requestURL, err := url.Parse(httpClient.requestURLs[0])
-			end, err := strconv.ParseInt(requestURL.Query().Get("end_time"), 10, 64)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(end).To(BeNumerically("~", time.Now().UnixNano(), 10000000))
+		It("drops the oldest lines when too many are buffered while paused", func() {
+			payloads := make([]string, 10002)
+			for i := range payloads {
+				payloads[i] = fmt.Sprintf("line %d", i)
+			}
+			// Walk skips envelopes from the future.
+			httpClient.responseBody = []string{logResponseBody(startTime.Add(-3*time.Hour), payloads...)}
 
-			Expect(requestURL.Path).To(Equal("/v1/read/app-name"))
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
 
-			counterFormat := "   %s [%s/%s] COUNTER %s:%d"
-			Expect(writer.lines()).To(Equal([]string{
-				fmt.Sprintf(
-					"Retrieving logs for source %s as %s...",
-					"app-name",
-					cliConn.usernameResp,
-				),
-				"",
-				fmt.Sprintf(counterFormat, startTime.Format(timeFormat), "app-name", "0", "some-name", 99),
-			}))
+			// Ignored keys are written once the previous keys are handled.
+			// Following resumes once the first read was written and stops
+			// once the buffered lines are shown.
+			pressed := make(chan struct{})
+			go func() {
+				defer close(pressed)
+				keys.Write([]byte(" "))
+				keys.Write([]byte("x"))
+				close(gated.gate)
+
+				select {
+				case <-gated.second:
+				case <-ctx.Done():
+					return
+				}
+				keys.Write([]byte(" "))
+				keys.Write([]byte("x"))
+				cancel()
+			}()
 
-			Expect(logger.printfMessages).To(ContainElement("app not found"))
-			Expect(logger.printfMessages).To(ContainElement("service not found"))
+			cf.Tail(ctx, cliConn, []string{"--follow", "--lines", "0", "app-name"}, gated, logger, writer, cf.WithTailNoHeaders(), controls)
+			keys.Close()
+			<-pressed
+
+			lines := writer.lines()
+			Expect(lines).To(HaveLen(10003))
+			Expect(lines[:3]).To(Equal([]string{
+				"--- paused, press space to resume ---",
+				"--- resumed ---",
+				"--- 2 older lines were dropped while paused ---",
+			}))
+			// Walk gets the envelopes newest first.
+			Expect(lines[3]).To(HaveSuffix("OUT line 9999"))
+			Expect(lines[10002]).To(HaveSuffix("OUT line 0"))
 		})
 
-		It("uses the LOG_CACHE_ADDR environment variable", func() {
-			os.Setenv("LOG_CACHE_ADDR", "https://different-log-cache:8080")
-			defer os.Unsetenv("LOG_CACHE_ADDR")
+		It("follows without controls when the terminal cannot be set up", func() {
+			controls = cf.WithTailControls(strings.NewReader(""), func() (func(), error) {
+				return nil, errors.New("not a terminal")
+			})
+			close(gated.gate)
 
-			cliConn.cliCommandResult = [][]string{{""}, {""}}
-			cliConn.cliCommandErr = []error{errors.New("app not found"), errors.New("service not found")}
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
 
-			cf.Tail(
-				context.Background(),
-				cliConn,
-				[]string{"app-name"},
-				httpClient,
-				logger,
-				writer,
-			)
-			Expect(httpClient.requestURLs).To(HaveLen(1))
+			cf.Tail(ctx, cliConn, []string{"--follow", "app-name"}, gated, logger, writer, cf.WithTailNoHeaders(), controls)
 
-			u, err := url.Parse(httpClient.requestURLs[0])
-			Expect(err).ToNot(HaveOccurred())
-			Expect(u.Scheme).To(Equal("https"))
-			Expect(u.Host).To(Equal("different-log-cache:8080"))
-			Expect(u.Path).To(ContainSubstring("app-name"))
+			Expect(logger.printfMessages).To(ContainElement("Interactive controls are unavailable: not a terminal"))
+			Expect(writer.lines()).To(HaveLen(3))
 		})
 	})
 })
@@ -1712,6 +3941,13 @@ func counterResponseBody(startTime time.Time) string {
 	)
 }
 
+func evictedMetaResponse(sourceID string, oldest time.Time) string {
+	return fmt.Sprintf(
+		`{"meta": {%q: {"count": "100", "expired": "50", "oldestTimestamp": "%d", "newestTimestamp": "%d"}}}`,
+		sourceID, oldest.UnixNano(), oldest.Add(time.Minute).UnixNano(),
+	)
+}
+
 func gaugeResponseBody(startTime time.Time) string {
 	return fmt.Sprintf(gaugeResponseTemplate,
 		startTime.UnixNano(),
@@ -1746,6 +3982,74 @@ func mixedResponseBodyNoInstanceId(startTime time.Time) string {
 	)
 }
 
+// logResponseBody returns a response with a log envelope for each payload.
+// The envelopes are one second apart and in descending order so they are
+// printed in the order given.
+func logResponseBody(startTime time.Time, payloads ...string) string {
+	var envelopes []string
+	for i := len(payloads) - 1; i >= 0; i-- {
+		envelopes = append(envelopes, fmt.Sprintf(`{
+			"timestamp":"%d",
+			"source_id": "app-name",
+			"instance_id":"0",
+			"tags":{
+				"source_type":"APP/PROC/WEB"
+			},
+			"log":{
+				"payload":%q
+			}
+		}`, startTime.Add(time.Duration(i)*time.Second).UnixNano(), base64.StdEncoding.EncodeToString([]byte(payloads[i]))))
+	}
+
+	return fmt.Sprintf(`{"envelopes":{"batch":[%s]}}`, strings.Join(envelopes, ","))
+}
+
+func capiEvent(guid, eventType, actor string, timestamp time.Time) string {
+	return fmt.Sprintf(`{
+		"metadata": {"guid": %q},
+		"entity": {
+			"type": %q,
+			"actor_name": %q,
+			"timestamp": %q,
+			"metadata": {}
+		}
+	}`, guid, eventType, actor, timestamp.UTC().Format(time.RFC3339))
+}
+
+func capiAuditEvent(guid, eventType, actor string, timestamp time.Time) string {
+	return fmt.Sprintf(`{
+		"guid": %q,
+		"type": %q,
+		"actor": {"type": "user", "name": %q},
+		"created_at": %q,
+		"data": {}
+	}`, guid, eventType, actor, timestamp.UTC().Format(time.RFC3339))
+}
+
+func capiEventsResponse(events ...string) string {
+	return fmt.Sprintf(`{"resources": [%s]}`, strings.Join(events, ","))
+}
+
+func writeScrubFile(rules string) *cf.Scrubber {
+	f, err := ioutil.TempFile("", "scrub")
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+
+	_, err = f.WriteString(rules)
+	Expect(err).ToNot(HaveOccurred())
+
+	s, err := cf.LoadScrubber(f.Name())
+	Expect(err).ToNot(HaveOccurred())
+
+	return s
+}
+
+// emptyMetaResponseBody answers the meta request of the retention check
+// for sources Log Cache knows nothing about.
+func emptyMetaResponseBody() string {
+	return `{"meta": {}}`
+}
+
 func emptyResponseBody() string {
 	return `{ "envelopes": { "batch": [] } }`
 }
@@ -2021,3 +4325,30 @@ var mixedResponseTemplate = `{
 		]
 	}
 }`
+
+// gatedHTTPClient blocks requests until the gate is closed. The second
+// channel is closed when the second read of envelopes is made, after the
+// first one was handled.
+type gatedHTTPClient struct {
+	c      cf.HTTPClient
+	gate   chan struct{}
+	second chan struct{}
+
+	mu    sync.Mutex
+	reads int
+}
+
+func (g *gatedHTTPClient) Do(r *http.Request) (*http.Response, error) {
+	<-g.gate
+
+	if strings.HasPrefix(r.URL.Path, "/v1/read/") {
+		g.mu.Lock()
+		g.reads++
+		if g.reads == 2 && g.second != nil {
+			close(g.second)
+		}
+		g.mu.Unlock()
+	}
+
+	return g.c.Do(r)
+}
@@ -0,0 +1,277 @@
+package cf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const telemetrySpoolFile = "telemetry.jsonl"
+
+// Error categories recorded with usage records. Error messages themselves
+// are never recorded.
+const (
+	errorCategoryUsage    = "usage"
+	errorCategoryAuth     = "auth"
+	errorCategoryNotFound = "not_found"
+	errorCategoryNetwork  = "network"
+	errorCategoryOther    = "other"
+)
+
+type usageRecord struct {
+	Command    string `json:"command"`
+	Date       string `json:"date"`
+	DurationMS int64  `json:"duration_ms"`
+	Envelopes  int64  `json:"envelopes"`
+	Error      string `json:"error,omitempty"`
+}
+
+// UsageRecorder records an anonymous usage record for a single command
+// invocation and appends it to the telemetry spool file.
+type UsageRecorder struct {
+	spool   string
+	command string
+	start   time.Time
+
+	mu        sync.Mutex
+	envelopes int64
+	category  string
+	finished  bool
+}
+
+// NewUsageRecorder starts recording the invocation of the given command.
+func NewUsageRecorder(spool, command string) *UsageRecorder {
+	return &UsageRecorder{
+		spool:   spool,
+		command: command,
+		start:   time.Now(),
+	}
+}
+
+// HTTPClient wraps the given client to count the envelopes read from Log
+// Cache.
+func (r *UsageRecorder) HTTPClient(c HTTPClient) HTTPClient {
+	return &countingHTTPClient{c: c, r: r}
+}
+
+// Logger wraps the given logger to record the category of fatal errors.
+// The record is written before the wrapped logger exits.
+func (r *UsageRecorder) Logger(l Logger) Logger {
+	return &usageLogger{Logger: l, r: r}
+}
+
+// Finish appends the usage record to the spool file. Only the first call
+// writes a record.
+func (r *UsageRecorder) Finish() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.finished {
+		return nil
+	}
+	r.finished = true
+
+	record, err := json.Marshal(usageRecord{
+		Command:    r.command,
+		Date:       r.start.UTC().Format("2006-01-02"),
+		DurationMS: int64(time.Since(r.start) / time.Millisecond),
+		Envelopes:  r.envelopes,
+		Error:      r.category,
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(r.spool, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(record, '\n'))
+	return err
+}
+
+func (r *UsageRecorder) addEnvelopes(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.envelopes += int64(n)
+}
+
+func (r *UsageRecorder) setErrorCategory(category string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.category = category
+}
+
+type countingHTTPClient struct {
+	c HTTPClient
+	r *UsageRecorder
+}
+
+func (c *countingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.c.Do(req)
+	if err != nil || !strings.Contains(req.URL.Path, "/read/") {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var r struct {
+		Envelopes struct {
+			Batch []json.RawMessage `json:"batch"`
+		} `json:"envelopes"`
+	}
+	if json.Unmarshal(body, &r) == nil {
+		c.r.addEnvelopes(len(r.Envelopes.Batch))
+	}
+
+	return resp, nil
+}
+
+type usageLogger struct {
+	Logger
+	r *UsageRecorder
+}
+
+func (l *usageLogger) Fatalf(format string, args ...interface{}) {
+	l.r.setErrorCategory(errorCategory(fmt.Sprintf(format, args...)))
+	l.r.Finish()
+	l.Logger.Fatalf(format, args...)
+}
+
+// errorCategory maps an error message to a coarse category.
+func errorCategory(msg string) string {
+	m := strings.ToLower(msg)
+
+	switch {
+	case strings.Contains(m, "flag") ||
+		strings.Contains(m, "argument") ||
+		strings.Contains(m, "cannot be used") ||
+		strings.Contains(m, "must be") ||
+		strings.Contains(m, "is required") ||
+		strings.Contains(m, "invalid"):
+		return errorCategoryUsage
+	case strings.Contains(m, "token") ||
+		strings.Contains(m, "unauthorized") ||
+		strings.Contains(m, "forbidden") ||
+		strings.Contains(m, "401") ||
+		strings.Contains(m, "403"):
+		return errorCategoryAuth
+	case strings.Contains(m, "not found"):
+		return errorCategoryNotFound
+	case strings.Contains(m, "dial") ||
+		strings.Contains(m, "timeout") ||
+		strings.Contains(m, "connection") ||
+		strings.Contains(m, "endpoint"):
+		return errorCategoryNetwork
+	default:
+		return errorCategoryOther
+	}
+}
+
+type telemetryOptionFlags struct {
+	Flush bool `long:"flush"`
+}
+
+// Telemetry reports the number of spooled usage records. With --flush the
+// records are posted to the configured endpoint and removed from the
+// spool.
+func Telemetry(args []string, conf Config, c HTTPClient, log Logger, w io.Writer) {
+	o := telemetryOptionFlags{}
+
+	args, err := parseFlags("log-telemetry", &o, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) > 0 {
+		log.Fatalf("Invalid arguments, expected 0, got %d.", len(args))
+	}
+
+	if !conf.Telemetry {
+		log.Fatalf("Telemetry is disabled. Set 'telemetry: true' in ~/.log-cache-cli/config.yml to enable it.")
+	}
+
+	records, err := readUsageRecords(conf.TelemetrySpool)
+	if err != nil {
+		log.Fatalf("Could not read telemetry spool: %s", err)
+	}
+
+	if !o.Flush {
+		fmt.Fprintf(w, "%d usage records spooled in %s.\n", len(records), conf.TelemetrySpool)
+		return
+	}
+
+	if conf.TelemetryEndpoint == "" {
+		log.Fatalf("--flush requires 'telemetry_endpoint' in ~/.log-cache-cli/config.yml.")
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintf(w, "No usage records to flush.\n")
+		return
+	}
+
+	body, err := json.Marshal(records)
+	if err != nil {
+		log.Fatalf("Could not encode usage records: %s", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, conf.TelemetryEndpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Fatalf("Invalid telemetry endpoint: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		log.Fatalf("Could not flush usage records: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Fatalf("Could not flush usage records: unexpected status code %d", resp.StatusCode)
+	}
+
+	if err := os.Remove(conf.TelemetrySpool); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Could not clear telemetry spool: %s", err)
+	}
+
+	fmt.Fprintf(w, "Flushed %d usage records to %s.\n", len(records), conf.TelemetryEndpoint)
+}
+
+func readUsageRecords(spool string) ([]json.RawMessage, error) {
+	f, err := os.Open(spool)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []json.RawMessage
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 || !json.Valid(line) {
+			continue
+		}
+		records = append(records, json.RawMessage(append([]byte(nil), line...)))
+	}
+
+	return records, s.Err()
+}
@@ -0,0 +1,174 @@
+package cf_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Telemetry", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		dir        string
+		spool      string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "")
+		Expect(err).ToNot(HaveOccurred())
+		spool = filepath.Join(dir, "telemetry.jsonl")
+
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	readSpool := func() []map[string]interface{} {
+		data, err := ioutil.ReadFile(spool)
+		Expect(err).ToNot(HaveOccurred())
+
+		var records []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var r map[string]interface{}
+			Expect(json.Unmarshal([]byte(line), &r)).To(Succeed())
+			records = append(records, r)
+		}
+
+		return records
+	}
+
+	It("records the command and the number of envelopes read", func() {
+		startTime := time.Now().Truncate(time.Second).Add(-time.Minute)
+		httpClient.responseBody = []string{logResponseBody(startTime, "one", "two", "three")}
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+
+		recorder := cf.NewUsageRecorder(spool, "tail")
+		cf.Tail(
+			context.Background(),
+			cliConn,
+			[]string{"app-name"},
+			recorder.HTTPClient(httpClient),
+			recorder.Logger(logger),
+			writer,
+			cf.WithTailNoHeaders(),
+		)
+		Expect(recorder.Finish()).To(Succeed())
+		Expect(recorder.Finish()).To(Succeed())
+
+		Expect(writer.lines()).To(HaveLen(3))
+
+		records := readSpool()
+		Expect(records).To(HaveLen(1))
+		Expect(records[0]["command"]).To(Equal("tail"))
+		Expect(records[0]["envelopes"]).To(BeEquivalentTo(3))
+		Expect(records[0]["date"]).To(Equal(time.Now().UTC().Format("2006-01-02")))
+		Expect(records[0]).To(HaveKey("duration_ms"))
+		Expect(records[0]).ToNot(HaveKey("error"))
+	})
+
+	It("records the category of fatal errors without the message", func() {
+		recorder := cf.NewUsageRecorder(spool, "tail")
+
+		Expect(func() {
+			cf.Tail(
+				context.Background(),
+				cliConn,
+				[]string{"--json", "--output-format", "{{.}}", "app-name"},
+				httpClient,
+				recorder.Logger(logger),
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Cannot use output-format and json flags together"))
+
+		records := readSpool()
+		Expect(records).To(HaveLen(1))
+		Expect(records[0]["error"]).To(Equal("usage"))
+		Expect(records[0]).To(HaveLen(5))
+	})
+
+	It("reports the number of spooled records", func() {
+		Expect(ioutil.WriteFile(spool, []byte(`{"command":"tail"}`+"\n"+`{"command":"log-meta"}`+"\n"), 0600)).To(Succeed())
+
+		cf.Telemetry(nil, cf.Config{Telemetry: true, TelemetrySpool: spool}, httpClient, logger, writer)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"2 usage records spooled in " + spool + ".",
+		}))
+	})
+
+	It("flushes spooled records to the endpoint", func() {
+		Expect(ioutil.WriteFile(spool, []byte(`{"command":"tail"}`+"\n"+`{"command":"log-meta"}`+"\n"), 0600)).To(Succeed())
+
+		cf.Telemetry(
+			[]string{"--flush"},
+			cf.Config{
+				Telemetry:         true,
+				TelemetryEndpoint: "https://telemetry.example.com/v1/usage",
+				TelemetrySpool:    spool,
+			},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(httpClient.requestURLs).To(Equal([]string{"https://telemetry.example.com/v1/usage"}))
+		Expect(httpClient.requestBodies).To(Equal([]string{`[{"command":"tail"},{"command":"log-meta"}]`}))
+		Expect(writer.lines()).To(Equal([]string{
+			"Flushed 2 usage records to https://telemetry.example.com/v1/usage.",
+		}))
+
+		_, err := os.Stat(spool)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("keeps the spool when the endpoint rejects the records", func() {
+		Expect(ioutil.WriteFile(spool, []byte(`{"command":"tail"}`+"\n"), 0600)).To(Succeed())
+		httpClient.responseCode = http.StatusInternalServerError
+
+		Expect(func() {
+			cf.Telemetry(
+				[]string{"--flush"},
+				cf.Config{
+					Telemetry:         true,
+					TelemetryEndpoint: "https://telemetry.example.com/v1/usage",
+					TelemetrySpool:    spool,
+				},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Could not flush usage records: unexpected status code 500"))
+		Expect(spool).To(BeAnExistingFile())
+	})
+
+	It("fatally logs when telemetry is disabled", func() {
+		Expect(func() {
+			cf.Telemetry(nil, cf.Config{}, httpClient, logger, writer)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Telemetry is disabled. Set 'telemetry: true' in ~/.log-cache-cli/config.yml to enable it."))
+	})
+})
@@ -0,0 +1,154 @@
+package cf
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+)
+
+const tokenCacheFile = "token.json"
+
+// tokenExpiryMargin is how long before its expiry a cached token is
+// considered stale, leaving time for the command to finish with it.
+const tokenExpiryMargin = time.Minute
+
+type cachedToken struct {
+	APIEndpoint string    `json:"api_endpoint"`
+	Username    string    `json:"username"`
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// accessToken returns the access token cached in the config directory if it
+// belongs to the current API endpoint and user and has not expired.
+// Otherwise it asks the CF CLI for a token and caches it. Failing to read
+// or write the cache never fails the command.
+func accessToken(cli plugin.CliConnection) (string, error) {
+	endpoint, err := cli.ApiEndpoint()
+	if err != nil {
+		return cli.AccessToken()
+	}
+	username, err := cli.Username()
+	if err != nil {
+		return cli.AccessToken()
+	}
+
+	path, err := tokenCachePath()
+	if err != nil {
+		return cli.AccessToken()
+	}
+
+	if t, ok := readCachedToken(path); ok &&
+		t.APIEndpoint == endpoint &&
+		t.Username == username &&
+		time.Now().Add(tokenExpiryMargin).Before(t.ExpiresAt) {
+		return t.AccessToken, nil
+	}
+
+	token, err := cli.AccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	if expiresAt, ok := tokenExpiry(token); ok {
+		_ = writeCachedToken(path, cachedToken{
+			APIEndpoint: endpoint,
+			Username:    username,
+			AccessToken: token,
+			ExpiresAt:   expiresAt,
+		})
+	}
+
+	return token, nil
+}
+
+func tokenCachePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, tokenCacheFile), nil
+}
+
+func readCachedToken(path string) (cachedToken, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cachedToken{}, false
+	}
+
+	var t cachedToken
+	if err := json.Unmarshal(data, &t); err != nil {
+		return cachedToken{}, false
+	}
+
+	return t, true
+}
+
+// writeCachedToken writes the token readable only by the current user. The
+// file is written to a temporary file first so concurrent invocations never
+// read a partial token.
+func writeCachedToken(path string, t cachedToken) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), tokenCacheFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// tokenExpiry reads the exp claim of a "bearer <JWT>" access token. Tokens
+// that are not JWTs have no known expiry and are not cached.
+func tokenExpiry(token string) (time.Time, bool) {
+	fields := strings.Fields(token)
+	if len(fields) == 0 {
+		return time.Time{}, false
+	}
+
+	parts := strings.Split(fields[len(fields)-1], ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
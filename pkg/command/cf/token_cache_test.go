@@ -0,0 +1,119 @@
+package cf_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token caching", func() {
+	var (
+		home     string
+		origHome string
+		cliConn  *stubCliConnection
+	)
+
+	BeforeEach(func() {
+		var err error
+		home, err = ioutil.TempDir("", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		origHome = os.Getenv("HOME")
+		Expect(os.Setenv("HOME", home)).To(Succeed())
+
+		cliConn = newStubCliConnection()
+		cliConn.usernameResp = "a-user"
+	})
+
+	AfterEach(func() {
+		Expect(os.Setenv("HOME", origHome)).To(Succeed())
+		Expect(os.RemoveAll(home)).To(Succeed())
+	})
+
+	runMeta := func() *stubHTTPClient {
+		httpClient := newStubHTTPClient()
+		httpClient.responseBody = []string{metaResponseInfo("source-1")}
+		cliConn.cliCommandArgs = nil
+		cliConn.cliCommandResult = [][]string{
+			{capiAppsResponse(map[string]string{"source-1": "app-1"})},
+		}
+
+		cf.Meta(
+			context.Background(),
+			cliConn,
+			nil,
+			nil,
+			httpClient,
+			&stubLogger{},
+			bytes.NewBuffer(nil),
+		)
+
+		return httpClient
+	}
+
+	It("reuses the token until it expires", func() {
+		cliConn.accessToken = jwtToken(time.Now().Add(time.Hour))
+
+		runMeta()
+		httpClient := runMeta()
+
+		Expect(cliConn.accessTokenCount).To(Equal(1))
+		Expect(httpClient.requestHeaders[0].Get("Authorization")).To(Equal(cliConn.accessToken))
+
+		info, err := os.Stat(filepath.Join(home, ".log-cache-cli", "token.json"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("fetches a new token when the cached one is about to expire", func() {
+		cliConn.accessToken = jwtToken(time.Now().Add(30 * time.Second))
+
+		runMeta()
+		runMeta()
+
+		Expect(cliConn.accessTokenCount).To(Equal(2))
+	})
+
+	It("fetches a new token for a different user", func() {
+		cliConn.accessToken = jwtToken(time.Now().Add(time.Hour))
+
+		runMeta()
+		cliConn.usernameResp = "another-user"
+		runMeta()
+
+		Expect(cliConn.accessTokenCount).To(Equal(2))
+	})
+
+	It("does not cache tokens without an expiry", func() {
+		cliConn.accessToken = "bearer some-token"
+
+		runMeta()
+		runMeta()
+
+		Expect(cliConn.accessTokenCount).To(Equal(2))
+		_, err := os.Stat(filepath.Join(home, ".log-cache-cli", "token.json"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
+
+func jwtToken(expiresAt time.Time) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	claims := fmt.Sprintf(`{"user_name":"a-user","exp":%d}`, expiresAt.Unix())
+
+	return fmt.Sprintf(
+		"bearer %s.%s.%s",
+		encode([]byte(`{"alg":"RS256"}`)),
+		encode([]byte(claims)),
+		encode([]byte("signature")),
+	)
+}
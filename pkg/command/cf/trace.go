@@ -0,0 +1,184 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	logcache "code.cloudfoundry.org/log-cache/client"
+)
+
+const routerSourceID = "gorouter"
+
+type traceOptionFlags struct {
+	App    string        `long:"app"`
+	Router bool          `long:"router"`
+	Last   time.Duration `long:"last" default:"1h"`
+}
+
+type traceSource struct {
+	id   string
+	name string
+}
+
+type traceMatch struct {
+	sourceID string
+	envelope *loggregator_v2.Envelope
+}
+
+// TraceOption configures the Trace command.
+type TraceOption func(*traceOptions)
+
+type traceOptions struct {
+	noHeaders bool
+	scrubber  *Scrubber
+}
+
+// WithTraceNoHeaders omits the banner.
+func WithTraceNoHeaders() TraceOption {
+	return func(o *traceOptions) {
+		o.noHeaders = true
+	}
+}
+
+// WithTraceScrubber masks sensitive data in each matching envelope before
+// it is written. The request ID is matched before masking.
+func WithTraceScrubber(s *Scrubber) TraceOption {
+	return func(o *traceOptions) {
+		o.scrubber = s
+	}
+}
+
+// Trace searches an app's envelopes, and optionally the router's, for a
+// request ID in tags or payloads and writes the matching envelopes of all
+// sources in time order.
+func Trace(
+	ctx context.Context,
+	cli plugin.CliConnection,
+	args []string,
+	c HTTPClient,
+	log Logger,
+	w io.Writer,
+	opts ...TraceOption,
+) {
+	o := traceOptionFlags{}
+
+	args, err := parseFlags("log-trace", &o, args)
+	if err != nil {
+		log.Fatalf("Could not parse flags: %s", err)
+	}
+
+	if len(args) != 1 {
+		log.Fatalf("Expected 1 argument, got %d.", len(args))
+	}
+
+	to := traceOptions{}
+	for _, opt := range opts {
+		opt(&to)
+	}
+
+	if o.App == "" {
+		log.Fatalf("--app is required.")
+	}
+
+	if o.Last <= 0 {
+		log.Fatalf("--last must be greater than 0.")
+	}
+
+	requestID := args[0]
+	if requestID == "" {
+		log.Fatalf("Request ID must not be empty.")
+	}
+
+	appGUID := getAppGUID(o.App, cli, log)
+	if appGUID == "" {
+		log.Fatalf(translate("App %s not found."), o.App)
+	}
+
+	logCacheEndpoint, err := logCacheEndpoint(cli)
+	if err != nil {
+		log.Fatalf("Could not determine Log Cache endpoint: %s", err)
+	}
+
+	client := logcache.NewClient(
+		logCacheEndpoint,
+		logcache.WithHTTPClient(authenticatedClient(cli, c, log)),
+	)
+
+	if !to.noHeaders {
+		writeAppHeader(w, cli, log, "Tracing request %s for app %s in org %s / space %s as %s...", requestID, o.App)
+	}
+
+	sources := []traceSource{{id: appGUID, name: o.App}}
+	if o.Router {
+		sources = append(sources, traceSource{id: routerSourceID, name: routerSourceID})
+	}
+
+	var matches []traceMatch
+	end := time.Now()
+
+	for _, source := range sources {
+		name := source.name
+		logcache.Walk(
+			ctx,
+			source.id,
+			logcache.Visitor(func(envelopes []*loggregator_v2.Envelope) bool {
+				for _, e := range envelopes {
+					if envelopeMentions(e, requestID) {
+						matches = append(matches, traceMatch{sourceID: name, envelope: e})
+					}
+				}
+				return true
+			}),
+			client.Read,
+			logcache.WithWalkStartTime(end.Add(-o.Last)),
+			logcache.WithWalkEndTime(end),
+			logcache.WithWalkBackoff(newBackoff(log)),
+		)
+	}
+
+	if len(matches) == 0 {
+		fmt.Fprintf(w, translate("No envelopes found for request %s in the last %s.")+"\n", requestID, o.Last)
+		return
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].envelope.Timestamp < matches[j].envelope.Timestamp
+	})
+
+	for _, m := range matches {
+		to.scrubber.scrubEnvelope(m.envelope)
+		fmt.Fprintf(w, "%s\n", envelopeWrapper{sourceID: m.sourceID, Envelope: m.envelope})
+	}
+}
+
+// envelopeMentions reports whether the value appears in any tag, log payload
+// or event of the envelope.
+func envelopeMentions(e *loggregator_v2.Envelope, value string) bool {
+	for _, v := range e.GetTags() {
+		if strings.Contains(v, value) {
+			return true
+		}
+	}
+
+	for _, v := range e.GetDeprecatedTags() {
+		if strings.Contains(v.GetText(), value) {
+			return true
+		}
+	}
+
+	switch m := e.Message.(type) {
+	case *loggregator_v2.Envelope_Log:
+		return strings.Contains(string(m.Log.GetPayload()), value)
+	case *loggregator_v2.Envelope_Event:
+		return strings.Contains(m.Event.GetTitle(), value) ||
+			strings.Contains(m.Event.GetBody(), value)
+	}
+
+	return false
+}
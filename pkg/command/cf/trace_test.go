@@ -0,0 +1,168 @@
+package cf_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Trace", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		startTime  time.Time
+		timeFormat string
+	)
+
+	BeforeEach(func() {
+		startTime = time.Now().Truncate(time.Second).Add(-time.Minute)
+		timeFormat = "2006-01-02T15:04:05.00-0700"
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+		cliConn.usernameResp = "a-user"
+		cliConn.orgName = "organization"
+		cliConn.spaceName = "space"
+
+		httpClient.responseBody = []string{
+			logResponseBody(
+				startTime,
+				"handling request req-1234",
+				"unrelated line",
+				"finished request req-1234 in 20ms",
+			),
+			emptyResponseBody(),
+			fmt.Sprintf(`{"envelopes":{"batch":[{
+				"timestamp": "%d",
+				"source_id": "gorouter",
+				"instance_id": "0",
+				"tags": {"request_id": "req-1234"},
+				"timer": {"name": "http", "start": "1000000", "stop": "3000000"}
+			}, {
+				"timestamp": "%d",
+				"source_id": "gorouter",
+				"instance_id": "0",
+				"tags": {"request_id": "req-5678"},
+				"timer": {"name": "http", "start": "1000000", "stop": "3000000"}
+			}]}}`, startTime.Add(time.Second).UnixNano(), startTime.UnixNano()),
+			emptyResponseBody(),
+		}
+	})
+
+	It("writes the matching envelopes of the app", func() {
+		cf.Trace(
+			context.Background(),
+			cliConn,
+			[]string{"--app", "app-name", "req-1234"},
+			httpClient,
+			logger,
+			writer,
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"Tracing request req-1234 for app app-name in org organization / space space as a-user...",
+			"",
+			fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT handling request req-1234", startTime.Format(timeFormat)),
+			fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT finished request req-1234 in 20ms", startTime.Add(2*time.Second).Format(timeFormat)),
+		}))
+		Expect(httpClient.requestURLs).To(HaveLen(2))
+	})
+
+	It("merges the router's envelopes in time order", func() {
+		cf.Trace(
+			context.Background(),
+			cliConn,
+			[]string{"--app", "app-name", "--router", "req-1234"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithTraceNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT handling request req-1234", startTime.Format(timeFormat)),
+			fmt.Sprintf("   %s [gorouter/0] TIMER http 2.00 ms", startTime.Add(time.Second).Format(timeFormat)),
+			fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT finished request req-1234 in 20ms", startTime.Add(2*time.Second).Format(timeFormat)),
+		}))
+		Expect(httpClient.requestURLs[2]).To(ContainSubstring("/v1/read/gorouter"))
+	})
+
+	It("scrubs the matching envelopes after matching the request", func() {
+		scrubber := writeScrubFile(`
+patterns:
+- pattern: 'req-\d+'
+  replacement: '[request]'
+`)
+
+		cf.Trace(
+			context.Background(),
+			cliConn,
+			[]string{"--app", "app-name", "req-1234"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithTraceNoHeaders(),
+			cf.WithTraceScrubber(scrubber),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT handling request [request]", startTime.Format(timeFormat)),
+			fmt.Sprintf("   %s [APP/PROC/WEB/0] OUT finished request [request] in 20ms", startTime.Add(2*time.Second).Format(timeFormat)),
+		}))
+	})
+
+	It("reports when nothing mentions the request", func() {
+		cf.Trace(
+			context.Background(),
+			cliConn,
+			[]string{"--app", "app-name", "req-0000"},
+			httpClient,
+			logger,
+			writer,
+			cf.WithTraceNoHeaders(),
+		)
+
+		Expect(writer.lines()).To(Equal([]string{
+			"No envelopes found for request req-0000 in the last 1h0m0s.",
+		}))
+	})
+
+	It("fatally logs without an app", func() {
+		Expect(func() {
+			cf.Trace(
+				context.Background(),
+				cliConn,
+				[]string{"req-1234"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("--app is required."))
+	})
+
+	It("fatally logs without a request ID", func() {
+		Expect(func() {
+			cf.Trace(
+				context.Background(),
+				cliConn,
+				[]string{"--app", "app-name"},
+				httpClient,
+				logger,
+				writer,
+			)
+		}).To(Panic())
+
+		Expect(logger.fatalfMessage).To(Equal("Expected 1 argument, got 0."))
+	})
+})
@@ -0,0 +1,176 @@
+package cf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName      = "code.cloudfoundry.org/log-cache-cli"
+	tracingShutdown = 5 * time.Second
+)
+
+// Tracer records OpenTelemetry spans for a single command invocation. The
+// command is the root span; Log Cache requests and CF CLI calls, such as
+// CAPI requests made through cf curl, are its children.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	ctx      context.Context
+	span     trace.Span
+}
+
+// NewTracer starts the span of the given command. The returned context
+// carries the span so requests made with it become its children.
+func NewTracer(ctx context.Context, command string, opts ...sdktrace.TracerProviderOption) (*Tracer, context.Context) {
+	provider := sdktrace.NewTracerProvider(opts...)
+	tracer := provider.Tracer(tracerName)
+
+	ctx, span := tracer.Start(ctx, command)
+
+	return &Tracer{
+		provider: provider,
+		tracer:   tracer,
+		ctx:      ctx,
+		span:     span,
+	}, ctx
+}
+
+// HTTPClient wraps the given client to record a span for every request.
+func (t *Tracer) HTTPClient(c HTTPClient) HTTPClient {
+	return &tracingHTTPClient{c: c, t: t}
+}
+
+// CliConnection wraps the given connection to record a span for CF CLI
+// commands and access token requests.
+func (t *Tracer) CliConnection(cli plugin.CliConnection) plugin.CliConnection {
+	return &tracingCliConnection{CliConnection: cli, t: t}
+}
+
+// Logger wraps the given logger to mark the command span as failed and
+// export the spans before the wrapped logger exits.
+func (t *Tracer) Logger(l Logger) Logger {
+	return &tracingLogger{Logger: l, t: t}
+}
+
+// Finish ends the command span and exports the recorded spans.
+func (t *Tracer) Finish() error {
+	t.span.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdown)
+	defer cancel()
+
+	return t.provider.Shutdown(ctx)
+}
+
+// start starts a child span. Requests whose context does not carry a span,
+// such as those made with context.Background(), become children of the
+// command span.
+func (t *Tracer) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = t.ctx
+	}
+
+	return t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+type tracingHTTPClient struct {
+	c HTTPClient
+	t *Tracer
+}
+
+func (c *tracingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	ctx, span := c.t.start(
+		req.Context(),
+		req.Method+" "+req.URL.Path,
+		attribute.String("http.method", req.Method),
+		attribute.String("http.host", req.URL.Host),
+		attribute.String("http.target", req.URL.Path),
+	)
+	defer span.End()
+
+	resp, err := c.c.Do(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	return resp, nil
+}
+
+type tracingCliConnection struct {
+	plugin.CliConnection
+	t *Tracer
+}
+
+func (c *tracingCliConnection) CliCommandWithoutTerminalOutput(args ...string) ([]string, error) {
+	name := "cf"
+	if len(args) > 0 {
+		name += " " + args[0]
+	}
+
+	_, span := c.t.start(c.t.ctx, name, attribute.String("cf.args", cliTraceArgs(args)))
+	defer span.End()
+
+	lines, err := c.CliConnection.CliCommandWithoutTerminalOutput(args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return lines, err
+}
+
+func (c *tracingCliConnection) AccessToken() (string, error) {
+	_, span := c.t.start(c.t.ctx, "cf oauth-token")
+	defer span.End()
+
+	token, err := c.CliConnection.AccessToken()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return token, err
+}
+
+// cliTraceArgs drops the query of cf curl paths, which can list hundreds
+// of GUIDs.
+func cliTraceArgs(args []string) string {
+	traced := make([]string, len(args))
+	for i, arg := range args {
+		if j := strings.Index(arg, "?"); j >= 0 {
+			arg = arg[:j]
+		}
+		traced[i] = arg
+	}
+
+	return strings.Join(traced, " ")
+}
+
+type tracingLogger struct {
+	Logger
+	t *Tracer
+}
+
+func (l *tracingLogger) Fatalf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	l.t.span.SetStatus(codes.Error, msg)
+	l.t.Finish()
+	l.Logger.Fatalf(format, args...)
+}
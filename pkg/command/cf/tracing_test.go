@@ -0,0 +1,107 @@
+package cf_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/log-cache-cli/pkg/command/cf"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracer", func() {
+	var (
+		logger     *stubLogger
+		writer     *stubWriter
+		httpClient *stubHTTPClient
+		cliConn    *stubCliConnection
+		recorder   *tracetest.SpanRecorder
+	)
+
+	BeforeEach(func() {
+		logger = &stubLogger{}
+		writer = &stubWriter{}
+		httpClient = newStubHTTPClient()
+		cliConn = newStubCliConnection()
+		recorder = tracetest.NewSpanRecorder()
+	})
+
+	spanNames := func() []string {
+		var names []string
+		for _, s := range recorder.Ended() {
+			names = append(names, s.Name())
+		}
+		return names
+	}
+
+	It("records the command with its CF CLI calls and requests as children", func() {
+		startTime := time.Now().Truncate(time.Second).Add(-time.Minute)
+		httpClient.responseBody = []string{logResponseBody(startTime, "one")}
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+
+		tracer, ctx := cf.NewTracer(context.Background(), "tail", sdktrace.WithSpanProcessor(recorder))
+		cf.Tail(
+			ctx,
+			tracer.CliConnection(cliConn),
+			[]string{"app-name"},
+			tracer.HTTPClient(httpClient),
+			tracer.Logger(logger),
+			writer,
+			cf.WithTailNoHeaders(),
+		)
+		Expect(tracer.Finish()).To(Succeed())
+
+		Expect(spanNames()).To(ConsistOf(
+			"cf app",
+			"cf oauth-token",
+			"GET /v1/read/app-guid",
+			"tail",
+		))
+
+		spans := recorder.Ended()
+		root := spans[len(spans)-1]
+		Expect(root.Name()).To(Equal("tail"))
+		for _, s := range spans[:len(spans)-1] {
+			Expect(s.Parent().SpanID()).To(Equal(root.SpanContext().SpanID()))
+
+			if s.Name() == "cf app" {
+				Expect(s.Attributes()).To(HaveLen(1))
+				Expect(s.Attributes()[0].Value.AsString()).To(Equal("app app-name --guid"))
+			}
+		}
+	})
+
+	It("marks failed requests and the command as failed", func() {
+		httpClient.responseErr = errors.New("some-error")
+		cliConn.cliCommandResult = [][]string{{"app-guid"}}
+
+		tracer, ctx := cf.NewTracer(context.Background(), "tail", sdktrace.WithSpanProcessor(recorder))
+		Expect(func() {
+			cf.Tail(
+				ctx,
+				tracer.CliConnection(cliConn),
+				[]string{"app-name"},
+				tracer.HTTPClient(httpClient),
+				tracer.Logger(logger),
+				writer,
+				cf.WithTailNoHeaders(),
+			)
+		}).To(Panic())
+
+		spans := recorder.Ended()
+		Expect(spans).ToNot(BeEmpty())
+
+		request := spans[len(spans)-2]
+		Expect(request.Name()).To(Equal("GET /v1/read/app-guid"))
+		Expect(request.Status().Code).To(Equal(codes.Error))
+
+		root := spans[len(spans)-1]
+		Expect(root.Name()).To(Equal("tail"))
+		Expect(root.Status().Code).To(Equal(codes.Error))
+	})
+})
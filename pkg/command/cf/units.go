@@ -0,0 +1,45 @@
+package cf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+
+// normalizeUnit converts a gauge value so metrics that report the same
+// quantity in different units are comparable. Byte values are scaled to the
+// largest binary unit that keeps them at or above 1, sub-millisecond
+// durations are converted to milliseconds and percentages are shown with
+// a percent sign. Other units are left as they are.
+func normalizeUnit(value float64, unit string) (float64, string) {
+	switch strings.ToLower(unit) {
+	case "b", "byte", "bytes":
+		i := 0
+		for i < len(byteUnits)-1 && math.Abs(value) >= 1024 {
+			value /= 1024
+			i++
+		}
+		return value, byteUnits[i]
+	case "ns", "nanos", "nanosecond", "nanoseconds":
+		return value / 1e6, "ms"
+	case "us", "µs", "micros", "microsecond", "microseconds":
+		return value / 1e3, "ms"
+	case "percent", "percentage":
+		return value, "%"
+	default:
+		return value, unit
+	}
+}
+
+// formatTimerDuration formats the duration of a timer in milliseconds, or
+// in microseconds when it is shorter than a millisecond.
+func formatTimerDuration(d time.Duration) string {
+	if d < time.Millisecond && d > -time.Millisecond {
+		return fmt.Sprintf("%.2f µs", float64(d)/float64(time.Microsecond))
+	}
+
+	return fmt.Sprintf("%.2f ms", float64(d)/float64(time.Millisecond))
+}